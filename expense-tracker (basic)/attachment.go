@@ -0,0 +1,301 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// receipts (images or pdf) are copied into the receipts directory next to expense.json
+// the file name is the sha256 of the content, so the same receipt attached twice
+// is stored once and a file can't be changed without the hash changing too
+
+const receiptDir = "receipts"
+
+type Attachment struct {
+	Name string `json:"name"` // the original file name
+	Hash string `json:"hash"` // sha256 of the content, also the name in receiptDir
+	Type string `json:"type"` // like image/jpeg or application/pdf
+	Size int64  `json:"size"`
+}
+
+// path of the stored copy, the extension of the original is kept so it opens with the right program
+func (a Attachment) path() string {
+	return filepath.Join(filepath.Dir(expenseFile), receiptDir, a.Hash+strings.ToLower(filepath.Ext(a.Name)))
+}
+
+// storeReceipt copies a file into the receipts directory
+func storeReceipt(source string) (Attachment, error) {
+	file, err := os.Open(source)
+	if err != nil {
+		return Attachment{}, err
+	}
+	defer file.Close()
+
+	head := make([]byte, 512)
+	n, err := io.ReadFull(file, head)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return Attachment{}, fmt.Errorf("%s: %w", source, err)
+	}
+	kind := http.DetectContentType(head[:n])
+	if !strings.HasPrefix(kind, "image/") && kind != "application/pdf" {
+		return Attachment{}, fmt.Errorf("%s is %s, only images and pdf files can be attached", source, kind)
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return Attachment{}, err
+	}
+
+	dir := filepath.Join(filepath.Dir(expenseFile), receiptDir)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return Attachment{}, err
+	}
+	// hash while copying to a temporary file, then rename it to its hash
+	tmp, err := os.CreateTemp(dir, "upload-*")
+	if err != nil {
+		return Attachment{}, err
+	}
+	defer os.Remove(tmp.Name())
+	hash := sha256.New()
+	size, err := io.Copy(io.MultiWriter(tmp, hash), file)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return Attachment{}, err
+	}
+
+	a := Attachment{
+		Name: filepath.Base(source),
+		Hash: hex.EncodeToString(hash.Sum(nil)),
+		Type: kind,
+		Size: size,
+	}
+	if _, err := os.Stat(a.path()); err == nil {
+		// already stored
+		return a, nil
+	}
+	if err := os.Rename(tmp.Name(), a.path()); err != nil {
+		return Attachment{}, err
+	}
+	return a, nil
+}
+
+// removeUnusedReceipt deletes a stored receipt once no expense uses it anymore
+func removeUnusedReceipt(data []Expense, a Attachment) {
+	for _, e := range data {
+		for _, other := range e.Attachments {
+			if other.path() == a.path() {
+				return
+			}
+		}
+	}
+	if err := os.Remove(a.path()); err != nil && !errors.Is(err, os.ErrNotExist) {
+		fail("error while removing the receipt: ", err)
+	}
+}
+
+// findExpense returns the expense with the id, or nil
+func findExpense(data []Expense, idText string) *Expense {
+	id, err := strconv.Atoi(idText)
+	if err != nil {
+		return nil
+	}
+	for i := range data {
+		if data[i].Id == id {
+			return &data[i]
+		}
+	}
+	return nil
+}
+
+// normalizeTag turns "#Work Trip" into "work-trip"
+func normalizeTag(tag string) string {
+	tag = strings.TrimPrefix(strings.TrimSpace(tag), "#")
+	return strings.Join(strings.Fields(strings.ToLower(tag)), "-")
+}
+
+// instance: go run . attach 3 ~/Downloads/receipt.pdf
+//
+//	go run . detach 3 1        (the first attachment of expense 3)
+//	go run . note 3 paid with the company card
+//	go run . tag 3 work trip-berlin
+//	go run . untag 3 work
+func cmdAnnotate(command string, args []string) {
+	usage := map[string]string{
+		"attach": "attach <id> <file>...",
+		"detach": "detach <id> <number>",
+		"note":   "note <id> [text], without text the note is removed",
+		"tag":    "tag <id> <tag>...",
+		"untag":  "untag <id> <tag>...",
+	}[command]
+	if len(args) < 1 || (len(args) < 2 && command != "note") {
+		fail("usage: " + usage)
+		return
+	}
+	data, err := loadExpenses()
+	if err != nil {
+		fail("error while loading the expenses: ", err)
+		return
+	}
+	expense := findExpense(data, args[0])
+	if expense == nil {
+		failf("no expense with id %s\n", args[0])
+		return
+	}
+
+	var removed *Attachment
+	switch command {
+	case "attach":
+		for _, source := range args[1:] {
+			a, err := storeReceipt(source)
+			if err != nil {
+				fail("error while attaching the file: ", err)
+				continue
+			}
+			if slices.ContainsFunc(expense.Attachments, func(other Attachment) bool { return other.Hash == a.Hash }) {
+				fmt.Printf("%s is already attached\n", source)
+				continue
+			}
+			expense.Attachments = append(expense.Attachments, a)
+		}
+	case "detach":
+		n, err := strconv.Atoi(args[1])
+		if err != nil || n < 1 || n > len(expense.Attachments) {
+			failf("no attachment number %s, see: show %d\n", args[1], expense.Id)
+			return
+		}
+		a := expense.Attachments[n-1]
+		removed = &a
+		expense.Attachments = append(expense.Attachments[:n-1], expense.Attachments[n:]...)
+	case "note":
+		expense.Notes = strings.Join(args[1:], " ")
+	case "tag":
+		for _, t := range args[1:] {
+			if t = normalizeTag(t); t != "" && !slices.Contains(expense.Tags, t) {
+				expense.Tags = append(expense.Tags, t)
+			}
+		}
+	case "untag":
+		expense.Tags = slices.DeleteFunc(expense.Tags, func(t string) bool {
+			return slices.ContainsFunc(args[1:], func(arg string) bool { return normalizeTag(arg) == t })
+		})
+	}
+
+	if err := saveExpenses(data); err != nil {
+		fail("error while saving the file: ", err)
+		return
+	}
+	if removed != nil {
+		removeUnusedReceipt(data, *removed)
+	}
+	fmt.Println("Expense updated successfully ")
+}
+
+// instance: go run . show 3
+func cmdShow(args []string) {
+	if len(args) < 1 {
+		fail("usage: show <id>")
+		return
+	}
+	data, err := loadExpenses()
+	if err != nil {
+		fail("error while loading the expenses: ", err)
+		return
+	}
+	e := findExpense(data, args[0])
+	if e == nil {
+		failf("no expense with id %s\n", args[0])
+		return
+	}
+
+	fmt.Printf("Expense %d\n", e.Id)
+	fmt.Printf("Date:        %s\n", e.Date.Format("2006-01-02 15:04"))
+	fmt.Printf("Category:    %s\n", e.Category)
+	fmt.Printf("Amount:      %s\n", e.Amount)
+	fmt.Printf("Description: %s\n", e.Description)
+	if e.RecurringId != 0 {
+		fmt.Printf("Recurring:   %d\n", e.RecurringId)
+	}
+	if len(e.Tags) > 0 {
+		fmt.Printf("Tags:        #%s\n", strings.Join(e.Tags, " #"))
+	}
+	if e.Payer != "" {
+		fmt.Printf("Paid by:     %s\n", e.Payer)
+		for _, s := range e.Shares {
+			fmt.Printf("             %s %s\n", s.Person, s.Amount)
+		}
+	}
+	if e.Notes != "" {
+		fmt.Printf("Notes:\n  %s\n", e.Notes)
+	}
+	if len(e.Attachments) > 0 {
+		fmt.Println("Attachments:")
+		for i, a := range e.Attachments {
+			status := ""
+			if _, err := os.Stat(a.path()); err != nil {
+				status = " (missing)"
+			}
+			fmt.Printf("  %d. %s (%s, %d KB) %s%s\n", i+1, a.Name, a.Type, (a.Size+1023)/1024, a.path(), status)
+		}
+	}
+}
+
+// instance: go run . search taxi
+//
+//	go run . search #work
+//
+// the words are searched in the description, notes, category and tags, all of them have to match
+func cmdSearch(args []string) {
+	if len(args) == 0 {
+		fail("usage: search <word|#tag>...")
+		return
+	}
+	data, err := loadExpenses()
+	if err != nil {
+		fail("error while loading the expenses: ", err)
+		return
+	}
+	found := 0
+	for _, e := range data {
+		if !matchesSearch(e, args) {
+			continue
+		}
+		found++
+		fmt.Printf("%d \t%s \t%s \t%s \t%s%s\n", e.Id, e.Category, e.Amount, e.Date.Format("2006-01-02"), e.Description, attachmentMark(e))
+	}
+	if found == 0 {
+		fail("nothing found ")
+	}
+}
+
+func matchesSearch(e Expense, words []string) bool {
+	text := strings.ToLower(strings.Join([]string{e.Description, e.Notes, e.Category, strings.Join(e.Tags, " ")}, " "))
+	for _, w := range words {
+		if strings.HasPrefix(w, "#") {
+			if !slices.Contains(e.Tags, normalizeTag(w)) {
+				return false
+			}
+			continue
+		}
+		if !strings.Contains(text, strings.ToLower(w)) {
+			return false
+		}
+	}
+	return true
+}
+
+// attachmentMark is shown after the description in the lists
+func attachmentMark(e Expense) string {
+	if len(e.Attachments) == 0 {
+		return ""
+	}
+	return fmt.Sprintf(" 📎%d", len(e.Attachments))
+}
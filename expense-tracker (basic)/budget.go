@@ -0,0 +1,319 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+// a budget limits the spending of one month, or of every month, and can be
+// limited to one category. they are kept in budgets.json
+// a budget for a specific month wins over the one for every month
+
+const budgetFile = "budgets.json"
+
+// the share of a budget at which "add" starts to warn
+const budgetWarnPercent = 80
+
+const monthLayout = "2006-01"
+
+type Budget struct {
+	Month    string `json:"month,omitempty"`    // like "2025-11", empty for every month
+	Category string `json:"category,omitempty"` // empty for all categories
+	Amount   Money  `json:"amount"`
+}
+
+func (b Budget) label() string {
+	month := b.Month
+	if month == "" {
+		month = "every month"
+	}
+	category := b.Category
+	if category == "" {
+		category = "all categories"
+	}
+	return month + ", " + category
+}
+
+func loadBudgets() ([]Budget, error) {
+	data, err := os.ReadFile(budgetFile)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return []Budget{}, nil
+		}
+		return nil, err
+	}
+	var budgets []Budget
+	if err := json.Unmarshal(data, &budgets); err != nil {
+		return nil, fmt.Errorf("%s: %w", budgetFile, err)
+	}
+	return budgets, nil
+}
+
+func saveBudgets(budgets []Budget) error {
+	data, err := json.MarshalIndent(budgets, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(budgetFile, data, 0644)
+}
+
+// findBudget returns the budget for a month ("2025-11") and category ("" for the overall one)
+func findBudget(budgets []Budget, month, category string) (Budget, bool) {
+	var fallback *Budget
+	for i, b := range budgets {
+		if b.Category != category {
+			continue
+		}
+		if b.Month == month {
+			return b, true
+		}
+		if b.Month == "" {
+			fallback = &budgets[i]
+		}
+	}
+	if fallback != nil {
+		return *fallback, true
+	}
+	return Budget{}, false
+}
+
+// spent sums the expenses of a budget's month in the budget's currency
+// amounts in other currencies are converted, those without an exchange rate are left out
+func spent(data []Expense, rates rateTable, b Budget, month time.Time) Money {
+	total := Money{Currency: b.Amount.Currency}
+	for _, e := range expensesInMonth(data, month.Year(), month.Month(), b.Category) {
+		converted, err := rates.convert(e.Amount, total.Currency, e.Date)
+		if err == nil {
+			total.Minor += converted.Minor
+		}
+	}
+	return total
+}
+
+func percentOf(part, whole Money) int64 {
+	if whole.Minor == 0 {
+		return 0
+	}
+	return part.Minor * 100 / whole.Minor
+}
+
+// budgetWarnings checks the budgets of the month of a new expense and
+// warns when the expense pushed the spending past 80% or 100% of one of them
+// data must already contain the new expense
+func budgetWarnings(data []Expense, added Expense) ([]string, error) {
+	budgets, err := loadBudgets()
+	if err != nil {
+		return nil, err
+	}
+	rates, err := loadRates()
+	if err != nil {
+		return nil, err
+	}
+
+	month := added.Date.Format(monthLayout)
+	var warnings []string
+	for _, category := range []string{"", added.Category} {
+		b, ok := findBudget(budgets, month, category)
+		if !ok {
+			continue
+		}
+		addedAmount, err := rates.convert(added.Amount, b.Amount.Currency, added.Date)
+		if err != nil {
+			continue
+		}
+		after := spent(data, rates, b, added.Date)
+		before := Money{Minor: after.Minor - addedAmount.Minor, Currency: after.Currency}
+
+		pBefore, pAfter := percentOf(before, b.Amount), percentOf(after, b.Amount)
+		switch {
+		case pAfter >= 100 && pBefore < 100:
+			warnings = append(warnings, fmt.Sprintf("warning: budget for %s exceeded, spent %s of %s",
+				b.label(), after, b.Amount))
+		case pAfter >= budgetWarnPercent && pBefore < budgetWarnPercent:
+			warnings = append(warnings, fmt.Sprintf("warning: %d%% of the budget for %s used, spent %s of %s",
+				pAfter, b.label(), after, b.Amount))
+		}
+	}
+	return warnings, nil
+}
+
+// instance: go run . budget set 2025-11 500
+//
+//	go run . budget set monthly 120 lunch
+//	go run . budget remove 2025-11
+//	go run . budget list
+//	go run . budget report 2025-11
+func cmdBudget(args []string) {
+	if len(args) == 0 {
+		fail("usage: budget [set <YYYY-MM|monthly> <amount> [category] | remove <YYYY-MM|monthly> [category] | list | report [YYYY-MM]]")
+		return
+	}
+	budgets, err := loadBudgets()
+	if err != nil {
+		fail("error while loading the budgets: ", err)
+		return
+	}
+
+	switch args[0] {
+	case "set":
+		if len(args) < 3 {
+			fail("usage: budget set <YYYY-MM|monthly> <amount> [category]")
+			return
+		}
+		b, ok := parseBudgetTarget(args[1], args[3:])
+		if !ok {
+			return
+		}
+		base, err := baseCurrency()
+		if err != nil {
+			fail(err)
+			return
+		}
+		amount, err := parseMoney(args[2], base)
+		if err != nil {
+			fail(err)
+			return
+		}
+		b.Amount = amount
+
+		replaced := false
+		for i := range budgets {
+			if budgets[i].Month == b.Month && budgets[i].Category == b.Category {
+				budgets[i] = b
+				replaced = true
+			}
+		}
+		if !replaced {
+			budgets = append(budgets, b)
+		}
+		if err := saveBudgets(budgets); err != nil {
+			fail("error while saving the budgets: ", err)
+			return
+		}
+		fmt.Printf("budget for %s set to %s\n", b.label(), b.Amount)
+
+	case "remove":
+		if len(args) < 2 {
+			fail("usage: budget remove <YYYY-MM|monthly> [category]")
+			return
+		}
+		b, ok := parseBudgetTarget(args[1], args[2:])
+		if !ok {
+			return
+		}
+		for i := range budgets {
+			if budgets[i].Month == b.Month && budgets[i].Category == b.Category {
+				budgets = append(budgets[:i], budgets[i+1:]...)
+				if err := saveBudgets(budgets); err != nil {
+					fail("error while saving the budgets: ", err)
+					return
+				}
+				fmt.Printf("budget for %s removed\n", b.label())
+				return
+			}
+		}
+		failf("no budget for %s\n", b.label())
+
+	case "list":
+		if len(budgets) == 0 {
+			fmt.Println("no budgets yet")
+			return
+		}
+		fmt.Println("Budgets: ")
+		for _, b := range budgets {
+			fmt.Printf("  %s: %s\n", b.label(), b.Amount)
+		}
+
+	case "report":
+		month := time.Now()
+		if len(args) > 1 {
+			month, err = time.ParseInLocation(monthLayout, args[1], time.Local)
+			if err != nil {
+				fail("month must look like 2025-11")
+				return
+			}
+		}
+		budgetReport(budgets, month)
+
+	default:
+		failf("unknown budget command %q\n", args[0])
+	}
+}
+
+// parseBudgetTarget reads the month and optional category of a budget command
+func parseBudgetTarget(month string, rest []string) (Budget, bool) {
+	var b Budget
+	if month != "monthly" {
+		if _, err := time.Parse(monthLayout, month); err != nil {
+			fail("month must look like 2025-11, or be \"monthly\" for every month")
+			return b, false
+		}
+		b.Month = month
+	}
+	if len(rest) > 0 {
+		categories, err := loadCategories()
+		if err != nil {
+			fail("error while loading the categories: ", err)
+			return b, false
+		}
+		b.Category = normalizeCategory(rest[0])
+		if !hasCategory(categories, b.Category) {
+			failf("unknown category %q\n", b.Category)
+			return b, false
+		}
+	}
+	return b, true
+}
+
+// budgetReport prints budget vs. actual for every budget that applies to the month
+func budgetReport(budgets []Budget, month time.Time) {
+	data, err := loadExpenses()
+	if err != nil {
+		fail("error while loading the expenses: ", err)
+		return
+	}
+	rates, err := loadRates()
+	if err != nil {
+		fail("error while loading the rates: ", err)
+		return
+	}
+	key := month.Format(monthLayout)
+
+	categories := []string{""}
+	for _, b := range budgets {
+		if b.Category != "" && !hasCategory(categories, b.Category) {
+			categories = append(categories, b.Category)
+		}
+	}
+
+	fmt.Printf("Budget report for %s\n", key)
+	fmt.Println("Budget			Limit		Spent		Left		Used")
+	found := false
+	for _, category := range categories {
+		b, ok := findBudget(budgets, key, category)
+		if !ok {
+			continue
+		}
+		found = true
+		used := spent(data, rates, b, month)
+		left := Money{Minor: b.Amount.Minor - used.Minor, Currency: b.Amount.Currency}
+		status := ""
+		switch p := percentOf(used, b.Amount); {
+		case p >= 100:
+			status = "  over budget"
+		case p >= budgetWarnPercent:
+			status = "  almost used"
+		}
+		name := b.Category
+		if name == "" {
+			name = "total"
+		}
+		fmt.Printf("%-16s\t%s\t%s\t%s\t%d%%%s\n", name, b.Amount, used, left, percentOf(used, b.Amount), status)
+	}
+	if !found {
+		fmt.Println("no budget applies to this month")
+	}
+}
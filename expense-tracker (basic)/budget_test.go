@@ -0,0 +1,102 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFindBudget(t *testing.T) {
+	budgets := []Budget{
+		{Amount: Money{10000, "USD"}},
+		{Month: "2025-11", Amount: Money{20000, "USD"}},
+		{Category: "lunch", Amount: Money{5000, "USD"}},
+	}
+	tests := []struct {
+		month, category string
+		want            int64 // 0 when there is no budget
+	}{
+		{"2025-11", "", 20000},
+		{"2025-12", "", 10000},
+		{"2025-11", "lunch", 5000},
+		{"2025-11", "dinner", 0},
+	}
+	for _, tt := range tests {
+		b, ok := findBudget(budgets, tt.month, tt.category)
+		if got := b.Amount.Minor; ok != (tt.want != 0) || got != tt.want {
+			t.Errorf("findBudget(%s, %q) = %d, %v, want %d", tt.month, tt.category, got, ok, tt.want)
+		}
+	}
+}
+
+func TestBudgetWarnings(t *testing.T) {
+	t.Chdir(t.TempDir())
+	// 100.00 every month, 50.00 for lunch, and 200.00 in January 2026
+	err := saveBudgets([]Budget{
+		{Amount: Money{10000, "USD"}},
+		{Category: "lunch", Amount: Money{5000, "USD"}},
+		{Month: "2026-01", Amount: Money{20000, "USD"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	at := func(date string, hour, minute int) time.Time {
+		d := day(date)
+		return time.Date(d.Year(), d.Month(), d.Day(), hour, minute, 0, 0, time.Local)
+	}
+	expense := func(date time.Time, category string, minor int64) Expense {
+		return Expense{Date: date, Category: category, Amount: Money{minor, "USD"}}
+	}
+	nov := at("2025-11-10", 12, 0)
+
+	tests := []struct {
+		name     string
+		existing []Expense
+		added    Expense
+		want     []string // parts of the warnings, in order
+	}{
+		{"below 80%", []Expense{expense(nov, "dinner", 5000)}, expense(nov, "dinner", 2000), nil},
+		{"crosses 80%", []Expense{expense(nov, "dinner", 7000)}, expense(nov, "dinner", 1500),
+			[]string{"85% of the budget for every month, all categories used, spent 85.00 USD of 100.00 USD"}},
+		{"exactly 80%", []Expense{expense(nov, "dinner", 6000)}, expense(nov, "dinner", 2000),
+			[]string{"80% of the budget"}},
+		{"already past 80%", []Expense{expense(nov, "dinner", 8500)}, expense(nov, "dinner", 500), nil},
+		{"crosses 100%", []Expense{expense(nov, "dinner", 9000)}, expense(nov, "dinner", 1500),
+			[]string{"budget for every month, all categories exceeded, spent 105.00 USD of 100.00 USD"}},
+		{"exactly 100%", []Expense{expense(nov, "dinner", 9000)}, expense(nov, "dinner", 1000),
+			[]string{"exceeded"}},
+		{"from below 80% past 100% warns once", []Expense{expense(nov, "dinner", 1000)}, expense(nov, "dinner", 10000),
+			[]string{"budget for every month, all categories exceeded"}},
+		{"already exceeded", []Expense{expense(nov, "dinner", 12000)}, expense(nov, "dinner", 1000), nil},
+		{"category budget", []Expense{expense(nov, "lunch", 3000)}, expense(nov, "lunch", 1500),
+			[]string{"90% of the budget for every month, lunch used"}},
+		{"category and overall budget", []Expense{expense(nov, "dinner", 5000), expense(nov, "lunch", 3000)}, expense(nov, "lunch", 2500),
+			[]string{"all categories exceeded", "lunch exceeded"}},
+		{"last month doesn't count", []Expense{expense(at("2025-10-31", 23, 59), "dinner", 9500)}, expense(at("2025-11-01", 0, 0), "dinner", 1000), nil},
+		{"last day of the month counts", []Expense{expense(at("2025-11-01", 0, 0), "dinner", 7000)}, expense(at("2025-11-30", 23, 59), "dinner", 1500),
+			[]string{"85% of the budget"}},
+		{"same month of another year doesn't count", []Expense{expense(at("2024-11-10", 12, 0), "dinner", 9500)}, expense(nov, "dinner", 1000), nil},
+		{"budget of the month wins", []Expense{expense(at("2026-01-05", 12, 0), "dinner", 9000)}, expense(at("2026-01-06", 12, 0), "dinner", 1500), nil},
+		{"budget of the month, December uses every month", []Expense{expense(at("2025-12-05", 12, 0), "dinner", 9000)}, expense(at("2025-12-31", 23, 59), "dinner", 1500),
+			[]string{"exceeded"}},
+		{"new year", []Expense{expense(at("2025-12-31", 23, 59), "dinner", 19000)}, expense(at("2026-01-01", 0, 0), "dinner", 16500),
+			[]string{"82% of the budget for 2026-01, all categories used"}},
+	}
+	for _, tt := range tests {
+		data := append(append([]Expense{}, tt.existing...), tt.added)
+		warnings, err := budgetWarnings(data, tt.added)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if len(warnings) != len(tt.want) {
+			t.Errorf("%s: warnings %q, want %d", tt.name, warnings, len(tt.want))
+			continue
+		}
+		for i, want := range tt.want {
+			if !strings.Contains(warnings[i], want) {
+				t.Errorf("%s: warning %q, want %q", tt.name, warnings[i], want)
+			}
+		}
+	}
+}
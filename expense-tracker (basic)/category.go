@@ -0,0 +1,121 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// the user decides which categories exist, they are kept in categories.json
+// until that file is created the default categories are used
+var defaultCategories = []string{"breakfast", "lunch", "dinner", "other"}
+
+// category given to old expenses whose description is not a known meal time
+const fallbackCategory = "other"
+
+func normalizeCategory(name string) string {
+	return strings.ToLower(strings.TrimSpace(name))
+}
+
+func loadCategories() ([]string, error) {
+	data, err := os.ReadFile(categoryFile)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return append([]string(nil), defaultCategories...), nil
+		}
+		return nil, err
+	}
+	var categories []string
+	if err := json.Unmarshal(data, &categories); err != nil {
+		return nil, fmt.Errorf("%s: %w", categoryFile, err)
+	}
+	return categories, nil
+}
+
+func saveCategories(categories []string) error {
+	data, err := json.MarshalIndent(categories, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(categoryFile, data, 0644)
+}
+
+func hasCategory(categories []string, name string) bool {
+	for _, c := range categories {
+		if c == name {
+			return true
+		}
+	}
+	return false
+}
+
+// instance: go run . category add travel
+//
+//	go run . category remove travel
+//	go run . category
+func cmdCategory(args []string) {
+	categories, err := loadCategories()
+	if err != nil {
+		fail("error while loading the categories: ", err)
+		return
+	}
+
+	if len(args) == 0 || args[0] == "list" {
+		fmt.Println("Categories: ")
+		for _, c := range categories {
+			fmt.Println("  " + c)
+		}
+		return
+	}
+	if len(args) < 2 {
+		fail("usage: category [list | add <name> | remove <name>]")
+		return
+	}
+
+	name := normalizeCategory(strings.Join(args[1:], " "))
+	switch args[0] {
+	case "add":
+		if name == "" {
+			fail("category name can not be empty")
+			return
+		}
+		if hasCategory(categories, name) {
+			failf("category %q already exists\n", name)
+			return
+		}
+		categories = append(categories, name)
+	case "remove":
+		if !hasCategory(categories, name) {
+			failf("category %q not found\n", name)
+			return
+		}
+		expenses, err := loadExpenses()
+		if err != nil {
+			fail("error while loading the expenses: ", err)
+			return
+		}
+		for _, e := range expenses {
+			if e.Category == name {
+				failf("category %q is still used by expense %d\n", name, e.Id)
+				return
+			}
+		}
+		for i, c := range categories {
+			if c == name {
+				categories = append(categories[:i], categories[i+1:]...)
+				break
+			}
+		}
+	default:
+		failf("unknown category command %q\n", args[0])
+		return
+	}
+
+	if err := saveCategories(categories); err != nil {
+		fail("error while saving the categories: ", err)
+		return
+	}
+	fmt.Println("categories updated successfully ")
+}
@@ -0,0 +1,418 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"time"
+)
+
+// every command is described once in the commands table below, the table is
+// used to parse the flags, to print the help and to write the completion scripts
+
+const programName = "expense-tracker"
+
+// exit codes: 0 when everything worked, 1 when a command failed, 2 for a wrong command line
+var exitCode = 0
+
+// fail prints an error to stderr and makes the program exit with 1
+func fail(a ...any) {
+	fmt.Fprintln(os.Stderr, a...)
+	exitCode = 1
+}
+
+func failf(format string, a ...any) {
+	fmt.Fprintf(os.Stderr, format, a...)
+	exitCode = 1
+}
+
+// options are the flag values of a command, by flag name
+type options map[string]string
+
+type flagSpec struct {
+	name  string
+	value string // the default
+	help  string
+}
+
+type command struct {
+	name    string
+	args    string // the arguments after the flags, shown in the usage
+	summary string
+	minArgs int
+	flags   []flagSpec
+	// raw commands read their own sub commands (like "category add"), they get the arguments as they are
+	// their flags are only for help and completion, the sub command parses them with parseFlags
+	raw bool
+	// sub commands, only used by the completion scripts
+	subcommands []string
+	run         func(opts options, args []string)
+}
+
+var commands []command
+
+// the table is filled in init because "help" and "completion" read it too
+func init() {
+	category := flagSpec{"category", "", "only expenses of this category"}
+	from := flagSpec{"from", "", "only expenses from this day on, like 2025-11-01"}
+	to := flagSpec{"to", "", "only expenses until this day, like 2025-11-30"}
+	now := time.Now()
+	lastYear := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.Local).AddDate(0, -11, 0)
+
+	commands = []command{
+		{name: "add", args: "<category> <amount> [description]", summary: "add an expense, the amount can have a currency like 12.50EUR",
+			minArgs: 2, flags: []flagSpec{{"date", "", "day of the expense, today when empty"}},
+			run: func(opts options, args []string) { cmdAdd(opts, args) }},
+		{name: "list", summary: "list the expenses",
+			flags: []flagSpec{category, from, to},
+			run:   func(opts options, args []string) { cmdList(opts) }},
+		{name: "summary", summary: "total of the expenses in the base currency",
+			flags: []flagSpec{category, from, to},
+			run:   func(opts options, args []string) { cmdSummary(opts) }},
+		{name: "summary-m", args: "<month> [year]", summary: "total of one month, this year when no year is given",
+			minArgs: 1, run: func(opts options, args []string) { cmdSummaryByMonth(args) }},
+		{name: "update", args: "<id> [description]", summary: "change an expense",
+			minArgs: 1, flags: []flagSpec{
+				{"category", "", "new category"},
+				{"amount", "", "new amount, like 12.50 or 12.50EUR"},
+				{"date", "", "new day, like 2025-11-25"},
+			},
+			run: func(opts options, args []string) { cmdUpdate(opts, args) }},
+		{name: "delete", args: "<id>", summary: "delete an expense",
+			minArgs: 1, run: func(opts options, args []string) { cmdDelete(args) }},
+		{name: "report", summary: "totals by day, week, month, year or category with charts",
+			flags: []flagSpec{
+				{"from", lastYear.Format(dateLayout), "first day of the report"},
+				{"to", now.Format(dateLayout), "last day of the report"},
+				{"by", "month", "group by day, week, month, year or category"},
+				{"category", "", "only this category"},
+				{"format", "table", "table, csv or json"},
+			},
+			run: func(opts options, args []string) { cmdReport(opts) }},
+		{name: "show", args: "<id>", summary: "show all details of an expense",
+			minArgs: 1, run: func(opts options, args []string) { cmdShow(args) }},
+		{name: "search", args: "<word|#tag>...", summary: "find expenses by description, notes, category or tag",
+			minArgs: 1, run: func(opts options, args []string) { cmdSearch(args) }},
+		{name: "attach", args: "<id> <file>...", summary: "attach receipts (images or pdf) to an expense",
+			minArgs: 2, run: func(opts options, args []string) { cmdAnnotate("attach", args) }},
+		{name: "detach", args: "<id> <number>", summary: "remove an attachment from an expense",
+			minArgs: 2, run: func(opts options, args []string) { cmdAnnotate("detach", args) }},
+		{name: "note", args: "<id> [text]", summary: "set the note of an expense, without text the note is removed",
+			minArgs: 1, run: func(opts options, args []string) { cmdAnnotate("note", args) }},
+		{name: "tag", args: "<id> <tag>...", summary: "add tags to an expense",
+			minArgs: 2, run: func(opts options, args []string) { cmdAnnotate("tag", args) }},
+		{name: "untag", args: "<id> <tag>...", summary: "remove tags from an expense",
+			minArgs: 2, run: func(opts options, args []string) { cmdAnnotate("untag", args) }},
+		{name: "split", args: "<id> <payer> <equal|percent|exact> <person[:value]>...", summary: "split an expense between people",
+			minArgs: 2, run: func(opts options, args []string) { cmdSplit(args) }},
+		{name: "balances", summary: "who owes whom, with a plan to settle up",
+			run: func(opts options, args []string) { cmdBalances() }},
+		{name: "import", args: "<file.csv|file.ofx|file.qfx> [profile]", summary: "import a bank statement",
+			minArgs: 1, run: func(opts options, args []string) { cmdImport(args) }},
+		{name: "category", args: "[list | add <name> | remove <name>]", summary: "manage the categories",
+			raw: true, subcommands: []string{"list", "add", "remove"},
+			run: func(opts options, args []string) { cmdCategory(args) }},
+		{name: "budget", args: "[set | remove | list | report]", summary: "monthly budgets",
+			raw: true, subcommands: []string{"set", "remove", "list", "report"},
+			run: func(opts options, args []string) { cmdBudget(args) }},
+		{name: "currency", args: "[base <code>]", summary: "show or set the base currency",
+			raw: true, subcommands: []string{"base"},
+			run: func(opts options, args []string) { cmdCurrency(args) }},
+		{name: "rates", args: "[list | add | import]", summary: "exchange rates",
+			raw: true, subcommands: []string{"list", "add", "import"},
+			run: func(opts options, args []string) { cmdRates(args) }},
+		{name: "rule", args: "[list | add | add-regex | remove]", summary: "categorization rules for imports",
+			raw: true, subcommands: []string{"list", "add", "add-regex", "remove"},
+			run: func(opts options, args []string) { cmdRule(args) }},
+		{name: "recurring", args: "[list | add | upcoming | cost | stop | remove]", summary: "rent, subscriptions and other recurring expenses",
+			raw: true, subcommands: []string{"list", "add", "upcoming", "cost", "stop", "remove"},
+			// read by "recurring add" with parseFlags
+			flags: []flagSpec{
+				{"start", now.Format(dateLayout), "add: date of the first charge"},
+				{"end", "", "add: date of the last charge"},
+				{"every", "1", "add: charge every n days, weeks, months or years"},
+			},
+			run: func(opts options, args []string) { cmdRecurring(args) }},
+		{name: "storage", args: "[use <json|sqlite>]", summary: "show or change where the expenses are kept",
+			raw: true, subcommands: []string{"use"},
+			run: func(opts options, args []string) { cmdStorage(args) }},
+		{name: "help", args: "[command]", summary: "show the help of the program or of a command",
+			run: func(opts options, args []string) { cmdHelp(args) }},
+		{name: "completion", args: "<bash|zsh|fish>", summary: "print a shell completion script",
+			minArgs: 1, subcommands: []string{"bash", "zsh", "fish"},
+			run: func(opts options, args []string) { cmdCompletion(args) }},
+	}
+}
+
+func findCommand(name string) (command, bool) {
+	for _, c := range commands {
+		if c.name == name {
+			return c, true
+		}
+	}
+	return command{}, false
+}
+
+// parse reads the flags of the command, they can come before, between or after
+// the arguments. everything after "--" is an argument
+func (c command) parse(args []string) (options, []string, error) {
+	if c.raw {
+		if len(args) > 0 && (args[0] == "-h" || args[0] == "--help") {
+			return nil, nil, flag.ErrHelp
+		}
+		return options{}, args, nil
+	}
+	return parseFlags(c.name, c.flags, args)
+}
+
+// parseFlags reads the flags of a command or sub command, see parse
+func parseFlags(name string, flags []flagSpec, args []string) (options, []string, error) {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(io.Discard) // errors are printed by runCommand
+	values := make(map[string]*string)
+	for _, f := range flags {
+		values[f.name] = fs.String(f.name, f.value, f.help)
+	}
+
+	var positional, afterDash []string
+	if i := slices.Index(args, "--"); i >= 0 {
+		args, afterDash = args[:i], args[i+1:]
+	}
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, nil, err
+		}
+		args = fs.Args()
+		if len(args) == 0 {
+			break
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+
+	opts := options{}
+	for name, value := range values {
+		opts[name] = *value
+	}
+	return opts, append(positional, afterDash...), nil
+}
+
+func (c command) usage(w io.Writer) {
+	line := programName + " " + c.name
+	if len(c.flags) > 0 {
+		line += " [flags]"
+	}
+	if c.args != "" {
+		line += " " + c.args
+	}
+	fmt.Fprintf(w, "usage: %s\n\n%s\n", line, c.summary)
+	if len(c.flags) == 0 {
+		return
+	}
+	fmt.Fprintln(w, "\nflags:")
+	for _, f := range c.flags {
+		def := ""
+		if f.value != "" {
+			def = fmt.Sprintf(" (default %s)", f.value)
+		}
+		fmt.Fprintf(w, "  --%-10s %s%s\n", f.name, f.help, def)
+	}
+}
+
+// runCommand parses the command line and runs the command, it returns the exit code
+func runCommand(name string, args []string) int {
+	c, ok := findCommand(name)
+	if !ok {
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n", name)
+		printHelp(os.Stderr)
+		return 2
+	}
+	opts, args, err := c.parse(args)
+	if errors.Is(err, flag.ErrHelp) {
+		c.usage(os.Stdout)
+		return 0
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n\n", err)
+		c.usage(os.Stderr)
+		return 2
+	}
+	if len(args) < c.minArgs {
+		c.usage(os.Stderr)
+		return 2
+	}
+
+	// help and completion don't touch the expenses
+	if c.name != "help" && c.name != "completion" {
+		if err := openExpenses(); err != nil {
+			fail(err)
+			return exitCode
+		}
+		defer store.Close()
+	}
+	c.run(opts, args)
+	return exitCode
+}
+
+// openExpenses opens the storage and adds the recurring expenses which became due
+func openExpenses() error {
+	settings, err := loadSettings()
+	if err != nil {
+		return fmt.Errorf("error while loading the settings: %w", err)
+	}
+	store, err = openStore(settings)
+	if err != nil {
+		return fmt.Errorf("error while opening the storage: %w", err)
+	}
+	// rent, subscriptions... which became due since the last run
+	materializeRecurring()
+	return nil
+}
+
+func printHelp(w io.Writer) {
+	fmt.Fprintf(w, "usage: %s <command> [flags] [arguments]\n\ncommands:\n", programName)
+	for _, c := range commands {
+		fmt.Fprintf(w, "  %-11s %s\n", c.name, c.summary)
+	}
+	fmt.Fprintf(w, "\nrun \"%s help <command>\" for the flags and arguments of a command\n", programName)
+}
+
+func cmdHelp(args []string) {
+	if len(args) == 0 {
+		printHelp(os.Stdout)
+		return
+	}
+	c, ok := findCommand(args[0])
+	if !ok {
+		failf("unknown command %q\n", args[0])
+		return
+	}
+	c.usage(os.Stdout)
+}
+
+// parseDay reads a --date, --from or --to flag
+func parseDay(name, value string) (time.Time, error) {
+	day, err := time.ParseInLocation(dateLayout, value, time.Local)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid --%s date %q, use 2025-11-25", name, value)
+	}
+	return day, nil
+}
+
+// filterExpenses keeps the expenses which match the --category, --from and --to flags
+func filterExpenses(data []Expense, opts options) ([]Expense, error) {
+	category := normalizeCategory(opts["category"])
+	var from, until time.Time
+	var err error
+	if opts["from"] != "" {
+		if from, err = parseDay("from", opts["from"]); err != nil {
+			return nil, err
+		}
+	}
+	if opts["to"] != "" {
+		if until, err = parseDay("to", opts["to"]); err != nil {
+			return nil, err
+		}
+		// --to is the last day, so everything before the next day
+		until = until.AddDate(0, 0, 1)
+	}
+
+	var filtered []Expense
+	for _, e := range data {
+		if category != "" && e.Category != category {
+			continue
+		}
+		if !from.IsZero() && e.Date.Before(from) {
+			continue
+		}
+		if !until.IsZero() && !e.Date.Before(until) {
+			continue
+		}
+		filtered = append(filtered, e)
+	}
+	return filtered, nil
+}
+
+// instance: expense-tracker completion bash > /etc/bash_completion.d/expense-tracker
+//
+//	source <(expense-tracker completion zsh)
+//	expense-tracker completion fish > ~/.config/fish/completions/expense-tracker.fish
+func cmdCompletion(args []string) {
+	switch args[0] {
+	case "bash":
+		fmt.Print(bashCompletion())
+	case "zsh":
+		fmt.Print("autoload -U +X bashcompinit && bashcompinit\n" + bashCompletion())
+	case "fish":
+		fmt.Print(fishCompletion())
+	default:
+		failf("no completion for %q, use bash, zsh or fish\n", args[0])
+	}
+}
+
+func commandNames() string {
+	names := make([]string, len(commands))
+	for i, c := range commands {
+		names[i] = c.name
+	}
+	return strings.Join(names, " ")
+}
+
+func bashCompletion() string {
+	var sb strings.Builder
+	sb.WriteString(`_expense_tracker() {
+	local cur prev words
+	cur="${COMP_WORDS[COMP_CWORD]}"
+	prev="${COMP_WORDS[COMP_CWORD-1]}"
+	if [ "$COMP_CWORD" -eq 1 ]; then
+		COMPREPLY=( $(compgen -W "` + commandNames() + `" -- "$cur") )
+		return
+	fi
+	if [ "$prev" = "--category" ]; then
+		COMPREPLY=( $(compgen -W "$(` + programName + ` category list 2>/dev/null | sed -n 's/^  //p')" -- "$cur") )
+		return
+	fi
+	case "${COMP_WORDS[1]}" in
+`)
+	for _, c := range commands {
+		var words []string
+		if len(c.subcommands) > 0 {
+			words = c.subcommands
+		}
+		for _, f := range c.flags {
+			words = append(words, "--"+f.name)
+		}
+		if c.name == "help" {
+			words = strings.Fields(commandNames())
+		}
+		if len(words) == 0 {
+			continue
+		}
+		fmt.Fprintf(&sb, "\t%s) words=%q ;;\n", c.name, strings.Join(words, " "))
+	}
+	sb.WriteString(`	*) words="" ;;
+	esac
+	COMPREPLY=( $(compgen -W "$words" -- "$cur") )
+}
+complete -o default -F _expense_tracker ` + programName + "\n")
+	return sb.String()
+}
+
+func fishCompletion() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "complete -c %s -f\n", programName)
+	for _, c := range commands {
+		fmt.Fprintf(&sb, "complete -c %s -n __fish_use_subcommand -a %s -d %q\n", programName, c.name, c.summary)
+		for _, s := range c.subcommands {
+			fmt.Fprintf(&sb, "complete -c %s -n '__fish_seen_subcommand_from %s' -a %s\n", programName, c.name, s)
+		}
+		for _, f := range c.flags {
+			fmt.Fprintf(&sb, "complete -c %s -n '__fish_seen_subcommand_from %s' -l %s -r -d %q\n", programName, c.name, f.name, f.help)
+		}
+	}
+	return sb.String()
+}
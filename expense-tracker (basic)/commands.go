@@ -2,66 +2,110 @@ package main
 
 import (
 	"fmt"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
 )
 
-func cmdAdd(arg []string) {
-	if len(arg) < 1 {
-		fmt.Println("provide amount !")
+// instance: go run . add lunch 12.50 pizza with the team
+// the amount can have a currency: go run . add travel 30EUR taxi
+// an older expense: go run . add --date 2025-11-02 lunch 9.90
+func cmdAdd(opts options, arg []string) {
+	if len(arg) < 2 {
+		fail("usage: add <category> <amount> [description]")
 		return
 	}
 
-	expense, _ := loadFile()
+	categories, err := loadCategories()
+	if err != nil {
+		fail("error while loading the categories: ", err)
+		return
+	}
+	category := normalizeCategory(arg[0])
+	if !hasCategory(categories, category) {
+		failf("unknown category %q, add it first with: category add %s\n", category, category)
+		return
+	}
 
-	var description string
-	switch arg[0] {
-	case "breakfast":
-		description = breakfast
-	case "launch":
-		description = launch
-	case "dinner":
-		description = dinner
-	default:
-		fmt.Println("unknown male time")
+	base, err := baseCurrency()
+	if err != nil {
+		fail(err)
 		return
 	}
+	amount, err := parseMoney(arg[1], base)
+	if err != nil {
+		fail(err)
+		return
+	}
+	description := strings.Join(arg[2:], " ")
+
+	date := time.Now()
+	if opts["date"] != "" {
+		if date, err = parseDay("date", opts["date"]); err != nil {
+			fail(err)
+			return
+		}
+	}
 
-	now := time.Now
-	amountString := arg[1]
-	amountInt, _ := strconv.Atoi(amountString)
+	expense, err := loadExpenses()
+	if err != nil {
+		fail("error while loading the expenses: ", err)
+		return
+	}
 
 	newExpense := Expense{
 		Id:          getNextId(expense),
-		Date:        now(),
+		Date:        date,
+		Category:    category,
 		Description: description,
-		Amount:      amountInt,
+		Amount:      amount,
 	}
 
 	expense = append(expense, newExpense)
-	if err := saveFile(&expense); err != nil {
-		fmt.Println("error ocurred while saving : ", err)
+	if err := saveExpenses(expense); err != nil {
+		fail("error ocurred while saving : ", err)
+		return
 	}
 
 	fmt.Println("Expense added successfully ")
 
+	warnings, err := budgetWarnings(expense, newExpense)
+	if err != nil {
+		fail("error while checking the budgets: ", err)
+	}
+	for _, w := range warnings {
+		fmt.Println(w)
+	}
+
 }
 
 // list the Expenses
 // ID    Description	Amount	Date
-func cmdList() {
-	data, _ := loadFile()
+// only some of them: go run . list --category lunch --from 2025-11-01 --to 2025-11-30
+func cmdList(opts options) {
+	data, err := loadExpenses()
+	if err != nil {
+		fail("error while loading the expenses: ", err)
+		return
+	}
+	data, err = filterExpenses(data, opts)
+	if err != nil {
+		fail(err)
+		return
+	}
 	fmt.Println("Lis of Expenses: ")
 
-	fmt.Println("ID  	 Desc   Amount		Date")
+	fmt.Println("ID  	 Category   Amount		Date		Desc")
 
 	for i := 0; i < len(data); i++ {
-		fmt.Printf("%d \t%s \t%d \t\t%s\n",
+		fmt.Printf("%d \t%s \t%s \t%s \t%s%s\n",
 			data[i].Id,
-			data[i].Description,
+			data[i].Category,
 			data[i].Amount,
 			data[i].Date.Format("2006-01-02"),
+			data[i].Description,
+			attachmentMark(data[i]),
 		)
 	}
 
@@ -74,35 +118,61 @@ func cmdList() {
 // get the json file
 //it return a list ,
 //sum all data[i].amout and print
+// the flags are the same as for list: go run . summary --category lunch
 
-func cmdSummary() {
-	data, _ := loadFile()
-
-	total := 0
-	for i := 0; i < len(data); i++ {
-		total += data[i].Amount
+func cmdSummary(opts options) {
+	data, err := loadExpenses()
+	if err != nil {
+		fail("error while loading the expenses: ", err)
+		return
+	}
+	data, err = filterExpenses(data, opts)
+	if err != nil {
+		fail(err)
+		return
 	}
 
-	fmt.Printf("total expense: $%d", total)
+	printTotal("total expense", data)
 
 }
 
 // input: go run . delete 2
 // output: expense 2 deleted successfully
 
-func cmdDelete(index int) {
-	data, _ := loadFile()
+func cmdDelete(args []string) {
+	index, err := strconv.Atoi(args[0])
+	if err != nil {
+		failf("invalid id %q\n", args[0])
+		return
+	}
+	data, err := loadExpenses()
+	if err != nil {
+		fail("error while loading the expenses: ", err)
+		return
+	}
 
+	var removed Expense
+	found := false
 	for i := 0; i < len(data); i++ {
 		if data[i].Id == index {
+			removed = data[i]
+			found = true
 			data = append(data[:i], data[i+1:]...)
 			break
 		}
 	}
-	if err := saveFile(&data); err != nil {
-		fmt.Println("Error while saving the file: ", err)
+	if !found {
+		failf("no expense with id %d\n", index)
+		return
+	}
+	if err := saveExpenses(data); err != nil {
+		fail("Error while saving the file: ", err)
 		return
 	}
+	// the receipts of the expense go too, unless another expense has the same file
+	for _, a := range removed.Attachments {
+		removeUnusedReceipt(data, a)
+	}
 	fmt.Println("file deleted successfully ")
 	// how to remove an element from a list in go
 	// answer : use append function
@@ -113,53 +183,165 @@ func cmdDelete(index int) {
 }
 
 // summaryByMonth
-func cmdSummaryByMonth(month int) {
-	if month > 12 || month < 1 {
-		fmt.Println("invalid month ")
+// instance: go run . summary-m 3 (march of this year) or go run . summary-m 3 2024
+func cmdSummaryByMonth(args []string) {
+	if len(args) == 0 {
+		fail("usage: summary-m <month> [year]")
+		return
+	}
+	month, err := strconv.Atoi(args[0])
+	if err != nil || month > 12 || month < 1 {
+		fail("invalid month ")
+		return
+	}
+	year := time.Now().Year()
+	if len(args) > 1 {
+		year, err = strconv.Atoi(args[1])
+		if err != nil || year < 1 {
+			fail("invalid year ")
+			return
+		}
+	}
+	data, err := loadExpenses()
+	if err != nil {
+		fail("error while loading the expenses: ", err)
 		return
 	}
-	data, _ := loadFile()
 
-	total := 0
-	m := 0
-	for i := 0; i < len(data); i++ {
-		if int(data[i].Date.Month()) == month {
-			m = i
-			total += data[i].Amount
+	printTotal(fmt.Sprintf("total expense for %s %d", time.Month(month), year), expensesInMonth(data, year, time.Month(month), ""))
 
+}
+
+// expensesInMonth returns the expenses of a month
+// year 0 matches the month of every year and an empty category matches all categories
+func expensesInMonth(data []Expense, year int, month time.Month, category string) []Expense {
+	var expenses []Expense
+	for i := 0; i < len(data); i++ {
+		if data[i].Date.Month() != month || (year != 0 && data[i].Date.Year() != year) {
+			continue
+		}
+		if category != "" && data[i].Category != category {
+			continue
 		}
+		expenses = append(expenses, data[i])
 	}
+	return expenses
+}
 
-	fmt.Printf("total expense for %s is %d", data[m].Date.Month(), total)
+// printTotal prints the sum of the expenses in the base currency
+// when they were paid in several currencies the original amounts are listed too
+func printTotal(label string, data []Expense) {
+	total, missing, err := sumInBase(data)
+	if err != nil {
+		fail("error while converting the amounts: ", err)
+		return
+	}
+	fmt.Printf("%s: %s\n", label, total)
 
+	var amounts []Money
+	for _, e := range data {
+		amounts = append(amounts, e.Amount)
+	}
+	if totals := totalsByCurrency(amounts); len(totals) > 1 {
+		fmt.Printf("paid in: %s\n", formatTotals(totals))
+	}
+	printMissingRates(missing, total.Currency)
 }
 
 // update a male time by its id
 // instance: go run . update 1 breakfast
-func cmdUpdate(args []string) {
+// other fields: go run . update 1 --category dinner --amount 14EUR --date 2025-11-02
+func cmdUpdate(opts options, args []string) {
 	//args = [1 , breakfst]
-	if len(args) < 2 {
-		fmt.Println("invalid input ")
+	if len(args) < 2 && opts["category"] == "" && opts["amount"] == "" && opts["date"] == "" {
+		fail("nothing to update, give a description or --category, --amount or --date")
+		return
+	}
+	id, err := strconv.Atoi(args[0])
+	if err != nil {
+		failf("invalid id %q\n", args[0])
 		return
 	}
-	id, _ := strconv.Atoi(args[0])
 	male := args[1:] // breakfst
 	// is there any ready function in go to join a list of string into a single string
 	// answer : yes , strings.Join
 	desc := strings.Join(male, " ")
 
-	expenses, _ := loadFile()
+	expenses, err := loadExpenses()
+	if err != nil {
+		fail("error while loading the expenses: ", err)
+		return
+	}
 	// find that instance with that id
-	for i := range expenses {
-		if id == expenses[i].Id {
-			expenses[i].Description = desc
-			break
+	expense := findExpense(expenses, args[0])
+	if expense == nil {
+		failf("no expense with id %d\n", id)
+		return
+	}
+	if desc != "" {
+		expense.Description = desc
+	}
+	if opts["category"] != "" {
+		categories, err := loadCategories()
+		if err != nil {
+			fail("error while loading the categories: ", err)
+			return
+		}
+		category := normalizeCategory(opts["category"])
+		if !hasCategory(categories, category) {
+			failf("unknown category %q, add it first with: category add %s\n", category, category)
+			return
+		}
+		expense.Category = category
+	}
+	if opts["amount"] != "" {
+		base, err := baseCurrency()
+		if err != nil {
+			fail(err)
+			return
+		}
+		amount, err := parseMoney(opts["amount"], base)
+		if err != nil {
+			fail(err)
+			return
+		}
+		// the shares were computed from the old amount
+		if len(expense.Shares) > 0 && amount != expense.Amount {
+			failf("expense %d is split, remove the split first with: split %d none\n", id, id)
+			return
 		}
+		expense.Amount = amount
 	}
-	if err := saveFile(&expenses); err != nil {
-		fmt.Println("error while saving the file , ", err)
+	if opts["date"] != "" {
+		date, err := parseDay("date", opts["date"])
+		if err != nil {
+			fail(err)
+			return
+		}
+		expense.Date = date
+	}
+
+	if err := saveExpenses(expenses); err != nil {
+		fail("error while saving the file , ", err)
 		return
 	}
-	fmt.Println("Expense description updated successfully ")
+	fmt.Println("Expense updated successfully ")
+
+}
 
+// formatTotals prints one total per currency, like "12.50 USD + 30.00 EUR"
+func formatTotals(totals map[string]Money) string {
+	if len(totals) == 0 {
+		return "0"
+	}
+	currencies := make([]string, 0, len(totals))
+	for c := range totals {
+		currencies = append(currencies, c)
+	}
+	sort.Strings(currencies)
+	parts := make([]string, len(currencies))
+	for i, c := range currencies {
+		parts[i] = totals[c].String()
+	}
+	return strings.Join(parts, " + ")
 }
@@ -5,15 +5,22 @@ import "time"
 // file to be saved
 const expenseFile = "expense.json"
 
-const (
-	launch    = "Lauch"
-	dinner    = "Dinner"
-	breakfast = "Breakfast"
-)
+// file with the user's categories, see category.go
+const categoryFile = "categories.json"
 
 type Expense struct {
 	Id          int       `json:"id"`
 	Date        time.Time `json:"date"`
+	Category    string    `json:"category"`
 	Description string    `json:"description"`
-	Amount      int       `json:"amount"`
+	Amount      Money     `json:"amount"`
+	// set when the expense was added by a recurring expense, see recurring.go
+	RecurringId int `json:"recurringId,omitempty"`
+	// shared expenses: who paid and the share of every participant, see split.go
+	Payer  string  `json:"payer,omitempty"`
+	Shares []Share `json:"shares,omitempty"`
+	// receipts, notes and tags, see attachment.go
+	Attachments []Attachment `json:"attachments,omitempty"`
+	Notes       string       `json:"notes,omitempty"`
+	Tags        []string     `json:"tags,omitempty"`
 }
@@ -0,0 +1,447 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// "import" reads a bank statement and adds its payments as expenses
+// OFX and QFX files are read as they are, CSV files differ for every bank so
+// the columns are described by a profile in import.json:
+//
+//	{
+//	  "mybank": {
+//	    "date": "Booking date", "dateFormat": "02.01.2006",
+//	    "amount": "Amount", "description": "Text",
+//	    "delimiter": ";", "decimalComma": true, "currency": "EUR"
+//	  }
+//	}
+//
+// columns are given by header name or by number (1 is the first column)
+// a bank which has separate columns for money going out and in uses "debit" and "credit"
+// instead of "amount". money coming in is not an expense and is skipped.
+// every transaction gets a category from the rules (see rules.go), and one
+// with the same date, amount and description as an existing expense is skipped
+
+const importFile = "import.json"
+
+type ImportProfile struct {
+	Date        string `json:"date"`
+	DateFormat  string `json:"dateFormat,omitempty"` // Go layout, default 2006-01-02
+	Amount      string `json:"amount,omitempty"`
+	Debit       string `json:"debit,omitempty"`
+	Credit      string `json:"credit,omitempty"`
+	Description string `json:"description"`
+	Delimiter   string `json:"delimiter,omitempty"` // default ","
+	// 1.234,56 instead of 1,234.56
+	DecimalComma bool   `json:"decimalComma,omitempty"`
+	Currency     string `json:"currency,omitempty"` // default is the base currency
+	// some banks write payments as positive numbers
+	ExpensesPositive bool `json:"expensesPositive,omitempty"`
+	NoHeader         bool `json:"noHeader,omitempty"`
+}
+
+// used when import.json has no profile for the file
+var defaultImportProfile = ImportProfile{
+	Date:        "date",
+	Amount:      "amount",
+	Description: "description",
+}
+
+// a transaction read from a statement, before it becomes an expense
+type bankTransaction struct {
+	date        time.Time
+	amount      Money
+	description string
+}
+
+func loadImportProfile(name string) (ImportProfile, error) {
+	data, err := os.ReadFile(importFile)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) && name == "" {
+			return defaultImportProfile, nil
+		}
+		return ImportProfile{}, err
+	}
+	var profiles map[string]ImportProfile
+	if err := json.Unmarshal(data, &profiles); err != nil {
+		return ImportProfile{}, fmt.Errorf("%s: %w", importFile, err)
+	}
+	if name == "" {
+		name = "default"
+	}
+	profile, ok := profiles[name]
+	if !ok {
+		if name == "default" {
+			return defaultImportProfile, nil
+		}
+		return ImportProfile{}, fmt.Errorf("no profile %q in %s", name, importFile)
+	}
+	return profile, nil
+}
+
+// instance: go run . import statement.ofx
+//
+//	go run . import export.csv mybank
+func cmdImport(args []string) {
+	if len(args) < 1 {
+		fail("usage: import <file.csv|file.ofx|file.qfx> [profile]")
+		return
+	}
+	path := args[0]
+
+	file, err := os.Open(path)
+	if err != nil {
+		fail("error while opening the file: ", err)
+		return
+	}
+	defer file.Close()
+
+	// amounts without a currency are in the base currency
+	base, err := baseCurrency()
+	if err != nil {
+		fail(err)
+		return
+	}
+
+	var transactions []bankTransaction
+	var skippedIncome int
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".ofx", ".qfx":
+		transactions, skippedIncome, err = readOFX(file, base)
+	default:
+		profileName := ""
+		if len(args) > 1 {
+			profileName = args[1]
+		}
+		var profile ImportProfile
+		profile, err = loadImportProfile(profileName)
+		if err == nil {
+			transactions, skippedIncome, err = readBankCSV(file, profile, base)
+		}
+	}
+	if err != nil {
+		fail("error while reading the statement: ", err)
+		return
+	}
+
+	rules, err := loadRules()
+	if err != nil {
+		fail("error while loading the rules: ", err)
+		return
+	}
+	categories, err := loadCategories()
+	if err != nil {
+		fail("error while loading the categories: ", err)
+		return
+	}
+
+	expenses, err := loadExpenses()
+	if err != nil {
+		fail("error while loading the expenses: ", err)
+		return
+	}
+	seen := make(map[string]bool)
+	for _, e := range expenses {
+		seen[duplicateKey(e.Date, e.Amount, e.Description)] = true
+	}
+
+	added, duplicates := 0, 0
+	perCategory := make(map[string]int)
+	for _, t := range transactions {
+		key := duplicateKey(t.date, t.amount, t.description)
+		if seen[key] {
+			duplicates++
+			continue
+		}
+		seen[key] = true
+
+		category, err := categorize(rules, categories, t.description)
+		if err != nil {
+			// nothing is saved yet, the import can simply be repeated
+			fail(err)
+			return
+		}
+		expenses = append(expenses, Expense{
+			Id:          getNextId(expenses),
+			Date:        t.date,
+			Category:    category,
+			Description: t.description,
+			Amount:      t.amount,
+		})
+		perCategory[category]++
+		added++
+	}
+
+	if added > 0 {
+		if err := saveExpenses(expenses); err != nil {
+			fail("error ocurred while saving : ", err)
+			return
+		}
+	}
+	fmt.Printf("%d expenses imported, %d duplicates skipped, %d incoming payments skipped\n", added, duplicates, skippedIncome)
+	used := make([]string, 0, len(perCategory))
+	for category := range perCategory {
+		used = append(used, category)
+	}
+	sort.Strings(used)
+	for _, category := range used {
+		fmt.Printf("  %s: %d\n", category, perCategory[category])
+	}
+}
+
+// duplicateKey is what an imported transaction shares with an expense it is a copy of
+func duplicateKey(date time.Time, amount Money, description string) string {
+	return date.Format(dateLayout) + "|" + amount.String() + "|" + strings.ToLower(strings.TrimSpace(description))
+}
+
+// ---------- CSV ----------
+
+func readBankCSV(r io.Reader, profile ImportProfile, base string) ([]bankTransaction, int, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	if profile.Delimiter != "" {
+		reader.Comma = []rune(profile.Delimiter)[0]
+	}
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, 0, err
+	}
+	if len(records) == 0 {
+		return nil, 0, nil
+	}
+
+	var header []string
+	if !profile.NoHeader {
+		header, records = records[0], records[1:]
+	}
+	column := func(name string) (int, error) {
+		if name == "" {
+			return -1, nil
+		}
+		if n, err := strconv.Atoi(name); err == nil && n > 0 {
+			return n - 1, nil
+		}
+		for i, h := range header {
+			if strings.EqualFold(strings.TrimSpace(h), name) {
+				return i, nil
+			}
+		}
+		return -1, fmt.Errorf("column %q not found", name)
+	}
+
+	dateCol, err := column(profile.Date)
+	if err != nil {
+		return nil, 0, err
+	}
+	descCol, err := column(profile.Description)
+	if err != nil {
+		return nil, 0, err
+	}
+	amountCol, err := column(profile.Amount)
+	if err != nil {
+		return nil, 0, err
+	}
+	debitCol, err := column(profile.Debit)
+	if err != nil {
+		return nil, 0, err
+	}
+	creditCol, err := column(profile.Credit)
+	if err != nil {
+		return nil, 0, err
+	}
+	if dateCol < 0 || descCol < 0 || (amountCol < 0 && debitCol < 0) {
+		return nil, 0, errors.New("the profile needs a date, a description and an amount or debit column")
+	}
+
+	layout := profile.DateFormat
+	if layout == "" {
+		layout = dateLayout
+	}
+	currency := profile.Currency
+	if currency == "" {
+		currency = base
+	}
+	cell := func(record []string, i int) string {
+		if i < 0 || i >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[i])
+	}
+
+	var transactions []bankTransaction
+	income := 0
+	for line, record := range records {
+		lineNumber := line + 1
+		if !profile.NoHeader {
+			lineNumber++
+		}
+		if cell(record, dateCol) == "" {
+			continue
+		}
+		date, err := time.ParseInLocation(layout, cell(record, dateCol), time.Local)
+		if err != nil {
+			return nil, 0, fmt.Errorf("line %d: invalid date %q", lineNumber, cell(record, dateCol))
+		}
+
+		// find out how much went out, as a positive number
+		var text string
+		outgoing := true
+		if amountCol >= 0 {
+			text = cell(record, amountCol)
+			negative := strings.HasPrefix(text, "-") || strings.HasPrefix(text, "(")
+			outgoing = negative != profile.ExpensesPositive
+		} else {
+			text = cell(record, debitCol)
+			if text == "" {
+				// an empty row, like a balance line, has nothing in either column
+				if cell(record, creditCol) == "" {
+					continue
+				}
+				outgoing = false
+			}
+		}
+		if !outgoing {
+			income++
+			continue
+		}
+		amount, err := parseBankAmount(text, currency, profile.DecimalComma)
+		if err != nil {
+			return nil, 0, fmt.Errorf("line %d: %w", lineNumber, err)
+		}
+		if amount.Minor == 0 {
+			continue
+		}
+		transactions = append(transactions, bankTransaction{
+			date:        date,
+			amount:      amount,
+			description: cell(record, descCol),
+		})
+	}
+	return transactions, income, nil
+}
+
+// parseBankAmount reads amounts like "-1,234.56", "(12.00)", "$12" or "1.234,56"
+// and returns the value without its sign
+func parseBankAmount(text, currency string, decimalComma bool) (Money, error) {
+	cleaned := strings.Map(func(r rune) rune {
+		switch {
+		case r >= '0' && r <= '9', r == '.', r == ',':
+			return r
+		}
+		// signs, brackets, spaces and currency symbols
+		return -1
+	}, text)
+	if decimalComma {
+		cleaned = strings.ReplaceAll(cleaned, ".", "")
+		cleaned = strings.ReplaceAll(cleaned, ",", ".")
+	} else {
+		cleaned = strings.ReplaceAll(cleaned, ",", "")
+	}
+	if cleaned == "" {
+		return Money{}, fmt.Errorf("invalid amount %q", text)
+	}
+
+	// banks sometimes write more decimals than the currency has
+	whole, fraction, _ := strings.Cut(cleaned, ".")
+	if d := decimals(currency); len(fraction) > d {
+		fraction = fraction[:d]
+	}
+	if strings.Trim(whole+fraction, "0") == "" {
+		return Money{Currency: currency}, nil
+	}
+	if fraction != "" {
+		whole += "." + fraction
+	}
+	return parseMoney(whole, currency)
+}
+
+// ---------- OFX / QFX ----------
+// OFX 1.x is SGML where most tags are never closed, 2.x is XML,
+// both have one <STMTTRN> block per transaction:
+//
+//	<STMTTRN><TRNTYPE>DEBIT<DTPOSTED>20251125120000<TRNAMT>-12.50<NAME>PIZZA PLACE</STMTTRN>
+
+var ofxTag = regexp.MustCompile(`<(/?)([A-Za-z0-9.]+)>([^<]*)`)
+
+// currency is used when the statement has no <CURDEF>
+func readOFX(r io.Reader, currency string) ([]bankTransaction, int, error) {
+	data, err := io.ReadAll(bufio.NewReader(r))
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var transactions []bankTransaction
+	income := 0
+	var current map[string]string
+
+	for _, match := range ofxTag.FindAllStringSubmatch(string(data), -1) {
+		closing, tag, value := match[1] == "/", strings.ToUpper(match[2]), strings.TrimSpace(match[3])
+		switch {
+		case tag == "CURDEF" && !closing && value != "":
+			currency = strings.ToUpper(value)
+		case tag == "STMTTRN" && !closing:
+			current = make(map[string]string)
+		case tag == "STMTTRN" && closing:
+			if current == nil {
+				continue
+			}
+			t, isIncome, err := ofxTransaction(current, currency)
+			if err != nil {
+				return nil, 0, err
+			}
+			if isIncome {
+				income++
+			} else if t.amount.Minor > 0 {
+				transactions = append(transactions, t)
+			}
+			current = nil
+		case current != nil && !closing && value != "":
+			current[tag] = html.UnescapeString(value)
+		}
+	}
+	return transactions, income, nil
+}
+
+func ofxTransaction(fields map[string]string, currency string) (bankTransaction, bool, error) {
+	amountText := fields["TRNAMT"]
+	if strings.HasPrefix(amountText, "+") || !strings.HasPrefix(amountText, "-") {
+		return bankTransaction{}, true, nil
+	}
+	amount, err := parseBankAmount(amountText, currency, false)
+	if err != nil {
+		return bankTransaction{}, false, err
+	}
+
+	// DTPOSTED looks like 20251125 or 20251125120000.000[-5:EST], the day is enough
+	posted := fields["DTPOSTED"]
+	if len(posted) < 8 {
+		return bankTransaction{}, false, fmt.Errorf("invalid DTPOSTED %q", posted)
+	}
+	date, err := time.ParseInLocation("20060102", posted[:8], time.Local)
+	if err != nil {
+		return bankTransaction{}, false, fmt.Errorf("invalid DTPOSTED %q", posted)
+	}
+
+	description := fields["NAME"]
+	if memo := fields["MEMO"]; memo != "" && memo != description {
+		if description == "" {
+			description = memo
+		} else {
+			description += " " + memo
+		}
+	}
+	return bankTransaction{date: date, amount: amount, description: description}, false, nil
+}
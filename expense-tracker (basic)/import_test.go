@@ -0,0 +1,201 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// describeTransactions writes the transactions short, like "2025-11-25 1250 USD PIZZA PLACE"
+func describeTransactions(transactions []bankTransaction) string {
+	var lines []string
+	for _, t := range transactions {
+		lines = append(lines, fmt.Sprintf("%s %d %s %s", t.date.Format(dateLayout), t.amount.Minor, t.amount.Currency, t.description))
+	}
+	return strings.Join(lines, "\n")
+}
+
+func TestReadBankCSV(t *testing.T) {
+	tests := []struct {
+		name    string
+		profile ImportProfile
+		csv     string
+		want    string
+		income  int
+	}{
+		{
+			"default profile",
+			defaultImportProfile,
+			"date,amount,description\n" +
+				"2025-11-25,-12.50,PIZZA PLACE\n" +
+				"2025-11-26,1000.00,SALARY\n" +
+				"2025-11-27,\"-1,003\",Laptop\n" +
+				"2025-11-28,-0.00,Fee waived\n",
+			"2025-11-25 1250 USD PIZZA PLACE\n2025-11-27 100300 USD Laptop",
+			1,
+		},
+		{
+			"decimal comma and header names",
+			ImportProfile{Date: "Buchungstag", DateFormat: "02.01.2006", Amount: "Betrag", Description: "Text", Delimiter: ";", DecimalComma: true, Currency: "EUR"},
+			"Buchungstag;Text;Betrag\n" +
+				"25.11.2025;REWE;-1.234,56\n" +
+				"26.11.2025;Gehalt;2.000,00\n" +
+				";Saldo;766,44\n",
+			"2025-11-25 123456 EUR REWE",
+			1,
+		},
+		{
+			"debit and credit columns",
+			ImportProfile{Date: "date", Debit: "debit", Credit: "credit", Description: "description"},
+			"date,description,debit,credit\n" +
+				"2025-11-25,Rent,950.00,\n" +
+				"2025-11-26,Refund,,20.00\n" +
+				"2025-11-27,Balance carried over,,\n" +
+				"2025-11-28,Groceries,(45.10),\n",
+			"2025-11-25 95000 USD Rent\n2025-11-28 4510 USD Groceries",
+			1,
+		},
+		{
+			"column numbers, no header, positive expenses",
+			ImportProfile{Date: "1", Description: "2", Amount: "3", NoHeader: true, ExpensesPositive: true},
+			"2025-11-25,Shop,$12.00\n" +
+				"2025-11-26,Refund,-5.00\n",
+			"2025-11-25 1200 USD Shop",
+			1,
+		},
+		{
+			"more decimals than the currency has",
+			ImportProfile{Date: "date", Amount: "amount", Description: "description", Currency: "JPY"},
+			"date,amount,description\n2025-11-25,-1200.00,Ramen\n",
+			"2025-11-25 1200 JPY Ramen",
+			0,
+		},
+		{"empty file", defaultImportProfile, "", "", 0},
+	}
+	for _, tt := range tests {
+		transactions, income, err := readBankCSV(strings.NewReader(tt.csv), tt.profile, "USD")
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if got := describeTransactions(transactions); got != tt.want || income != tt.income {
+			t.Errorf("%s: transactions\n%s\nincome %d, want\n%s\nincome %d", tt.name, got, income, tt.want, tt.income)
+		}
+	}
+}
+
+func TestReadBankCSVErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		profile ImportProfile
+		csv     string
+		want    string
+	}{
+		{"invalid date", defaultImportProfile, "date,amount,description\n2025-11-25,-1,ok\n25.11.2025,-1,bad\n", `line 3: invalid date "25.11.2025"`},
+		{"invalid amount", defaultImportProfile, "date,amount,description\n2025-11-25,-,bad\n", `line 2: invalid amount "-"`},
+		{"missing column", defaultImportProfile, "date,value,description\n", `column "amount" not found`},
+		{"no amount column", ImportProfile{Date: "date", Description: "description"}, "date,description\n", "needs a date, a description and an amount or debit column"},
+	}
+	for _, tt := range tests {
+		_, _, err := readBankCSV(strings.NewReader(tt.csv), tt.profile, "USD")
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: error %v, want %q", tt.name, err, tt.want)
+		}
+	}
+}
+
+func TestReadOFX(t *testing.T) {
+	tests := []struct {
+		name   string
+		ofx    string
+		want   string
+		income int
+	}{
+		{
+			"OFX 1.x without closing tags",
+			`OFXHEADER:100
+DATA:OFXSGML
+<OFX><BANKMSGSRSV1><STMTTRNRS><STMTRS><CURDEF>EUR
+<BANKTRANLIST>
+<STMTTRN><TRNTYPE>DEBIT<DTPOSTED>20251125120000.000[-5:EST]<TRNAMT>-12.50<NAME>PIZZA PLACE<MEMO>CARD 1234</STMTTRN>
+<STMTTRN><TRNTYPE>CREDIT<DTPOSTED>20251126<TRNAMT>1500.00<NAME>SALARY</STMTTRN>
+<STMTTRN><TRNTYPE>DEBIT<DTPOSTED>20251127<TRNAMT>-3.20<MEMO>BAKERY</STMTTRN>
+</BANKTRANLIST></STMTRS></STMTTRNRS></BANKMSGSRSV1></OFX>`,
+			"2025-11-25 1250 EUR PIZZA PLACE CARD 1234\n2025-11-27 320 EUR BAKERY",
+			1,
+		},
+		{
+			"OFX 2.x XML",
+			`<?xml version="1.0" encoding="UTF-8"?>
+<OFX><BANKMSGSRSV1><STMTTRNRS><STMTRS>
+<BANKTRANLIST>
+<STMTTRN>
+  <TRNTYPE>DEBIT</TRNTYPE>
+  <DTPOSTED>20251201</DTPOSTED>
+  <TRNAMT>-42.00</TRNAMT>
+  <NAME>FISH &amp; CHIPS</NAME>
+  <MEMO>FISH &amp; CHIPS</MEMO>
+</STMTTRN>
+<STMTTRN>
+  <TRNTYPE>DEBIT</TRNTYPE>
+  <DTPOSTED>20251202</DTPOSTED>
+  <TRNAMT>+5.00</TRNAMT>
+  <NAME>CASHBACK</NAME>
+</STMTTRN>
+</BANKTRANLIST></STMTRS></STMTTRNRS></BANKMSGSRSV1></OFX>`,
+			"2025-12-01 4200 USD FISH & CHIPS",
+			1,
+		},
+		{"no transactions", "<OFX><CURDEF>EUR</OFX>", "", 0},
+	}
+	for _, tt := range tests {
+		transactions, income, err := readOFX(strings.NewReader(tt.ofx), "USD")
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if got := describeTransactions(transactions); got != tt.want || income != tt.income {
+			t.Errorf("%s: transactions\n%s\nincome %d, want\n%s\nincome %d", tt.name, got, income, tt.want, tt.income)
+		}
+	}
+
+	_, _, err := readOFX(strings.NewReader("<STMTTRN><TRNAMT>-1.00<DTPOSTED>2025</STMTTRN>"), "USD")
+	if err == nil || !strings.Contains(err.Error(), "invalid DTPOSTED") {
+		t.Errorf("short DTPOSTED: error %v", err)
+	}
+}
+
+func TestCategorize(t *testing.T) {
+	rules := []Rule{
+		{Pattern: "pizza", Category: "dinner"},
+		{Pattern: `^(uber|lyft)\b`, Regex: true, Category: "travel"},
+		{Pattern: "bakery", Category: "breakfast"},
+	}
+	tests := []struct {
+		name        string
+		categories  []string
+		description string
+		want        string // "" when an error is expected
+	}{
+		{"keyword", defaultCategories, "PIZZA PLACE", "dinner"},
+		{"first rule wins", defaultCategories, "pizza bakery", "dinner"},
+		{"fallback", defaultCategories, "HARDWARE STORE", fallbackCategory},
+		{"regex", append([]string{"travel"}, defaultCategories...), "Uber trip", "travel"},
+		{"regex doesn't match in the middle", defaultCategories, "my uber trip", fallbackCategory},
+		{"rule for a removed category", defaultCategories, "UBER *TRIP", ""},
+		{"fallback removed", []string{"breakfast", "dinner"}, "HARDWARE STORE", ""},
+		{"fallback removed, a rule matches", []string{"breakfast", "dinner"}, "bakery", "breakfast"},
+	}
+	for _, tt := range tests {
+		got, err := categorize(rules, tt.categories, tt.description)
+		if tt.want == "" {
+			if err == nil {
+				t.Errorf("%s: categorize(%q) = %q, want an error", tt.name, tt.description, got)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("%s: categorize(%q) = %q, %v, want %q", tt.name, tt.description, got, err, tt.want)
+		}
+	}
+}
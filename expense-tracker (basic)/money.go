@@ -0,0 +1,190 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// base currency until another one is set with "currency base", see settings.go
+// the amounts of old expense.json files were in this currency too
+const defaultCurrency = "USD"
+
+// Money is a fixed-point amount, it never uses float64 so 0.1 + 0.2 is exactly 0.3
+// Minor is the amount in the smallest unit of the currency: cents for USD, yen for JPY
+type Money struct {
+	Minor    int64
+	Currency string // ISO 4217 code like "USD"
+}
+
+// currencies which don't have 2 digits after the decimal point
+var currencyDecimals = map[string]int{
+	"JPY": 0, "KRW": 0, "VND": 0, "CLP": 0, "ISK": 0, "HUF": 0,
+	"BHD": 3, "KWD": 3, "OMR": 3, "JOD": 3, "TND": 3,
+}
+
+func decimals(currency string) int {
+	if d, ok := currencyDecimals[currency]; ok {
+		return d
+	}
+	return 2
+}
+
+func pow10(n int) int64 {
+	p := int64(1)
+	for i := 0; i < n; i++ {
+		p *= 10
+	}
+	return p
+}
+
+func validCurrency(code string) bool {
+	if len(code) != 3 {
+		return false
+	}
+	for _, c := range code {
+		if c < 'A' || c > 'Z' {
+			return false
+		}
+	}
+	return true
+}
+
+// parseMoney reads amounts like "12", "12.5", "12.50 EUR" or "12.50EUR"
+// currency is used when the text has no currency code
+// negative, zero and malformed amounts are rejected
+func parseMoney(text, currency string) (Money, error) {
+	m, err := parseAmount(text, currency)
+	if err != nil {
+		return Money{}, err
+	}
+	if m.Minor <= 0 {
+		return Money{}, fmt.Errorf("amount must be greater than zero")
+	}
+	return m, nil
+}
+
+// parseAmount is parseMoney without the "greater than zero" rule, it reads back
+// what Amount wrote: zero and negative amounts like "-3.00" are fine here.
+// the rule is for what the user types, a saved file must always load again
+func parseAmount(text, currency string) (Money, error) {
+	text = strings.TrimSpace(text)
+	// split off a trailing currency code
+	if n := len(text); n > 3 {
+		code := strings.ToUpper(text[n-3:])
+		if validCurrency(code) {
+			currency = code
+			text = strings.TrimSpace(text[:n-3])
+		}
+	}
+	currency = strings.ToUpper(currency)
+	if !validCurrency(currency) {
+		return Money{}, fmt.Errorf("invalid currency %q", currency)
+	}
+
+	negative := strings.HasPrefix(text, "-")
+	if negative {
+		text = text[1:]
+	}
+	whole, fraction, hasPoint := strings.Cut(text, ".")
+	if whole == "" || (hasPoint && fraction == "") {
+		return Money{}, fmt.Errorf("invalid amount %q", text)
+	}
+	if len(fraction) > decimals(currency) {
+		return Money{}, fmt.Errorf("invalid amount %q: %s has only %d decimal places", text, currency, decimals(currency))
+	}
+	for _, part := range []string{whole, fraction} {
+		for _, c := range part {
+			if c < '0' || c > '9' {
+				return Money{}, fmt.Errorf("invalid amount %q", text)
+			}
+		}
+	}
+	// 15 digits keep us far away from the int64 limit
+	if len(whole) > 15 {
+		return Money{}, fmt.Errorf("amount %q is too large", text)
+	}
+
+	// "12.5" in USD becomes 1250 cents
+	fraction += strings.Repeat("0", decimals(currency)-len(fraction))
+	units, _ := strconv.ParseInt(whole, 10, 64)
+	minor := units * pow10(decimals(currency))
+	if fraction != "" {
+		f, _ := strconv.ParseInt(fraction, 10, 64)
+		minor += f
+	}
+	if negative {
+		minor = -minor
+	}
+	return Money{Minor: minor, Currency: currency}, nil
+}
+
+// Amount returns only the number, like "12.50"
+func (m Money) Amount() string {
+	d := decimals(m.Currency)
+	sign := ""
+	minor := m.Minor
+	if minor < 0 {
+		sign = "-"
+		minor = -minor
+	}
+	if d == 0 {
+		return sign + strconv.FormatInt(minor, 10)
+	}
+	p := pow10(d)
+	return fmt.Sprintf("%s%d.%0*d", sign, minor/p, d, minor%p)
+}
+
+// String returns the amount with its currency, like "12.50 USD"
+func (m Money) String() string {
+	return m.Amount() + " " + m.Currency
+}
+
+var errCurrencyMismatch = errors.New("can not add amounts in different currencies")
+
+func (m Money) Add(other Money) (Money, error) {
+	if m.Currency != other.Currency {
+		return Money{}, errCurrencyMismatch
+	}
+	return Money{Minor: m.Minor + other.Minor, Currency: m.Currency}, nil
+}
+
+// in expense.json money is saved as a string like "12.50 USD"
+func (m Money) MarshalJSON() ([]byte, error) {
+	return json.Marshal(m.String())
+}
+
+// UnmarshalJSON also reads the old files where the amount was a plain integer
+func (m *Money) UnmarshalJSON(data []byte) error {
+	var text string
+	if err := json.Unmarshal(data, &text); err != nil {
+		var legacy int64
+		if err := json.Unmarshal(data, &legacy); err != nil {
+			return fmt.Errorf("invalid amount %s", data)
+		}
+		*m = Money{Minor: legacy * pow10(decimals(defaultCurrency)), Currency: defaultCurrency}
+		return nil
+	}
+
+	amount, currency, _ := strings.Cut(text, " ")
+	parsed, err := parseAmount(amount, currency)
+	if err != nil {
+		return err
+	}
+	*m = parsed
+	return nil
+}
+
+// totalsByCurrency sums amounts per currency, the map has one entry per currency used
+func totalsByCurrency(amounts []Money) map[string]Money {
+	totals := make(map[string]Money)
+	for _, a := range amounts {
+		t := totals[a.Currency]
+		t.Currency = a.Currency
+		t.Minor += a.Minor
+		totals[a.Currency] = t
+	}
+	return totals
+}
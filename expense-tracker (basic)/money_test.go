@@ -0,0 +1,107 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestParseMoney(t *testing.T) {
+	tests := []struct {
+		text     string
+		currency string
+		want     Money
+		wantErr  bool
+	}{
+		{"12", "USD", Money{1200, "USD"}, false},
+		{"12.5", "USD", Money{1250, "USD"}, false},
+		{"0.01", "USD", Money{1, "USD"}, false},
+		{"12.50 EUR", "USD", Money{1250, "EUR"}, false},
+		{"12.50eur", "USD", Money{1250, "EUR"}, false},
+		{"1500", "JPY", Money{1500, "JPY"}, false},
+		{"1.234", "KWD", Money{1234, "KWD"}, false},
+		{"12.5", "JPY", Money{}, true},
+		{"1.234", "USD", Money{}, true},
+		{"0", "USD", Money{}, true},
+		{"0.00", "USD", Money{}, true},
+		{"-5", "USD", Money{}, true},
+		{"12.", "USD", Money{}, true},
+		{".5", "USD", Money{}, true},
+		{"1,5", "USD", Money{}, true},
+		{"abc", "USD", Money{}, true},
+		{"12", "usdollar", Money{}, true},
+		{"1234567890123456", "USD", Money{}, true},
+	}
+	for _, tt := range tests {
+		got, err := parseMoney(tt.text, tt.currency)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseMoney(%q, %q) error = %v, want error %v", tt.text, tt.currency, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("parseMoney(%q, %q) = %v, want %v", tt.text, tt.currency, got, tt.want)
+		}
+	}
+}
+
+func TestMoneyString(t *testing.T) {
+	tests := []struct {
+		money Money
+		want  string
+	}{
+		{Money{1250, "USD"}, "12.50 USD"},
+		{Money{5, "USD"}, "0.05 USD"},
+		{Money{0, "USD"}, "0.00 USD"},
+		{Money{-301, "USD"}, "-3.01 USD"},
+		{Money{1500, "JPY"}, "1500 JPY"},
+		{Money{1234, "KWD"}, "1.234 KWD"},
+	}
+	for _, tt := range tests {
+		if got := tt.money.String(); got != tt.want {
+			t.Errorf("%#v.String() = %q, want %q", tt.money, got, tt.want)
+		}
+	}
+}
+
+func TestMoneyJSON(t *testing.T) {
+	// everything String writes has to load again, also zero and negative amounts
+	for _, m := range []Money{{1250, "USD"}, {0, "USD"}, {-301, "EUR"}, {1500, "JPY"}} {
+		data, err := json.Marshal(m)
+		if err != nil {
+			t.Fatalf("Marshal(%v): %v", m, err)
+		}
+		var got Money
+		if err := json.Unmarshal(data, &got); err != nil {
+			t.Errorf("Unmarshal(%s): %v", data, err)
+			continue
+		}
+		if got != m {
+			t.Errorf("Unmarshal(%s) = %v, want %v", data, got, m)
+		}
+	}
+
+	// old files have whole dollars as a number
+	var legacy Money
+	if err := json.Unmarshal([]byte("20"), &legacy); err != nil {
+		t.Fatalf("Unmarshal(20): %v", err)
+	}
+	if want := (Money{2000, "USD"}); legacy != want {
+		t.Errorf("Unmarshal(20) = %v, want %v", legacy, want)
+	}
+
+	for _, bad := range []string{`"abc USD"`, `"12.50 US"`, `true`, `"1.5 JPY"`} {
+		var m Money
+		if err := json.Unmarshal([]byte(bad), &m); err == nil {
+			t.Errorf("Unmarshal(%s) = %v, want an error", bad, m)
+		}
+	}
+}
+
+func TestTotalsByCurrency(t *testing.T) {
+	totals := totalsByCurrency([]Money{{100, "USD"}, {250, "EUR"}, {1, "USD"}})
+	if len(totals) != 2 || totals["USD"] != (Money{101, "USD"}) || totals["EUR"] != (Money{250, "EUR"}) {
+		t.Errorf("totalsByCurrency() = %v", totals)
+	}
+	if got := formatTotals(totals); got != "2.50 EUR + 1.01 USD" {
+		t.Errorf("formatTotals() = %q", got)
+	}
+}
@@ -0,0 +1,322 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+// expenses keep the currency they were paid in, summaries convert them to the
+// base currency with a local table of dated exchange rates (rates.json)
+// an expense uses the newest rate from on or before its date, so old expenses
+// keep the rate of their day when new rates are added
+
+const rateFile = "rates.json"
+
+const dateLayout = "2006-01-02"
+
+// Rate says 1 From is worth Rate To on Date, the rate is a decimal string like "1.0834"
+type Rate struct {
+	Date string `json:"date"`
+	From string `json:"from"`
+	To   string `json:"to"`
+	Rate string `json:"rate"`
+}
+
+type rateTable []Rate
+
+func loadRates() (rateTable, error) {
+	data, err := os.ReadFile(rateFile)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return rateTable{}, nil
+		}
+		return nil, err
+	}
+	var rates rateTable
+	if err := json.Unmarshal(data, &rates); err != nil {
+		return nil, fmt.Errorf("%s: %w", rateFile, err)
+	}
+	// the file can be edited by hand, a rate which is not a number or 0 would
+	// break every conversion later, so the same checks as "rates add" run here
+	for i, r := range rates {
+		checked, err := newRate(r.Date, r.From, r.To, r.Rate)
+		if err != nil {
+			return nil, fmt.Errorf("%s: entry %d (%s %s/%s): %w", rateFile, i+1, r.Date, r.From, r.To, err)
+		}
+		rates[i] = checked
+	}
+	return rates, nil
+}
+
+func saveRates(rates rateTable) error {
+	// keep the file ordered by date so it is easy to read
+	sort.SliceStable(rates, func(i, j int) bool { return rates[i].Date < rates[j].Date })
+	data, err := json.MarshalIndent(rates, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(rateFile, data, 0644)
+}
+
+// newRate checks the values of a rate before it goes into the table
+func newRate(date, from, to, rate string) (Rate, error) {
+	from, to = strings.ToUpper(strings.TrimSpace(from)), strings.ToUpper(strings.TrimSpace(to))
+	date, rate = strings.TrimSpace(date), strings.TrimSpace(rate)
+	if _, err := time.Parse(dateLayout, date); err != nil {
+		return Rate{}, fmt.Errorf("invalid date %q, use 2025-11-25", date)
+	}
+	if !validCurrency(from) || !validCurrency(to) {
+		return Rate{}, fmt.Errorf("invalid currency pair %s/%s", from, to)
+	}
+	if from == to {
+		return Rate{}, fmt.Errorf("a rate needs two different currencies")
+	}
+	r, ok := new(big.Rat).SetString(rate)
+	if !ok || r.Sign() <= 0 {
+		return Rate{}, fmt.Errorf("invalid rate %q", rate)
+	}
+	return Rate{Date: date, From: from, To: to, Rate: rate}, nil
+}
+
+// add puts a rate in the table, a rate for the same day and pair is replaced
+func (rt rateTable) add(r Rate) rateTable {
+	for i := range rt {
+		if rt[i].Date == r.Date && rt[i].From == r.From && rt[i].To == r.To {
+			rt[i] = r
+			return rt
+		}
+	}
+	return append(rt, r)
+}
+
+// direct finds the rate from -> to of the day, also by inverting a to -> from rate
+func (rt rateTable) direct(from, to string, day string) (*big.Rat, bool) {
+	var best *Rate
+	inverse := false
+	for i := range rt {
+		r := &rt[i]
+		if r.Date > day {
+			continue
+		}
+		switch {
+		case r.From == from && r.To == to:
+			if best == nil || r.Date >= best.Date {
+				best, inverse = r, false
+			}
+		case r.From == to && r.To == from:
+			if best == nil || r.Date > best.Date {
+				best, inverse = r, true
+			}
+		}
+	}
+	if best == nil {
+		return nil, false
+	}
+	value, _ := new(big.Rat).SetString(best.Rate)
+	if inverse {
+		value.Inv(value)
+	}
+	return value, true
+}
+
+// rate finds how much 1 from is worth in to on a day
+// when there is no rate between the two it goes through a third currency
+func (rt rateTable) rate(from, to string, date time.Time) (*big.Rat, error) {
+	if from == to {
+		return big.NewRat(1, 1), nil
+	}
+	day := date.Format(dateLayout)
+	if r, ok := rt.direct(from, to, day); ok {
+		return r, nil
+	}
+
+	var via []string
+	for _, r := range rt {
+		for _, c := range []string{r.From, r.To} {
+			if c != from && c != to && !slices.Contains(via, c) {
+				via = append(via, c)
+			}
+		}
+	}
+	for _, c := range via {
+		first, ok1 := rt.direct(from, c, day)
+		second, ok2 := rt.direct(c, to, day)
+		if ok1 && ok2 {
+			return first.Mul(first, second), nil
+		}
+	}
+	return nil, fmt.Errorf("no %s/%s rate on or before %s", from, to, day)
+}
+
+// convert changes an amount to another currency with the rate of the given date
+// the result is rounded to the smallest unit of the new currency
+func (rt rateTable) convert(m Money, to string, date time.Time) (Money, error) {
+	if m.Currency == to {
+		return m, nil
+	}
+	r, err := rt.rate(m.Currency, to, date)
+	if err != nil {
+		return Money{}, err
+	}
+	// 12.34 EUR is 1234 cents, times the rate, then moved to the decimals of the new currency
+	value := new(big.Rat).SetInt64(m.Minor)
+	value.Mul(value, r)
+	value.Mul(value, new(big.Rat).SetFrac64(pow10(decimals(to)), pow10(decimals(m.Currency))))
+	return Money{Minor: roundRat(value), Currency: to}, nil
+}
+
+// roundRat rounds half away from zero
+func roundRat(r *big.Rat) int64 {
+	num := new(big.Int).Abs(r.Num())
+	q, rem := new(big.Int).QuoRem(num, r.Denom(), new(big.Int))
+	if rem.Mul(rem, big.NewInt(2)).Cmp(r.Denom()) >= 0 {
+		q.Add(q, big.NewInt(1))
+	}
+	if r.Sign() < 0 {
+		q.Neg(q)
+	}
+	return q.Int64()
+}
+
+// sumInBase converts the expenses to the base currency and adds them up
+// expenses without a usable rate are returned so the caller can list them
+func sumInBase(data []Expense) (Money, []Expense, error) {
+	rates, err := loadRates()
+	if err != nil {
+		return Money{}, nil, err
+	}
+	base, err := baseCurrency()
+	if err != nil {
+		return Money{}, nil, err
+	}
+	total := Money{Currency: base}
+	var missing []Expense
+	for _, e := range data {
+		converted, err := rates.convert(e.Amount, base, e.Date)
+		if err != nil {
+			missing = append(missing, e)
+			continue
+		}
+		total.Minor += converted.Minor
+	}
+	return total, missing, nil
+}
+
+// printMissingRates lists the expenses which could not be converted to base
+func printMissingRates(missing []Expense, base string) {
+	if len(missing) == 0 {
+		return
+	}
+	var amounts []Money
+	for _, e := range missing {
+		amounts = append(amounts, e.Amount)
+	}
+	fmt.Printf("\nnot converted, no exchange rate to %s: %s\n", base, formatTotals(totalsByCurrency(amounts)))
+	fmt.Println("add one with: rates add <date> <from> <to> <rate>")
+}
+
+// instance: go run . rates add 2025-11-01 EUR USD 1.0834
+//
+//	go run . rates import rates.csv
+//	go run . rates list
+//
+// the csv file has the columns date,from,to,rate, a header line is optional
+func cmdRates(args []string) {
+	if len(args) == 0 {
+		fail("usage: rates [list | add <date> <from> <to> <rate> | import <file.csv>]")
+		return
+	}
+	rates, err := loadRates()
+	if err != nil {
+		fail("error while loading the rates: ", err)
+		return
+	}
+
+	switch args[0] {
+	case "list":
+		if len(rates) == 0 {
+			fmt.Println("no exchange rates yet")
+			return
+		}
+		fmt.Println("Date		Pair		Rate")
+		for _, r := range rates {
+			fmt.Printf("%s\t%s/%s\t\t%s\n", r.Date, r.From, r.To, r.Rate)
+		}
+		return
+
+	case "add":
+		if len(args) < 5 {
+			fail("usage: rates add <date> <from> <to> <rate>")
+			return
+		}
+		r, err := newRate(args[1], args[2], args[3], args[4])
+		if err != nil {
+			fail(err)
+			return
+		}
+		rates = rates.add(r)
+		if err := saveRates(rates); err != nil {
+			fail("error while saving the rates: ", err)
+			return
+		}
+		fmt.Printf("rate %s/%s on %s saved\n", r.From, r.To, r.Date)
+
+	case "import":
+		if len(args) < 2 {
+			fail("usage: rates import <file.csv>")
+			return
+		}
+		imported, err := readRatesCSV(args[1])
+		if err != nil {
+			fail(err)
+			return
+		}
+		for _, r := range imported {
+			rates = rates.add(r)
+		}
+		if err := saveRates(rates); err != nil {
+			fail("error while saving the rates: ", err)
+			return
+		}
+		fmt.Printf("%d rates imported\n", len(imported))
+
+	default:
+		failf("unknown rates command %q\n", args[0])
+	}
+}
+
+func readRatesCSV(path string) ([]Rate, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	records, err := csv.NewReader(file).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	var rates []Rate
+	for i, record := range records {
+		if len(record) < 4 {
+			return nil, fmt.Errorf("line %d: expected date,from,to,rate", i+1)
+		}
+		if i == 0 && strings.EqualFold(strings.TrimSpace(record[0]), "date") {
+			continue
+		}
+		r, err := newRate(record[0], record[1], record[2], record[3])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", i+1, err)
+		}
+		rates = append(rates, r)
+	}
+	return rates, nil
+}
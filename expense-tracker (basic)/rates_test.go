@@ -0,0 +1,112 @@
+package main
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func day(s string) time.Time {
+	t, err := time.ParseInLocation(dateLayout, s, time.Local)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+func TestNewRate(t *testing.T) {
+	tests := []struct {
+		date, from, to, rate string
+		wantErr              bool
+	}{
+		{"2025-11-01", "eur", "usd", "1.0834", false},
+		{"2025-11-01", "EUR", "USD", "3/2", false},
+		{"2025-11-1", "EUR", "USD", "1.08", true},
+		{"2025-11-01", "EUR", "EUR", "1", true},
+		{"2025-11-01", "EURO", "USD", "1.08", true},
+		{"2025-11-01", "EUR", "USD", "0", true},
+		{"2025-11-01", "EUR", "USD", "-1.08", true},
+		{"2025-11-01", "EUR", "USD", "abc", true},
+	}
+	for _, tt := range tests {
+		_, err := newRate(tt.date, tt.from, tt.to, tt.rate)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("newRate(%q, %q, %q, %q) error = %v, want error %v", tt.date, tt.from, tt.to, tt.rate, err, tt.wantErr)
+		}
+	}
+}
+
+func TestConvert(t *testing.T) {
+	rates := rateTable{
+		{Date: "2025-01-01", From: "EUR", To: "USD", Rate: "1.10"},
+		{Date: "2025-06-01", From: "EUR", To: "USD", Rate: "1.20"},
+		{Date: "2025-01-01", From: "USD", To: "JPY", Rate: "150"},
+		{Date: "2025-01-01", From: "GBP", To: "EUR", Rate: "1.25"},
+	}
+	tests := []struct {
+		name    string
+		amount  Money
+		to      string
+		date    string
+		want    Money
+		wantErr bool
+	}{
+		{"same currency", Money{1000, "USD"}, "USD", "2024-01-01", Money{1000, "USD"}, false},
+		{"direct", Money{1000, "EUR"}, "USD", "2025-03-01", Money{1100, "USD"}, false},
+		{"newest rate on or before the day", Money{1000, "EUR"}, "USD", "2025-06-01", Money{1200, "USD"}, false},
+		{"inverted", Money{1100, "USD"}, "EUR", "2025-03-01", Money{1000, "EUR"}, false},
+		{"inverted and rounded", Money{100, "USD"}, "EUR", "2025-03-01", Money{91, "EUR"}, false},
+		{"no decimals in yen", Money{1050, "USD"}, "JPY", "2025-03-01", Money{1575, "JPY"}, false},
+		{"yen back to dollars", Money{1575, "JPY"}, "USD", "2025-03-01", Money{1050, "USD"}, false},
+		{"cross rate through EUR", Money{1000, "GBP"}, "USD", "2025-03-01", Money{1375, "USD"}, false},
+		{"cross rate through USD", Money{1000, "EUR"}, "JPY", "2025-03-01", Money{1650, "JPY"}, false},
+		{"before the first rate", Money{1000, "EUR"}, "USD", "2024-12-31", Money{}, true},
+		{"unknown currency", Money{1000, "CHF"}, "USD", "2025-03-01", Money{}, true},
+	}
+	for _, tt := range tests {
+		got, err := rates.convert(tt.amount, tt.to, day(tt.date))
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: convert(%v, %s) error = %v, want error %v", tt.name, tt.amount, tt.to, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%s: convert(%v, %s) = %v, want %v", tt.name, tt.amount, tt.to, got, tt.want)
+		}
+	}
+}
+
+func TestRateTableAddReplacesSameDay(t *testing.T) {
+	rates := rateTable{}.add(Rate{Date: "2025-01-01", From: "EUR", To: "USD", Rate: "1.10"})
+	rates = rates.add(Rate{Date: "2025-01-01", From: "EUR", To: "USD", Rate: "1.11"})
+	rates = rates.add(Rate{Date: "2025-01-02", From: "EUR", To: "USD", Rate: "1.12"})
+	if len(rates) != 2 || rates[0].Rate != "1.11" {
+		t.Errorf("add() = %v", rates)
+	}
+}
+
+func TestLoadRatesRejectsBadEntries(t *testing.T) {
+	t.Chdir(t.TempDir())
+
+	for _, bad := range []string{
+		`[{"date":"2025-01-01","from":"EUR","to":"USD","rate":"0"}]`,
+		`[{"date":"2025-01-01","from":"EUR","to":"USD","rate":"1.1"},{"date":"2025-01-02","from":"EUR","to":"USD","rate":"abc"}]`,
+		`[{"date":"01.01.2025","from":"EUR","to":"USD","rate":"1.1"}]`,
+	} {
+		if err := os.WriteFile(rateFile, []byte(bad), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := loadRates(); err == nil || !strings.Contains(err.Error(), "entry") {
+			t.Errorf("loadRates(%s) error = %v, want an error naming the entry", bad, err)
+		}
+	}
+
+	good := `[{"date":"2025-01-01","from":"eur","to":"USD","rate":"1.1"}]`
+	if err := os.WriteFile(rateFile, []byte(good), 0644); err != nil {
+		t.Fatal(err)
+	}
+	rates, err := loadRates()
+	if err != nil || len(rates) != 1 || rates[0].From != "EUR" {
+		t.Errorf("loadRates() = %v, %v", rates, err)
+	}
+}
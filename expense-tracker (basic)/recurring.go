@@ -0,0 +1,443 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// recurring expenses like rent or subscriptions, kept in recurring.json
+// every time the tracker runs the occurrences which are due are added to
+// expense.json (see materializeRecurring), so nothing has to be typed twice
+
+const recurringFile = "recurring.json"
+
+type Recurring struct {
+	Id          int    `json:"id"`
+	Category    string `json:"category"`
+	Description string `json:"description"`
+	Amount      Money  `json:"amount"`
+	Every       string `json:"every"`    // day, week, month or year
+	Interval    int    `json:"interval"` // 2 with "week" is every two weeks
+	Start       string `json:"start"`    // date of the first charge, like 2025-11-25
+	End         string `json:"end,omitempty"`
+	// how many charges were already added to expense.json
+	// the next one is always computed from Start so monthly charges on the 31st don't drift
+	Added int `json:"added"`
+}
+
+var recurringPeriods = []string{"day", "week", "month", "year"}
+
+// occurrence returns the date of the n-th charge, the first one is n = 0
+// a charge on the 31st falls on the last day of shorter months
+func (r Recurring) occurrence(n int) time.Time {
+	start, _ := time.ParseInLocation(dateLayout, r.Start, time.Local)
+	step := n * r.Interval
+	switch r.Every {
+	case "day":
+		return start.AddDate(0, 0, step)
+	case "week":
+		return start.AddDate(0, 0, 7*step)
+	case "year":
+		return addMonthsClamped(start, 12*step)
+	}
+	return addMonthsClamped(start, step)
+}
+
+func addMonthsClamped(t time.Time, months int) time.Time {
+	first := time.Date(t.Year(), t.Month()+time.Month(months), 1, 0, 0, 0, 0, t.Location())
+	lastDay := first.AddDate(0, 1, -1).Day()
+	day := t.Day()
+	if day > lastDay {
+		day = lastDay
+	}
+	return time.Date(first.Year(), first.Month(), day, 0, 0, 0, 0, t.Location())
+}
+
+// active is false once the last charge before the end date was added
+func (r Recurring) active() bool {
+	return r.End == "" || r.occurrence(r.Added).Format(dateLayout) <= r.End
+}
+
+// due reports whether the n-th charge should be in expense.json by today
+func (r Recurring) due(n int, today time.Time) bool {
+	date := r.occurrence(n)
+	if r.End != "" && date.Format(dateLayout) > r.End {
+		return false
+	}
+	return !date.After(today)
+}
+
+// monthlyCost is the average cost per month of the schedule
+func (r Recurring) monthlyCost() Money {
+	perYear := map[string]int64{"day": 365, "week": 52, "month": 12, "year": 1}[r.Every]
+	cost := r.Amount.Minor * perYear / 12 / int64(r.Interval)
+	return Money{Minor: cost, Currency: r.Amount.Currency}
+}
+
+func (r Recurring) schedule() string {
+	if r.Interval == 1 {
+		return "every " + r.Every
+	}
+	return fmt.Sprintf("every %d %ss", r.Interval, r.Every)
+}
+
+func loadRecurring() ([]Recurring, error) {
+	data, err := os.ReadFile(recurringFile)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return []Recurring{}, nil
+		}
+		return nil, err
+	}
+	var list []Recurring
+	if err := json.Unmarshal(data, &list); err != nil {
+		return nil, fmt.Errorf("%s: %w", recurringFile, err)
+	}
+	return list, nil
+}
+
+func saveRecurring(list []Recurring) error {
+	data, err := json.MarshalIndent(list, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(recurringFile, data, 0644)
+}
+
+// materializeRecurring adds the charges which became due since the last run
+// main calls it before every command, the notice goes to stderr
+func materializeRecurring() {
+	list, err := loadRecurring()
+	if err != nil {
+		fail("error while loading the recurring expenses: ", err)
+		return
+	}
+	today := time.Now()
+	pending := false
+	for _, r := range list {
+		pending = pending || r.due(r.Added, today)
+	}
+	if !pending {
+		return
+	}
+
+	expenses, err := loadExpenses()
+	if err != nil {
+		fail("error while loading the expenses: ", err)
+		return
+	}
+	due := dueCharges(list, expenses, today)
+	for _, e := range due {
+		e.Id = getNextId(expenses)
+		expenses = append(expenses, e)
+	}
+	// expense.json is saved first: if the tracker stops before recurring.json is saved,
+	// the next run finds the charges by their key and doesn't add them again
+	if len(due) > 0 {
+		if err := saveExpenses(expenses); err != nil {
+			fail("error while saving the file: ", err)
+			return
+		}
+	}
+	if err := saveRecurring(list); err != nil {
+		fail("error while saving the recurring expenses: ", err)
+		return
+	}
+	if len(due) > 0 {
+		// stderr, so the output of a command like "report --format csv" stays clean
+		fmt.Fprintf(os.Stderr, "%d recurring expenses added\n", len(due))
+	}
+}
+
+// dueCharges returns the charges which are due by today and advances Added of the list
+// a charge is known by its recurring id and date, the ones already in expenses are skipped
+func dueCharges(list []Recurring, expenses []Expense, today time.Time) []Expense {
+	type key struct {
+		id   int
+		date string
+	}
+	added := map[key]bool{}
+	for _, e := range expenses {
+		if e.RecurringId != 0 {
+			added[key{e.RecurringId, e.Date.Format(dateLayout)}] = true
+		}
+	}
+
+	var due []Expense
+	for i := range list {
+		for list[i].due(list[i].Added, today) {
+			date := list[i].occurrence(list[i].Added)
+			list[i].Added++
+			if added[key{list[i].Id, date.Format(dateLayout)}] {
+				continue
+			}
+			due = append(due, Expense{
+				Date:        date,
+				Category:    list[i].Category,
+				Description: list[i].Description,
+				Amount:      list[i].Amount,
+				RecurringId: list[i].Id,
+			})
+		}
+	}
+	return due
+}
+
+// instance: go run . recurring add --start 2025-11-01 rent 950 month flat rent
+//
+//	go run . recurring add --every 2 sport 15EUR week climbing
+//	go run . recurring list
+//	go run . recurring upcoming 30
+//	go run . recurring cost
+//	go run . recurring stop 2
+//	go run . recurring remove 2
+func cmdRecurring(args []string) {
+	if len(args) == 0 {
+		fail("usage: recurring [list | add [--start date] [--end date] [--every n] <category> <amount> <day|week|month|year> [description] | upcoming [days] | cost | stop <id> | remove <id>]")
+		return
+	}
+	list, err := loadRecurring()
+	if err != nil {
+		fail("error while loading the recurring expenses: ", err)
+		return
+	}
+	today := time.Now()
+
+	switch args[0] {
+	case "list":
+		if len(list) == 0 {
+			fmt.Println("no recurring expenses yet")
+			return
+		}
+		fmt.Println("ID	Category	Amount		Schedule		Next		Desc")
+		for _, r := range list {
+			next := "ended"
+			if r.active() {
+				next = r.occurrence(r.Added).Format(dateLayout)
+			}
+			fmt.Printf("%d \t%s \t%s \t%s \t%s \t%s\n", r.Id, r.Category, r.Amount, r.schedule(), next, r.Description)
+		}
+		return
+
+	case "add":
+		r, err := parseRecurring(args[1:])
+		if err != nil {
+			fail(err)
+			return
+		}
+		r.Id = 1
+		for _, other := range list {
+			if other.Id >= r.Id {
+				r.Id = other.Id + 1
+			}
+		}
+		list = append(list, r)
+		if err := saveRecurring(list); err != nil {
+			fail("error while saving the recurring expenses: ", err)
+			return
+		}
+		fmt.Printf("recurring expense %d added, first charge on %s\n", r.Id, r.Start)
+		// a start date in the past adds the charges up to today right away
+		materializeRecurring()
+		return
+
+	case "upcoming":
+		days := 30
+		if len(args) > 1 {
+			days, err = strconv.Atoi(args[1])
+			if err != nil || days < 1 {
+				fail("invalid number of days ")
+				return
+			}
+		}
+		printUpcoming(list, today, today.AddDate(0, 0, days))
+		return
+
+	case "cost":
+		printMonthlyCost(list, today)
+		return
+
+	case "stop", "remove":
+		if len(args) < 2 {
+			failf("usage: recurring %s <id>\n", args[0])
+			return
+		}
+		id, err := strconv.Atoi(args[1])
+		if err != nil {
+			failf("invalid id %q\n", args[1])
+			return
+		}
+		index := -1
+		for i := range list {
+			if list[i].Id == id {
+				index = i
+			}
+		}
+		if index < 0 {
+			failf("no recurring expense with id %d\n", id)
+			return
+		}
+		if args[0] == "stop" {
+			// charges already added stay in expense.json
+			list[index].End = today.Format(dateLayout)
+		} else {
+			list = append(list[:index], list[index+1:]...)
+		}
+
+	default:
+		failf("unknown recurring command %q\n", args[0])
+		return
+	}
+
+	if err := saveRecurring(list); err != nil {
+		fail("error while saving the recurring expenses: ", err)
+		return
+	}
+	fmt.Println("recurring expenses updated successfully ")
+}
+
+func parseRecurring(args []string) (Recurring, error) {
+	// the flags are in the commands table, so "help recurring" and the completions know them
+	c, _ := findCommand("recurring")
+	opts, args, err := parseFlags("recurring add", c.flags, args)
+	if err != nil {
+		return Recurring{}, err
+	}
+	start, end := opts["start"], opts["end"]
+	every, err := strconv.Atoi(opts["every"])
+	if err != nil {
+		return Recurring{}, fmt.Errorf("invalid --every %q", opts["every"])
+	}
+	if len(args) < 3 {
+		return Recurring{}, errors.New("usage: recurring add [--start date] [--end date] [--every n] <category> <amount> <day|week|month|year> [description]")
+	}
+
+	categories, err := loadCategories()
+	if err != nil {
+		return Recurring{}, fmt.Errorf("error while loading the categories: %w", err)
+	}
+	category := normalizeCategory(args[0])
+	if !hasCategory(categories, category) {
+		return Recurring{}, fmt.Errorf("unknown category %q, add it first with: category add %s", category, category)
+	}
+	base, err := baseCurrency()
+	if err != nil {
+		return Recurring{}, err
+	}
+	amount, err := parseMoney(args[1], base)
+	if err != nil {
+		return Recurring{}, err
+	}
+	period := strings.TrimSuffix(strings.TrimSuffix(strings.ToLower(args[2]), "ly"), "s")
+	if period == "dai" {
+		period = "day"
+	}
+	valid := false
+	for _, p := range recurringPeriods {
+		valid = valid || p == period
+	}
+	if !valid {
+		return Recurring{}, fmt.Errorf("invalid period %q, use day, week, month or year", args[2])
+	}
+	if every < 1 {
+		return Recurring{}, errors.New("--every must be 1 or more")
+	}
+	if _, err := time.Parse(dateLayout, start); err != nil {
+		return Recurring{}, fmt.Errorf("invalid --start date %q, use 2025-11-25", start)
+	}
+	if end != "" {
+		if _, err := time.Parse(dateLayout, end); err != nil {
+			return Recurring{}, fmt.Errorf("invalid --end date %q, use 2025-11-25", end)
+		}
+		if end < start {
+			return Recurring{}, errors.New("--end is before --start")
+		}
+	}
+
+	return Recurring{
+		Category:    category,
+		Description: strings.Join(args[3:], " "),
+		Amount:      amount,
+		Every:       period,
+		Interval:    every,
+		Start:       start,
+		End:         end,
+	}, nil
+}
+
+// printUpcoming lists the charges between today and until, soonest first
+func printUpcoming(list []Recurring, today, until time.Time) {
+	type charge struct {
+		date time.Time
+		r    Recurring
+	}
+	var charges []charge
+	for _, r := range list {
+		for n := r.Added; r.due(n, until); n++ {
+			charges = append(charges, charge{r.occurrence(n), r})
+		}
+	}
+	if len(charges) == 0 {
+		fmt.Printf("no charges until %s\n", until.Format(dateLayout))
+		return
+	}
+	sort.SliceStable(charges, func(i, j int) bool { return charges[i].date.Before(charges[j].date) })
+
+	var amounts []Money
+	fmt.Println("Date		Category	Amount		Desc")
+	for _, c := range charges {
+		fmt.Printf("%s \t%s \t%s \t%s\n", c.date.Format(dateLayout), c.r.Category, c.r.Amount, c.r.Description)
+		amounts = append(amounts, c.r.Amount)
+	}
+	fmt.Printf("\ntotal until %s: %s\n", until.Format(dateLayout), formatTotals(totalsByCurrency(amounts)))
+}
+
+// printMonthlyCost sums the average monthly cost of the active schedules in the base currency
+func printMonthlyCost(list []Recurring, today time.Time) {
+	rates, err := loadRates()
+	if err != nil {
+		fail("error while loading the rates: ", err)
+		return
+	}
+	base, err := baseCurrency()
+	if err != nil {
+		fail(err)
+		return
+	}
+	total := Money{Currency: base}
+	var missing []Expense
+	count := 0
+	for _, r := range list {
+		if !r.active() {
+			continue
+		}
+		count++
+		cost := r.monthlyCost()
+		fmt.Printf("%-20s %14s per month (%s %s)\n", describeRecurring(r), cost, r.Amount, r.schedule())
+		converted, err := rates.convert(cost, base, today)
+		if err != nil {
+			missing = append(missing, Expense{Amount: cost})
+			continue
+		}
+		total.Minor += converted.Minor
+	}
+	if count == 0 {
+		fmt.Println("no active recurring expenses")
+		return
+	}
+	fmt.Printf("\nmonthly cost of %d recurring expenses: %s\n", count, total)
+	printMissingRates(missing, base)
+}
+
+func describeRecurring(r Recurring) string {
+	if r.Description != "" {
+		return r.Description
+	}
+	return r.Category
+}
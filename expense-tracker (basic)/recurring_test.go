@@ -0,0 +1,135 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestAddMonthsClamped(t *testing.T) {
+	tests := []struct {
+		start  string
+		months int
+		want   string
+	}{
+		{"2025-01-15", 1, "2025-02-15"},
+		{"2025-01-31", 1, "2025-02-28"},
+		{"2024-01-31", 1, "2024-02-29"},
+		{"2025-01-31", 2, "2025-03-31"},
+		{"2025-03-31", 1, "2025-04-30"},
+		{"2025-12-31", 2, "2026-02-28"},
+		{"2024-02-29", 12, "2025-02-28"},
+		{"2024-02-29", 48, "2028-02-29"},
+		{"2025-05-31", -1, "2025-04-30"},
+	}
+	for _, tt := range tests {
+		got := addMonthsClamped(day(tt.start), tt.months).Format(dateLayout)
+		if got != tt.want {
+			t.Errorf("addMonthsClamped(%s, %d) = %s, want %s", tt.start, tt.months, got, tt.want)
+		}
+	}
+}
+
+func TestOccurrence(t *testing.T) {
+	tests := []struct {
+		every    string
+		interval int
+		start    string
+		n        int
+		want     string
+	}{
+		{"day", 1, "2025-01-30", 3, "2025-02-02"},
+		{"week", 2, "2025-01-01", 2, "2025-01-29"},
+		// the n-th charge is computed from the start, so the 31st doesn't drift to the 28th
+		{"month", 1, "2025-01-31", 1, "2025-02-28"},
+		{"month", 1, "2025-01-31", 2, "2025-03-31"},
+		{"month", 3, "2025-11-30", 1, "2026-02-28"},
+		{"year", 1, "2024-02-29", 1, "2025-02-28"},
+		{"year", 1, "2024-02-29", 4, "2028-02-29"},
+	}
+	for _, tt := range tests {
+		r := Recurring{Every: tt.every, Interval: tt.interval, Start: tt.start}
+		if got := r.occurrence(tt.n).Format(dateLayout); got != tt.want {
+			t.Errorf("occurrence(%d) every %d %s from %s = %s, want %s", tt.n, tt.interval, tt.every, tt.start, got, tt.want)
+		}
+	}
+}
+
+func TestRecurringDueAndActive(t *testing.T) {
+	r := Recurring{Every: "month", Interval: 1, Start: "2025-01-31", End: "2025-03-31"}
+	today := day("2025-03-15")
+	for n, want := range []bool{true, true, false, false} {
+		if got := r.due(n, today); got != want {
+			t.Errorf("due(%d) on %s = %v, want %v", n, today.Format(dateLayout), got, want)
+		}
+	}
+	// charge 3 would be on 2025-04-30, after the end
+	if got := r.due(3, day("2025-05-01")); got {
+		t.Error("due(3) after the end date = true, want false")
+	}
+
+	r.Added = 3
+	if r.active() {
+		t.Error("active() = true after the last charge before the end date")
+	}
+	r.Added = 2
+	if !r.active() {
+		t.Error("active() = false while the 2025-03-31 charge is still to come")
+	}
+}
+
+func TestMonthlyCost(t *testing.T) {
+	tests := []struct {
+		every    string
+		interval int
+		amount   int64
+		want     int64
+	}{
+		{"month", 1, 1000, 1000},
+		{"month", 3, 3000, 1000},
+		{"year", 1, 12000, 1000},
+		{"week", 1, 1200, 5200},
+		{"day", 1, 100, 3041},
+	}
+	for _, tt := range tests {
+		r := Recurring{Every: tt.every, Interval: tt.interval, Amount: Money{tt.amount, "USD"}}
+		if got := r.monthlyCost(); got.Minor != tt.want {
+			t.Errorf("monthlyCost() of %d every %d %s = %d, want %d", tt.amount, tt.interval, tt.every, got.Minor, tt.want)
+		}
+	}
+}
+
+func TestDueCharges(t *testing.T) {
+	list := []Recurring{
+		{Id: 1, Category: "housing", Amount: Money{95000, "EUR"}, Every: "month", Interval: 1, Start: "2025-01-31"},
+		{Id: 2, Category: "sport", Amount: Money{1500, "EUR"}, Every: "week", Interval: 2, Start: "2025-03-01", Added: 1},
+		{Id: 3, Category: "other", Amount: Money{500, "EUR"}, Every: "day", Interval: 1, Start: "2025-04-01"},
+	}
+	// a run stopped after saving expense.json: the February rent is there, but Added still says 0
+	expenses := []Expense{
+		{Id: 1, Date: day("2025-02-28"), Category: "housing", Amount: Money{95000, "EUR"}, RecurringId: 1},
+		// the same date from another schedule or typed by hand doesn't count
+		{Id: 2, Date: day("2025-01-31"), Category: "housing", Amount: Money{95000, "EUR"}},
+		{Id: 3, Date: day("2025-03-15"), Category: "sport", Amount: Money{1500, "EUR"}, RecurringId: 1},
+	}
+
+	due := dueCharges(list, expenses, day("2025-03-31"))
+	var got []string
+	for _, e := range due {
+		got = append(got, fmt.Sprintf("%d %s", e.RecurringId, e.Date.Format(dateLayout)))
+	}
+	want := []string{"1 2025-01-31", "1 2025-03-31", "2 2025-03-15", "2 2025-03-29"}
+	if strings.Join(got, ", ") != strings.Join(want, ", ") {
+		t.Errorf("due charges %v, want %v", got, want)
+	}
+	for i, added := range []int{3, 3, 0} {
+		if list[i].Added != added {
+			t.Errorf("recurring %d: added %d, want %d", list[i].Id, list[i].Added, added)
+		}
+	}
+
+	// the next run has nothing to add
+	if again := dueCharges(list, append(expenses, due...), day("2025-03-31")); len(again) != 0 {
+		t.Errorf("second run added %d charges", len(again))
+	}
+}
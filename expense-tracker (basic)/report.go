@@ -0,0 +1,371 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// "report" sums the expenses of a date range by day, week, month, year or category
+// every group is compared with the one before it (for categories: the same
+// category in the period of the same length right before the range)
+//
+// instance: go run . report --from 2025-01-01 --to 2025-12-31 --by month
+//
+//	go run . report --by category --format csv > report.csv
+
+// widest bar of the chart, in characters
+const barWidth = 40
+
+type reportGroup struct {
+	Name     string
+	Total    Money
+	Previous Money // the group before, or the same category in the previous period
+	HasPrev  bool
+}
+
+type report struct {
+	From, To      time.Time
+	GroupBy       string
+	Currency      string
+	Total         Money
+	PreviousTotal Money
+	Groups        []reportGroup
+	Missing       []Expense // expenses without an exchange rate to the base currency
+}
+
+func cmdReport(opts options) {
+	start, err := parseDay("from", opts["from"])
+	if err != nil {
+		fail(err)
+		return
+	}
+	end, err := parseDay("to", opts["to"])
+	if err != nil {
+		fail(err)
+		return
+	}
+	if end.Before(start) {
+		fail("--to is before --from")
+		return
+	}
+	switch opts["by"] {
+	case "day", "week", "month", "year", "category":
+	default:
+		fail("--by must be day, week, month, year or category")
+		return
+	}
+
+	data, err := loadExpenses()
+	if err != nil {
+		fail("error while loading the expenses: ", err)
+		return
+	}
+	// the range is checked by buildReport, it also needs the period before it
+	data, err = filterExpenses(data, options{"category": opts["category"]})
+	if err != nil {
+		fail(err)
+		return
+	}
+	rates, err := loadRates()
+	if err != nil {
+		fail("error while loading the rates: ", err)
+		return
+	}
+
+	base, err := baseCurrency()
+	if err != nil {
+		fail(err)
+		return
+	}
+
+	r := buildReport(data, rates, base, start, end, opts["by"])
+	switch opts["format"] {
+	case "table":
+		printReport(r)
+	case "csv":
+		err = writeReportCSV(r)
+	case "json":
+		err = writeReportJSON(r)
+	default:
+		fail("--format must be table, csv or json")
+		return
+	}
+	if err != nil {
+		fail("error while writing the report: ", err)
+	}
+}
+
+// groupKey is the name of the group an expense date falls into
+func groupKey(t time.Time, by string) string {
+	switch by {
+	case "day":
+		return t.Format(dateLayout)
+	case "week":
+		year, week := t.ISOWeek()
+		return fmt.Sprintf("%d-W%02d", year, week)
+	case "year":
+		return t.Format("2006")
+	}
+	return t.Format(monthLayout)
+}
+
+// periodKeys lists every group between start and end, so empty days or months show up too
+func periodKeys(start, end time.Time, by string) []string {
+	var keys []string
+	for t := start; !t.After(end); t = t.AddDate(0, 0, 1) {
+		key := groupKey(t, by)
+		if len(keys) == 0 || keys[len(keys)-1] != key {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+func buildReport(data []Expense, rates rateTable, base string, start, end time.Time, by string) report {
+	r := report{From: start, To: end, GroupBy: by, Currency: base,
+		Total: Money{Currency: base}, PreviousTotal: Money{Currency: base}}
+
+	// the previous period has the same number of days and ends the day before start
+	days := int(end.Sub(start).Hours()/24+0.5) + 1
+	prevStart := start.AddDate(0, 0, -days)
+	endOfDay := end.AddDate(0, 0, 1)
+
+	current := make(map[string]int64)
+	previous := make(map[string]int64)
+	for _, e := range data {
+		inRange := !e.Date.Before(start) && e.Date.Before(endOfDay)
+		inPrevious := !e.Date.Before(prevStart) && e.Date.Before(start)
+		if !inRange && !inPrevious {
+			continue
+		}
+		converted, err := rates.convert(e.Amount, base, e.Date)
+		if err != nil {
+			if inRange {
+				r.Missing = append(r.Missing, e)
+			}
+			continue
+		}
+
+		key := e.Category
+		if by != "category" {
+			key = groupKey(e.Date, by)
+		}
+		if inRange {
+			current[key] += converted.Minor
+			r.Total.Minor += converted.Minor
+		} else {
+			previous[key] += converted.Minor
+			r.PreviousTotal.Minor += converted.Minor
+		}
+	}
+
+	if by == "category" {
+		// a category spent on only in the previous period shows up too, as a drop to 0
+		names := make([]string, 0, len(current))
+		for name := range current {
+			names = append(names, name)
+		}
+		for name := range previous {
+			if _, ok := current[name]; !ok {
+				names = append(names, name)
+			}
+		}
+		// biggest spending first, then the biggest drop, then by name so the order is stable
+		sort.Slice(names, func(i, j int) bool {
+			a, b := names[i], names[j]
+			if current[a] != current[b] {
+				return current[a] > current[b]
+			}
+			if previous[a] != previous[b] {
+				return previous[a] > previous[b]
+			}
+			return a < b
+		})
+		for _, name := range names {
+			r.Groups = append(r.Groups, reportGroup{
+				Name:     name,
+				Total:    Money{Minor: current[name], Currency: base},
+				Previous: Money{Minor: previous[name], Currency: base},
+				HasPrev:  true,
+			})
+		}
+		return r
+	}
+
+	keys := periodKeys(start, end, by)
+	for i, key := range keys {
+		g := reportGroup{Name: key, Total: Money{Minor: current[key], Currency: base}}
+		if i > 0 {
+			g.Previous = Money{Minor: current[keys[i-1]], Currency: base}
+			g.HasPrev = true
+		} else {
+			// the first group is compared with the last group of the previous period
+			prevKeys := periodKeys(prevStart, start.AddDate(0, 0, -1), by)
+			if len(prevKeys) > 0 {
+				last := prevKeys[len(prevKeys)-1]
+				g.Previous = Money{Minor: previous[last], Currency: base}
+				g.HasPrev = true
+			}
+		}
+		r.Groups = append(r.Groups, g)
+	}
+	return r
+}
+
+// change returns the change in percent, ok is false when there is nothing to compare with
+func change(current, previous Money) (float64, bool) {
+	if previous.Minor == 0 {
+		return 0, false
+	}
+	return float64(current.Minor-previous.Minor) * 100 / float64(previous.Minor), true
+}
+
+func formatChange(current, previous Money, hasPrev bool) string {
+	if !hasPrev {
+		return ""
+	}
+	p, ok := change(current, previous)
+	if !ok {
+		if current.Minor == 0 {
+			return "0%"
+		}
+		return "new"
+	}
+	return fmt.Sprintf("%+.1f%%", p)
+}
+
+func printReport(r report) {
+	fmt.Printf("Expenses from %s to %s by %s, in %s\n\n", r.From.Format(dateLayout), r.To.Format(dateLayout), r.GroupBy, r.Currency)
+
+	var max int64
+	nameWidth := len(r.GroupBy)
+	for _, g := range r.Groups {
+		if g.Total.Minor > max {
+			max = g.Total.Minor
+		}
+		if len(g.Name) > nameWidth {
+			nameWidth = len(g.Name)
+		}
+	}
+
+	fmt.Printf("%-*s  %14s  %8s\n", nameWidth, r.GroupBy, "total", "change")
+	for _, g := range r.Groups {
+		fmt.Printf("%-*s  %14s  %8s  %s\n", nameWidth, g.Name, g.Total.Amount(),
+			formatChange(g.Total, g.Previous, g.HasPrev), bar(g.Total.Minor, max, barWidth))
+	}
+
+	fmt.Println()
+	fmt.Printf("total: %s (previous period: %s, %s)\n", r.Total, r.PreviousTotal,
+		formatChange(r.Total, r.PreviousTotal, true))
+	if r.GroupBy != "category" && len(r.Groups) > 1 {
+		values := make([]int64, len(r.Groups))
+		for i, g := range r.Groups {
+			values[i] = g.Total.Minor
+		}
+		fmt.Printf("trend: %s\n", sparkline(values))
+	}
+	printMissingRates(r.Missing, r.Currency)
+}
+
+// bar draws a horizontal bar, eighth blocks make the end of the bar smooth
+func bar(value, max int64, width int) string {
+	if max <= 0 || value <= 0 {
+		return ""
+	}
+	eighths := int(value * int64(width) * 8 / max)
+	if eighths == 0 {
+		eighths = 1
+	}
+	partial := []string{"", "▏", "▎", "▍", "▌", "▋", "▊", "▉"}
+	return strings.Repeat("█", eighths/8) + partial[eighths%8]
+}
+
+// sparkline draws one small bar per value, from ▁ for the lowest to █ for the highest
+func sparkline(values []int64) string {
+	levels := []rune("▁▂▃▄▅▆▇█")
+	var min, max int64
+	for i, v := range values {
+		if i == 0 || v < min {
+			min = v
+		}
+		if v > max {
+			max = v
+		}
+	}
+	var sb strings.Builder
+	for _, v := range values {
+		level := 0
+		if max > min {
+			level = int((v - min) * int64(len(levels)-1) / (max - min))
+		}
+		sb.WriteRune(levels[level])
+	}
+	return sb.String()
+}
+
+func writeReportCSV(r report) error {
+	w := csv.NewWriter(os.Stdout)
+	w.Write([]string{r.GroupBy, "total", "previous", "change_percent", "currency"})
+	for _, g := range r.Groups {
+		changePercent := ""
+		if p, ok := change(g.Total, g.Previous); ok && g.HasPrev {
+			changePercent = strconv.FormatFloat(p, 'f', 1, 64)
+		}
+		previous := ""
+		if g.HasPrev {
+			previous = g.Previous.Amount()
+		}
+		w.Write([]string{g.Name, g.Total.Amount(), previous, changePercent, r.Currency})
+	}
+	w.Flush()
+	return w.Error()
+}
+
+func writeReportJSON(r report) error {
+	type jsonGroup struct {
+		Name          string   `json:"name"`
+		Total         string   `json:"total"`
+		Previous      *string  `json:"previous,omitempty"`
+		ChangePercent *float64 `json:"changePercent,omitempty"`
+	}
+	out := struct {
+		From          string      `json:"from"`
+		To            string      `json:"to"`
+		GroupBy       string      `json:"groupBy"`
+		Currency      string      `json:"currency"`
+		Total         string      `json:"total"`
+		PreviousTotal string      `json:"previousTotal"`
+		Groups        []jsonGroup `json:"groups"`
+		NotConverted  int         `json:"notConverted"`
+	}{
+		From:          r.From.Format(dateLayout),
+		To:            r.To.Format(dateLayout),
+		GroupBy:       r.GroupBy,
+		Currency:      r.Currency,
+		Total:         r.Total.Amount(),
+		PreviousTotal: r.PreviousTotal.Amount(),
+		Groups:        []jsonGroup{},
+		NotConverted:  len(r.Missing),
+	}
+	for _, g := range r.Groups {
+		jg := jsonGroup{Name: g.Name, Total: g.Total.Amount()}
+		if g.HasPrev {
+			previous := g.Previous.Amount()
+			jg.Previous = &previous
+			if p, ok := change(g.Total, g.Previous); ok {
+				jg.ChangePercent = &p
+			}
+		}
+		out.Groups = append(out.Groups, jg)
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(out)
+}
@@ -0,0 +1,47 @@
+package main
+
+import "testing"
+
+func TestBuildReportByCategory(t *testing.T) {
+	expense := func(date, category string, minor int64) Expense {
+		return Expense{Date: day(date), Category: category, Amount: Money{minor, "USD"}}
+	}
+	// the report covers November, the previous period is the 30 days before
+	data := []Expense{
+		expense("2025-11-03", "lunch", 1200),
+		expense("2025-11-20", "lunch", 800),
+		expense("2025-11-21", "dinner", 3000),
+		expense("2025-10-15", "lunch", 1000),
+		expense("2025-10-20", "travel", 5000), // nothing in November
+		expense("2025-10-25", "other", 700),   // nothing in November
+		expense("2025-09-30", "travel", 9999), // before the previous period
+		expense("2025-12-01", "dinner", 9999), // after the range
+	}
+
+	r := buildReport(data, nil, "USD", day("2025-11-01"), day("2025-11-30"), "category")
+	want := []struct {
+		name            string
+		total, previous int64
+		change          string
+	}{
+		{"dinner", 3000, 0, "new"},
+		{"lunch", 2000, 1000, "+100.0%"},
+		{"travel", 0, 5000, "-100.0%"},
+		{"other", 0, 700, "-100.0%"},
+	}
+	if len(r.Groups) != len(want) {
+		t.Fatalf("groups %+v, want %d", r.Groups, len(want))
+	}
+	for i, w := range want {
+		g := r.Groups[i]
+		if g.Name != w.name || g.Total.Minor != w.total || g.Previous.Minor != w.previous || !g.HasPrev {
+			t.Errorf("group %d = %+v, want %s %d (previous %d)", i, g, w.name, w.total, w.previous)
+		}
+		if got := formatChange(g.Total, g.Previous, g.HasPrev); got != w.change {
+			t.Errorf("%s: change %q, want %q", w.name, got, w.change)
+		}
+	}
+	if r.Total.Minor != 5000 || r.PreviousTotal.Minor != 6700 {
+		t.Errorf("totals %s and %s, want 50.00 and 67.00", r.Total, r.PreviousTotal)
+	}
+}
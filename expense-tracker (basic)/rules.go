@@ -0,0 +1,162 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// rules give a category to imported bank transactions by their description
+// they are tried in order and the first match wins, a rule matches by keyword
+// (case insensitive "contains") or by regular expression. kept in rules.json
+
+const ruleFile = "rules.json"
+
+type Rule struct {
+	Pattern  string `json:"pattern"`
+	Regex    bool   `json:"regex,omitempty"`
+	Category string `json:"category"`
+
+	compiled *regexp.Regexp
+}
+
+func (r *Rule) matches(description string) bool {
+	if !r.Regex {
+		return strings.Contains(strings.ToLower(description), strings.ToLower(r.Pattern))
+	}
+	if r.compiled == nil {
+		// case insensitive unless the pattern says otherwise
+		r.compiled = regexp.MustCompile("(?i)" + r.Pattern)
+	}
+	return r.compiled.MatchString(description)
+}
+
+func loadRules() ([]Rule, error) {
+	data, err := os.ReadFile(ruleFile)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return []Rule{}, nil
+		}
+		return nil, err
+	}
+	var rules []Rule
+	if err := json.Unmarshal(data, &rules); err != nil {
+		return nil, fmt.Errorf("%s: %w", ruleFile, err)
+	}
+	for _, r := range rules {
+		if r.Regex {
+			if _, err := regexp.Compile("(?i)" + r.Pattern); err != nil {
+				return nil, fmt.Errorf("%s: rule %q: %w", ruleFile, r.Pattern, err)
+			}
+		}
+	}
+	return rules, nil
+}
+
+func saveRules(rules []Rule) error {
+	data, err := json.MarshalIndent(rules, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(ruleFile, data, 0644)
+}
+
+// categorize returns the category of the first rule that matches, or the fallback category
+// categories can be removed after a rule was added, so both are checked against the list
+func categorize(rules []Rule, categories []string, description string) (string, error) {
+	for i := range rules {
+		if rules[i].matches(description) {
+			if !hasCategory(categories, rules[i].Category) {
+				return "", fmt.Errorf("rule %q is for the unknown category %q, add it with: category add %s", rules[i].Pattern, rules[i].Category, rules[i].Category)
+			}
+			return rules[i].Category, nil
+		}
+	}
+	if !hasCategory(categories, fallbackCategory) {
+		return "", fmt.Errorf("no rule matches %q and there is no category %q, add it with: category add %s", description, fallbackCategory, fallbackCategory)
+	}
+	return fallbackCategory, nil
+}
+
+// instance: go run . rule add lunch pizza
+//
+//	go run . rule add-regex travel "^(uber|lyft)\b"
+//	go run . rule list
+//	go run . rule remove 2
+func cmdRule(args []string) {
+	if len(args) == 0 {
+		fail("usage: rule [list | add <category> <keyword> | add-regex <category> <regex> | remove <number>]")
+		return
+	}
+	rules, err := loadRules()
+	if err != nil {
+		fail("error while loading the rules: ", err)
+		return
+	}
+
+	switch args[0] {
+	case "list":
+		if len(rules) == 0 {
+			fmt.Println("no rules yet")
+			return
+		}
+		for i, r := range rules {
+			kind := "keyword"
+			if r.Regex {
+				kind = "regex"
+			}
+			fmt.Printf("%d \t%s \t%q \t-> %s\n", i+1, kind, r.Pattern, r.Category)
+		}
+		return
+
+	case "add", "add-regex":
+		if len(args) < 3 {
+			failf("usage: rule %s <category> <pattern>\n", args[0])
+			return
+		}
+		categories, err := loadCategories()
+		if err != nil {
+			fail("error while loading the categories: ", err)
+			return
+		}
+		category := normalizeCategory(args[1])
+		if !hasCategory(categories, category) {
+			failf("unknown category %q, add it first with: category add %s\n", category, category)
+			return
+		}
+		rule := Rule{Pattern: strings.Join(args[2:], " "), Regex: args[0] == "add-regex", Category: category}
+		if rule.Regex {
+			if _, err := regexp.Compile("(?i)" + rule.Pattern); err != nil {
+				fail("invalid regular expression: ", err)
+				return
+			}
+		}
+		rules = append(rules, rule)
+
+	case "remove":
+		if len(args) < 2 {
+			fail("usage: rule remove <number>")
+			return
+		}
+		n, err := strconv.Atoi(args[1])
+		if err != nil || n < 1 || n > len(rules) {
+			failf("no rule number %s, see: rule list\n", args[1])
+			return
+		}
+		rules = append(rules[:n-1], rules[n:]...)
+
+	default:
+		failf("unknown rule command %q\n", args[0])
+		return
+	}
+
+	if err := saveRules(rules); err != nil {
+		fail("error while saving the rules: ", err)
+		return
+	}
+	fmt.Println("rules updated successfully ")
+}
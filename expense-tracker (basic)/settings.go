@@ -0,0 +1,96 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// settings.json keeps the choices which are not expenses, for now only the base currency
+const settingsFile = "settings.json"
+
+type Settings struct {
+	// summaries and budgets are shown in this currency, and amounts typed
+	// without a currency are in it too
+	BaseCurrency string `json:"baseCurrency"`
+	// where the expenses are kept: "json" (the default) or "sqlite", see storage.go
+	Storage string `json:"storage,omitempty"`
+	// the sqlite database file, expenses.db when empty
+	Database string `json:"database,omitempty"`
+}
+
+func (s Settings) sqlitePath() string {
+	if s.Database != "" {
+		return s.Database
+	}
+	return "expenses.db"
+}
+
+func loadSettings() (Settings, error) {
+	settings := Settings{BaseCurrency: defaultCurrency}
+	data, err := os.ReadFile(settingsFile)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return settings, nil
+		}
+		return settings, err
+	}
+	if err := json.Unmarshal(data, &settings); err != nil {
+		return settings, fmt.Errorf("%s: %w", settingsFile, err)
+	}
+	return settings, nil
+}
+
+func saveSettings(settings Settings) error {
+	data, err := json.MarshalIndent(settings, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(settingsFile, data, 0644)
+}
+
+// baseCurrency is USD until another one is set, a settings.json which can't be
+// read is an error: amounts would silently be read and converted in the wrong currency
+func baseCurrency() (string, error) {
+	settings, err := loadSettings()
+	if err != nil {
+		return "", fmt.Errorf("error while loading the settings: %w", err)
+	}
+	if settings.BaseCurrency == "" {
+		return defaultCurrency, nil
+	}
+	return settings.BaseCurrency, nil
+}
+
+// instance: go run . currency base EUR
+//
+//	go run . currency
+func cmdCurrency(args []string) {
+	settings, err := loadSettings()
+	if err != nil {
+		fail("error while loading the settings: ", err)
+		return
+	}
+	if len(args) == 0 {
+		fmt.Printf("base currency: %s\n", settings.BaseCurrency)
+		return
+	}
+	if args[0] != "base" || len(args) < 2 {
+		fail("usage: currency [base <code>]")
+		return
+	}
+
+	code := strings.ToUpper(args[1])
+	if !validCurrency(code) {
+		failf("invalid currency %q, use a code like USD or EUR\n", args[1])
+		return
+	}
+	settings.BaseCurrency = code
+	if err := saveSettings(settings); err != nil {
+		fail("error while saving the settings: ", err)
+		return
+	}
+	fmt.Printf("base currency set to %s\n", code)
+}
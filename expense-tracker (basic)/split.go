@@ -0,0 +1,318 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// shared expenses: one person pays the bill and it is split between the participants
+// the share of every participant is saved in the expense, so "balances" only has
+// to add them up. the payer can be one of the participants
+
+type Share struct {
+	Person string `json:"person"`
+	Amount Money  `json:"amount"`
+}
+
+// splitEqual divides the amount in equal shares, the cents left go to the first people
+// 10.00 between 3 people is 3.34 + 3.33 + 3.33
+func splitEqual(amount Money, people []string) ([]Share, error) {
+	n := int64(len(people))
+	// 0.02 between 3 people would give somebody a share of 0.00
+	if amount.Minor < n {
+		return nil, fmt.Errorf("%s can not be split between %d people", amount, n)
+	}
+	shares := make([]Share, len(people))
+	for i, p := range people {
+		minor := amount.Minor / n
+		if int64(i) < amount.Minor%n {
+			minor++
+		}
+		shares[i] = Share{Person: p, Amount: Money{Minor: minor, Currency: amount.Currency}}
+	}
+	return shares, nil
+}
+
+// splitPercent divides the amount by percentages which have to add up to 100
+// the rounding difference goes to the last person so the shares add up to the amount
+func splitPercent(amount Money, people []string, percents []string) ([]Share, error) {
+	sum := new(big.Rat)
+	shares := make([]Share, len(people))
+	var given int64
+	for i, p := range people {
+		pct, ok := new(big.Rat).SetString(percents[i])
+		if !ok || pct.Sign() <= 0 {
+			return nil, fmt.Errorf("invalid percentage %q for %s", percents[i], p)
+		}
+		sum.Add(sum, pct)
+		value := new(big.Rat).SetInt64(amount.Minor)
+		value.Mul(value, pct)
+		value.Quo(value, big.NewRat(100, 1))
+		shares[i] = Share{Person: p, Amount: Money{Minor: roundRat(value), Currency: amount.Currency}}
+		given += shares[i].Amount.Minor
+	}
+	if sum.Cmp(big.NewRat(100, 1)) != 0 {
+		return nil, fmt.Errorf("the percentages add up to %s, not 100", sum.FloatString(2))
+	}
+	shares[len(shares)-1].Amount.Minor += amount.Minor - given
+	// a tiny percentage of a small amount rounds to 0.00, and the rounding
+	// difference could even make the last share negative
+	for _, share := range shares {
+		if share.Amount.Minor <= 0 {
+			return nil, fmt.Errorf("the share of %s would be %s, every share has to be more than zero",
+				share.Person, share.Amount)
+		}
+	}
+	return shares, nil
+}
+
+// splitExact takes the amount of every person, they have to add up to the expense
+func splitExact(amount Money, people []string, values []string) ([]Share, error) {
+	shares := make([]Share, len(people))
+	var given int64
+	for i, p := range people {
+		m, err := parseMoney(values[i], amount.Currency)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", p, err)
+		}
+		if m.Currency != amount.Currency {
+			return nil, fmt.Errorf("%s: the share has to be in %s like the expense", p, amount.Currency)
+		}
+		shares[i] = Share{Person: p, Amount: m}
+		given += m.Minor
+	}
+	if given != amount.Minor {
+		return nil, fmt.Errorf("the shares add up to %s, not %s",
+			Money{Minor: given, Currency: amount.Currency}, amount)
+	}
+	return shares, nil
+}
+
+// parseSplit reads the participants of a split like "alice bob" or "alice:60 bob:40"
+func parseSplit(amount Money, mode string, args []string) ([]Share, error) {
+	if len(args) == 0 {
+		return nil, errors.New("a split needs at least one participant")
+	}
+	people := make([]string, len(args))
+	values := make([]string, len(args))
+	seen := make(map[string]bool)
+	for i, arg := range args {
+		person, value, hasValue := strings.Cut(arg, ":")
+		person = strings.ToLower(strings.TrimSpace(person))
+		if person == "" {
+			return nil, fmt.Errorf("missing name in %q", arg)
+		}
+		if seen[person] {
+			return nil, fmt.Errorf("%s is twice in the split", person)
+		}
+		seen[person] = true
+		if mode != "equal" && !hasValue {
+			return nil, fmt.Errorf("missing %s for %s, use %s:<value>", mode, person, person)
+		}
+		people[i], values[i] = person, value
+	}
+
+	switch mode {
+	case "equal":
+		return splitEqual(amount, people)
+	case "percent":
+		return splitPercent(amount, people, values)
+	case "exact":
+		return splitExact(amount, people, values)
+	}
+	return nil, fmt.Errorf("invalid split %q, use equal, percent or exact", mode)
+}
+
+// instance: go run . split 4 alice equal alice bob carol
+//
+//	go run . split 4 alice percent alice:50 bob:30 carol:20
+//	go run . split 4 bob exact alice:12.50 bob:7.50
+//	go run . split 4 none
+func cmdSplit(args []string) {
+	if len(args) < 2 || (args[1] != "none" && len(args) < 4) {
+		fail("usage: split <id> <payer> <equal|percent|exact> <person[:value]>... | split <id> none")
+		return
+	}
+	id, err := strconv.Atoi(args[0])
+	if err != nil {
+		fail("invalid id ")
+		return
+	}
+	expenses, err := loadExpenses()
+	if err != nil {
+		fail("error while loading the expenses: ", err)
+		return
+	}
+	var expense *Expense
+	for i := range expenses {
+		if expenses[i].Id == id {
+			expense = &expenses[i]
+		}
+	}
+	if expense == nil {
+		failf("no expense with id %d\n", id)
+		return
+	}
+
+	if args[1] == "none" {
+		expense.Payer, expense.Shares = "", nil
+	} else {
+		shares, err := parseSplit(expense.Amount, args[2], args[3:])
+		if err != nil {
+			fail(err)
+			return
+		}
+		expense.Payer = strings.ToLower(args[1])
+		expense.Shares = shares
+	}
+
+	if err := saveExpenses(expenses); err != nil {
+		fail("error while saving the file: ", err)
+		return
+	}
+	for _, s := range expense.Shares {
+		fmt.Printf("%s \t%s\n", s.Person, s.Amount)
+	}
+	fmt.Println("Expense split updated successfully ")
+}
+
+// debt is a payment which settles (part of) the balances
+type debt struct {
+	From, To string
+	Amount   Money
+}
+
+// balances returns how much every person is owed (positive) or owes (negative)
+// in the base currency. shared expenses without an exchange rate are returned too
+func balances(data []Expense, rates rateTable, base string) (map[string]int64, []Expense) {
+	net := make(map[string]int64)
+	var missing []Expense
+	for _, e := range data {
+		if e.Payer == "" || len(e.Shares) == 0 {
+			continue
+		}
+		// converting every share on its own could lose a cent, so the payer gets
+		// exactly the sum of the converted shares
+		var converted []int64
+		ok := true
+		for _, s := range e.Shares {
+			c, err := rates.convert(s.Amount, base, e.Date)
+			if err != nil {
+				ok = false
+				break
+			}
+			converted = append(converted, c.Minor)
+		}
+		if !ok {
+			missing = append(missing, e)
+			continue
+		}
+		for i, s := range e.Shares {
+			net[s.Person] -= converted[i]
+			net[e.Payer] += converted[i]
+		}
+	}
+	return net, missing
+}
+
+// settleUp turns the balances into a short list of payments: the person who owes
+// the most pays the person who is owed the most, until everybody is at zero
+func settleUp(net map[string]int64, currency string) []debt {
+	type person struct {
+		name   string
+		amount int64
+	}
+	var debtors, creditors []person
+	for name, amount := range net {
+		switch {
+		case amount < 0:
+			debtors = append(debtors, person{name, -amount})
+		case amount > 0:
+			creditors = append(creditors, person{name, amount})
+		}
+	}
+	// biggest first, names keep the order stable when amounts are equal
+	byAmount := func(list []person) func(i, j int) bool {
+		return func(i, j int) bool {
+			if list[i].amount != list[j].amount {
+				return list[i].amount > list[j].amount
+			}
+			return list[i].name < list[j].name
+		}
+	}
+
+	var plan []debt
+	for len(debtors) > 0 && len(creditors) > 0 {
+		sort.Slice(debtors, byAmount(debtors))
+		sort.Slice(creditors, byAmount(creditors))
+		d, c := &debtors[0], &creditors[0]
+		pay := min(d.amount, c.amount)
+		plan = append(plan, debt{From: d.name, To: c.name, Amount: Money{Minor: pay, Currency: currency}})
+		d.amount -= pay
+		c.amount -= pay
+		if d.amount == 0 {
+			debtors = debtors[1:]
+		}
+		if c.amount == 0 {
+			creditors = creditors[1:]
+		}
+	}
+	return plan
+}
+
+// instance: go run . balances
+func cmdBalances() {
+	data, err := loadExpenses()
+	if err != nil {
+		fail("error while loading the expenses: ", err)
+		return
+	}
+	rates, err := loadRates()
+	if err != nil {
+		fail("error while loading the rates: ", err)
+		return
+	}
+	base, err := baseCurrency()
+	if err != nil {
+		fail(err)
+		return
+	}
+	net, missing := balances(data, rates, base)
+	if len(net) == 0 {
+		fmt.Println("no shared expenses yet, split one with: split <id> <payer> equal <people>...")
+		printMissingRates(missing, base)
+		return
+	}
+
+	names := make([]string, 0, len(net))
+	for name := range net {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	fmt.Println("Balances:")
+	for _, name := range names {
+		amount := Money{Minor: net[name], Currency: base}
+		switch {
+		case net[name] > 0:
+			fmt.Printf("%s \tis owed %s\n", name, amount)
+		case net[name] < 0:
+			amount.Minor = -amount.Minor
+			fmt.Printf("%s \towes %s\n", name, amount)
+		default:
+			fmt.Printf("%s \tis settled\n", name)
+		}
+	}
+
+	plan := settleUp(net, base)
+	if len(plan) > 0 {
+		fmt.Println("\nSettle up:")
+		for _, d := range plan {
+			fmt.Printf("%s pays %s %s\n", d.From, d.To, d.Amount)
+		}
+	}
+	printMissingRates(missing, base)
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"encoding/json"
+	"slices"
+	"testing"
+)
+
+// sharesOf returns the amounts of the shares in minor units
+func sharesOf(shares []Share) []int64 {
+	amounts := make([]int64, len(shares))
+	for i, s := range shares {
+		amounts[i] = s.Amount.Minor
+	}
+	return amounts
+}
+
+func TestParseSplit(t *testing.T) {
+	tests := []struct {
+		name    string
+		amount  int64
+		mode    string
+		args    []string
+		want    []int64
+		wantErr bool
+	}{
+		{"equal", 1000, "equal", []string{"alice", "bob", "carol"}, []int64{334, 333, 333}, false},
+		{"equal exact cents", 300, "equal", []string{"alice", "bob", "carol"}, []int64{100, 100, 100}, false},
+		{"equal one cent each", 3, "equal", []string{"alice", "bob", "carol"}, []int64{1, 1, 1}, false},
+		{"equal less than a cent each", 2, "equal", []string{"alice", "bob", "carol"}, nil, true},
+		{"percent", 1000, "percent", []string{"alice:50", "bob:30", "carol:20"}, []int64{500, 300, 200}, false},
+		{"percent rounding goes to the last", 1000, "percent", []string{"alice:33.3", "bob:33.3", "carol:33.4"}, []int64{333, 333, 334}, false},
+		{"percent not 100", 1000, "percent", []string{"alice:50", "bob:40"}, nil, true},
+		{"percent zero", 1000, "percent", []string{"alice:0", "bob:100"}, nil, true},
+		{"percent negative", 1000, "percent", []string{"alice:-10", "bob:110"}, nil, true},
+		{"percent rounds to zero", 10, "percent", []string{"alice:1", "bob:99"}, nil, true},
+		{"percent makes the last share zero", 2, "percent", []string{"alice:50", "bob:49.9", "carol:0.1"}, nil, true},
+		{"exact", 2000, "exact", []string{"alice:12.50", "bob:7.50"}, []int64{1250, 750}, false},
+		{"exact wrong total", 2000, "exact", []string{"alice:12.50", "bob:7"}, nil, true},
+		{"exact zero", 2000, "exact", []string{"alice:20", "bob:0"}, nil, true},
+		{"exact other currency", 2000, "exact", []string{"alice:10EUR", "bob:10"}, nil, true},
+		{"missing value", 1000, "percent", []string{"alice:50", "bob"}, nil, true},
+		{"same person twice", 1000, "equal", []string{"alice", "Alice"}, nil, true},
+		{"no participants", 1000, "equal", nil, nil, true},
+		{"unknown mode", 1000, "thirds", []string{"alice:1"}, nil, true},
+	}
+	for _, tt := range tests {
+		shares, err := parseSplit(Money{tt.amount, "USD"}, tt.mode, tt.args)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: parseSplit() error = %v, want error %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && !slices.Equal(sharesOf(shares), tt.want) {
+			t.Errorf("%s: parseSplit() = %v, want %v", tt.name, sharesOf(shares), tt.want)
+		}
+	}
+}
+
+// a split that was accepted must be saved and loaded again, the bug was a
+// 0.00 share which made expense.json unreadable
+func TestSplitSharesLoadAgain(t *testing.T) {
+	shares, err := parseSplit(Money{3, "USD"}, "equal", []string{"alice", "bob", "carol"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := json.Marshal(Expense{Id: 1, Amount: Money{3, "USD"}, Payer: "alice", Shares: shares})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var loaded Expense
+	if err := json.Unmarshal(data, &loaded); err != nil {
+		t.Fatalf("Unmarshal(%s): %v", data, err)
+	}
+	if !slices.Equal(sharesOf(loaded.Shares), []int64{1, 1, 1}) {
+		t.Errorf("loaded shares = %v", sharesOf(loaded.Shares))
+	}
+}
+
+func TestBalancesAndSettleUp(t *testing.T) {
+	rates := rateTable{{Date: "2025-01-01", From: "EUR", To: "USD", Rate: "2"}}
+	data := []Expense{
+		// alice paid 30.00 for three
+		{Id: 1, Date: day("2025-02-01"), Amount: Money{3000, "USD"}, Payer: "alice",
+			Shares: []Share{{"alice", Money{1000, "USD"}}, {"bob", Money{1000, "USD"}}, {"carol", Money{1000, "USD"}}}},
+		// bob paid 5.00 EUR (10.00 USD) for carol
+		{Id: 2, Date: day("2025-02-02"), Amount: Money{500, "EUR"}, Payer: "bob",
+			Shares: []Share{{"carol", Money{500, "EUR"}}}},
+		// no rate for GBP, it is reported as missing
+		{Id: 3, Date: day("2025-02-03"), Amount: Money{100, "GBP"}, Payer: "carol",
+			Shares: []Share{{"alice", Money{100, "GBP"}}}},
+		// not shared
+		{Id: 4, Date: day("2025-02-04"), Amount: Money{999, "USD"}},
+	}
+
+	net, missing := balances(data, rates, "USD")
+	want := map[string]int64{"alice": 2000, "bob": 0, "carol": -2000}
+	for name, amount := range want {
+		if net[name] != amount {
+			t.Errorf("balance of %s = %d, want %d", name, net[name], amount)
+		}
+	}
+	if len(missing) != 1 || missing[0].Id != 3 {
+		t.Errorf("missing = %v, want expense 3", missing)
+	}
+
+	plan := settleUp(net, "USD")
+	if len(plan) != 1 || plan[0] != (debt{From: "carol", To: "alice", Amount: Money{2000, "USD"}}) {
+		t.Errorf("settleUp() = %v", plan)
+	}
+}
+
+func TestSettleUpEveryoneEndsAtZero(t *testing.T) {
+	net := map[string]int64{"a": -700, "b": -300, "c": 600, "d": 400}
+	left := map[string]int64{}
+	for name, amount := range net {
+		left[name] = amount
+	}
+	for _, d := range settleUp(net, "USD") {
+		left[d.From] += d.Amount.Minor
+		left[d.To] -= d.Amount.Minor
+	}
+	for name, amount := range left {
+		if amount != 0 {
+			t.Errorf("%s is at %d after settling up", name, amount)
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	_ "modernc.org/sqlite" // pure go driver, no cgo needed
+)
+
+// sqliteStore keeps the expenses in an sqlite database
+// the simple fields have their own column so the file can be queried with the
+// sqlite3 shell, the lists (shares, attachments, tags) are saved as json text
+
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS expenses (
+	id           INTEGER PRIMARY KEY,
+	date         TEXT    NOT NULL,
+	category     TEXT    NOT NULL,
+	description  TEXT    NOT NULL DEFAULT '',
+	amount_minor INTEGER NOT NULL,
+	currency     TEXT    NOT NULL,
+	recurring_id INTEGER NOT NULL DEFAULT 0,
+	payer        TEXT    NOT NULL DEFAULT '',
+	shares       TEXT    NOT NULL DEFAULT '[]',
+	attachments  TEXT    NOT NULL DEFAULT '[]',
+	notes        TEXT    NOT NULL DEFAULT '',
+	tags         TEXT    NOT NULL DEFAULT '[]'
+)`
+
+type sqliteStore struct {
+	db *sql.DB
+}
+
+func openSQLiteStore(path string) (*sqliteStore, error) {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := db.Exec(sqliteSchema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return &sqliteStore{db: db}, nil
+}
+
+func (s *sqliteStore) Load() ([]Expense, error) {
+	rows, err := s.db.Query(`SELECT id, date, category, description, amount_minor, currency,
+		recurring_id, payer, shares, attachments, notes, tags FROM expenses ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	expenses := []Expense{}
+	for rows.Next() {
+		var e Expense
+		var date, shares, attachments, tags string
+		err := rows.Scan(&e.Id, &date, &e.Category, &e.Description, &e.Amount.Minor, &e.Amount.Currency,
+			&e.RecurringId, &e.Payer, &shares, &attachments, &e.Notes, &tags)
+		if err != nil {
+			return nil, err
+		}
+		if e.Date, err = time.Parse(time.RFC3339Nano, date); err != nil {
+			return nil, fmt.Errorf("expense %d: %w", e.Id, err)
+		}
+		for _, field := range []struct {
+			text string
+			to   any
+		}{{shares, &e.Shares}, {attachments, &e.Attachments}, {tags, &e.Tags}} {
+			if err := json.Unmarshal([]byte(field.text), field.to); err != nil {
+				return nil, fmt.Errorf("expense %d: %w", e.Id, err)
+			}
+		}
+		expenses = append(expenses, e)
+	}
+	return expenses, rows.Err()
+}
+
+// Save replaces all rows in one transaction, if anything fails nothing changes
+func (s *sqliteStore) Save(expenses []Expense) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback() // does nothing after the commit
+
+	if _, err := tx.Exec(`DELETE FROM expenses`); err != nil {
+		return err
+	}
+	insert, err := tx.Prepare(`INSERT INTO expenses (id, date, category, description, amount_minor,
+		currency, recurring_id, payer, shares, attachments, notes, tags)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`)
+	if err != nil {
+		return err
+	}
+	defer insert.Close()
+
+	for _, e := range expenses {
+		var lists [3]string
+		for i, v := range []any{e.Shares, e.Attachments, e.Tags} {
+			if lists[i], err = jsonList(v); err != nil {
+				return err
+			}
+		}
+		_, err := insert.Exec(e.Id, e.Date.Format(time.RFC3339Nano), e.Category, e.Description, e.Amount.Minor,
+			e.Amount.Currency, e.RecurringId, e.Payer, lists[0], lists[1], e.Notes, lists[2])
+		if err != nil {
+			return fmt.Errorf("expense %d: %w", e.Id, err)
+		}
+	}
+	return tx.Commit()
+}
+
+// jsonList saves an empty list as [] and not as null
+func jsonList(v any) (string, error) {
+	data, err := json.Marshal(v)
+	if err != nil || string(data) == "null" {
+		return "[]", err
+	}
+	return string(data), nil
+}
+
+func (s *sqliteStore) Close() error {
+	return s.db.Close()
+}
@@ -2,40 +2,147 @@ package main
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
+	"path/filepath"
+	"strings"
 )
 
-func loadFile() ([]Expense, error) {
-	data, _ := os.ReadFile(expenseFile)
-
-	// if err != nil {
-	// 	if errors.Is(err,os.ErrNotExist){
-	// 		return []Expense{},err
-	// 	}
-	// 	fmt.Println("error while loading the file: ", err)
-	// 	return  nil, err
-	// }
-
-	// // if it's empty
-	// if len(data) == 0{
-	// 	return []Expense{}, nil
-	// }
-	var expenses []Expense
-	err := json.Unmarshal(data, &expenses)
+// Store is where the expenses are kept, expense.json (jsonStore) or an sqlite
+// database (sqliteStore). the commands load every expense, change the list and
+// save it back, so a store only has to read and write the whole list
+type Store interface {
+	Load() ([]Expense, error)
+	Save(expenses []Expense) error
+	Close() error
+}
+
+// store is opened by main from the storage setting, see openStore
+var store Store
+
+func loadExpenses() ([]Expense, error) {
+	return store.Load()
+}
+
+func saveExpenses(expenses []Expense) error {
+	return store.Save(expenses)
+}
+
+// openStore opens the backend chosen with "storage use", json is the default
+func openStore(settings Settings) (Store, error) {
+	switch settings.Storage {
+	case "", "json":
+		return &jsonStore{path: expenseFile}, nil
+	case "sqlite":
+		return openSQLiteStore(settings.sqlitePath())
+	}
+	return nil, fmt.Errorf("unknown storage %q in %s, use json or sqlite", settings.Storage, settingsFile)
+}
+
+// jsonStore keeps the expenses in expense.json
+// a missing file is an empty list, but a file which can't be read or parsed is
+// an error, so it is never overwritten by an empty list
+type jsonStore struct {
+	path string
+}
+
+func (s *jsonStore) Load() ([]Expense, error) {
+	data, err := os.ReadFile(s.path)
 	if err != nil {
-		fmt.Println("error while Unmarshaling the file: ", err)
+		if errors.Is(err, os.ErrNotExist) {
+			return []Expense{}, nil
+		}
 		return nil, err
 	}
+	if len(strings.TrimSpace(string(data))) == 0 {
+		return []Expense{}, nil
+	}
+
+	var expenses []Expense
+	if err := json.Unmarshal(data, &expenses); err != nil {
+		return nil, fmt.Errorf("%s is corrupted (%w), the last good version is in %s", s.path, err, s.path+".bak")
+	}
+
+	// old files have no category, the description was the meal time and the
+	// amount a whole number of dollars (Money reads that one by itself)
+	// the migrated file is saved right away, the old one is kept as a backup
+	migrated := false
+	for i := range expenses {
+		if expenses[i].Category == "" {
+			expenses[i].Category = legacyCategory(expenses[i].Description)
+			migrated = true
+		}
+	}
+	if migrated {
+		if err := s.Save(expenses); err != nil {
+			return nil, err
+		}
+	}
 	return expenses, nil
+}
 
+// Save copies the current file to expense.json.bak and then replaces it
+// the new content is written to a temporary file first and renamed over the
+// old one, so a crash in the middle never leaves half a file behind
+func (s *jsonStore) Save(expenses []Expense) error {
+	if expenses == nil {
+		expenses = []Expense{}
+	}
+	data, err := json.MarshalIndent(expenses, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := copyFile(s.path, s.path+".bak"); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("backup of %s: %w", s.path, err)
+	}
+	return writeFileAtomic(s.path, data)
 }
 
-// save file
-func saveFile(expense *[]Expense) error {
-	data, _ := json.MarshalIndent(expense, "", "  ")
-	return os.WriteFile(expenseFile, data, 0644)
+func (s *jsonStore) Close() error { return nil }
 
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // does nothing after the rename
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func copyFile(from, to string) error {
+	data, err := os.ReadFile(from)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(to, data)
+}
+
+// legacyCategory maps the meal times the old version accepted to a category
+func legacyCategory(description string) string {
+	switch strings.ReplaceAll(normalizeCategory(description), " ", "") {
+	case "breakfast":
+		return "breakfast"
+	case "lunch", "launch", "lauch":
+		return "lunch"
+	case "dinner":
+		return "dinner"
+	}
+	return fallbackCategory
 }
 
 func getNextId(tasks []Expense) int {
@@ -47,3 +154,71 @@ func getNextId(tasks []Expense) int {
 	}
 	return maxId + 1
 }
+
+// instance: go run . storage
+//
+//	go run . storage use sqlite
+//	go run . storage use json
+//
+// "use" copies the expenses into the new storage, which has to be empty
+func cmdStorage(args []string) {
+	settings, err := loadSettings()
+	if err != nil {
+		fail("error while loading the settings: ", err)
+		return
+	}
+	current := settings.Storage
+	if current == "" {
+		current = "json"
+	}
+	if len(args) == 0 {
+		where := expenseFile
+		if current == "sqlite" {
+			where = settings.sqlitePath()
+		}
+		fmt.Printf("storage: %s (%s)\n", current, where)
+		return
+	}
+	if args[0] != "use" || len(args) < 2 {
+		fail("usage: storage [use <json|sqlite>]")
+		return
+	}
+	if args[1] == current {
+		fmt.Printf("already using %s\n", current)
+		return
+	}
+
+	next := settings
+	next.Storage = args[1]
+	target, err := openStore(next)
+	if err != nil {
+		fail("error while opening the storage: ", err)
+		return
+	}
+	defer target.Close()
+
+	expenses, err := loadExpenses()
+	if err != nil {
+		fail("error while loading the expenses: ", err)
+		return
+	}
+	existing, err := target.Load()
+	if err != nil {
+		fail("error while reading the new storage: ", err)
+		return
+	}
+	if len(existing) > 0 {
+		failf("the %s storage already has %d expenses, not copying over them\n", args[1], len(existing))
+		fail("move it away first if the expenses should be copied")
+		return
+	}
+	if err := target.Save(expenses); err != nil {
+		fail("error while copying the expenses: ", err)
+		return
+	}
+	if err := saveSettings(next); err != nil {
+		fail("error while saving the settings: ", err)
+		return
+	}
+	fmt.Printf("%d expenses copied, now using %s\n", len(expenses), args[1])
+}
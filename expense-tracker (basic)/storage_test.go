@@ -0,0 +1,94 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// an expense.json of the first version: no category, the meal time in the
+// description and the amount in whole dollars
+const legacyExpenses = `[
+  {"id": 1, "date": "2025-11-25T01:12:10+03:00", "description": "breakfast", "amount": 10},
+  {"id": 2, "date": "2025-11-25T01:13:43+03:00", "description": "Dinner", "amount": 20},
+  {"id": 3, "date": "2025-11-25T01:15:38+03:00", "description": "launch", "amount": 5},
+  {"id": 4, "date": "2025-11-25T01:15:50+03:00", "description": "break fast", "amount": 50},
+  {"id": 5, "date": "2025-11-25T01:16:00+03:00", "description": "snack", "amount": 3}
+]`
+
+func TestLegacyCategory(t *testing.T) {
+	tests := []struct {
+		description string
+		want        string
+	}{
+		{"breakfast", "breakfast"},
+		{"Break Fast", "breakfast"},
+		{"lunch", "lunch"},
+		{"launch", "lunch"},
+		{"lauch", "lunch"},
+		{" DINNER ", "dinner"},
+		{"snack", fallbackCategory},
+		{"", fallbackCategory},
+	}
+	for _, tt := range tests {
+		if got := legacyCategory(tt.description); got != tt.want {
+			t.Errorf("legacyCategory(%q) = %q, want %q", tt.description, got, tt.want)
+		}
+	}
+}
+
+func TestJSONStoreMigratesLegacyFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "expense.json")
+	if err := os.WriteFile(path, []byte(legacyExpenses), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	s := &jsonStore{path: path}
+	expenses, err := s.Load()
+	if err != nil {
+		t.Fatalf("Load() error: %v", err)
+	}
+	want := []struct {
+		category string
+		amount   Money
+	}{
+		{"breakfast", Money{1000, "USD"}},
+		{"dinner", Money{2000, "USD"}},
+		{"lunch", Money{500, "USD"}},
+		{"breakfast", Money{5000, "USD"}},
+		{fallbackCategory, Money{300, "USD"}},
+	}
+	if len(expenses) != len(want) {
+		t.Fatalf("Load() returned %d expenses, want %d", len(expenses), len(want))
+	}
+	for i, w := range want {
+		if expenses[i].Category != w.category || expenses[i].Amount != w.amount {
+			t.Errorf("expense %d = %s %v, want %s %v", expenses[i].Id, expenses[i].Category, expenses[i].Amount, w.category, w.amount)
+		}
+	}
+
+	// the migrated list is saved, the old file is kept as the backup
+	backup, err := os.ReadFile(path + ".bak")
+	if err != nil || string(backup) != legacyExpenses {
+		t.Errorf("the backup is not the old file (error %v)", err)
+	}
+	again, err := s.Load()
+	if err != nil {
+		t.Fatalf("second Load() error: %v", err)
+	}
+	for i := range again {
+		if again[i].Category != expenses[i].Category || again[i].Amount != expenses[i].Amount {
+			t.Errorf("expense %d changed after saving: %v, want %v", again[i].Id, again[i], expenses[i])
+		}
+	}
+}
+
+func TestJSONStoreCorruptFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "expense.json")
+	if err := os.WriteFile(path, []byte(`[{"id": 1,`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := (&jsonStore{path: path}).Load(); err == nil {
+		t.Error("Load() of a corrupted file returned no error")
+	}
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/term"
+)
+
+// cmdBoard prints the tasks as a Kanban board, one column per workflow status
+// a column over its WIP limit is marked with "!"
+func cmdBoard() {
+	tasks, err := loadTasks()
+	if err != nil {
+		fmt.Println("Error loading tasks: ", err)
+		return
+	}
+
+	columns := append([]statusColumn(nil), activeWorkflow.Statuses...)
+	// tasks whose status is not in the workflow (anymore) are shown in an extra column
+	known := make(map[string]bool)
+	for _, column := range columns {
+		known[column.Name] = true
+	}
+	for _, t := range tasks {
+		if !known[t.Status] {
+			known[t.Status] = true
+			columns = append(columns, statusColumn{Name: t.Status, Title: t.Status + " (unknown)"})
+		}
+	}
+
+	width, _, err := term.GetSize(int(os.Stdout.Fd()))
+	if err != nil || width <= 0 {
+		width = 100
+	}
+	// every column is separated by a "│", and there is one on each side
+	colWidth := (width-1)/len(columns) - 1
+	if colWidth < 12 {
+		colWidth = 12
+	}
+
+	headers := make([]string, len(columns))
+	cells := make([][]string, len(columns))
+	height := 0
+	for i, column := range columns {
+		title := column.Title
+		if title == "" {
+			title = column.Name
+		}
+		count := countStatus(tasks, column.Name)
+		if column.WIPLimit > 0 {
+			title += fmt.Sprintf(" %d/%d", count, column.WIPLimit)
+			if count > column.WIPLimit {
+				title += " !"
+			}
+		} else {
+			title += fmt.Sprintf(" %d", count)
+		}
+		headers[i] = title
+
+		for _, t := range tasks {
+			if t.Status != column.Name {
+				continue
+			}
+			if len(cells[i]) > 0 {
+				cells[i] = append(cells[i], "")
+			}
+			cells[i] = append(cells[i], wrapText(fmt.Sprintf("#%d %s", t.ID, t.Description), colWidth-2)...)
+		}
+		if len(cells[i]) > height {
+			height = len(cells[i])
+		}
+	}
+
+	line := func(left, middle, right string) {
+		parts := make([]string, len(columns))
+		for i := range parts {
+			parts[i] = strings.Repeat("─", colWidth)
+		}
+		fmt.Println(left + strings.Join(parts, middle) + right)
+	}
+	row := func(texts []string) {
+		parts := make([]string, len(texts))
+		for i, text := range texts {
+			parts[i] = " " + padRight(text, colWidth-2) + " "
+		}
+		fmt.Println("│" + strings.Join(parts, "│") + "│")
+	}
+
+	line("┌", "┬", "┐")
+	row(headers)
+	line("├", "┼", "┤")
+	for r := 0; r < height; r++ {
+		texts := make([]string, len(columns))
+		for i := range columns {
+			if r < len(cells[i]) {
+				texts[i] = cells[i][r]
+			}
+		}
+		row(texts)
+	}
+	line("└", "┴", "┘")
+}
+
+// wrapText breaks text into lines of at most width characters, on spaces when possible
+func wrapText(text string, width int) []string {
+	var lines []string
+	current := ""
+	for _, word := range strings.Fields(text) {
+		// a word longer than the column is cut into pieces
+		for utf8.RuneCountInString(word) > width {
+			if current != "" {
+				lines = append(lines, current)
+				current = ""
+			}
+			runes := []rune(word)
+			lines = append(lines, string(runes[:width]))
+			word = string(runes[width:])
+		}
+		switch {
+		case current == "":
+			current = word
+		case utf8.RuneCountInString(current)+1+utf8.RuneCountInString(word) <= width:
+			current += " " + word
+		default:
+			lines = append(lines, current)
+			current = word
+		}
+	}
+	if current != "" {
+		lines = append(lines, current)
+	}
+	return lines
+}
+
+func padRight(text string, width int) string {
+	n := utf8.RuneCountInString(text)
+	if n >= width {
+		return string([]rune(text)[:width])
+	}
+	return text + strings.Repeat(" ", width-n)
+}
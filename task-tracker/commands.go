@@ -2,6 +2,7 @@ package main
 
 import (
 	"fmt"
+	"os"
 	"strconv"
 	"strings"
 	"time"
@@ -37,11 +38,17 @@ func cmdAdd(args []string) {
 		fmt.Println("en error occured : ", err)
 	}
 
+	// new tasks start in the initial status of the workflow, "todo" by default
+	if err := activeWorkflow.checkMove(tasks, 0, activeWorkflow.Initial); err != nil {
+		fmt.Println(err)
+		return
+	}
+
 	now := time.Now
 	newTask := Task{
 		ID:          getNextId(tasks),
 		Description: description,
-		Status:      statusToDo, // default status is "todo",declared in task.go
+		Status:      activeWorkflow.Initial,
 		CreatedAt:   now(),
 		UpdatedAt:   now(),
 	}
@@ -121,11 +128,6 @@ func cmdUpdate(args []string) {
 	}
 	status := args[1]
 
-	if status != statusToDo && status != statusDone && status != statusInProgress {
-		fmt.Println("invalied status")
-		return
-	}
-
 	tasks, err := loadTasks()
 	if err != nil {
 		fmt.Println("Error while loading the file:  ", err)
@@ -137,6 +139,12 @@ func cmdUpdate(args []string) {
 		return
 	}
 
+	// the workflow decides which statuses exist, which changes are allowed and the WIP limits
+	if err := activeWorkflow.checkMove(tasks, id, status); err != nil {
+		fmt.Println(err)
+		return
+	}
+
 	task.Status = status
 	task.UpdatedAt = time.Now()
 
@@ -148,3 +156,85 @@ func cmdUpdate(args []string) {
 	fmt.Println("task updated successfully ")
 
 }
+
+// export the tasks to another format
+// instance: go run . export todotxt todo.txt
+// without a file name the result is printed
+func cmdExport(args []string) {
+	if len(args) < 1 {
+		fmt.Println("usage: export <todotxt|markdown|csv|ical> [file]")
+		return
+	}
+	format, err := lookupFormat(args[0])
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	tasks, err := loadTasks()
+	if err != nil {
+		fmt.Println("Error loading tasks: ", err)
+		return
+	}
+
+	if len(args) < 2 {
+		if err := format.write(os.Stdout, tasks); err != nil {
+			fmt.Println("Error exporting tasks: ", err)
+		}
+		return
+	}
+
+	file, err := os.Create(args[1])
+	if err != nil {
+		fmt.Println("Error creating the file: ", err)
+		return
+	}
+	defer file.Close()
+	if err := format.write(file, tasks); err != nil {
+		fmt.Println("Error exporting tasks: ", err)
+		return
+	}
+	fmt.Printf("%d tasks exported to %s\n", len(tasks), args[1])
+}
+
+// import tasks from another format, tasks with the same description and
+// creation date as an existing task are skipped
+// instance: go run . import markdown TODO.md
+func cmdImport(args []string) {
+	if len(args) < 2 {
+		fmt.Println("usage: import <todotxt|markdown|csv|ical> <file>")
+		return
+	}
+	format, err := lookupFormat(args[0])
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	file, err := os.Open(args[1])
+	if err != nil {
+		fmt.Println("Error opening the file: ", err)
+		return
+	}
+	defer file.Close()
+	imported, err := format.read(file)
+	if err != nil {
+		fmt.Println("Error reading the file: ", err)
+		return
+	}
+
+	tasks, err := loadTasks()
+	if err != nil {
+		fmt.Println("Error loading tasks: ", err)
+		return
+	}
+	tasks, added, skipped := mergeImported(tasks, imported)
+	if err := saveTasks(tasks); err != nil {
+		fmt.Println("Error saving tasks: ", err)
+		return
+	}
+	fmt.Printf("%d tasks imported, %d duplicates skipped\n", added, skipped)
+	// imported tasks keep their status, even when that fills a column over its limit
+	for _, warning := range activeWorkflow.overLimit(tasks) {
+		fmt.Println("warning:", warning)
+	}
+}
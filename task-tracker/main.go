@@ -9,8 +9,19 @@ import (
 
 func main() {
 
+	// the statuses every command works with, see workflow.go
+	wf, err := loadWorkflow()
+	if err != nil {
+		fmt.Println("Error loading the workflow: ", err)
+		return
+	}
+	activeWorkflow = wf
+
+	// without a command the interactive mode is started
 	if len(os.Args) < 2 {
-		fmt.Println("No command provided.")
+		if err := runTUI(); err != nil {
+			fmt.Println("Error: ", err)
+		}
 		return
 	}
 
@@ -29,6 +40,18 @@ func main() {
 		cmdList()
 	case "update":
 		cmdUpdate(arg)
+	case "import":
+		cmdImport(arg)
+	case "export":
+		cmdExport(arg)
+	case "serve":
+		cmdServe(arg)
+	case "sync":
+		cmdSync(arg)
+	case "board":
+		cmdBoard()
+	case "workflow":
+		cmdWorkflow(arg)
 
 	default:
 		fmt.Printf("Unknown command: %s\n", command)
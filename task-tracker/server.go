@@ -0,0 +1,525 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// "serve" exposes the task list as a small REST API on top of the same tasks.json
+//
+//	GET    /tasks              list tasks, ?status=todo filters
+//	POST   /tasks              create {"description": "...", "status": "todo"}
+//	GET    /tasks/{id}         one task
+//	PATCH  /tasks/{id}         change description and/or status
+//	DELETE /tasks/{id}         delete
+//	POST   /tasks/{id}/status  status transition {"status": "done"}
+//	GET    /events             server-sent events for every change
+//
+// every task response carries an ETag, sending it back in If-Match makes a
+// change fail with 412 when somebody else changed the task in the meantime.
+// status changes the workflow does not allow fail with 409
+
+const defaultServeAddr = "127.0.0.1:8080"
+
+// pollInterval is how often the file is checked for changes made by the cli
+const pollInterval = 2 * time.Second
+
+type taskEvent struct {
+	Type string `json:"type"` // created, updated, deleted or reloaded
+	Task *Task  `json:"task,omitempty"`
+}
+
+type server struct {
+	mu     sync.Mutex // serializes load-change-save so two requests don't overwrite each other
+	broker *broker
+
+	lastMod time.Time // modification time of the file after our last save
+}
+
+// httpError lets a change inside modify decide the response status
+type httpError struct {
+	status int
+	msg    string
+}
+
+func (e *httpError) Error() string { return e.msg }
+
+func cmdServe(args []string) {
+	addr := defaultServeAddr
+	if len(args) > 0 {
+		addr = args[0]
+	}
+
+	s := &server{broker: newBroker()}
+	s.lastMod = fileModTime()
+	go s.watchFile()
+
+	fmt.Printf("Serving tasks on http://%s\n", addr)
+	if err := http.ListenAndServe(addr, s.routes()); err != nil {
+		fmt.Println("Server stopped: ", err)
+	}
+}
+
+// routes returns the handler of the REST API, the tests call it with httptest
+func (s *server) routes() *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /tasks", s.handleList)
+	mux.HandleFunc("POST /tasks", s.handleCreate)
+	mux.HandleFunc("GET /tasks/{id}", s.handleGet)
+	mux.HandleFunc("PATCH /tasks/{id}", s.handleUpdate)
+	mux.HandleFunc("DELETE /tasks/{id}", s.handleDelete)
+	mux.HandleFunc("POST /tasks/{id}/status", s.handleStatus)
+	mux.HandleFunc("GET /events", s.handleEvents)
+	return mux
+}
+
+// ---------- handlers ----------
+
+func (s *server) handleList(w http.ResponseWriter, r *http.Request) {
+	tasks, err := loadTasks()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if status := r.URL.Query().Get("status"); status != "" {
+		filtered := []Task{}
+		for _, t := range tasks {
+			if t.Status == status {
+				filtered = append(filtered, t)
+			}
+		}
+		tasks = filtered
+	}
+
+	etag := computeETag(tasks)
+	w.Header().Set("ETag", etag)
+	if noneMatch(r, etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	writeJSON(w, http.StatusOK, tasks)
+}
+
+func (s *server) handleGet(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+	tasks, err := loadTasks()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	task, index := getbyID(tasks, id)
+	if index == -1 {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("task with ID %d not found", id))
+		return
+	}
+
+	etag := computeETag(task)
+	if noneMatch(r, etag) {
+		w.Header().Set("ETag", etag)
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	writeTask(w, http.StatusOK, task)
+}
+
+func (s *server) handleCreate(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Description string `json:"description"`
+		Status      string `json:"status"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid json: "+err.Error())
+		return
+	}
+	if body.Description == "" {
+		writeError(w, http.StatusBadRequest, "description is required")
+		return
+	}
+	if body.Status == "" {
+		body.Status = activeWorkflow.Initial
+	}
+	if !isValidStatus(body.Status) {
+		writeError(w, http.StatusBadRequest, "invalid status")
+		return
+	}
+
+	var created Task
+	saved, err := s.modify(func(tasks []Task) ([]Task, error) {
+		if err := activeWorkflow.checkMove(tasks, 0, body.Status); err != nil {
+			return nil, &httpError{http.StatusConflict, err.Error()}
+		}
+		now := time.Now()
+		created = Task{
+			ID:          getNextId(tasks),
+			Description: body.Description,
+			Status:      body.Status,
+			CreatedAt:   now,
+			UpdatedAt:   now,
+		}
+		return append(tasks, created), nil
+	})
+	if err != nil {
+		writeModifyError(w, err)
+		return
+	}
+	// the saved copy has the uid and clock saveTasks added, the ETag has to match it
+	task, _ := getbyID(saved, created.ID)
+	s.broker.publish(taskEvent{Type: "created", Task: task})
+	w.Header().Set("Location", fmt.Sprintf("/tasks/%d", task.ID))
+	writeTask(w, http.StatusCreated, task)
+}
+
+func (s *server) handleUpdate(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+	// pointers tell us which fields were sent
+	var body struct {
+		Description *string `json:"description"`
+		Status      *string `json:"status"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid json: "+err.Error())
+		return
+	}
+	if body.Description != nil && *body.Description == "" {
+		writeError(w, http.StatusBadRequest, "description can not be empty")
+		return
+	}
+	if body.Status != nil && !isValidStatus(*body.Status) {
+		writeError(w, http.StatusBadRequest, "invalid status")
+		return
+	}
+
+	status := ""
+	if body.Status != nil {
+		status = *body.Status
+	}
+	s.changeTask(w, r, id, status, func(task *Task) {
+		if body.Description != nil {
+			task.Description = *body.Description
+		}
+	})
+}
+
+func (s *server) handleStatus(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+	var body struct {
+		Status string `json:"status"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid json: "+err.Error())
+		return
+	}
+	if !isValidStatus(body.Status) {
+		writeError(w, http.StatusBadRequest, "invalid status")
+		return
+	}
+
+	s.changeTask(w, r, id, body.Status, nil)
+}
+
+func (s *server) handleDelete(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+
+	var deleted Task
+	_, err := s.modify(func(tasks []Task) ([]Task, error) {
+		task, index := getbyID(tasks, id)
+		if index == -1 {
+			return nil, &httpError{http.StatusNotFound, fmt.Sprintf("task with ID %d not found", id)}
+		}
+		if err := checkIfMatch(r, task); err != nil {
+			return nil, err
+		}
+		deleted = *task
+		return append(tasks[:index], tasks[index+1:]...), nil
+	})
+	if err != nil {
+		writeModifyError(w, err)
+		return
+	}
+	s.broker.publish(taskEvent{Type: "deleted", Task: &deleted})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// changeTask is shared by PATCH and the status transition
+// a non empty status is checked against the workflow before it is set
+func (s *server) changeTask(w http.ResponseWriter, r *http.Request, id int, status string, change func(task *Task)) {
+	saved, err := s.modify(func(tasks []Task) ([]Task, error) {
+		task, index := getbyID(tasks, id)
+		if index == -1 {
+			return nil, &httpError{http.StatusNotFound, fmt.Sprintf("task with ID %d not found", id)}
+		}
+		if err := checkIfMatch(r, task); err != nil {
+			return nil, err
+		}
+		if status != "" {
+			if err := activeWorkflow.checkMove(tasks, id, status); err != nil {
+				return nil, &httpError{http.StatusConflict, err.Error()}
+			}
+			task.Status = status
+		}
+		if change != nil {
+			change(task)
+		}
+		task.UpdatedAt = time.Now()
+		return tasks, nil
+	})
+	if err != nil {
+		writeModifyError(w, err)
+		return
+	}
+	updated, _ := getbyID(saved, id)
+	s.broker.publish(taskEvent{Type: "updated", Task: updated})
+	writeTask(w, http.StatusOK, updated)
+}
+
+// handleEvents keeps the connection open and streams every change as a server-sent event
+func (s *server) handleEvents(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, "streaming is not supported")
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+
+	// subscribe before the client sees the response, so it misses no change made after that
+	events := s.broker.subscribe()
+	defer s.broker.unsubscribe(events)
+
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case event := <-events:
+			data, err := json.Marshal(event)
+			if err != nil {
+				continue
+			}
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Type, data)
+			flusher.Flush()
+		}
+	}
+}
+
+// ---------- storage helpers ----------
+
+// modify loads the tasks, applies the change and saves them while holding the lock
+// it returns the tasks as they were saved, saveTasks adds the uid and counts the change on the clock
+func (s *server) modify(change func(tasks []Task) ([]Task, error)) ([]Task, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tasks, err := loadTasks()
+	if err != nil {
+		return nil, err
+	}
+	tasks, err = change(tasks)
+	if err != nil {
+		return nil, err
+	}
+	if err := saveTasks(tasks); err != nil {
+		return nil, err
+	}
+	s.lastMod = fileModTime()
+	return loadTasks()
+}
+
+// watchFile notices changes made by the cli or the interactive mode while the server runs
+func (s *server) watchFile() {
+	for range time.Tick(pollInterval) {
+		s.mu.Lock()
+		mod := fileModTime()
+		changed := !mod.Equal(s.lastMod)
+		s.lastMod = mod
+		s.mu.Unlock()
+
+		if changed {
+			s.broker.publish(taskEvent{Type: "reloaded"})
+		}
+	}
+}
+
+func fileModTime() time.Time {
+	info, err := os.Stat(taskFile)
+	if err != nil {
+		return time.Time{}
+	}
+	return info.ModTime()
+}
+
+// ---------- ETags ----------
+
+// computeETag hashes the json form of a value, any change to a task changes its ETag
+func computeETag(v any) string {
+	data, _ := json.Marshal(v)
+	sum := sha256.Sum256(data)
+	return `"` + hex.EncodeToString(sum[:8]) + `"`
+}
+
+// checkIfMatch lets a change through when If-Match is missing, "*" or lists the task's ETag
+// If-Match uses the strong comparison (RFC 7232 3.1), so a weak W/"..." tag never matches
+func checkIfMatch(r *http.Request, task *Task) error {
+	tags, ok := headerETags(r, "If-Match")
+	if !ok {
+		return nil
+	}
+	etag := computeETag(task)
+	for _, tag := range tags {
+		if tag == "*" || tag == etag {
+			return nil
+		}
+	}
+	return &httpError{http.StatusPreconditionFailed, "task was changed by someone else, reload it and try again"}
+}
+
+// noneMatch reports whether If-None-Match lists the ETag, then the client's copy is current
+// If-None-Match uses the weak comparison (RFC 7232 3.2), W/"x" matches "x"
+func noneMatch(r *http.Request, etag string) bool {
+	tags, _ := headerETags(r, "If-None-Match")
+	for _, tag := range tags {
+		if tag == "*" || strings.TrimPrefix(tag, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// headerETags parses an If-Match or If-None-Match header: "*" or a comma separated
+// list of entity tags like "abc", W/"abc". ok is false when the header is not sent
+// a malformed list ends at the first tag which can't be read
+func headerETags(r *http.Request, name string) (tags []string, ok bool) {
+	values := r.Header.Values(name)
+	if len(values) == 0 {
+		return nil, false
+	}
+	for _, value := range values {
+		rest := value
+		for {
+			rest = strings.TrimLeft(rest, " \t,")
+			if rest == "" {
+				break
+			}
+			if rest[0] == '*' {
+				tags = append(tags, "*")
+				rest = rest[1:]
+				continue
+			}
+			weak := strings.HasPrefix(rest, "W/")
+			if weak {
+				rest = rest[2:]
+			}
+			if !strings.HasPrefix(rest, `"`) {
+				break
+			}
+			end := strings.IndexByte(rest[1:], '"')
+			if end < 0 {
+				break
+			}
+			tag := rest[:end+2]
+			if weak {
+				tag = "W/" + tag
+			}
+			tags = append(tags, tag)
+			rest = rest[end+2:]
+		}
+	}
+	return tags, true
+}
+
+// ---------- responses ----------
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeTask(w http.ResponseWriter, status int, task *Task) {
+	w.Header().Set("ETag", computeETag(task))
+	writeJSON(w, status, task)
+}
+
+func writeError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, map[string]string{"error": msg})
+}
+
+func writeModifyError(w http.ResponseWriter, err error) {
+	var he *httpError
+	if errors.As(err, &he) {
+		writeError(w, he.status, he.msg)
+		return
+	}
+	writeError(w, http.StatusInternalServerError, err.Error())
+}
+
+func pathID(w http.ResponseWriter, r *http.Request) (int, bool) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid task ID")
+		return 0, false
+	}
+	return id, true
+}
+
+// ---------- events ----------
+
+// broker fans out events to every connected /events client
+type broker struct {
+	mu          sync.Mutex
+	subscribers map[chan taskEvent]struct{}
+}
+
+func newBroker() *broker {
+	return &broker{subscribers: make(map[chan taskEvent]struct{})}
+}
+
+func (b *broker) subscribe() chan taskEvent {
+	ch := make(chan taskEvent, 16)
+	b.mu.Lock()
+	b.subscribers[ch] = struct{}{}
+	b.mu.Unlock()
+	return ch
+}
+
+func (b *broker) unsubscribe(ch chan taskEvent) {
+	b.mu.Lock()
+	delete(b.subscribers, ch)
+	b.mu.Unlock()
+}
+
+// publish never blocks, a client which does not keep up misses events
+func (b *broker) publish(event taskEvent) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for ch := range b.subscribers {
+		select {
+		case ch <- event:
+		default:
+		}
+	}
+}
@@ -0,0 +1,219 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// newTestServer serves an empty tasks.json in a temporary directory
+func newTestServer(t *testing.T) *server {
+	t.Helper()
+	t.Chdir(t.TempDir())
+	t.Setenv("TASK_TRACKER_DEVICE", "test")
+	return &server{broker: newBroker()}
+}
+
+// request sends a request to the server, headers are name, value pairs
+func request(t *testing.T, s *server, method, path, body string, headers ...string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	for i := 0; i+1 < len(headers); i += 2 {
+		req.Header.Add(headers[i], headers[i+1])
+	}
+	rec := httptest.NewRecorder()
+	s.routes().ServeHTTP(rec, req)
+	return rec
+}
+
+func expectStatus(t *testing.T, rec *httptest.ResponseRecorder, status int, what string) {
+	t.Helper()
+	if rec.Code != status {
+		t.Fatalf("%s: status %d, want %d: %s", what, rec.Code, status, rec.Body)
+	}
+}
+
+// the ETag of a response has to be the ETag of the saved task, saveTasks adds
+// the uid and the clock after the handler made its change
+func TestETagOfChangesMatchesSavedTask(t *testing.T) {
+	s := newTestServer(t)
+
+	rec := request(t, s, "POST", "/tasks", `{"description": "buy milk"}`)
+	expectStatus(t, rec, http.StatusCreated, "create")
+	etag := rec.Header().Get("ETag")
+	var created Task
+	if err := json.NewDecoder(rec.Body).Decode(&created); err != nil {
+		t.Fatal(err)
+	}
+	if created.UID == "" || len(created.Clock) == 0 {
+		t.Errorf("created task has no uid or clock: %+v", created)
+	}
+
+	rec = request(t, s, "PATCH", "/tasks/1", `{"description": "buy oat milk"}`, "If-Match", etag)
+	expectStatus(t, rec, http.StatusOK, "PATCH with the ETag of the create")
+	etag = rec.Header().Get("ETag")
+
+	rec = request(t, s, "PATCH", "/tasks/1", `{"status": "in-progress"}`, "If-Match", etag)
+	expectStatus(t, rec, http.StatusOK, "PATCH with the ETag of the first PATCH")
+
+	if get := request(t, s, "GET", "/tasks/1", ""); get.Header().Get("ETag") != rec.Header().Get("ETag") {
+		t.Errorf("GET ETag %s, PATCH returned %s", get.Header().Get("ETag"), rec.Header().Get("ETag"))
+	}
+}
+
+func TestHeaderETags(t *testing.T) {
+	tests := []struct {
+		values []string
+		want   []string
+		ok     bool
+	}{
+		{nil, nil, false},
+		{[]string{`"abc"`}, []string{`"abc"`}, true},
+		{[]string{`*`}, []string{`*`}, true},
+		{[]string{`W/"abc"`}, []string{`W/"abc"`}, true},
+		{[]string{`"a", W/"b" ,"c"`}, []string{`"a"`, `W/"b"`, `"c"`}, true},
+		{[]string{`"a"`, `"b"`}, []string{`"a"`, `"b"`}, true},
+		{[]string{`"a,b"`}, []string{`"a,b"`}, true},
+		{[]string{`abc`}, nil, true},
+		{[]string{`"a", b, "c"`}, []string{`"a"`}, true},
+		{[]string{`"abc`}, nil, true},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", "/tasks", nil)
+		for _, v := range tt.values {
+			req.Header.Add("If-Match", v)
+		}
+		got, ok := headerETags(req, "If-Match")
+		if ok != tt.ok || strings.Join(got, " ") != strings.Join(tt.want, " ") {
+			t.Errorf("headerETags(%q) = %q, %v, want %q, %v", tt.values, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestIfMatch(t *testing.T) {
+	s := newTestServer(t)
+	expectStatus(t, request(t, s, "POST", "/tasks", `{"description": "buy milk"}`), http.StatusCreated, "create")
+
+	tests := []struct {
+		name    string
+		ifMatch func(etag string) []string // the If-Match header lines for the current ETag
+		want    int
+	}{
+		{"no header", func(string) []string { return nil }, http.StatusOK},
+		{"current", func(etag string) []string { return []string{etag} }, http.StatusOK},
+		{"any", func(string) []string { return []string{"*"} }, http.StatusOK},
+		{"in a list", func(etag string) []string { return []string{`"old", ` + etag} }, http.StatusOK},
+		{"in a second header line", func(etag string) []string { return []string{`"old"`, etag} }, http.StatusOK},
+		{"stale", func(string) []string { return []string{`"0000000000000000"`} }, http.StatusPreconditionFailed},
+		{"weak never matches", func(etag string) []string { return []string{"W/" + etag} }, http.StatusPreconditionFailed},
+		{"without quotes", func(etag string) []string { return []string{strings.Trim(etag, `"`)} }, http.StatusPreconditionFailed},
+	}
+	for _, tt := range tests {
+		etag := request(t, s, "GET", "/tasks/1", "").Header().Get("ETag")
+		var headers []string
+		for _, v := range tt.ifMatch(etag) {
+			headers = append(headers, "If-Match", v)
+		}
+		rec := request(t, s, "PATCH", "/tasks/1", `{"description": "buy milk `+tt.name+`"}`, headers...)
+		if rec.Code != tt.want {
+			t.Errorf("%s: PATCH status %d, want %d: %s", tt.name, rec.Code, tt.want, rec.Body)
+		}
+	}
+
+	rec := request(t, s, "DELETE", "/tasks/1", "", "If-Match", `"0000000000000000"`)
+	expectStatus(t, rec, http.StatusPreconditionFailed, "DELETE with a stale ETag")
+	etag := request(t, s, "GET", "/tasks/1", "").Header().Get("ETag")
+	expectStatus(t, request(t, s, "DELETE", "/tasks/1", "", "If-Match", etag), http.StatusNoContent, "DELETE with the current ETag")
+}
+
+func TestIfNoneMatch(t *testing.T) {
+	s := newTestServer(t)
+	expectStatus(t, request(t, s, "POST", "/tasks", `{"description": "buy milk"}`), http.StatusCreated, "create")
+
+	for _, path := range []string{"/tasks", "/tasks/1", "/tasks?status=todo"} {
+		etag := request(t, s, "GET", path, "").Header().Get("ETag")
+		if etag == "" {
+			t.Fatalf("GET %s has no ETag", path)
+		}
+		tests := []struct {
+			ifNoneMatch string
+			want        int
+		}{
+			{etag, http.StatusNotModified},
+			{"W/" + etag, http.StatusNotModified},
+			{`"old", ` + etag, http.StatusNotModified},
+			{"*", http.StatusNotModified},
+			{`"old"`, http.StatusOK},
+		}
+		for _, tt := range tests {
+			rec := request(t, s, "GET", path, "", "If-None-Match", tt.ifNoneMatch)
+			if rec.Code != tt.want {
+				t.Errorf("GET %s If-None-Match %s: status %d, want %d", path, tt.ifNoneMatch, rec.Code, tt.want)
+			}
+			if rec.Header().Get("ETag") != etag {
+				t.Errorf("GET %s If-None-Match %s: ETag %s, want %s", path, tt.ifNoneMatch, rec.Header().Get("ETag"), etag)
+			}
+		}
+	}
+
+	// after a change the old ETag is stale
+	etag := request(t, s, "GET", "/tasks", "").Header().Get("ETag")
+	expectStatus(t, request(t, s, "POST", "/tasks/1/status", `{"status": "done"}`), http.StatusOK, "status change")
+	expectStatus(t, request(t, s, "GET", "/tasks", "", "If-None-Match", etag), http.StatusOK, "GET with the ETag from before the change")
+}
+
+func TestEvents(t *testing.T) {
+	s := newTestServer(t)
+	ts := httptest.NewServer(s.routes())
+	defer ts.Close()
+
+	resp, err := http.Get(ts.URL + "/events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("Content-Type %q", ct)
+	}
+
+	created, err := http.Post(ts.URL+"/tasks", "application/json", strings.NewReader(`{"description": "buy milk"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	created.Body.Close()
+	req, _ := http.NewRequest("PATCH", ts.URL+"/tasks/1", strings.NewReader(`{"status": "done"}`))
+	req.Header.Set("If-Match", created.Header.Get("ETag"))
+	updated, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	updated.Body.Close()
+	if updated.StatusCode != http.StatusOK {
+		t.Fatalf("PATCH status %d", updated.StatusCode)
+	}
+
+	// every event carries the task as it was saved, with the ETag the response had
+	scanner := bufio.NewScanner(resp.Body)
+	for _, want := range []struct {
+		event string
+		etag  string
+	}{{"created", created.Header.Get("ETag")}, {"updated", updated.Header.Get("ETag")}} {
+		var lines []string
+		for scanner.Scan() && scanner.Text() != "" {
+			lines = append(lines, scanner.Text())
+		}
+		if len(lines) != 2 || lines[0] != "event: "+want.event || !strings.HasPrefix(lines[1], "data: ") {
+			t.Fatalf("event %q, want %s", lines, want.event)
+		}
+		var event taskEvent
+		if err := json.Unmarshal([]byte(strings.TrimPrefix(lines[1], "data: ")), &event); err != nil {
+			t.Fatal(err)
+		}
+		if event.Type != want.event || event.Task == nil || computeETag(event.Task) != want.etag {
+			t.Errorf("%s event = %+v, want the task with ETag %s", want.event, event, want.etag)
+		}
+	}
+}
@@ -4,10 +4,37 @@ import (
 	"encoding/json"
 	"errors"
 	"os"
+	"time"
 )
 
+// loadTasks returns the tasks which are not deleted
 func loadTasks() ([]Task, error) {
-	data, err := os.ReadFile(taskFile)
+	all, err := readTaskFile(taskFile)
+	if err != nil {
+		return nil, err
+	}
+	tasks := []Task{}
+	for _, t := range all {
+		if t.DeletedAt == nil {
+			tasks = append(tasks, t)
+		}
+	}
+	return tasks, nil
+}
+
+// saveTasks writes the tasks back, it compares them with the file to count the
+// change on this device's clock and to keep a tombstone for every deleted task
+func saveTasks(tasks []Task) error {
+	previous, err := readTaskFile(taskFile)
+	if err != nil {
+		return err
+	}
+	return writeTaskFile(taskFile, recordChanges(previous, tasks, deviceID(), time.Now()))
+}
+
+// readTaskFile reads every entry of a task file, tombstones included
+func readTaskFile(path string) ([]Task, error) {
+	data, err := os.ReadFile(path)
 	// data variable data type is []byte , what that means is that it holds raw bytes read from the file
 	//it is like a slice of bytes for example : []byte{0x7b, 0x22, 0x49, 0x44, 0x22, ...} which represents the json content
 	//[]byte{0x7b, 0x22, 0x49, 0x44, 0x22} what is that ?
@@ -29,16 +56,22 @@ func loadTasks() ([]Task, error) {
 	if err != nil {
 		return nil, err
 	}
+	// files written before sync existed have no uid yet
+	for i := range tasks {
+		if tasks[i].UID == "" {
+			tasks[i].UID = taskUID(tasks[i])
+		}
+	}
 	return tasks, nil
 }
 
-func saveTasks(tasks []Task) error {
+func writeTaskFile(path string, tasks []Task) error {
 	data, err := json.MarshalIndent(tasks, "", "  ")
 	if err != nil {
 		return err
 
 	}
-	return os.WriteFile(taskFile, data, 0644)
+	return os.WriteFile(path, data, 0644)
 }
 
 // get the next Id
@@ -0,0 +1,319 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// "sync" merges task files edited on several devices without losing changes
+//
+// every task has a uid and a vector clock: a map from device name to the number
+// of changes that device made to the task. saveTasks counts each change, and a
+// deleted task stays in the file as a tombstone so it can't come back in a sync.
+//
+// when both files have the same task, the clocks tell which version is newer:
+// if one clock includes all changes of the other, that version wins. if each
+// side has changes the other has not seen, the task was edited on both devices.
+// that is a conflict, the version changed last wins and the conflict is reported.
+
+// deviceID names this device in the vector clocks
+// it is the host name unless TASK_TRACKER_DEVICE is set
+func deviceID() string {
+	if id := os.Getenv("TASK_TRACKER_DEVICE"); id != "" {
+		return id
+	}
+	if host, err := os.Hostname(); err == nil && host != "" {
+		return host
+	}
+	return "local"
+}
+
+// taskUID is derived from the creation time and description so the same old
+// task gets the same uid on every device that upgrades its file
+func taskUID(t Task) string {
+	sum := sha256.Sum256([]byte(t.CreatedAt.UTC().Format(time.RFC3339Nano) + "\x00" + t.Description))
+	return hex.EncodeToString(sum[:8])
+}
+
+type vectorClock map[string]int
+
+// next returns a copy of the clock with one more change from device
+func (c vectorClock) next(device string) vectorClock {
+	n := vectorClock{}
+	for d, v := range c {
+		n[d] = v
+	}
+	n[device]++
+	return n
+}
+
+// merge returns a clock which includes the changes of both clocks
+func (c vectorClock) merge(other vectorClock) vectorClock {
+	n := vectorClock{}
+	for d, v := range c {
+		n[d] = v
+	}
+	for d, v := range other {
+		if v > n[d] {
+			n[d] = v
+		}
+	}
+	return n
+}
+
+type clockOrder int
+
+const (
+	clockEqual clockOrder = iota
+	clockBefore
+	clockAfter
+	clockConcurrent
+)
+
+// compare tells if c happened before, after or concurrently with other
+func (c vectorClock) compare(other vectorClock) clockOrder {
+	less, greater := false, false
+	for d, v := range c {
+		if v > other[d] {
+			greater = true
+		} else if v < other[d] {
+			less = true
+		}
+	}
+	for d, v := range other {
+		if _, ok := c[d]; !ok && v > 0 {
+			less = true
+		}
+	}
+	switch {
+	case less && greater:
+		return clockConcurrent
+	case less:
+		return clockBefore
+	case greater:
+		return clockAfter
+	}
+	return clockEqual
+}
+
+// sameVersion reports whether two copies of a task have the same content
+func sameVersion(a, b Task) bool {
+	return a.Description == b.Description &&
+		a.Status == b.Status &&
+		a.UpdatedAt.Equal(b.UpdatedAt) &&
+		(a.DeletedAt == nil) == (b.DeletedAt == nil)
+}
+
+// lastChange is when the task was last edited or deleted
+func lastChange(t Task) time.Time {
+	if t.DeletedAt != nil {
+		return *t.DeletedAt
+	}
+	return t.UpdatedAt
+}
+
+// recordChanges compares the tasks about to be saved with the file content:
+// new and edited tasks get a new clock entry and tasks that disappeared become tombstones
+func recordChanges(previous, tasks []Task, device string, now time.Time) []Task {
+	byUID := make(map[string]Task)
+	for _, t := range previous {
+		byUID[t.UID] = t
+	}
+
+	result := make([]Task, 0, len(previous)+1)
+	saved := make(map[string]bool)
+	for _, t := range tasks {
+		if t.UID == "" {
+			t.UID = taskUID(t)
+		}
+		old, ok := byUID[t.UID]
+		if !ok || !sameVersion(old, t) {
+			t.Clock = old.Clock.next(device)
+			t.DeletedAt = nil
+		}
+		saved[t.UID] = true
+		result = append(result, t)
+	}
+
+	for _, old := range previous {
+		if saved[old.UID] {
+			continue
+		}
+		if old.DeletedAt == nil {
+			deletedAt := now
+			old.DeletedAt = &deletedAt
+			old.Clock = old.Clock.next(device)
+		}
+		result = append(result, old)
+	}
+	return result
+}
+
+type syncConflict struct {
+	local  Task
+	remote Task
+	winner string // "local" or "remote"
+}
+
+func (c syncConflict) String() string {
+	kind := "edited on both devices"
+	switch {
+	case c.local.DeletedAt != nil && c.remote.DeletedAt == nil:
+		kind = "deleted here, edited on the other device"
+	case c.local.DeletedAt == nil && c.remote.DeletedAt != nil:
+		kind = "edited here, deleted on the other device"
+	}
+	return fmt.Sprintf("%q (uid %s): %s\n    local:  %s\n    remote: %s\n    kept the %s version",
+		c.local.Description, c.local.UID, kind, describeVersion(c.local), describeVersion(c.remote), c.winner)
+}
+
+func describeVersion(t Task) string {
+	if t.DeletedAt != nil {
+		return "deleted at " + t.DeletedAt.Format("2006-01-02 15:04:05")
+	}
+	return fmt.Sprintf("%q [%s] updated at %s", t.Description, t.Status, t.UpdatedAt.Format("2006-01-02 15:04:05"))
+}
+
+// mergeTasks merges remote into local, the order and the IDs of local tasks are kept
+// remote tasks whose ID is already taken get the next free ID
+func mergeTasks(local, remote []Task) ([]Task, []syncConflict) {
+	merged := append([]Task(nil), local...)
+	index := make(map[string]int)
+	for i, t := range merged {
+		index[t.UID] = i
+	}
+
+	var conflicts []syncConflict
+	for _, r := range remote {
+		i, ok := index[r.UID]
+		if !ok {
+			index[r.UID] = len(merged)
+			merged = append(merged, r)
+			continue
+		}
+
+		l := merged[i]
+		order := l.Clock.compare(r.Clock)
+		if order == clockEqual && !sameVersion(l, r) {
+			// both sides changed a task which had no clock yet
+			order = clockConcurrent
+		}
+		switch order {
+		case clockBefore:
+			r.ID = l.ID
+			merged[i] = r
+		case clockConcurrent:
+			conflict := syncConflict{local: l, remote: r, winner: "local"}
+			winner := l
+			if lastChange(r).After(lastChange(l)) {
+				conflict.winner = "remote"
+				winner = r
+				winner.ID = l.ID
+			}
+			winner.Clock = l.Clock.merge(r.Clock)
+			merged[i] = winner
+			conflicts = append(conflicts, conflict)
+		}
+	}
+
+	// live local tasks keep their ID, every other live task gets a free one
+	used := make(map[int]bool)
+	localLive := make(map[string]bool)
+	for _, t := range local {
+		if t.DeletedAt == nil {
+			used[t.ID] = true
+			localLive[t.UID] = true
+		}
+	}
+	maxID := 0
+	for _, t := range merged {
+		if t.ID > maxID {
+			maxID = t.ID
+		}
+	}
+	for i := range merged {
+		t := &merged[i]
+		if t.DeletedAt != nil || localLive[t.UID] {
+			continue
+		}
+		if used[t.ID] || t.ID <= 0 {
+			maxID++
+			t.ID = maxID
+		}
+		used[t.ID] = true
+	}
+	return merged, conflicts
+}
+
+// cmdSync merges the local tasks with another task file or a shared directory
+// instance: go run . sync ~/Dropbox/tasks.json
+// with a directory every device writes its own tasks-<device>.json there and
+// reads the files of all the others, so nobody overwrites anybody
+func cmdSync(args []string) {
+	if len(args) < 1 {
+		fmt.Println("usage: sync <file|directory>")
+		return
+	}
+	target := args[0]
+
+	local, err := readTaskFile(taskFile)
+	if err != nil {
+		fmt.Println("Error loading tasks: ", err)
+		return
+	}
+
+	sources := []string{target}
+	output := target
+	if info, err := os.Stat(target); err == nil && info.IsDir() {
+		output = filepath.Join(target, "tasks-"+deviceID()+".json")
+		sources, err = filepath.Glob(filepath.Join(target, "*.json"))
+		if err != nil {
+			fmt.Println("Error reading the directory: ", err)
+			return
+		}
+	}
+
+	merged := local
+	var conflicts []syncConflict
+	for _, source := range sources {
+		remote, err := readTaskFile(source)
+		if err != nil {
+			fmt.Printf("Error reading %s: %v\n", source, err)
+			return
+		}
+		var found []syncConflict
+		merged, found = mergeTasks(merged, remote)
+		conflicts = append(conflicts, found...)
+	}
+
+	if err := writeTaskFile(taskFile, merged); err != nil {
+		fmt.Println("Error saving tasks: ", err)
+		return
+	}
+	if err := writeTaskFile(output, merged); err != nil {
+		fmt.Printf("Error writing %s: %v\n", output, err)
+		return
+	}
+
+	live, deleted := 0, 0
+	for _, t := range merged {
+		if t.DeletedAt == nil {
+			live++
+		} else {
+			deleted++
+		}
+	}
+	fmt.Printf("Synced with %s: %d tasks, %d deleted, %d conflicts\n", target, live, deleted, len(conflicts))
+	if len(conflicts) > 0 {
+		fmt.Println()
+		fmt.Println("Conflict report:")
+		for _, c := range conflicts {
+			fmt.Println("  " + strings.ReplaceAll(c.String(), "\n", "\n  "))
+		}
+	}
+}
@@ -0,0 +1,215 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestVectorClockCompare(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b vectorClock
+		want clockOrder
+	}{
+		{"both empty", nil, nil, clockEqual},
+		{"same", vectorClock{"laptop": 2, "phone": 1}, vectorClock{"laptop": 2, "phone": 1}, clockEqual},
+		{"zero entry is no change", vectorClock{"laptop": 1, "phone": 0}, vectorClock{"laptop": 1}, clockEqual},
+		{"before", vectorClock{"laptop": 1}, vectorClock{"laptop": 2}, clockBefore},
+		{"before, other device added", vectorClock{"laptop": 1}, vectorClock{"laptop": 1, "phone": 1}, clockBefore},
+		{"after", vectorClock{"laptop": 3, "phone": 1}, vectorClock{"laptop": 2, "phone": 1}, clockAfter},
+		{"after empty", vectorClock{"laptop": 1}, nil, clockAfter},
+		{"concurrent", vectorClock{"laptop": 2, "phone": 1}, vectorClock{"laptop": 1, "phone": 2}, clockConcurrent},
+		{"concurrent, different devices", vectorClock{"laptop": 1}, vectorClock{"phone": 1}, clockConcurrent},
+	}
+	for _, tt := range tests {
+		if got := tt.a.compare(tt.b); got != tt.want {
+			t.Errorf("%s: %v.compare(%v) = %d, want %d", tt.name, tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestVectorClockNextAndMerge(t *testing.T) {
+	c := vectorClock{"laptop": 1}
+	n := c.next("phone")
+	if c["phone"] != 0 {
+		t.Errorf("next() changed the original clock: %v", c)
+	}
+	if n["laptop"] != 1 || n["phone"] != 1 {
+		t.Errorf("next(phone) = %v", n)
+	}
+
+	m := vectorClock{"laptop": 3, "phone": 1}.merge(vectorClock{"laptop": 2, "phone": 4, "desk": 1})
+	want := vectorClock{"laptop": 3, "phone": 4, "desk": 1}
+	if m.compare(want) != clockEqual {
+		t.Errorf("merge() = %v, want %v", m, want)
+	}
+}
+
+func TestMergeTasks(t *testing.T) {
+	created := time.Date(2025, 3, 1, 9, 0, 0, 0, time.UTC)
+	at := func(minutes int) time.Time { return created.Add(time.Duration(minutes) * time.Minute) }
+	task := func(id int, uid, description string, updated int, clock vectorClock) Task {
+		return Task{ID: id, UID: uid, Description: description, Status: statusToDo,
+			CreatedAt: created, UpdatedAt: at(updated), Clock: clock}
+	}
+	deleted := func(t Task, minutes int) Task {
+		d := at(minutes)
+		t.DeletedAt = &d
+		return t
+	}
+
+	tests := []struct {
+		name          string
+		local, remote []Task
+		want          []Task // compared by ID, UID, description and whether deleted
+		conflicts     int
+		winner        string
+	}{
+		{
+			name:   "remote is newer",
+			local:  []Task{task(1, "a", "buy milk", 0, vectorClock{"laptop": 1})},
+			remote: []Task{task(1, "a", "buy oat milk", 5, vectorClock{"laptop": 1, "phone": 1})},
+			want:   []Task{task(1, "a", "buy oat milk", 0, nil)},
+		},
+		{
+			name:   "local is newer",
+			local:  []Task{task(1, "a", "buy oat milk", 5, vectorClock{"laptop": 2})},
+			remote: []Task{task(1, "a", "buy milk", 0, vectorClock{"laptop": 1})},
+			want:   []Task{task(1, "a", "buy oat milk", 0, nil)},
+		},
+		{
+			name:      "edited on both, remote changed last",
+			local:     []Task{task(1, "a", "buy milk today", 5, vectorClock{"laptop": 2})},
+			remote:    []Task{task(1, "a", "buy oat milk", 9, vectorClock{"laptop": 1, "phone": 1})},
+			want:      []Task{task(1, "a", "buy oat milk", 0, nil)},
+			conflicts: 1,
+			winner:    "remote",
+		},
+		{
+			name:      "edited on both, local changed last",
+			local:     []Task{task(1, "a", "buy milk today", 9, vectorClock{"laptop": 2})},
+			remote:    []Task{task(1, "a", "buy oat milk", 5, vectorClock{"laptop": 1, "phone": 1})},
+			want:      []Task{task(1, "a", "buy milk today", 0, nil)},
+			conflicts: 1,
+			winner:    "local",
+		},
+		{
+			name:   "deleted on the other device",
+			local:  []Task{task(1, "a", "buy milk", 0, vectorClock{"laptop": 1})},
+			remote: []Task{deleted(task(1, "a", "buy milk", 0, vectorClock{"laptop": 1, "phone": 1}), 5)},
+			want:   []Task{deleted(task(1, "a", "buy milk", 0, nil), 5)},
+		},
+		{
+			name:   "tombstone does not come back",
+			local:  []Task{deleted(task(1, "a", "buy milk", 0, vectorClock{"laptop": 2}), 5)},
+			remote: []Task{task(1, "a", "buy milk", 0, vectorClock{"laptop": 1})},
+			want:   []Task{deleted(task(1, "a", "buy milk", 0, nil), 5)},
+		},
+		{
+			name:      "deleted here, edited there",
+			local:     []Task{deleted(task(1, "a", "buy milk", 0, vectorClock{"laptop": 2}), 5)},
+			remote:    []Task{task(1, "a", "buy oat milk", 9, vectorClock{"laptop": 1, "phone": 1})},
+			want:      []Task{task(1, "a", "buy oat milk", 0, nil)},
+			conflicts: 1,
+			winner:    "remote",
+		},
+		{
+			name:      "same task without clocks, changed on both",
+			local:     []Task{task(1, "a", "buy milk", 5, nil)},
+			remote:    []Task{task(1, "a", "buy bread", 9, nil)},
+			want:      []Task{task(1, "a", "buy bread", 0, nil)},
+			conflicts: 1,
+			winner:    "remote",
+		},
+		{
+			name:   "new remote task gets a free ID",
+			local:  []Task{task(1, "a", "buy milk", 0, vectorClock{"laptop": 1}), task(2, "b", "call mum", 0, vectorClock{"laptop": 1})},
+			remote: []Task{task(1, "c", "water plants", 0, vectorClock{"phone": 1})},
+			want:   []Task{task(1, "a", "buy milk", 0, nil), task(2, "b", "call mum", 0, nil), task(3, "c", "water plants", 0, nil)},
+		},
+		{
+			name:   "new remote task keeps a free ID",
+			local:  []Task{task(1, "a", "buy milk", 0, vectorClock{"laptop": 1})},
+			remote: []Task{task(7, "c", "water plants", 0, vectorClock{"phone": 1})},
+			want:   []Task{task(1, "a", "buy milk", 0, nil), task(7, "c", "water plants", 0, nil)},
+		},
+	}
+	for _, tt := range tests {
+		merged, conflicts := mergeTasks(tt.local, tt.remote)
+		if len(merged) != len(tt.want) {
+			t.Errorf("%s: mergeTasks() returned %d tasks, want %d", tt.name, len(merged), len(tt.want))
+			continue
+		}
+		for i, w := range tt.want {
+			got := merged[i]
+			if got.ID != w.ID || got.UID != w.UID || got.Description != w.Description || (got.DeletedAt == nil) != (w.DeletedAt == nil) {
+				t.Errorf("%s: task %d = #%d %s %q deleted %v, want #%d %s %q deleted %v", tt.name, i,
+					got.ID, got.UID, got.Description, got.DeletedAt != nil, w.ID, w.UID, w.Description, w.DeletedAt != nil)
+			}
+		}
+		if len(conflicts) != tt.conflicts {
+			t.Errorf("%s: %d conflicts, want %d", tt.name, len(conflicts), tt.conflicts)
+			continue
+		}
+		if tt.conflicts > 0 && conflicts[0].winner != tt.winner {
+			t.Errorf("%s: kept the %s version, want %s", tt.name, conflicts[0].winner, tt.winner)
+		}
+	}
+}
+
+// after a conflict the merged clock includes both sides, so the next sync in
+// the other direction is not reported again
+func TestMergeTasksConflictOnlyOnce(t *testing.T) {
+	created := time.Date(2025, 3, 1, 9, 0, 0, 0, time.UTC)
+	local := []Task{{ID: 1, UID: "a", Description: "buy milk today", Status: statusToDo,
+		CreatedAt: created, UpdatedAt: created.Add(time.Minute), Clock: vectorClock{"laptop": 2}}}
+	remote := []Task{{ID: 1, UID: "a", Description: "buy oat milk", Status: statusToDo,
+		CreatedAt: created, UpdatedAt: created.Add(2 * time.Minute), Clock: vectorClock{"laptop": 1, "phone": 1}}}
+
+	merged, conflicts := mergeTasks(local, remote)
+	if len(conflicts) != 1 {
+		t.Fatalf("first sync: %d conflicts, want 1", len(conflicts))
+	}
+	if _, conflicts := mergeTasks(remote, merged); len(conflicts) != 0 {
+		t.Errorf("second sync: %d conflicts, want 0", len(conflicts))
+	}
+}
+
+func TestRecordChanges(t *testing.T) {
+	created := time.Date(2025, 3, 1, 9, 0, 0, 0, time.UTC)
+	now := created.Add(time.Hour)
+	milk := Task{ID: 1, UID: "a", Description: "buy milk", Status: statusToDo, CreatedAt: created, UpdatedAt: created, Clock: vectorClock{"laptop": 1}}
+	mum := Task{ID: 2, UID: "b", Description: "call mum", Status: statusToDo, CreatedAt: created, UpdatedAt: created, Clock: vectorClock{"laptop": 1}}
+	previous := []Task{milk, mum}
+
+	edited := milk
+	edited.Status = statusDone
+	edited.UpdatedAt = now
+	fresh := Task{ID: 3, Description: "water plants", Status: statusToDo, CreatedAt: now, UpdatedAt: now}
+
+	// milk is edited, mum is deleted and a new task is added
+	saved := recordChanges(previous, []Task{edited, fresh}, "phone", now)
+	if len(saved) != 3 {
+		t.Fatalf("recordChanges() returned %d tasks, want 3", len(saved))
+	}
+	if got := saved[0].Clock; got["laptop"] != 1 || got["phone"] != 1 {
+		t.Errorf("clock of the edited task = %v", got)
+	}
+	if saved[1].UID != taskUID(fresh) || saved[1].Clock["phone"] != 1 {
+		t.Errorf("new task = uid %q clock %v", saved[1].UID, saved[1].Clock)
+	}
+	if saved[2].UID != "b" || saved[2].DeletedAt == nil || !saved[2].DeletedAt.Equal(now) || saved[2].Clock["phone"] != 1 {
+		t.Errorf("deleted task = %+v, want a tombstone", saved[2])
+	}
+
+	// saving again without changes keeps the clocks
+	again := recordChanges(saved, []Task{saved[0], saved[1]}, "phone", now.Add(time.Hour))
+	for i := range again {
+		if again[i].Clock.compare(saved[i].Clock) != clockEqual {
+			t.Errorf("task %s: clock %v changed to %v without an edit", again[i].UID, saved[i].Clock, again[i].Clock)
+		}
+	}
+	if !again[2].DeletedAt.Equal(now) {
+		t.Errorf("tombstone deleted at %v, want %v", again[2].DeletedAt, now)
+	}
+}
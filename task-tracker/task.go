@@ -7,19 +7,31 @@ import (
 // file to be saved
 const taskFile = "tasks.json"
 
-// represents each status
+// optional file with custom statuses, see workflow.go
+const workflowFile = "workflow.json"
+
+// the statuses of the default workflow
 const (
 	statusToDo       = "todo"
 	statusInProgress = "in-progress"
 	statusDone       = "done"
 )
 
+func isValidStatus(status string) bool {
+	return activeWorkflow.hasStatus(status)
+}
+
 type Task struct {
 	ID          int       `json:"ID"`
 	Description string    `json:"description"`
 	Status      string    `json:"status"`
 	CreatedAt   time.Time `json:"createdAt"`
 	UpdatedAt   time.Time `json:"updatedAt"`
+
+	// used by "sync" to merge task files from several devices, see sync.go
+	UID       string      `json:"uid,omitempty"`       // stable identity, the ID is only unique per file
+	Clock     vectorClock `json:"clock,omitempty"`     // how many changes each device made to this task
+	DeletedAt *time.Time  `json:"deletedAt,omitempty"` // set on tombstones, which are kept so a sync does not bring the task back
 	//variable which starts with uppercase letter will be exported in json
 	// and small letter will not be exported
 }
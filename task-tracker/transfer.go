@@ -0,0 +1,481 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// this file moves tasks in and out of other tools
+// every format has a writer used by "export" and a reader used by "import"
+// readers only fill Description, Status, CreatedAt and UpdatedAt, the IDs are
+// always given again when the tasks are added to our own list (see mergeImported)
+
+type taskFormat struct {
+	write func(w io.Writer, tasks []Task) error
+	read  func(r io.Reader) ([]Task, error)
+}
+
+var taskFormats = map[string]taskFormat{
+	"todotxt":  {writeTodoTxt, readTodoTxt},
+	"markdown": {writeMarkdown, readMarkdown},
+	"csv":      {writeCSV, readCSV},
+	"ical":     {writeICal, readICal},
+}
+
+// other names people use for the same formats
+var formatAliases = map[string]string{
+	"todo.txt":  "todotxt",
+	"todo":      "todotxt",
+	"md":        "markdown",
+	"ics":       "ical",
+	"icalendar": "ical",
+}
+
+const dateLayout = "2006-01-02"
+
+func lookupFormat(name string) (taskFormat, error) {
+	name = strings.ToLower(name)
+	if alias, ok := formatAliases[name]; ok {
+		name = alias
+	}
+	format, ok := taskFormats[name]
+	if !ok {
+		return taskFormat{}, fmt.Errorf("unknown format %q, use one of: todotxt, markdown, csv, ical", name)
+	}
+	return format, nil
+}
+
+// duplicateKey is what two tasks must share to be the same task when importing:
+// the description (ignoring case and surrounding spaces) and the day it was created.
+// A task read from a file without a creation date has no day to compare, it is the
+// same task as one with the same description and status.
+func duplicateKey(t Task) string {
+	description := strings.ToLower(strings.TrimSpace(t.Description))
+	if t.CreatedAt.IsZero() {
+		return description + "|status:" + t.Status
+	}
+	return description + "|" + t.CreatedAt.Format(dateLayout)
+}
+
+// mergeImported appends the imported tasks which are not already in the list
+// it returns the new list and how many tasks were added and skipped
+func mergeImported(tasks []Task, imported []Task) ([]Task, int, int) {
+	seen := make(map[string]bool)
+	for _, t := range tasks {
+		seen[duplicateKey(t)] = true
+		undated := t
+		undated.CreatedAt = time.Time{}
+		seen[duplicateKey(undated)] = true
+	}
+
+	now := time.Now()
+	nextID := getNextId(tasks)
+	added, skipped := 0, 0
+	for _, t := range imported {
+		key := duplicateKey(t)
+		if seen[key] {
+			skipped++
+			continue
+		}
+		seen[key] = true
+		// the task is new, so it was created now
+		if t.CreatedAt.IsZero() {
+			t.CreatedAt = now
+		}
+		if t.UpdatedAt.IsZero() {
+			t.UpdatedAt = t.CreatedAt
+		}
+		t.ID = nextID
+		nextID++
+		tasks = append(tasks, t)
+		added++
+	}
+	return tasks, added, skipped
+}
+
+// newImportedTask fills the fields a format did not provide
+// CreatedAt stays zero when the file has no date, mergeImported needs to know that
+func newImportedTask(description, status string, createdAt, updatedAt time.Time) Task {
+	if !isValidStatus(status) {
+		status = activeWorkflow.Initial
+	}
+	if updatedAt.IsZero() {
+		updatedAt = createdAt
+	}
+	return Task{
+		Description: strings.TrimSpace(description),
+		Status:      status,
+		CreatedAt:   createdAt,
+		UpdatedAt:   updatedAt,
+	}
+}
+
+// parseTime accepts the full timestamps we write and plain dates
+func parseTime(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339Nano, value); err == nil {
+		return t, nil
+	}
+	return time.ParseInLocation(dateLayout, value, time.Local)
+}
+
+// ---------- todo.txt ----------
+// a line looks like: x 2025-11-16 2025-11-15 buy a house
+// "x" marks a done task and is followed by the completion date, then the creation date
+// todo.txt only knows open and done, any other status is kept in a tag like status:in-progress
+
+var priorityPattern = regexp.MustCompile(`^\([A-Z]\)$`)
+
+func writeTodoTxt(w io.Writer, tasks []Task) error {
+	for _, t := range tasks {
+		var line string
+		if t.Status == activeWorkflow.Done {
+			line = fmt.Sprintf("x %s %s %s", t.UpdatedAt.Format(dateLayout), t.CreatedAt.Format(dateLayout), t.Description)
+		} else {
+			line = fmt.Sprintf("%s %s", t.CreatedAt.Format(dateLayout), t.Description)
+			if t.Status != activeWorkflow.Initial {
+				line += " status:" + t.Status
+			}
+		}
+		if _, err := fmt.Fprintln(w, line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func readTodoTxt(r io.Reader) ([]Task, error) {
+	var tasks []Task
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+
+		status := activeWorkflow.Initial
+		var createdAt, completedAt time.Time
+		if fields[0] == "x" {
+			status = activeWorkflow.Done
+			fields = fields[1:]
+			if len(fields) > 0 {
+				if d, err := time.ParseInLocation(dateLayout, fields[0], time.Local); err == nil {
+					completedAt = d
+					fields = fields[1:]
+				}
+			}
+		}
+		if len(fields) > 0 && priorityPattern.MatchString(fields[0]) {
+			fields = fields[1:]
+		}
+		if len(fields) > 0 {
+			if d, err := time.ParseInLocation(dateLayout, fields[0], time.Local); err == nil {
+				createdAt = d
+				fields = fields[1:]
+			}
+		}
+
+		var words []string
+		for _, field := range fields {
+			if value, ok := strings.CutPrefix(field, "status:"); ok && isValidStatus(value) {
+				status = value
+				continue
+			}
+			words = append(words, field)
+		}
+		if len(words) == 0 {
+			continue
+		}
+		tasks = append(tasks, newImportedTask(strings.Join(words, " "), status, createdAt, completedAt))
+	}
+	return tasks, scanner.Err()
+}
+
+// ---------- Markdown checklist ----------
+// - [ ] build a new project <!-- created:2025-11-15 status:in-progress -->
+// GitHub only knows checked and unchecked, the rest is kept in an HTML comment
+// so it does not show up when the file is rendered
+
+var checklistPattern = regexp.MustCompile(`^\s*[-*+]\s+\[([ xX])\]\s+(.*)$`)
+var commentPattern = regexp.MustCompile(`\s*<!--(.*?)-->\s*$`)
+
+func writeMarkdown(w io.Writer, tasks []Task) error {
+	if _, err := fmt.Fprint(w, "# Tasks\n\n"); err != nil {
+		return err
+	}
+	for _, t := range tasks {
+		check := " "
+		if t.Status == activeWorkflow.Done {
+			check = "x"
+		}
+		meta := "created:" + t.CreatedAt.Format(dateLayout)
+		if t.Status != activeWorkflow.Done && t.Status != activeWorkflow.Initial {
+			meta += " status:" + t.Status
+		}
+		if _, err := fmt.Fprintf(w, "- [%s] %s <!-- %s -->\n", check, t.Description, meta); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func readMarkdown(r io.Reader) ([]Task, error) {
+	var tasks []Task
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		match := checklistPattern.FindStringSubmatch(scanner.Text())
+		if match == nil {
+			continue
+		}
+		status := activeWorkflow.Initial
+		if match[1] != " " {
+			status = activeWorkflow.Done
+		}
+		description := match[2]
+
+		var createdAt time.Time
+		if comment := commentPattern.FindStringSubmatch(description); comment != nil {
+			description = strings.TrimSuffix(description, comment[0])
+			for _, field := range strings.Fields(comment[1]) {
+				key, value, _ := strings.Cut(field, ":")
+				switch key {
+				case "created":
+					if d, err := parseTime(value); err == nil {
+						createdAt = d
+					}
+				case "status":
+					if isValidStatus(value) && status != activeWorkflow.Done {
+						status = value
+					}
+				}
+			}
+		}
+		if strings.TrimSpace(description) == "" {
+			continue
+		}
+		tasks = append(tasks, newImportedTask(description, status, createdAt, time.Time{}))
+	}
+	return tasks, scanner.Err()
+}
+
+// ---------- CSV ----------
+// the columns have the same names as the json fields, columns can be in any order
+// and only "description" is required when importing
+
+var csvHeader = []string{"ID", "description", "status", "createdAt", "updatedAt"}
+
+func writeCSV(w io.Writer, tasks []Task) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(csvHeader); err != nil {
+		return err
+	}
+	for _, t := range tasks {
+		record := []string{
+			strconv.Itoa(t.ID),
+			t.Description,
+			t.Status,
+			t.CreatedAt.Format(time.RFC3339),
+			t.UpdatedAt.Format(time.RFC3339),
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func readCSV(r io.Reader) ([]Task, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	records, err := cr.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, nil
+	}
+
+	columns := make(map[string]int)
+	for i, name := range records[0] {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	if _, ok := columns["description"]; !ok {
+		return nil, fmt.Errorf("csv file has no description column")
+	}
+	// value returns the cell of a column or "" when the row or the column is missing
+	value := func(record []string, name string) string {
+		i, ok := columns[strings.ToLower(name)]
+		if !ok || i >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[i])
+	}
+
+	var tasks []Task
+	for line, record := range records[1:] {
+		description := value(record, "description")
+		if description == "" {
+			continue
+		}
+		var createdAt, updatedAt time.Time
+		if v := value(record, "createdAt"); v != "" {
+			if createdAt, err = parseTime(v); err != nil {
+				return nil, fmt.Errorf("line %d: invalid createdAt %q", line+2, v)
+			}
+		}
+		if v := value(record, "updatedAt"); v != "" {
+			if updatedAt, err = parseTime(v); err != nil {
+				return nil, fmt.Errorf("line %d: invalid updatedAt %q", line+2, v)
+			}
+		}
+		tasks = append(tasks, newImportedTask(description, value(record, "status"), createdAt, updatedAt))
+	}
+	return tasks, nil
+}
+
+// ---------- iCalendar VTODO ----------
+// every task becomes a VTODO inside a VCALENDAR (RFC 5545)
+// lines end with CRLF and long lines are folded by starting the next line with a space
+
+const icalTimeLayout = "20060102T150405Z"
+
+// iCalendar only knows NEEDS-ACTION, IN-PROCESS and COMPLETED, the workflow
+// status itself is kept in X-TASK-TRACKER-STATUS so it survives a round trip
+func icalStatus(status string) string {
+	switch status {
+	case activeWorkflow.Initial:
+		return "NEEDS-ACTION"
+	case activeWorkflow.Done:
+		return "COMPLETED"
+	}
+	return "IN-PROCESS"
+}
+
+func writeICal(w io.Writer, tasks []Task) error {
+	bw := bufio.NewWriter(w)
+	writeLine := func(line string) {
+		// fold lines longer than 75 bytes without cutting a character in half
+		for len(line) > 75 {
+			cut := 75
+			for cut > 0 && !utf8.RuneStart(line[cut]) {
+				cut--
+			}
+			bw.WriteString(line[:cut] + "\r\n")
+			line = " " + line[cut:]
+		}
+		bw.WriteString(line + "\r\n")
+	}
+	stamp := time.Now().UTC().Format(icalTimeLayout)
+
+	writeLine("BEGIN:VCALENDAR")
+	writeLine("VERSION:2.0")
+	writeLine("PRODID:-//task-tracker//EN")
+	for _, t := range tasks {
+		writeLine("BEGIN:VTODO")
+		writeLine(fmt.Sprintf("UID:task-%d-%d@task-tracker", t.ID, t.CreatedAt.Unix()))
+		writeLine("DTSTAMP:" + stamp)
+		writeLine("CREATED:" + t.CreatedAt.UTC().Format(icalTimeLayout))
+		writeLine("LAST-MODIFIED:" + t.UpdatedAt.UTC().Format(icalTimeLayout))
+		writeLine("SUMMARY:" + icalEscape(t.Description))
+		writeLine("STATUS:" + icalStatus(t.Status))
+		writeLine("X-TASK-TRACKER-STATUS:" + icalEscape(t.Status))
+		if t.Status == activeWorkflow.Done {
+			writeLine("COMPLETED:" + t.UpdatedAt.UTC().Format(icalTimeLayout))
+		}
+		writeLine("END:VTODO")
+	}
+	writeLine("END:VCALENDAR")
+	return bw.Flush()
+}
+
+func readICal(r io.Reader) ([]Task, error) {
+	// unfold first: a line starting with a space or tab continues the previous one
+	var lines []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) && len(lines) > 0 {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		lines = append(lines, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	var tasks []Task
+	var inTodo bool
+	var description, status, exactStatus string
+	var createdAt, updatedAt time.Time
+	for _, line := range lines {
+		name, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		// drop parameters like DTSTART;VALUE=DATE
+		name, _, _ = strings.Cut(strings.ToUpper(name), ";")
+
+		switch {
+		case name == "BEGIN" && value == "VTODO":
+			inTodo = true
+			description, status, exactStatus = "", activeWorkflow.Initial, ""
+			createdAt, updatedAt = time.Time{}, time.Time{}
+		case name == "END" && value == "VTODO":
+			inTodo = false
+			if isValidStatus(exactStatus) {
+				status = exactStatus
+			}
+			if strings.TrimSpace(description) != "" {
+				tasks = append(tasks, newImportedTask(description, status, createdAt, updatedAt))
+			}
+		case !inTodo:
+		case name == "SUMMARY":
+			description = icalUnescape(value)
+		case name == "STATUS":
+			switch strings.ToUpper(value) {
+			case "COMPLETED":
+				status = activeWorkflow.Done
+			case "IN-PROCESS":
+				if isValidStatus(statusInProgress) {
+					status = statusInProgress
+				}
+			}
+		case name == "X-TASK-TRACKER-STATUS":
+			exactStatus = icalUnescape(value)
+		case name == "COMPLETED":
+			status = activeWorkflow.Done
+		case name == "CREATED":
+			createdAt, _ = parseICalTime(value)
+		case name == "LAST-MODIFIED":
+			updatedAt, _ = parseICalTime(value)
+		}
+	}
+	return tasks, nil
+}
+
+func parseICalTime(value string) (time.Time, error) {
+	for _, layout := range []string{icalTimeLayout, "20060102T150405", "20060102"} {
+		loc := time.Local
+		if layout == icalTimeLayout {
+			loc = time.UTC
+		}
+		if t, err := time.ParseInLocation(layout, value, loc); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid iCalendar time %q", value)
+}
+
+var icalEscaper = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\n", `\n`)
+var icalUnescaper = strings.NewReplacer(`\\`, `\`, `\;`, ";", `\,`, ",", `\n`, "\n", `\N`, "\n")
+
+func icalEscape(s string) string   { return icalEscaper.Replace(s) }
+func icalUnescape(s string) string { return icalUnescaper.Replace(s) }
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+// exportedTasks covers what a format has to keep: every status, and descriptions with
+// the characters the formats escape or quote
+func exportedTasks() []Task {
+	created := time.Date(2025, 11, 15, 9, 30, 0, 0, time.Local)
+	return []Task{
+		{ID: 1, Description: "buy a house", Status: statusToDo, CreatedAt: created, UpdatedAt: created},
+		{ID: 2, Description: "write report, part 2; \"final\"", Status: statusInProgress, CreatedAt: created.AddDate(0, 0, 1), UpdatedAt: created.AddDate(0, 0, 2)},
+		{ID: 3, Description: `back\slash and ümlauts` + strings.Repeat(" long", 20), Status: statusDone, CreatedAt: created.AddDate(0, 0, 2), UpdatedAt: created.AddDate(0, 0, 3)},
+	}
+}
+
+func TestFormatsRoundTrip(t *testing.T) {
+	for _, name := range []string{"todotxt", "markdown", "csv", "ical"} {
+		format, err := lookupFormat(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tasks := exportedTasks()
+		var buf bytes.Buffer
+		if err := format.write(&buf, tasks); err != nil {
+			t.Fatalf("%s: write: %v", name, err)
+		}
+		read, err := format.read(&buf)
+		if err != nil {
+			t.Fatalf("%s: read: %v", name, err)
+		}
+		if len(read) != len(tasks) {
+			t.Fatalf("%s: read %d tasks, want %d", name, len(read), len(tasks))
+		}
+		for i, got := range read {
+			want := tasks[i]
+			// todo.txt and Markdown only keep the day
+			if got.Description != want.Description || got.Status != want.Status ||
+				got.CreatedAt.Format(dateLayout) != want.CreatedAt.Format(dateLayout) {
+				t.Errorf("%s: task %d read back as %q %s %s, want %q %s %s", name, i+1,
+					got.Description, got.Status, got.CreatedAt.Format(dateLayout),
+					want.Description, want.Status, want.CreatedAt.Format(dateLayout))
+			}
+		}
+
+		// importing what was exported adds nothing
+		merged, added, skipped := mergeImported(tasks, read)
+		if added != 0 || skipped != len(tasks) || len(merged) != len(tasks) {
+			t.Errorf("%s: importing the export added %d and skipped %d", name, added, skipped)
+		}
+	}
+}
+
+func TestFormatsKeepTimes(t *testing.T) {
+	tasks := exportedTasks()
+	for _, name := range []string{"csv", "ical"} {
+		format, _ := lookupFormat(name)
+		var buf bytes.Buffer
+		if err := format.write(&buf, tasks); err != nil {
+			t.Fatal(err)
+		}
+		read, err := format.read(&buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i, got := range read {
+			if !got.CreatedAt.Equal(tasks[i].CreatedAt) || !got.UpdatedAt.Equal(tasks[i].UpdatedAt) {
+				t.Errorf("%s: task %d times %s %s, want %s %s", name, i+1, got.CreatedAt, got.UpdatedAt, tasks[i].CreatedAt, tasks[i].UpdatedAt)
+			}
+		}
+	}
+}
+
+func TestICalFoldsLongLines(t *testing.T) {
+	var buf bytes.Buffer
+	if err := writeICal(&buf, exportedTasks()); err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range strings.Split(strings.TrimSuffix(buf.String(), "\r\n"), "\r\n") {
+		if len(line) > 75 {
+			t.Errorf("line of %d bytes: %q", len(line), line)
+		}
+	}
+}
+
+func TestMergeImported(t *testing.T) {
+	created := time.Date(2025, 11, 15, 0, 0, 0, 0, time.Local)
+	tasks := []Task{
+		{ID: 1, Description: "buy milk", Status: statusToDo, CreatedAt: created},
+		{ID: 7, Description: "Call Bob", Status: statusDone, CreatedAt: created},
+	}
+	imported, err := readTodoTxt(strings.NewReader(strings.Join([]string{
+		"2025-11-15 Buy Milk", // same day, other case
+		"2025-11-16 buy milk", // another day, another task
+		"buy milk",            // no date, same status as task 1
+		"call bob",            // no date, but task 7 is done
+		"x call bob",          // no date, done like task 7
+		"water the plants",    // no date, new
+		"water the plants",    // the same line twice
+		"(A) 2025-11-17 pay the rent",
+	}, "\n")))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	before := time.Now()
+	merged, added, skipped := mergeImported(tasks, imported)
+	if added != 4 || skipped != 4 {
+		t.Errorf("added %d and skipped %d, want 4 and 4", added, skipped)
+	}
+	var got []string
+	for _, task := range merged[len(tasks):] {
+		got = append(got, fmt.Sprintf("%d %s %s", task.ID, task.Description, task.Status))
+		if task.CreatedAt.IsZero() || task.UpdatedAt.Before(task.CreatedAt) {
+			t.Errorf("task %d created %s, updated %s", task.ID, task.CreatedAt, task.UpdatedAt)
+		}
+	}
+	want := []string{"8 buy milk todo", "9 call bob todo", "10 water the plants todo", "11 pay the rent todo"}
+	if strings.Join(got, ", ") != strings.Join(want, ", ") {
+		t.Errorf("added %v, want %v", got, want)
+	}
+	// a task without a date in the file was created by the import
+	if task, _ := getbyID(merged, 10); task == nil || task.CreatedAt.Before(before) {
+		t.Errorf("undated task = %+v, want it created now", task)
+	}
+}
+
+func TestMergeImportedManyTasks(t *testing.T) {
+	var imported []Task
+	for i := 0; i < 20000; i++ {
+		imported = append(imported, newImportedTask(fmt.Sprintf("task %d", i), "", time.Time{}, time.Time{}))
+	}
+	merged, added, _ := mergeImported(nil, imported)
+	if added != len(imported) || merged[len(merged)-1].ID != len(imported) {
+		t.Errorf("added %d, last ID %d", added, merged[len(merged)-1].ID)
+	}
+}
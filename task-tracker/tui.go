@@ -0,0 +1,503 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"golang.org/x/term"
+)
+
+// the interactive mode is started when task-tracker runs without a command
+// it puts the terminal into raw mode so every key press is read immediately
+// and redraws the whole screen with ANSI escape codes after each key
+
+// special keys returned by readKey, normal keys are returned as the typed character
+const (
+	keyUp        = "<up>"
+	keyDown      = "<down>"
+	keyEnter     = "<enter>"
+	keyEscape    = "<esc>"
+	keyBackspace = "<backspace>"
+	keyCtrlC     = "<ctrl-c>"
+)
+
+// what the bottom line of the screen is currently used for
+type tuiMode int
+
+const (
+	modeBrowse tuiMode = iota
+	modeAdd
+	modeEdit
+	modeFilter
+	modeConfirmDelete
+)
+
+type tui struct {
+	tasks   []Task
+	visible []int // indexes into tasks which match the filter
+	cursor  int   // position inside visible
+	offset  int   // first visible row of the list, used for scrolling
+	filter  string
+	mode    tuiMode
+	input   []rune // text typed into the prompt
+	message string
+	width   int
+	height  int
+	in      *bufio.Reader
+	out     *bufio.Writer
+}
+
+// runTUI starts the interactive task list and returns when the user quits
+func runTUI() error {
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		return errors.New("interactive mode needs a terminal, use a command like \"list\" instead")
+	}
+
+	tasks, err := loadTasks()
+	if err != nil {
+		return err
+	}
+
+	oldState, err := term.MakeRaw(fd)
+	if err != nil {
+		return err
+	}
+	defer term.Restore(fd, oldState)
+
+	t := &tui{
+		tasks: tasks,
+		in:    bufio.NewReader(os.Stdin),
+		out:   bufio.NewWriter(os.Stdout),
+	}
+	t.applyFilter()
+
+	// switch to the alternate screen and hide the cursor, undo both on exit
+	fmt.Fprint(t.out, "\x1b[?1049h\x1b[?25l")
+	defer func() {
+		fmt.Fprint(t.out, "\x1b[?25h\x1b[?1049l")
+		t.out.Flush()
+	}()
+
+	for {
+		t.width, t.height, err = term.GetSize(int(os.Stdout.Fd()))
+		if err != nil {
+			t.width, t.height = 80, 24
+		}
+		t.render()
+
+		key, err := t.readKey()
+		if err != nil {
+			return err
+		}
+		if t.handleKey(key) {
+			return nil
+		}
+	}
+}
+
+// readKey reads a single key press, arrow keys arrive as escape sequences like ESC [ A
+func (t *tui) readKey() (string, error) {
+	r, _, err := t.in.ReadRune()
+	if err != nil {
+		return "", err
+	}
+	switch r {
+	case '\r', '\n':
+		return keyEnter, nil
+	case 127, '\b':
+		return keyBackspace, nil
+	case 3:
+		return keyCtrlC, nil
+	case 27:
+		// a lone escape key has nothing buffered behind it
+		if t.in.Buffered() == 0 {
+			return keyEscape, nil
+		}
+		next, _ := t.in.ReadByte()
+		if next != '[' && next != 'O' {
+			return keyEscape, nil
+		}
+		code := t.readSequenceEnd(next)
+		switch code {
+		case 'A':
+			return keyUp, nil
+		case 'B':
+			return keyDown, nil
+		}
+		// other keys like Delete (ESC [ 3 ~) or F5 are ignored as a whole
+		return "", nil
+	}
+	return string(r), nil
+}
+
+// readSequenceEnd reads the rest of an escape sequence and returns its final byte
+// ESC O is followed by just the final byte, ESC [ (CSI) can have parameters like
+// "3" or "1;5" first. the final byte of a CSI is between 0x40 and 0x7E
+func (t *tui) readSequenceEnd(introducer byte) byte {
+	for {
+		b, err := t.in.ReadByte()
+		if err != nil {
+			return 0
+		}
+		if introducer == 'O' || (b >= 0x40 && b <= 0x7E) {
+			return b
+		}
+		// parameter and intermediate bytes are 0x20 to 0x3F, anything else
+		// means the sequence is broken, so it ends here
+		if b < 0x20 || b > 0x3F {
+			return 0
+		}
+	}
+}
+
+// handleKey applies a key press to the state, it returns true when the user wants to quit
+func (t *tui) handleKey(key string) bool {
+	if key == keyCtrlC {
+		return true
+	}
+	if t.mode != modeBrowse {
+		t.handlePromptKey(key)
+		return false
+	}
+
+	t.message = ""
+	switch key {
+	case "q":
+		return true
+	case keyUp, "k":
+		t.moveCursor(-1)
+	case keyDown, "j":
+		t.moveCursor(1)
+	case "a":
+		t.startPrompt(modeAdd, "")
+	case "e":
+		if task := t.selected(); task != nil {
+			t.startPrompt(modeEdit, task.Description)
+		}
+	case "s":
+		if task := t.selected(); task != nil {
+			t.setStatus(task.ID, activeWorkflow.nextStatus(task.Status))
+		}
+	case "d":
+		if t.selected() != nil {
+			t.mode = modeConfirmDelete
+		}
+	case "/":
+		t.startPrompt(modeFilter, t.filter)
+	case keyEscape:
+		// escape in browse mode clears the filter
+		t.filter = ""
+		t.applyFilter()
+	}
+	return false
+}
+
+// handlePromptKey handles typing into the bottom line
+func (t *tui) handlePromptKey(key string) {
+	if t.mode == modeConfirmDelete {
+		if key == "y" || key == "Y" {
+			if task := t.selected(); task != nil {
+				t.deleteTask(task.ID)
+			}
+		}
+		t.mode = modeBrowse
+		return
+	}
+
+	switch key {
+	case keyEscape:
+		if t.mode == modeFilter {
+			t.filter = ""
+			t.applyFilter()
+		}
+		t.mode = modeBrowse
+		return
+	case keyEnter:
+		t.submitPrompt()
+		return
+	case keyBackspace:
+		if len(t.input) > 0 {
+			t.input = t.input[:len(t.input)-1]
+		}
+	case keyUp, keyDown, "":
+		return
+	default:
+		t.input = append(t.input, []rune(key)...)
+	}
+
+	// the filter is applied while typing so the list narrows down live
+	if t.mode == modeFilter {
+		t.filter = string(t.input)
+		t.applyFilter()
+	}
+}
+
+func (t *tui) startPrompt(mode tuiMode, initial string) {
+	t.mode = mode
+	t.input = []rune(initial)
+}
+
+func (t *tui) submitPrompt() {
+	text := strings.TrimSpace(string(t.input))
+	mode := t.mode
+	t.mode = modeBrowse
+
+	switch mode {
+	case modeAdd:
+		if text == "" {
+			t.message = "description can not be empty"
+			return
+		}
+		t.addTask(text)
+	case modeEdit:
+		if text == "" {
+			t.message = "description can not be empty"
+			return
+		}
+		if task := t.selected(); task != nil {
+			t.editTask(task.ID, text)
+		}
+	case modeFilter:
+		t.filter = text
+		t.applyFilter()
+	}
+}
+
+func (t *tui) selected() *Task {
+	if t.cursor < 0 || t.cursor >= len(t.visible) {
+		return nil
+	}
+	return &t.tasks[t.visible[t.cursor]]
+}
+
+func (t *tui) moveCursor(delta int) {
+	t.cursor += delta
+	if t.cursor >= len(t.visible) {
+		t.cursor = len(t.visible) - 1
+	}
+	if t.cursor < 0 {
+		t.cursor = 0
+	}
+}
+
+// applyFilter rebuilds the visible list, the filter is matched against description and status
+func (t *tui) applyFilter() {
+	needle := strings.ToLower(t.filter)
+	t.visible = t.visible[:0]
+	for i, task := range t.tasks {
+		if needle == "" ||
+			strings.Contains(strings.ToLower(task.Description), needle) ||
+			strings.Contains(task.Status, needle) {
+			t.visible = append(t.visible, i)
+		}
+	}
+	t.moveCursor(0)
+}
+
+// selectID moves the cursor to the task with the given id if it is visible
+func (t *tui) selectID(id int) {
+	for i, index := range t.visible {
+		if t.tasks[index].ID == id {
+			t.cursor = i
+			return
+		}
+	}
+}
+
+// every change reloads the file first so edits made with the one-shot commands
+// while the interactive mode is open are not overwritten
+func (t *tui) modify(change func(tasks []Task) ([]Task, int, error)) {
+	tasks, err := loadTasks()
+	if err != nil {
+		t.message = "error loading tasks: " + err.Error()
+		return
+	}
+	tasks, id, err := change(tasks)
+	if err != nil {
+		t.message = err.Error()
+		return
+	}
+	if err := saveTasks(tasks); err != nil {
+		t.message = "error saving tasks: " + err.Error()
+		return
+	}
+	t.tasks = tasks
+	t.applyFilter()
+	t.selectID(id)
+}
+
+func (t *tui) addTask(description string) {
+	t.modify(func(tasks []Task) ([]Task, int, error) {
+		if err := activeWorkflow.checkMove(tasks, 0, activeWorkflow.Initial); err != nil {
+			return nil, 0, err
+		}
+		now := time.Now()
+		newTask := Task{
+			ID:          getNextId(tasks),
+			Description: description,
+			Status:      activeWorkflow.Initial,
+			CreatedAt:   now,
+			UpdatedAt:   now,
+		}
+		t.message = fmt.Sprintf("task added with ID %d", newTask.ID)
+		return append(tasks, newTask), newTask.ID, nil
+	})
+}
+
+func (t *tui) editTask(id int, description string) {
+	t.modify(func(tasks []Task) ([]Task, int, error) {
+		task, index := getbyID(tasks, id)
+		if index == -1 {
+			return nil, 0, fmt.Errorf("task with ID %d not found", id)
+		}
+		task.Description = description
+		task.UpdatedAt = time.Now()
+		t.message = fmt.Sprintf("task %d updated", id)
+		return tasks, id, nil
+	})
+}
+
+func (t *tui) setStatus(id int, status string) {
+	t.modify(func(tasks []Task) ([]Task, int, error) {
+		if err := activeWorkflow.checkMove(tasks, id, status); err != nil {
+			return nil, 0, err
+		}
+		task, _ := getbyID(tasks, id)
+		task.Status = status
+		task.UpdatedAt = time.Now()
+		t.message = fmt.Sprintf("task %d is now %s", id, status)
+		return tasks, id, nil
+	})
+}
+
+func (t *tui) deleteTask(id int) {
+	t.modify(func(tasks []Task) ([]Task, int, error) {
+		_, index := getbyID(tasks, id)
+		if index == -1 {
+			return nil, 0, fmt.Errorf("task with ID %d not found", id)
+		}
+		t.message = fmt.Sprintf("task %d deleted", id)
+		return append(tasks[:index], tasks[index+1:]...), 0, nil
+	})
+}
+
+// statusMark is the small checkbox shown in front of each task
+func statusMark(status string) string {
+	switch status {
+	case activeWorkflow.Done:
+		return "[x]"
+	case activeWorkflow.Initial:
+		return "[ ]"
+	}
+	return "[~]"
+}
+
+// render draws the whole screen: header, task list, detail pane, prompt and help line
+func (t *tui) render() {
+	const detailLines = 7 // separator + 5 fields + separator
+	const chromeLines = 2 + detailLines + 2
+	listHeight := t.height - chromeLines
+	if listHeight < 3 {
+		listHeight = 3
+	}
+
+	// keep the cursor inside the scrolled window
+	if t.cursor < t.offset {
+		t.offset = t.cursor
+	}
+	if t.cursor >= t.offset+listHeight {
+		t.offset = t.cursor - listHeight + 1
+	}
+
+	var lines []string
+	header := fmt.Sprintf(" Task Tracker - %d of %d tasks", len(t.visible), len(t.tasks))
+	if t.filter != "" {
+		header += fmt.Sprintf("   filter: %q", t.filter)
+	}
+	lines = append(lines, "\x1b[1m"+t.fit(header)+"\x1b[0m", strings.Repeat("─", t.width))
+
+	for row := 0; row < listHeight; row++ {
+		i := t.offset + row
+		if i >= len(t.visible) {
+			if i == 0 {
+				lines = append(lines, "  no tasks")
+			} else {
+				lines = append(lines, "")
+			}
+			continue
+		}
+		task := t.tasks[t.visible[i]]
+		line := fmt.Sprintf("  %s %3d  %s", statusMark(task.Status), task.ID, task.Description)
+		if i == t.cursor {
+			// reverse video for the selected row
+			lines = append(lines, "\x1b[7m"+t.fit(line)+"\x1b[0m")
+		} else {
+			lines = append(lines, t.fit(line))
+		}
+	}
+
+	lines = append(lines, strings.Repeat("─", t.width))
+	if task := t.selected(); task != nil {
+		lines = append(lines,
+			t.fit(" ID:           "+fmt.Sprint(task.ID)),
+			t.fit(" Description:  "+task.Description),
+			t.fit(" Status:       "+task.Status),
+			t.fit(" CreatedAt:    "+task.CreatedAt.Format("2006-01-02 15:04:05")),
+			t.fit(" UpdatedAt:    "+task.UpdatedAt.Format("2006-01-02 15:04:05")),
+		)
+	} else {
+		lines = append(lines, "", "", "", "", "")
+	}
+	lines = append(lines, strings.Repeat("─", t.width))
+
+	lines = append(lines, t.fit(t.promptLine()))
+	lines = append(lines, "\x1b[2m"+t.fit(t.helpLine())+"\x1b[0m")
+
+	// move home, then clear each line while writing it
+	fmt.Fprint(t.out, "\x1b[H")
+	for i, line := range lines {
+		if i > 0 {
+			fmt.Fprint(t.out, "\r\n")
+		}
+		fmt.Fprint(t.out, line, "\x1b[K")
+	}
+	fmt.Fprint(t.out, "\x1b[J")
+	t.out.Flush()
+}
+
+func (t *tui) promptLine() string {
+	switch t.mode {
+	case modeAdd:
+		return " new task: " + string(t.input) + "_"
+	case modeEdit:
+		return " edit description: " + string(t.input) + "_"
+	case modeFilter:
+		return " /" + string(t.input) + "_"
+	case modeConfirmDelete:
+		if task := t.selected(); task != nil {
+			return fmt.Sprintf(" delete task %d? (y/n)", task.ID)
+		}
+	}
+	return " " + t.message
+}
+
+func (t *tui) helpLine() string {
+	if t.mode != modeBrowse && t.mode != modeConfirmDelete {
+		return " enter confirm  esc cancel"
+	}
+	return " ↑/↓ move  a add  e edit  s change status  d delete  / filter  q quit"
+}
+
+// fit cuts a line so it does not wrap past the terminal width
+func (t *tui) fit(line string) string {
+	if t.width <= 0 || utf8.RuneCountInString(line) <= t.width {
+		return line
+	}
+	return string([]rune(line)[:t.width])
+}
@@ -0,0 +1,268 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// the statuses a task can have, which status changes are allowed and how many
+// tasks a column may hold (the WIP limit) come from workflow.json
+// without that file the workflow is the classic todo -> in-progress -> done
+//
+// instance of workflow.json:
+//
+//	{
+//	  "statuses": [
+//	    {"name": "todo", "title": "To Do"},
+//	    {"name": "review", "title": "Review", "wipLimit": 2},
+//	    {"name": "done", "title": "Done"}
+//	  ],
+//	  "transitions": {"todo": ["review"], "review": ["todo", "done"], "done": ["todo"]},
+//	  "initial": "todo",
+//	  "done": "done"
+//	}
+//
+// when "transitions" is left out every change is allowed
+
+type statusColumn struct {
+	Name     string `json:"name"`
+	Title    string `json:"title,omitempty"`
+	WIPLimit int    `json:"wipLimit,omitempty"` // 0 means no limit
+}
+
+type workflow struct {
+	Statuses    []statusColumn      `json:"statuses"`
+	Transitions map[string][]string `json:"transitions,omitempty"`
+	Initial     string              `json:"initial"` // status of new tasks
+	Done        string              `json:"done"`    // status of finished tasks, used by import and export
+}
+
+// activeWorkflow is loaded by main before any command runs
+var activeWorkflow = defaultWorkflow()
+
+func defaultWorkflow() *workflow {
+	return &workflow{
+		Statuses: []statusColumn{
+			{Name: statusToDo, Title: "To Do"},
+			{Name: statusInProgress, Title: "In Progress"},
+			{Name: statusDone, Title: "Done"},
+		},
+		Initial: statusToDo,
+		Done:    statusDone,
+	}
+}
+
+func loadWorkflow() (*workflow, error) {
+	data, err := os.ReadFile(workflowFile)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return defaultWorkflow(), nil
+		}
+		return nil, err
+	}
+	var wf workflow
+	if err := json.Unmarshal(data, &wf); err != nil {
+		return nil, fmt.Errorf("%s: %w", workflowFile, err)
+	}
+	if err := wf.validate(); err != nil {
+		return nil, fmt.Errorf("%s: %w", workflowFile, err)
+	}
+	return &wf, nil
+}
+
+func saveWorkflow(wf *workflow) error {
+	data, err := json.MarshalIndent(wf, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(workflowFile, data, 0644)
+}
+
+// validate checks the config and fills the optional fields
+func (wf *workflow) validate() error {
+	if len(wf.Statuses) == 0 {
+		return errors.New("at least one status is required")
+	}
+	seen := make(map[string]bool)
+	for i, s := range wf.Statuses {
+		if s.Name == "" {
+			return fmt.Errorf("status %d has no name", i+1)
+		}
+		if seen[s.Name] {
+			return fmt.Errorf("status %q is declared twice", s.Name)
+		}
+		if s.WIPLimit < 0 {
+			return fmt.Errorf("status %q has a negative wipLimit", s.Name)
+		}
+		seen[s.Name] = true
+	}
+	for from, targets := range wf.Transitions {
+		if !seen[from] {
+			return fmt.Errorf("transition from unknown status %q", from)
+		}
+		for _, to := range targets {
+			if !seen[to] {
+				return fmt.Errorf("transition from %q to unknown status %q", from, to)
+			}
+		}
+	}
+
+	if wf.Initial == "" {
+		wf.Initial = wf.Statuses[0].Name
+	}
+	if wf.Done == "" {
+		wf.Done = wf.Statuses[len(wf.Statuses)-1].Name
+	}
+	if !seen[wf.Initial] {
+		return fmt.Errorf("initial status %q is not declared", wf.Initial)
+	}
+	if !seen[wf.Done] {
+		return fmt.Errorf("done status %q is not declared", wf.Done)
+	}
+	return nil
+}
+
+func (wf *workflow) column(status string) (statusColumn, bool) {
+	for _, s := range wf.Statuses {
+		if s.Name == status {
+			return s, true
+		}
+	}
+	return statusColumn{}, false
+}
+
+func (wf *workflow) hasStatus(status string) bool {
+	_, ok := wf.column(status)
+	return ok
+}
+
+func (wf *workflow) canMove(from, to string) bool {
+	if from == to || wf.Transitions == nil {
+		return true
+	}
+	for _, allowed := range wf.Transitions[from] {
+		if allowed == to {
+			return true
+		}
+	}
+	return false
+}
+
+// checkMove tells whether the task with the given id may get the status "to"
+// id 0 means a new task, for which only the WIP limit is checked
+func (wf *workflow) checkMove(tasks []Task, id int, to string) error {
+	column, ok := wf.column(to)
+	if !ok {
+		return fmt.Errorf("unknown status %q, use one of: %s", to, wf.statusNames())
+	}
+
+	if id != 0 {
+		task, index := getbyID(tasks, id)
+		if index == -1 {
+			return fmt.Errorf("task with ID %d not found", id)
+		}
+		if task.Status == to {
+			return nil
+		}
+		if !wf.canMove(task.Status, to) {
+			return fmt.Errorf("can not move a task from %q to %q", task.Status, to)
+		}
+	}
+
+	if column.WIPLimit > 0 && countStatus(tasks, to) >= column.WIPLimit {
+		return fmt.Errorf("%q already has %d tasks, its WIP limit is %d", to, countStatus(tasks, to), column.WIPLimit)
+	}
+	return nil
+}
+
+// overLimit describes every column which has more tasks than its WIP limit
+// imports don't refuse tasks because of a limit, they report it with this afterwards
+func (wf *workflow) overLimit(tasks []Task) []string {
+	var over []string
+	for _, s := range wf.Statuses {
+		if count := countStatus(tasks, s.Name); s.WIPLimit > 0 && count > s.WIPLimit {
+			over = append(over, fmt.Sprintf("%q has %d tasks, its WIP limit is %d", s.Name, count, s.WIPLimit))
+		}
+	}
+	return over
+}
+
+// nextStatus is the next column after status which the task may move to, it wraps around
+func (wf *workflow) nextStatus(status string) string {
+	start := 0
+	for i, s := range wf.Statuses {
+		if s.Name == status {
+			start = i
+		}
+	}
+	for step := 1; step < len(wf.Statuses); step++ {
+		next := wf.Statuses[(start+step)%len(wf.Statuses)].Name
+		if wf.canMove(status, next) {
+			return next
+		}
+	}
+	return status
+}
+
+func (wf *workflow) statusNames() string {
+	var names []string
+	for _, s := range wf.Statuses {
+		names = append(names, s.Name)
+	}
+	return strings.Join(names, ", ")
+}
+
+func countStatus(tasks []Task, status string) int {
+	n := 0
+	for _, t := range tasks {
+		if t.Status == status {
+			n++
+		}
+	}
+	return n
+}
+
+// cmdWorkflow prints the workflow, "workflow init" writes the default one to workflow.json to edit it
+func cmdWorkflow(args []string) {
+	if len(args) > 0 && args[0] == "init" {
+		if _, err := os.Stat(workflowFile); err == nil {
+			fmt.Printf("%s already exists\n", workflowFile)
+			return
+		}
+		if err := saveWorkflow(defaultWorkflow()); err != nil {
+			fmt.Println("Error saving the workflow: ", err)
+			return
+		}
+		fmt.Printf("%s created, edit it to change the statuses\n", workflowFile)
+		return
+	}
+
+	wf := activeWorkflow
+	for _, s := range wf.Statuses {
+		line := s.Name
+		if s.Title != "" {
+			line += fmt.Sprintf(" (%s)", s.Title)
+		}
+		if s.WIPLimit > 0 {
+			line += fmt.Sprintf(", WIP limit %d", s.WIPLimit)
+		}
+		switch s.Name {
+		case wf.Initial:
+			line += ", new tasks start here"
+		case wf.Done:
+			line += ", finished"
+		}
+		fmt.Println(line)
+
+		if wf.Transitions == nil {
+			fmt.Println("    -> any status")
+			continue
+		}
+		for _, to := range wf.Transitions[s.Name] {
+			fmt.Println("    -> " + to)
+		}
+	}
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+// reviewWorkflow is the workflow of the example in workflow.go
+func reviewWorkflow() *workflow {
+	return &workflow{
+		Statuses: []statusColumn{
+			{Name: "todo", Title: "To Do"},
+			{Name: "review", Title: "Review", WIPLimit: 2},
+			{Name: "done", Title: "Done"},
+		},
+		Transitions: map[string][]string{"todo": {"review"}, "review": {"todo", "done"}, "done": {"todo"}},
+		Initial:     "todo",
+		Done:        "done",
+	}
+}
+
+func TestCheckMove(t *testing.T) {
+	tasks := []Task{
+		{ID: 1, Status: "todo"},
+		{ID: 2, Status: "review"},
+		{ID: 3, Status: "done"},
+		{ID: 4, Status: "todo"},
+	}
+	full := append(append([]Task{}, tasks...), Task{ID: 5, Status: "review"})
+
+	tests := []struct {
+		name  string
+		tasks []Task
+		id    int
+		to    string
+		err   string // part of the error, "" if the move is allowed
+	}{
+		{"allowed", tasks, 1, "review", ""},
+		{"back", tasks, 2, "todo", ""},
+		{"reopen", tasks, 3, "todo", ""},
+		{"same status", full, 2, "review", ""},
+		{"skipping a column", tasks, 1, "done", `can not move a task from "todo" to "done"`},
+		{"not allowed back", tasks, 3, "review", `can not move a task from "done" to "review"`},
+		{"unknown status", tasks, 1, "blocked", `unknown status "blocked", use one of: todo, review, done`},
+		{"unknown task", tasks, 9, "review", "task with ID 9 not found"},
+		{"limit reached", full, 4, "review", `"review" already has 2 tasks, its WIP limit is 2`},
+		{"new task, limit reached", full, 0, "review", "WIP limit is 2"},
+		{"new task, no limit", full, 0, "done", ""},
+		{"new task may skip transitions", tasks, 0, "review", ""},
+	}
+	wf := reviewWorkflow()
+	for _, tt := range tests {
+		err := wf.checkMove(tt.tasks, tt.id, tt.to)
+		if tt.err == "" {
+			if err != nil {
+				t.Errorf("%s: %v", tt.name, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("%s: error %v, want %q", tt.name, err, tt.err)
+		}
+	}
+
+	// without transitions every move is allowed
+	if err := defaultWorkflow().checkMove(tasks[:1], 1, statusDone); err != nil {
+		t.Errorf("default workflow: %v", err)
+	}
+}
+
+func TestNextStatus(t *testing.T) {
+	wf := reviewWorkflow()
+	for from, want := range map[string]string{"todo": "review", "review": "done", "done": "todo"} {
+		if got := wf.nextStatus(from); got != want {
+			t.Errorf("nextStatus(%q) = %q, want %q", from, got, want)
+		}
+	}
+}
+
+func TestOverLimit(t *testing.T) {
+	tasks := []Task{{ID: 1, Status: "review"}, {ID: 2, Status: "review"}}
+	if over := reviewWorkflow().overLimit(tasks); len(over) != 0 {
+		t.Errorf("at the limit: %v", over)
+	}
+	tasks = append(tasks, Task{ID: 3, Status: "review"})
+	if over := reviewWorkflow().overLimit(tasks); len(over) != 1 || over[0] != `"review" has 3 tasks, its WIP limit is 2` {
+		t.Errorf("over the limit: %v", over)
+	}
+}
+
+func TestLoadWorkflow(t *testing.T) {
+	t.Chdir(t.TempDir())
+
+	wf, err := loadWorkflow()
+	if err != nil || wf.Initial != statusToDo || wf.Done != statusDone {
+		t.Fatalf("without a file: %+v, %v", wf, err)
+	}
+
+	tests := []struct {
+		name string
+		file string
+		err  string // part of the error, "" if the file is valid
+	}{
+		{"valid", `{"statuses": [{"name": "todo"}, {"name": "review", "wipLimit": 2}, {"name": "done"}], "transitions": {"todo": ["review"]}}`, ""},
+		{"not json", `statuses: todo, done`, "workflow.json: invalid character"},
+		{"wrong type", `{"statuses": "todo"}`, "workflow.json: json: cannot unmarshal"},
+		{"no statuses", `{"statuses": []}`, "at least one status is required"},
+		{"status without a name", `{"statuses": [{"title": "To Do"}]}`, "status 1 has no name"},
+		{"status twice", `{"statuses": [{"name": "todo"}, {"name": "todo"}]}`, `status "todo" is declared twice`},
+		{"negative limit", `{"statuses": [{"name": "todo", "wipLimit": -1}]}`, `status "todo" has a negative wipLimit`},
+		{"transition from unknown", `{"statuses": [{"name": "todo"}], "transitions": {"doing": ["todo"]}}`, `transition from unknown status "doing"`},
+		{"transition to unknown", `{"statuses": [{"name": "todo"}], "transitions": {"todo": ["doing"]}}`, `transition from "todo" to unknown status "doing"`},
+		{"unknown initial", `{"statuses": [{"name": "todo"}], "initial": "new"}`, `initial status "new" is not declared`},
+		{"unknown done", `{"statuses": [{"name": "todo"}], "done": "finished"}`, `done status "finished" is not declared`},
+	}
+	for _, tt := range tests {
+		if err := os.WriteFile(workflowFile, []byte(tt.file), 0644); err != nil {
+			t.Fatal(err)
+		}
+		wf, err := loadWorkflow()
+		if tt.err == "" {
+			if err != nil {
+				t.Errorf("%s: %v", tt.name, err)
+			} else if wf.Initial != "todo" || wf.Done != "done" {
+				// initial and done default to the first and the last status
+				t.Errorf("%s: initial %q, done %q", tt.name, wf.Initial, wf.Done)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("%s: error %v, want %q", tt.name, err, tt.err)
+		}
+	}
+}
@@ -0,0 +1,294 @@
+package analytics
+
+import (
+	"math"
+	"sort"
+	"time"
+	"workout-tracker/models"
+)
+
+// The analytics package turns logged sets into numbers for the progress endpoints.
+// It doesn't touch the database: the handlers load the sets through the store
+// and pass them in, which keeps these functions easy to check by hand.
+
+// Formulas for EstimateOneRepMax
+const (
+	FormulaEpley   = "epley"
+	FormulaBrzycki = "brzycki"
+)
+
+// Kinds of personal records
+const (
+	RecordMaxWeight    = "max_weight"    // heaviest working set
+	RecordEstimatedMax = "estimated_1rm" // best estimated one-rep max (Epley)
+	RecordMaxVolume    = "max_volume"    // most volume in one session
+	RecordMaxReps      = "max_reps"      // most reps in one set, only for bodyweight sets (weight 0)
+)
+
+// MaxRepsForEstimate is the highest rep count we estimate a one-rep max from
+// The formulas get unreliable for long sets, and Brzycki breaks down completely at 37 reps
+const MaxRepsForEstimate = 12
+
+// EstimateOneRepMax guesses the heaviest weight you could lift once, from a set of several reps
+//
+//	Epley:   weight x (1 + reps / 30)
+//	Brzycki: weight x 36 / (37 - reps)
+//
+// ok is false when the set can't be used (no reps, no weight, or more than MaxRepsForEstimate reps)
+func EstimateOneRepMax(weight float64, reps int, formula string) (estimate float64, ok bool) {
+	if weight <= 0 || reps < 1 || reps > MaxRepsForEstimate {
+		return 0, false
+	}
+	// A single rep is already a one-rep max, both formulas agree on that
+	if reps == 1 {
+		return weight, true
+	}
+	if formula == FormulaBrzycki {
+		return round(weight * 36 / float64(37-reps)), true
+	}
+	return round(weight * (1 + float64(reps)/30)), true
+}
+
+// ValidFormula tells whether the formula name is one we know
+func ValidFormula(formula string) bool {
+	return formula == FormulaEpley || formula == FormulaBrzycki
+}
+
+// round keeps two decimals, enough for kg and lbs and nicer in JSON
+func round(value float64) float64 {
+	return math.Round(value*100) / 100
+}
+
+// session is one logged exercise: all its sets from one workout
+type session struct {
+	scheduleID int
+	exerciseID int
+	name       string
+	date       time.Time
+	sets       []models.WorkoutSet
+}
+
+// sessionKey is the workout and the exercise, an exercise logged twice in one workout is one session
+type sessionKey struct {
+	scheduleID int
+	exerciseID int
+}
+
+// sessions groups the sets by workout and exercise, oldest first
+func sessions(sets []models.LoggedSet) []session {
+	byKey := map[sessionKey]*session{}
+	order := []sessionKey{}
+	for _, set := range sets {
+		key := sessionKey{set.ScheduleID, set.ExerciseID}
+		s, ok := byKey[key]
+		if !ok {
+			s = &session{
+				scheduleID: set.ScheduleID,
+				exerciseID: set.ExerciseID,
+				name:       set.ExerciseName,
+				date:       set.LoggedAt,
+			}
+			byKey[key] = s
+			order = append(order, key)
+		}
+		s.sets = append(s.sets, set.WorkoutSet)
+	}
+
+	list := []session{}
+	for _, key := range order {
+		list = append(list, *byKey[key])
+	}
+	sort.SliceStable(list, func(i, j int) bool { return list[i].date.Before(list[j].date) })
+	return list
+}
+
+// summary is the best of one session in every record kind
+// The value of a kind is 0 when the session had nothing for it
+type summary struct {
+	values map[string]float64
+	weight map[string]float64 // the set behind each value
+	reps   map[string]int
+	point  models.StrengthPoint
+}
+
+func summarize(s session, formula string) summary {
+	sum := summary{values: map[string]float64{}, weight: map[string]float64{}, reps: map[string]int{}}
+	better := func(kind string, value, weight float64, reps int) {
+		if value > sum.values[kind] {
+			sum.values[kind] = value
+			sum.weight[kind] = weight
+			sum.reps[kind] = reps
+		}
+	}
+
+	point := models.StrengthPoint{Date: s.date, ScheduleID: s.scheduleID}
+	for _, set := range s.sets {
+		// Warm-ups don't count for records or volume
+		if set.IsWarmup {
+			continue
+		}
+		point.WorkingSets++
+		point.Volume += float64(set.Reps) * set.Weight
+		if set.Weight > point.TopWeight || (set.Weight == point.TopWeight && set.Reps > point.TopReps) {
+			point.TopWeight = set.Weight
+			point.TopReps = set.Reps
+		}
+
+		if set.Reps > 0 {
+			better(RecordMaxWeight, set.Weight, set.Weight, set.Reps)
+		}
+		if estimate, ok := EstimateOneRepMax(set.Weight, set.Reps, formula); ok {
+			better(RecordEstimatedMax, estimate, set.Weight, set.Reps)
+			if estimate > point.EstimatedOneRM {
+				point.EstimatedOneRM = estimate
+			}
+		}
+		if set.Weight == 0 {
+			better(RecordMaxReps, float64(set.Reps), 0, set.Reps)
+		}
+	}
+	point.Volume = round(point.Volume)
+	better(RecordMaxVolume, point.Volume, point.TopWeight, point.TopReps)
+
+	sum.point = point
+	return sum
+}
+
+// recordKinds keeps the records in the same order in every response
+var recordKinds = []string{RecordMaxWeight, RecordEstimatedMax, RecordMaxVolume, RecordMaxReps}
+
+// RecordHistory walks through all sessions from old to new and returns every
+// time a best was beaten, newest first.
+// The first session of an exercise sets the bar, it isn't a record itself.
+func RecordHistory(sets []models.LoggedSet) []models.PersonalRecord {
+	records := []models.PersonalRecord{}
+	best := map[int]map[string]float64{} // exercise ID -> kind -> best value so far
+
+	for _, s := range sessions(sets) {
+		sum := summarize(s, FormulaEpley)
+		previous, seen := best[s.exerciseID]
+		if !seen {
+			best[s.exerciseID] = sum.values
+			continue
+		}
+		for _, kind := range recordKinds {
+			if sum.values[kind] > previous[kind] {
+				// Bodyweight reps and the weight records only make sense once there was something to beat
+				if previous[kind] > 0 {
+					records = append(records, models.PersonalRecord{
+						ExerciseID:   s.exerciseID,
+						ExerciseName: s.name,
+						Kind:         kind,
+						Value:        sum.values[kind],
+						Previous:     previous[kind],
+						Weight:       sum.weight[kind],
+						Reps:         sum.reps[kind],
+						ScheduleID:   s.scheduleID,
+						AchievedAt:   s.date,
+					})
+				}
+				previous[kind] = sum.values[kind]
+			}
+		}
+	}
+
+	// Newest first, records of the same session keep the order of recordKinds
+	sort.SliceStable(records, func(i, j int) bool { return records[i].AchievedAt.After(records[j].AchievedAt) })
+	return records
+}
+
+// NewRecords returns the records the log sets compared with the earlier sets of the same exercise
+// All sets of the exercise in the workout must be in the one log, else each part is compared on its own
+// It is used when a workout is completed, before the log is saved
+func NewRecords(previous []models.LoggedSet, log models.WorkoutLog, scheduleID int, at time.Time) []models.PersonalRecord {
+	if len(previous) == 0 {
+		return []models.PersonalRecord{}
+	}
+
+	// Add the new log as the latest session, the schedule isn't completed yet so none of its sets are saved
+	all := append([]models.LoggedSet{}, previous...)
+	for _, set := range log.Sets {
+		all = append(all, models.LoggedSet{
+			WorkoutSet:   set,
+			ScheduleID:   scheduleID,
+			ExerciseID:   log.ExerciseID,
+			ExerciseName: previous[0].ExerciseName,
+			LoggedAt:     at,
+		})
+	}
+
+	records := []models.PersonalRecord{}
+	for _, record := range RecordHistory(all) {
+		if record.AchievedAt.Equal(at) && record.ScheduleID == scheduleID {
+			records = append(records, record)
+		}
+	}
+	return records
+}
+
+// StrengthSeries returns one point per session of the exercise, oldest first
+func StrengthSeries(sets []models.LoggedSet, formula string) []models.StrengthPoint {
+	points := []models.StrengthPoint{}
+	for _, s := range sessions(sets) {
+		points = append(points, summarize(s, formula).point)
+	}
+	return points
+}
+
+// WeekStart returns the Monday (00:00) of the week the time is in
+func WeekStart(t time.Time) time.Time {
+	t = time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	// Weekday() counts from Sunday = 0, we want Monday = 0
+	daysSinceMonday := (int(t.Weekday()) + 6) % 7
+	return t.AddDate(0, 0, -daysSinceMonday)
+}
+
+// WeeklyVolume adds up sets x reps x weight of the working sets per week and group
+// by is "muscle_group" or "category". Weeks without training are 0, so every series
+// has one value for every week from the week of "from" up to the week of "to".
+// Bodyweight sets have no weight, so they add nothing.
+func WeeklyVolume(sets []models.LoggedSet, by string, from, to time.Time) models.VolumeReport {
+	report := models.VolumeReport{By: by, Weeks: []string{}, Series: []models.VolumeSeries{}}
+
+	index := map[string]int{} // "2024-01-15" -> position in report.Weeks
+	for week := WeekStart(from); !week.After(to); week = week.AddDate(0, 0, 7) {
+		label := week.Format("2006-01-02")
+		index[label] = len(report.Weeks)
+		report.Weeks = append(report.Weeks, label)
+	}
+
+	totals := map[string][]float64{}
+	for _, set := range sets {
+		if set.IsWarmup || set.LoggedAt.Before(from) || set.LoggedAt.After(to) {
+			continue
+		}
+		i, ok := index[WeekStart(set.LoggedAt).Format("2006-01-02")]
+		if !ok {
+			continue
+		}
+		group := set.MuscleGroup
+		if by == "category" {
+			group = set.Category
+		}
+		if group == "" {
+			group = "Other"
+		}
+		if totals[group] == nil {
+			totals[group] = make([]float64, len(report.Weeks))
+		}
+		totals[group][i] += float64(set.Reps) * set.Weight
+	}
+
+	groups := []string{}
+	for group := range totals {
+		groups = append(groups, group)
+	}
+	sort.Strings(groups)
+	for _, group := range groups {
+		for i := range totals[group] {
+			totals[group][i] = round(totals[group][i])
+		}
+		report.Series = append(report.Series, models.VolumeSeries{Group: group, Volume: totals[group]})
+	}
+	return report
+}
@@ -0,0 +1,266 @@
+package analytics
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+	"workout-tracker/models"
+)
+
+const squat, pullUp = 1, 2
+
+func date(s string) time.Time {
+	t, err := time.Parse("2006-01-02", s)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+func set(reps int, weight float64) models.WorkoutSet {
+	return models.WorkoutSet{Reps: reps, Weight: weight}
+}
+
+func warmup(reps int, weight float64) models.WorkoutSet {
+	return models.WorkoutSet{Reps: reps, Weight: weight, IsWarmup: true}
+}
+
+// logged returns the sets of one log the way the store lists them
+func logged(logID, scheduleID, exerciseID int, day string, sets ...models.WorkoutSet) []models.LoggedSet {
+	list := []models.LoggedSet{}
+	for i, s := range sets {
+		s.WorkoutLogID = logID
+		s.SetNumber = i + 1
+		list = append(list, models.LoggedSet{
+			WorkoutSet:   s,
+			ScheduleID:   scheduleID,
+			ExerciseID:   exerciseID,
+			ExerciseName: fmt.Sprintf("exercise %d", exerciseID),
+			LoggedAt:     date(day),
+		})
+	}
+	return list
+}
+
+func concat(logs ...[]models.LoggedSet) []models.LoggedSet {
+	all := []models.LoggedSet{}
+	for _, log := range logs {
+		all = append(all, log...)
+	}
+	return all
+}
+
+// describe writes the records short, like "2025-01-13 max_weight 105 (100)"
+func describe(records []models.PersonalRecord) []string {
+	list := []string{}
+	for _, r := range records {
+		list = append(list, fmt.Sprintf("%s %s %g (%g)", r.AchievedAt.Format("2006-01-02"), r.Kind, r.Value, r.Previous))
+	}
+	return list
+}
+
+func TestEstimateOneRepMax(t *testing.T) {
+	tests := []struct {
+		name    string
+		weight  float64
+		reps    int
+		formula string
+		want    float64
+		ok      bool
+	}{
+		{"epley", 100, 5, FormulaEpley, 116.67, true},
+		{"brzycki", 100, 5, FormulaBrzycki, 112.5, true},
+		{"brzycki 10 reps", 80, 10, FormulaBrzycki, 106.67, true},
+		{"unknown formula is epley", 100, 5, "", 116.67, true},
+		{"one rep epley", 140, 1, FormulaEpley, 140, true},
+		{"one rep brzycki", 140, 1, FormulaBrzycki, 140, true},
+		{"twelve reps", 100, 12, FormulaEpley, 140, true},
+		{"thirteen reps", 100, 13, FormulaEpley, 0, false},
+		{"thirteen reps brzycki", 100, 13, FormulaBrzycki, 0, false},
+		{"no reps", 100, 0, FormulaEpley, 0, false},
+		{"bodyweight", 0, 8, FormulaEpley, 0, false},
+		{"negative weight", -10, 5, FormulaBrzycki, 0, false},
+	}
+	for _, tt := range tests {
+		got, ok := EstimateOneRepMax(tt.weight, tt.reps, tt.formula)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("%s: EstimateOneRepMax(%g, %d, %q) = %g, %v, want %g, %v", tt.name, tt.weight, tt.reps, tt.formula, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestRecordHistory(t *testing.T) {
+	tests := []struct {
+		name string
+		sets []models.LoggedSet
+		want []string
+	}{
+		{
+			"first session sets the bar",
+			logged(1, 1, squat, "2025-01-06", set(5, 100), set(5, 100)),
+			[]string{},
+		},
+		{
+			"heavier second session",
+			concat(
+				logged(1, 1, squat, "2025-01-06", set(5, 100), set(5, 100)),
+				logged(2, 2, squat, "2025-01-13", set(5, 105)),
+			),
+			[]string{"2025-01-13 max_weight 105 (100)", "2025-01-13 estimated_1rm 122.5 (116.67)"},
+		},
+		{
+			"more volume, same weight",
+			concat(
+				logged(1, 1, squat, "2025-01-06", set(5, 100)),
+				logged(2, 2, squat, "2025-01-13", set(5, 100), set(5, 100)),
+			),
+			[]string{"2025-01-13 max_volume 1000 (500)"},
+		},
+		{
+			"warm-ups don't count",
+			concat(
+				logged(1, 1, squat, "2025-01-06", set(5, 100)),
+				logged(2, 2, squat, "2025-01-13", warmup(1, 120), warmup(10, 60), set(5, 100)),
+			),
+			[]string{},
+		},
+		{
+			"bodyweight reps",
+			concat(
+				logged(1, 1, pullUp, "2025-01-06", set(8, 0), set(6, 0)),
+				logged(2, 2, pullUp, "2025-01-13", set(10, 0)),
+			),
+			[]string{"2025-01-13 max_reps 10 (8)"},
+		},
+		{
+			"reps with weight aren't max_reps",
+			concat(
+				logged(1, 1, pullUp, "2025-01-06", set(8, 10)),
+				logged(2, 2, pullUp, "2025-01-13", set(12, 10)),
+			),
+			[]string{"2025-01-13 estimated_1rm 14 (12.67)", "2025-01-13 max_volume 120 (80)"},
+		},
+		{
+			"exercises have their own bar",
+			concat(
+				logged(1, 1, squat, "2025-01-06", set(5, 100)),
+				logged(2, 1, pullUp, "2025-01-06", set(8, 0)),
+				logged(3, 2, pullUp, "2025-01-13", set(9, 0)),
+			),
+			[]string{"2025-01-13 max_reps 9 (8)"},
+		},
+		{
+			"two logs of one exercise in a workout are one session",
+			concat(
+				logged(1, 1, squat, "2025-01-06", set(5, 100)),
+				logged(2, 2, squat, "2025-01-13", set(5, 100)),
+				logged(3, 2, squat, "2025-01-13", set(5, 100)),
+			),
+			[]string{"2025-01-13 max_volume 1000 (500)"},
+		},
+		{
+			"newest first",
+			concat(
+				logged(1, 1, squat, "2025-01-06", set(5, 100)),
+				logged(2, 2, squat, "2025-01-13", set(5, 102.5)),
+				logged(3, 3, squat, "2025-01-20", set(5, 105)),
+			),
+			[]string{
+				"2025-01-20 max_weight 105 (102.5)", "2025-01-20 estimated_1rm 122.5 (119.58)", "2025-01-20 max_volume 525 (512.5)",
+				"2025-01-13 max_weight 102.5 (100)", "2025-01-13 estimated_1rm 119.58 (116.67)", "2025-01-13 max_volume 512.5 (500)",
+			},
+		},
+	}
+	for _, tt := range tests {
+		if got := describe(RecordHistory(tt.sets)); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: records %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestNewRecords(t *testing.T) {
+	at := date("2025-01-13")
+	previous := logged(1, 1, squat, "2025-01-06", set(5, 100), set(5, 100))
+
+	tests := []struct {
+		name     string
+		previous []models.LoggedSet
+		sets     []models.WorkoutSet
+		want     []string
+	}{
+		{"first time", nil, []models.WorkoutSet{set(5, 200)}, []string{}},
+		{"heavier", previous, []models.WorkoutSet{set(3, 110)}, []string{"2025-01-13 max_weight 110 (100)", "2025-01-13 estimated_1rm 121 (116.67)"}},
+		{"no better", previous, []models.WorkoutSet{set(5, 100)}, []string{}},
+		{"heavier warm-up", previous, []models.WorkoutSet{warmup(1, 150), set(5, 100)}, []string{}},
+		{"more volume", previous, []models.WorkoutSet{set(5, 100), set(5, 100), set(5, 100)}, []string{"2025-01-13 max_volume 1500 (1000)"}},
+	}
+	for _, tt := range tests {
+		log := models.WorkoutLog{ExerciseID: squat, Sets: tt.sets}
+		records := NewRecords(tt.previous, log, 2, at)
+		if got := describe(records); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: records %q, want %q", tt.name, got, tt.want)
+		}
+		for _, r := range records {
+			if r.ScheduleID != 2 || r.ExerciseID != squat || r.ExerciseName != "exercise 1" {
+				t.Errorf("%s: record %+v", tt.name, r)
+			}
+		}
+	}
+}
+
+func TestWeekStart(t *testing.T) {
+	for day, want := range map[string]string{
+		"2025-01-13": "2025-01-13", // Monday
+		"2025-01-15": "2025-01-13",
+		"2025-01-19": "2025-01-13", // Sunday
+		"2025-01-01": "2024-12-30",
+	} {
+		if got := WeekStart(date(day).Add(15 * time.Hour)); !got.Equal(date(want)) {
+			t.Errorf("WeekStart(%s) = %s, want %s", day, got, want)
+		}
+	}
+}
+
+func TestWeeklyVolume(t *testing.T) {
+	from, to := date("2025-01-08"), date("2025-01-22").Add(23*time.Hour)
+	group := func(muscleGroup, category string, sets []models.LoggedSet) []models.LoggedSet {
+		for i := range sets {
+			sets[i].MuscleGroup = muscleGroup
+			sets[i].Category = category
+		}
+		return sets
+	}
+	sets := concat(
+		group("Legs", "strength", logged(1, 1, squat, "2025-01-07", set(5, 100))), // before "from"
+		group("Legs", "strength", logged(2, 2, squat, "2025-01-08", warmup(5, 60), set(5, 100), set(5, 100))),
+		group("Legs", "strength", logged(3, 3, squat, "2025-01-14", set(20, 0))),
+		group("Chest", "strength", logged(4, 4, 3, "2025-01-21", set(10, 80))),
+		group("", "", logged(5, 5, 4, "2025-01-21", set(10, 12.5))),
+		group("Legs", "strength", logged(6, 6, squat, "2025-01-23", set(5, 100))), // after "to"
+	)
+
+	tests := []struct {
+		by   string
+		want []models.VolumeSeries
+	}{
+		{"muscle_group", []models.VolumeSeries{
+			{Group: "Chest", Volume: []float64{0, 0, 800}},
+			{Group: "Legs", Volume: []float64{1000, 0, 0}},
+			{Group: "Other", Volume: []float64{0, 0, 125}},
+		}},
+		{"category", []models.VolumeSeries{
+			{Group: "Other", Volume: []float64{0, 0, 125}},
+			{Group: "strength", Volume: []float64{1000, 0, 800}},
+		}},
+	}
+	for _, tt := range tests {
+		report := WeeklyVolume(sets, tt.by, from, to)
+		if want := []string{"2025-01-06", "2025-01-13", "2025-01-20"}; !reflect.DeepEqual(report.Weeks, want) {
+			t.Errorf("by %s: weeks %v, want %v", tt.by, report.Weeks, want)
+		}
+		if report.By != tt.by || !reflect.DeepEqual(report.Series, tt.want) {
+			t.Errorf("by %s: report %+v, want series %+v", tt.by, report, tt.want)
+		}
+	}
+}
@@ -1,6 +1,9 @@
 package auth
 
 import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"time"
 
@@ -12,14 +15,21 @@ import (
 // In production, this should come from environment variables
 var JWTSecret = []byte("your-secret-key-change-this-in-production")
 
-// TokenExpirationHours defines how long a token is valid
-var TokenExpirationHours = 24
+// AccessTokenMinutes defines how long an access token (the JWT) is valid
+// It is short on purpose: the client gets a new one with its refresh token
+var AccessTokenMinutes = 15
+
+// RefreshTokenDays defines how long a refresh token is valid
+// Every refresh gives a new refresh token, so a user who comes back within this time stays logged in
+var RefreshTokenDays = 30
 
 // Claims represents the data stored in a JWT token
 // This is what gets encoded into the token
 type Claims struct {
 	UserID   int    `json:"user_id"`
 	Username string `json:"username"`
+	// SessionID is the login the token belongs to, AuthMiddleware checks it wasn't logged out
+	SessionID int `json:"sid"`
 	jwt.RegisteredClaims
 }
 
@@ -39,16 +49,17 @@ func CheckPassword(password, hash string) bool {
 	return err == nil
 }
 
-// GenerateToken creates a new JWT token for a user
-// The token contains the user's ID and username, and is valid for 24 hours
-func GenerateToken(userID int, username string) (string, error) {
+// GenerateToken creates a new JWT access token for a user
+// The token contains the user's ID, username and session, and is valid for AccessTokenMinutes
+func GenerateToken(userID int, username string, sessionID int) (string, error) {
 	// Set when the token expires
-	expirationTime := time.Now().Add(time.Duration(TokenExpirationHours) * time.Hour)
+	expirationTime := time.Now().Add(time.Duration(AccessTokenMinutes) * time.Minute)
 
 	// Create the claims (the data that goes in the token)
 	claims := &Claims{
-		UserID:   userID,
-		Username: username,
+		UserID:    userID,
+		Username:  username,
+		SessionID: sessionID,
 		RegisteredClaims: jwt.RegisteredClaims{
 			ExpiresAt: jwt.NewNumericDate(expirationTime),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
@@ -90,3 +101,25 @@ func ValidateToken(tokenString string) (*Claims, error) {
 
 	return nil, errors.New("invalid token")
 }
+
+// NewOpaqueToken returns 32 random bytes as hex
+// Used for refresh tokens and calendar feed URLs: they mean nothing by themselves,
+// the server looks them up (by their hash) in the database
+func NewOpaqueToken() (string, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(raw), nil
+}
+
+// HashToken returns the SHA-256 of an opaque token as hex
+//
+// Why do we only store the hash?
+// answer: The token works like a password. If the database leaks,
+// nobody can use the hashes to log in. SHA-256 is enough here (bcrypt isn't needed)
+// because the token is 32 random bytes, nobody can guess it.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,84 @@
+package calendar
+
+import (
+	"strings"
+	"time"
+)
+
+// Event is one entry of the .ics feed
+type Event struct {
+	UID         string // stays the same for the same schedule, so calendar apps update instead of duplicating it
+	Start       time.Time
+	Duration    time.Duration
+	Summary     string
+	Description string
+}
+
+// icsTime formats a time the way iCalendar wants it: 20240115T070000Z (UTC)
+func icsTime(t time.Time) string {
+	return t.UTC().Format("20060102T150405Z")
+}
+
+// escapeText escapes the characters that have a meaning in iCalendar text values (RFC 5545, 3.3.11)
+func escapeText(value string) string {
+	value = strings.ReplaceAll(value, `\`, `\\`)
+	value = strings.ReplaceAll(value, ";", `\;`)
+	value = strings.ReplaceAll(value, ",", `\,`)
+	value = strings.ReplaceAll(value, "\r\n", `\n`)
+	value = strings.ReplaceAll(value, "\n", `\n`)
+	return value
+}
+
+// foldLine splits lines longer than 75 bytes, every continuation line starts with a space
+// It never cuts a UTF-8 character in half
+func foldLine(line string) string {
+	const limit = 75
+	var b strings.Builder
+	width := 0
+	for _, r := range line {
+		size := len(string(r))
+		if width+size > limit {
+			b.WriteString("\r\n ")
+			width = 1 // the space counts too
+		}
+		b.WriteRune(r)
+		width += size
+	}
+	return b.String()
+}
+
+// Feed writes a whole calendar with the events, ready to be served as text/calendar
+// stamp is when the feed was made, iCalendar needs it on every event (DTSTAMP)
+// iCalendar also needs CRLF line endings
+func Feed(name string, events []Event, stamp time.Time) string {
+	lines := []string{
+		"BEGIN:VCALENDAR",
+		"VERSION:2.0",
+		"PRODID:-//workout-tracker//EN",
+		"CALSCALE:GREGORIAN",
+		"METHOD:PUBLISH",
+		"X-WR-CALNAME:" + escapeText(name),
+	}
+	for _, event := range events {
+		lines = append(lines,
+			"BEGIN:VEVENT",
+			"UID:"+event.UID,
+			"DTSTAMP:"+icsTime(stamp),
+			"DTSTART:"+icsTime(event.Start),
+			"DTEND:"+icsTime(event.Start.Add(event.Duration)),
+			"SUMMARY:"+escapeText(event.Summary),
+		)
+		if event.Description != "" {
+			lines = append(lines, "DESCRIPTION:"+escapeText(event.Description))
+		}
+		lines = append(lines, "END:VEVENT")
+	}
+	lines = append(lines, "END:VCALENDAR")
+
+	var b strings.Builder
+	for _, line := range lines {
+		b.WriteString(foldLine(line))
+		b.WriteString("\r\n")
+	}
+	return b.String()
+}
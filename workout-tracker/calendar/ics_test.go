@@ -0,0 +1,96 @@
+package calendar
+
+import (
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+func TestEscapeText(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{"Leg day", "Leg day"},
+		{"Squat, bench; deadlift", `Squat\, bench\; deadlift`},
+		{`C:\notes`, `C:\\notes`},
+		{"line one\nline two", `line one\nline two`},
+		{"line one\r\nline two", `line one\nline two`},
+		{`already \, escaped`, `already \\\, escaped`},
+		{"colon: stays", "colon: stays"},
+	}
+	for _, tt := range tests {
+		if got := escapeText(tt.value); got != tt.want {
+			t.Errorf("escapeText(%q) = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestFoldLine(t *testing.T) {
+	tests := []struct {
+		name string
+		line string
+	}{
+		{"short", "SUMMARY:Leg day"},
+		{"exactly 75 bytes", "SUMMARY:" + strings.Repeat("a", 67)},
+		{"76 bytes", "SUMMARY:" + strings.Repeat("a", 68)},
+		{"several folds", "DESCRIPTION:" + strings.Repeat("0123456789", 20)},
+		{"multi-byte characters", "SUMMARY:" + strings.Repeat("Übung 💪 ", 20)},
+	}
+	for _, tt := range tests {
+		folded := foldLine(tt.line)
+		parts := strings.Split(folded, "\r\n")
+		for i, part := range parts {
+			if len(part) > 75 {
+				t.Errorf("%s: line %d is %d bytes long", tt.name, i+1, len(part))
+			}
+			if i > 0 && !strings.HasPrefix(part, " ") {
+				t.Errorf("%s: continuation line %d doesn't start with a space: %q", tt.name, i+1, part)
+			}
+			if !utf8.ValidString(part) {
+				t.Errorf("%s: line %d cuts a character in half: %q", tt.name, i+1, part)
+			}
+		}
+		// Unfolding (RFC 5545, 3.1) gives back the line
+		if unfolded := strings.ReplaceAll(folded, "\r\n ", ""); unfolded != tt.line {
+			t.Errorf("%s: unfolded %q, want %q", tt.name, unfolded, tt.line)
+		}
+		if want := len(tt.line) <= 75; (len(parts) == 1) != want {
+			t.Errorf("%s: %d lines for %d bytes", tt.name, len(parts), len(tt.line))
+		}
+	}
+}
+
+func TestFeed(t *testing.T) {
+	start := time.Date(2025, 1, 6, 8, 0, 0, 0, time.FixedZone("CET", 3600))
+	stamp := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	feed := Feed("Alice's workouts", []Event{
+		{UID: "schedule-1@workout-tracker", Start: start, Duration: time.Hour, Summary: "Legs, core; stretch", Description: "Warm up first\nthen squat"},
+		{UID: "schedule-2@workout-tracker", Start: start.AddDate(0, 0, 2), Duration: time.Hour, Summary: "Done: Push day"},
+	}, stamp)
+
+	if !strings.HasSuffix(feed, "END:VCALENDAR\r\n") {
+		t.Errorf("the feed doesn't end with END:VCALENDAR and CRLF: %q", feed)
+	}
+	if strings.Contains(strings.ReplaceAll(feed, "\r\n", ""), "\n") {
+		t.Error("the feed has a line ending without CR")
+	}
+	for _, line := range []string{
+		"BEGIN:VCALENDAR", "VERSION:2.0", "X-WR-CALNAME:Alice's workouts",
+		"UID:schedule-1@workout-tracker", "DTSTAMP:20250101T120000Z",
+		"DTSTART:20250106T070000Z", "DTEND:20250106T080000Z",
+		`SUMMARY:Legs\, core\; stretch`, `DESCRIPTION:Warm up first\nthen squat`,
+		"DTSTART:20250108T070000Z", "SUMMARY:Done: Push day",
+	} {
+		if !strings.Contains(feed, "\r\n"+line+"\r\n") && !strings.HasPrefix(feed, line+"\r\n") {
+			t.Errorf("the feed has no line %q:\n%s", line, feed)
+		}
+	}
+	if n := strings.Count(feed, "BEGIN:VEVENT\r\n"); n != 2 {
+		t.Errorf("%d events, want 2", n)
+	}
+	if n := strings.Count(feed, "DESCRIPTION:"); n != 1 {
+		t.Errorf("%d descriptions, want 1 (the second event has none)", n)
+	}
+}
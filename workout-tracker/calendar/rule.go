@@ -0,0 +1,167 @@
+package calendar
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The calendar package has the date logic of recurring schedules and the iCalendar (.ics) feed.
+// Like analytics and programs it doesn't touch the database.
+
+// MaxWeeks is the longest series we expand
+const MaxWeeks = 52
+
+// Rule says when a recurring workout happens, e.g. Mon/Wed/Fri at 07:00 for 8 weeks
+// All times are UTC, like the rest of the API
+type Rule struct {
+	Weekdays []time.Weekday
+	Hour     int
+	Minute   int
+	Weeks    int
+}
+
+// ParseWeekday understands full English day names and their first three letters
+func ParseWeekday(name string) (time.Weekday, bool) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	for day := time.Sunday; day <= time.Saturday; day++ {
+		full := strings.ToLower(day.String())
+		if name == full || name == full[:3] {
+			return day, true
+		}
+	}
+	return time.Sunday, false
+}
+
+// WeekdayName is the short name we store and return: "mon", "tue"...
+func WeekdayName(day time.Weekday) string {
+	return strings.ToLower(day.String()[:3])
+}
+
+// clockPattern matches "7", "7am", "7:30pm", "07:00" and "19:00"
+var clockPattern = regexp.MustCompile(`^(\d{1,2})(?::(\d{2}))?\s*(am|pm)?$`)
+
+// ParseClock reads a time of day, in 24-hour ("19:00") or 12-hour ("7pm") format
+func ParseClock(value string) (hour, minute int, err error) {
+	match := clockPattern.FindStringSubmatch(strings.ToLower(strings.TrimSpace(value)))
+	if match == nil {
+		return 0, 0, fmt.Errorf("invalid time %q, use 07:00 or 7am", value)
+	}
+	hour, _ = strconv.Atoi(match[1])
+	if match[2] != "" {
+		minute, _ = strconv.Atoi(match[2])
+	}
+	switch match[3] {
+	case "am", "pm":
+		if hour < 1 || hour > 12 {
+			return 0, 0, fmt.Errorf("invalid time %q", value)
+		}
+		// 12am is midnight and 12pm is noon
+		hour = hour % 12
+		if match[3] == "pm" {
+			hour += 12
+		}
+	}
+	if hour > 23 || minute > 59 {
+		return 0, 0, fmt.Errorf("invalid time %q", value)
+	}
+	return hour, minute, nil
+}
+
+// rulePattern matches "Mon/Wed/Fri at 7am for 8 weeks", the day separator can also be "," or " "
+var rulePattern = regexp.MustCompile(`^(.+?)\s+at\s+(.+?)\s+for\s+(\d+)\s+weeks?$`)
+
+// ParseRule reads a rule written like "Mon/Wed/Fri at 7am for 8 weeks"
+func ParseRule(text string) (Rule, error) {
+	match := rulePattern.FindStringSubmatch(strings.ToLower(strings.TrimSpace(text)))
+	if match == nil {
+		return Rule{}, errors.New(`invalid rule, write it like "Mon/Wed/Fri at 7am for 8 weeks"`)
+	}
+
+	days := strings.FieldsFunc(match[1], func(r rune) bool { return r == '/' || r == ',' || r == ' ' })
+	hour, minute, err := ParseClock(match[2])
+	if err != nil {
+		return Rule{}, err
+	}
+	weeks, _ := strconv.Atoi(match[3])
+	return NewRule(days, fmt.Sprintf("%02d:%02d", hour, minute), weeks)
+}
+
+// NewRule builds a rule from its parts, the way the API receives them:
+// weekday names, a time of day and a number of weeks
+func NewRule(weekdays []string, clock string, weeks int) (Rule, error) {
+	rule := Rule{Weeks: weeks}
+	if len(weekdays) == 0 {
+		return rule, errors.New("at least one weekday is required")
+	}
+	seen := map[time.Weekday]bool{}
+	for _, name := range weekdays {
+		day, ok := ParseWeekday(name)
+		if !ok {
+			return rule, fmt.Errorf("unknown weekday %q", name)
+		}
+		if seen[day] {
+			return rule, fmt.Errorf("%s is listed twice", day)
+		}
+		seen[day] = true
+	}
+	// Keep them in calendar order, Monday first
+	for _, day := range []time.Weekday{time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday, time.Saturday, time.Sunday} {
+		if seen[day] {
+			rule.Weekdays = append(rule.Weekdays, day)
+		}
+	}
+
+	var err error
+	rule.Hour, rule.Minute, err = ParseClock(clock)
+	if err != nil {
+		return rule, err
+	}
+	if weeks < 1 || weeks > MaxWeeks {
+		return rule, fmt.Errorf("weeks must be between 1 and %d", MaxWeeks)
+	}
+	return rule, nil
+}
+
+// WeekdayNames returns the weekdays as stored: "mon", "wed", "fri"
+func (r Rule) WeekdayNames() []string {
+	names := []string{}
+	for _, day := range r.Weekdays {
+		names = append(names, WeekdayName(day))
+	}
+	return names
+}
+
+// Clock returns the time of day as "07:00"
+func (r Rule) Clock() string {
+	return fmt.Sprintf("%02d:%02d", r.Hour, r.Minute)
+}
+
+// Dates expands the rule into the date and time of every occurrence, earliest first
+// The series runs for Weeks weeks from the start date, so the start date counts as day one
+func (r Rule) Dates(start time.Time) []time.Time {
+	start = start.UTC()
+	day := time.Date(start.Year(), start.Month(), start.Day(), r.Hour, r.Minute, 0, 0, time.UTC)
+
+	on := map[time.Weekday]bool{}
+	for _, weekday := range r.Weekdays {
+		on[weekday] = true
+	}
+
+	dates := []time.Time{}
+	for i := 0; i < 7*r.Weeks; i++ {
+		date := day.AddDate(0, 0, i)
+		if on[date.Weekday()] {
+			dates = append(dates, date)
+		}
+	}
+	return dates
+}
+
+// SameDay tells whether two times fall on the same day (UTC)
+func SameDay(a, b time.Time) bool {
+	return a.UTC().Format("2006-01-02") == b.UTC().Format("2006-01-02")
+}
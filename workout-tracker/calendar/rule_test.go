@@ -0,0 +1,168 @@
+package calendar
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseClock(t *testing.T) {
+	tests := []struct {
+		value        string
+		hour, minute int
+		ok           bool
+	}{
+		{"07:00", 7, 0, true},
+		{"7", 7, 0, true},
+		{"19:45", 19, 45, true},
+		{"0:00", 0, 0, true},
+		{"23:59", 23, 59, true},
+		{"7am", 7, 0, true},
+		{"7:30 PM", 19, 30, true},
+		{"12am", 0, 0, true},
+		{"12:15am", 0, 15, true},
+		{"12pm", 12, 0, true},
+		{"12:30pm", 12, 30, true},
+		{"11pm", 23, 0, true},
+		{"24:00", 0, 0, false},
+		{"7:60", 0, 0, false},
+		{"0am", 0, 0, false},
+		{"13pm", 0, 0, false},
+		{"7:5", 0, 0, false},
+		{"noon", 0, 0, false},
+		{"", 0, 0, false},
+	}
+	for _, tt := range tests {
+		hour, minute, err := ParseClock(tt.value)
+		if (err == nil) != tt.ok {
+			t.Errorf("ParseClock(%q) error %v, want ok %v", tt.value, err, tt.ok)
+			continue
+		}
+		if tt.ok && (hour != tt.hour || minute != tt.minute) {
+			t.Errorf("ParseClock(%q) = %02d:%02d, want %02d:%02d", tt.value, hour, minute, tt.hour, tt.minute)
+		}
+	}
+}
+
+func TestParseRule(t *testing.T) {
+	tests := []struct {
+		text     string
+		weekdays []string
+		clock    string
+		weeks    int
+		err      string // part of the error, "" if the rule is valid
+	}{
+		{"Mon/Wed/Fri at 7am for 8 weeks", []string{"mon", "wed", "fri"}, "07:00", 8, ""},
+		{"fri, mon at 18:30 for 1 week", []string{"mon", "fri"}, "18:30", 1, ""},
+		{"Sunday Saturday at 12am for 52 weeks", []string{"sat", "sun"}, "00:00", 52, ""},
+		{"tue at 12pm for 4 weeks", []string{"tue"}, "12:00", 4, ""},
+		{"Mon/mon at 7am for 8 weeks", nil, "", 0, "listed twice"},
+		{"Mon/Monday at 7am for 8 weeks", nil, "", 0, "listed twice"},
+		{"Mon/Funday at 7am for 8 weeks", nil, "", 0, "unknown weekday"},
+		{"Mon at 25:00 for 8 weeks", nil, "", 0, "invalid time"},
+		{"Mon at 7am for 0 weeks", nil, "", 0, "weeks must be between 1 and 52"},
+		{"Mon at 7am for 53 weeks", nil, "", 0, "weeks must be between 1 and 52"},
+		{"Mon at 7am", nil, "", 0, "invalid rule"},
+		{"every day", nil, "", 0, "invalid rule"},
+	}
+	for _, tt := range tests {
+		rule, err := ParseRule(tt.text)
+		if tt.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("ParseRule(%q) error %v, want %q", tt.text, err, tt.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseRule(%q): %v", tt.text, err)
+			continue
+		}
+		if !reflect.DeepEqual(rule.WeekdayNames(), tt.weekdays) || rule.Clock() != tt.clock || rule.Weeks != tt.weeks {
+			t.Errorf("ParseRule(%q) = %v at %s for %d weeks, want %v at %s for %d weeks",
+				tt.text, rule.WeekdayNames(), rule.Clock(), rule.Weeks, tt.weekdays, tt.clock, tt.weeks)
+		}
+	}
+}
+
+func TestNewRule(t *testing.T) {
+	tests := []struct {
+		name     string
+		weekdays []string
+		clock    string
+		weeks    int
+		ok       bool
+	}{
+		{"valid", []string{"mon", "thu"}, "07:00", 8, true},
+		{"no weekdays", nil, "07:00", 8, false},
+		{"duplicate weekday", []string{"thu", "Thursday"}, "07:00", 8, false},
+		{"no time", []string{"mon"}, "", 8, false},
+		{"one week", []string{"mon"}, "07:00", 1, true},
+		{"most weeks", []string{"mon"}, "07:00", MaxWeeks, true},
+		{"no weeks", []string{"mon"}, "07:00", 0, false},
+		{"too many weeks", []string{"mon"}, "07:00", MaxWeeks + 1, false},
+		{"negative weeks", []string{"mon"}, "07:00", -1, false},
+	}
+	for _, tt := range tests {
+		if _, err := NewRule(tt.weekdays, tt.clock, tt.weeks); (err == nil) != tt.ok {
+			t.Errorf("%s: NewRule(%v, %q, %d) error %v, want ok %v", tt.name, tt.weekdays, tt.clock, tt.weeks, err, tt.ok)
+		}
+	}
+}
+
+func TestRuleDates(t *testing.T) {
+	tests := []struct {
+		name  string
+		rule  string
+		start string
+		want  []string
+	}{
+		{
+			"starts on a rule day",
+			"Mon/Wed at 7am for 2 weeks", "2025-01-06T15:00:00Z",
+			[]string{"2025-01-06T07:00:00Z", "2025-01-08T07:00:00Z", "2025-01-13T07:00:00Z", "2025-01-15T07:00:00Z"},
+		},
+		{
+			"the start date is day one, not the Monday before",
+			"Mon/Wed at 7am for 2 weeks", "2025-01-07T00:00:00Z",
+			[]string{"2025-01-08T07:00:00Z", "2025-01-13T07:00:00Z", "2025-01-15T07:00:00Z", "2025-01-20T07:00:00Z"},
+		},
+		{
+			"one week ends the day before the weekday comes back",
+			"Sun at 11:30pm for 1 week", "2025-01-05T00:00:00Z",
+			[]string{"2025-01-05T23:30:00Z"},
+		},
+		{
+			"across the end of the year",
+			"Tue at 12pm for 2 weeks", "2024-12-30T00:00:00Z",
+			[]string{"2024-12-31T12:00:00Z", "2025-01-07T12:00:00Z"},
+		},
+		{
+			"a start in another time zone counts in UTC",
+			"Mon at 12am for 1 week", "2025-01-06T01:00:00+02:00",
+			[]string{"2025-01-06T00:00:00Z"},
+		},
+	}
+	for _, tt := range tests {
+		rule, err := ParseRule(tt.rule)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		start, err := time.Parse(time.RFC3339, tt.start)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		got := []string{}
+		for _, date := range rule.Dates(start) {
+			got = append(got, date.Format(time.RFC3339))
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: dates %v, want %v", tt.name, got, tt.want)
+		}
+	}
+
+	rule, _ := NewRule([]string{"mon", "tue", "wed", "thu", "fri", "sat", "sun"}, "07:00", MaxWeeks)
+	if dates := rule.Dates(time.Now()); len(dates) != 7*MaxWeeks {
+		t.Errorf("every day for %d weeks has %d dates, want %d", MaxWeeks, len(dates), 7*MaxWeeks)
+	}
+}
@@ -0,0 +1,287 @@
+package database
+
+import (
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Migrations are numbered SQL files in database/migrations:
+//
+//	0003_add_rpe_to_logs.up.sql   - applied by "migrate up"
+//	0003_add_rpe_to_logs.down.sql - undoes it for "migrate down"
+//
+// They are embedded in the binary, so the server never needs the .sql files at runtime.
+// Applied versions are recorded in the schema_migrations table, and every
+// migration runs in its own transaction together with that bookkeeping,
+// so a failing migration leaves the database exactly as it was.
+// To change the schema, add a new pair of files with the next number - never edit an applied one.
+
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// migrationLockID is an arbitrary number for pg_advisory_xact_lock
+// It stops two servers starting at the same time from applying the same migration twice
+const migrationLockID = 727274
+
+// Migration is one numbered schema change
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// MigrationStatus tells whether a migration has been applied, and when
+type MigrationStatus struct {
+	Version   int
+	Name      string
+	AppliedAt *time.Time
+}
+
+// loadMigrations reads the .sql files in the migrations directory, sorted by version
+// The server passes the embedded files, the tests a file system of their own
+// Every version needs an up and a down file, and the versions count up from 1 without gaps
+func loadMigrations(fsys fs.FS) ([]Migration, error) {
+	files, err := fs.ReadDir(fsys, "migrations")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := map[int]*Migration{}
+	hasDown := map[int]bool{} // a down file may be empty when there is nothing to undo
+	for _, file := range files {
+		// File names look like 0001_initial_schema.up.sql
+		base, direction, ok := strings.Cut(strings.TrimSuffix(file.Name(), ".sql"), ".")
+		if !ok || (direction != "up" && direction != "down") {
+			return nil, fmt.Errorf("migration %s: name must end in .up.sql or .down.sql", file.Name())
+		}
+		number, name, _ := strings.Cut(base, "_")
+		version, err := strconv.Atoi(number)
+		if err != nil || version <= 0 {
+			return nil, fmt.Errorf("migration %s: name must start with a version number", file.Name())
+		}
+
+		content, err := fs.ReadFile(fsys, "migrations/"+file.Name())
+		if err != nil {
+			return nil, err
+		}
+
+		m, exists := byVersion[version]
+		if !exists {
+			m = &Migration{Version: version, Name: name}
+			byVersion[version] = m
+		}
+		if m.Name != name {
+			return nil, fmt.Errorf("migration %d has two names: %s and %s", version, m.Name, name)
+		}
+		if direction == "up" {
+			m.Up = string(content)
+		} else {
+			m.Down = string(content)
+			hasDown[version] = true
+		}
+	}
+
+	migrations := []Migration{}
+	for _, m := range byVersion {
+		if m.Up == "" {
+			return nil, fmt.Errorf("migration %d (%s) has no .up.sql file", m.Version, m.Name)
+		}
+		if !hasDown[m.Version] {
+			return nil, fmt.Errorf("migration %d (%s) has no .down.sql file", m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+
+	// A missing number is usually a file that was renamed or not committed
+	for i, m := range migrations {
+		if m.Version != i+1 {
+			return nil, fmt.Errorf("migration %d is missing, the versions must count up from 1 without gaps", i+1)
+		}
+	}
+	return migrations, nil
+}
+
+// ensureMigrationsTable creates the bookkeeping table if this is the first run
+func ensureMigrationsTable(db *sql.DB) error {
+	_, err := db.Exec(`
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version INTEGER PRIMARY KEY,
+			name VARCHAR(255) NOT NULL,
+			applied_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("error creating schema_migrations table: %w", err)
+	}
+	return nil
+}
+
+// querier is anything we can run a SELECT on, both *sql.DB and *sql.Tx have this method
+type querier interface {
+	Query(query string, args ...any) (*sql.Rows, error)
+}
+
+// appliedVersions returns the applied versions with the time they were applied
+func appliedVersions(q querier) (map[int]time.Time, error) {
+	rows, err := q.Query(`SELECT version, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := map[int]time.Time{}
+	for rows.Next() {
+		var version int
+		var appliedAt time.Time
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, err
+		}
+		applied[version] = appliedAt
+	}
+	return applied, rows.Err()
+}
+
+// MigrateUp applies pending migrations in order
+// steps limits how many are applied, 0 means all of them
+func MigrateUp(db *sql.DB, steps int) (int, error) {
+	migrations, err := loadMigrations(migrationFiles)
+	if err != nil {
+		return 0, err
+	}
+	if err := ensureMigrationsTable(db); err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for steps == 0 || count < steps {
+		applied, err := migrateStep(db, func(done map[int]time.Time) (*Migration, string) {
+			for i := range migrations {
+				if _, ok := done[migrations[i].Version]; !ok {
+					return &migrations[i], migrations[i].Up
+				}
+			}
+			return nil, ""
+		}, true)
+		if err != nil {
+			return count, err
+		}
+		if applied == nil {
+			break
+		}
+		log.Printf("Applied migration %04d_%s", applied.Version, applied.Name)
+		count++
+	}
+	return count, nil
+}
+
+// MigrateDown rolls back the most recently applied migrations, newest first
+func MigrateDown(db *sql.DB, steps int) (int, error) {
+	migrations, err := loadMigrations(migrationFiles)
+	if err != nil {
+		return 0, err
+	}
+	if err := ensureMigrationsTable(db); err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for count < steps {
+		reverted, err := migrateStep(db, func(done map[int]time.Time) (*Migration, string) {
+			for i := len(migrations) - 1; i >= 0; i-- {
+				if _, ok := done[migrations[i].Version]; ok {
+					return &migrations[i], migrations[i].Down
+				}
+			}
+			return nil, ""
+		}, false)
+		if err != nil {
+			return count, err
+		}
+		if reverted == nil {
+			break
+		}
+		log.Printf("Reverted migration %04d_%s", reverted.Version, reverted.Name)
+		count++
+	}
+	return count, nil
+}
+
+// migrateStep applies or reverts a single migration in a transaction
+// pick chooses the migration from the versions applied so far, it is called
+// after taking the lock so two servers never pick the same one
+func migrateStep(db *sql.DB, pick func(map[int]time.Time) (*Migration, string), up bool) (*Migration, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	// If anything goes wrong, rollback the transaction
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`SELECT pg_advisory_xact_lock($1)`, migrationLockID); err != nil {
+		return nil, fmt.Errorf("error locking migrations: %w", err)
+	}
+	done, err := appliedVersions(tx)
+	if err != nil {
+		return nil, fmt.Errorf("error reading schema_migrations: %w", err)
+	}
+
+	m, script := pick(done)
+	if m == nil {
+		return nil, tx.Commit()
+	}
+	if !up && strings.TrimSpace(script) == "" {
+		return nil, fmt.Errorf("migration %04d_%s has no .down.sql file, it can't be reverted", m.Version, m.Name)
+	}
+
+	if _, err := tx.Exec(script); err != nil {
+		return nil, fmt.Errorf("migration %04d_%s failed: %w", m.Version, m.Name, err)
+	}
+	if up {
+		_, err = tx.Exec(`INSERT INTO schema_migrations (version, name) VALUES ($1, $2)`, m.Version, m.Name)
+	} else {
+		_, err = tx.Exec(`DELETE FROM schema_migrations WHERE version = $1`, m.Version)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error recording migration %04d_%s: %w", m.Version, m.Name, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Status lists every known migration and when it was applied (nil if pending)
+func Status(db *sql.DB) ([]MigrationStatus, error) {
+	migrations, err := loadMigrations(migrationFiles)
+	if err != nil {
+		return nil, err
+	}
+	if err := ensureMigrationsTable(db); err != nil {
+		return nil, err
+	}
+	applied, err := appliedVersions(db)
+	if err != nil {
+		return nil, err
+	}
+
+	status := []MigrationStatus{}
+	for _, m := range migrations {
+		s := MigrationStatus{Version: m.Version, Name: m.Name}
+		if at, ok := applied[m.Version]; ok {
+			at := at
+			s.AppliedAt = &at
+		}
+		status = append(status, s)
+	}
+	return status, nil
+}
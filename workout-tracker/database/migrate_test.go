@@ -0,0 +1,99 @@
+package database
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+// migrationFS builds a migrations directory, every file contains its own name
+func migrationFS(names ...string) fstest.MapFS {
+	fsys := fstest.MapFS{}
+	for _, name := range names {
+		fsys["migrations/"+name] = &fstest.MapFile{Data: []byte("-- " + name)}
+	}
+	return fsys
+}
+
+func TestLoadMigrations(t *testing.T) {
+	fsys := migrationFS(
+		"0002_add_index.down.sql", "0002_add_index.up.sql",
+		"0001_initial_schema.up.sql", "0001_initial_schema.down.sql",
+		"10_late.up.sql", "10_late.down.sql",
+	)
+	for v := 3; v <= 9; v++ {
+		name := fmt.Sprintf("%04d_step", v)
+		fsys["migrations/"+name+".up.sql"] = &fstest.MapFile{Data: []byte("-- up")}
+		fsys["migrations/"+name+".down.sql"] = &fstest.MapFile{}
+	}
+
+	migrations, err := loadMigrations(fsys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(migrations) != 10 {
+		t.Fatalf("%d migrations, want 10", len(migrations))
+	}
+	for i, m := range migrations {
+		if m.Version != i+1 {
+			t.Errorf("migration %d has version %d", i, m.Version)
+		}
+	}
+	first := migrations[0]
+	if first.Name != "initial_schema" || first.Up != "-- 0001_initial_schema.up.sql" || first.Down != "-- 0001_initial_schema.down.sql" {
+		t.Errorf("first migration = %+v", first)
+	}
+	// "10" sorts after "9" by number, not by name
+	if last := migrations[9]; last.Name != "late" || last.Up != "-- 10_late.up.sql" {
+		t.Errorf("last migration = %+v", last)
+	}
+	// an empty down file is fine, some migrations have nothing to undo
+	if migrations[2].Down != "" {
+		t.Errorf("migration 3 down = %q, want empty", migrations[2].Down)
+	}
+}
+
+func TestLoadMigrationsErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		files []string
+		want  string
+	}{
+		{"no up file", []string{"0001_initial.down.sql"}, "migration 1 (initial) has no .up.sql file"},
+		{"no down file", []string{"0001_initial.up.sql", "0001_initial.down.sql", "0002_index.up.sql"}, "migration 2 (index) has no .down.sql file"},
+		{"gap", []string{"0001_a.up.sql", "0001_a.down.sql", "0003_c.up.sql", "0003_c.down.sql"}, "migration 2 is missing"},
+		{"not starting at 1", []string{"0002_b.up.sql", "0002_b.down.sql"}, "migration 1 is missing"},
+		{"two names", []string{"0001_a.up.sql", "0001_b.down.sql"}, "migration 1 has two names: a and b"},
+		{"no direction", []string{"0001_a.sql"}, "name must end in .up.sql or .down.sql"},
+		{"wrong direction", []string{"0001_a.sideways.sql"}, "name must end in .up.sql or .down.sql"},
+		{"no version", []string{"initial.up.sql"}, "name must start with a version number"},
+		{"version 0", []string{"0000_zero.up.sql"}, "name must start with a version number"},
+	}
+	for _, tt := range tests {
+		_, err := loadMigrations(migrationFS(tt.files...))
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: error %v, want %q", tt.name, err, tt.want)
+		}
+	}
+
+	if _, err := loadMigrations(fstest.MapFS{}); err == nil {
+		t.Error("no migrations directory: no error")
+	}
+}
+
+// The migrations shipped in the binary must pass the same checks
+func TestEmbeddedMigrations(t *testing.T) {
+	migrations, err := loadMigrations(migrationFiles)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(migrations) == 0 {
+		t.Fatal("no embedded migrations")
+	}
+	for _, m := range migrations {
+		if strings.TrimSpace(m.Up) == "" || strings.TrimSpace(m.Down) == "" {
+			t.Errorf("migration %d (%s) has an empty file", m.Version, m.Name)
+		}
+	}
+}
@@ -0,0 +1,7 @@
+-- Drop in reverse order so the foreign keys don't get in the way
+DROP TABLE IF EXISTS workout_logs;
+DROP TABLE IF EXISTS schedules;
+DROP TABLE IF EXISTS workout_exercises;
+DROP TABLE IF EXISTS workouts;
+DROP TABLE IF EXISTS exercises;
+DROP TABLE IF EXISTS users;
@@ -0,0 +1,66 @@
+-- The tables the app started with.
+-- IF NOT EXISTS keeps this safe for databases created before migrations existed.
+
+-- users stores user account information
+CREATE TABLE IF NOT EXISTS users (
+	id SERIAL PRIMARY KEY,
+	username VARCHAR(50) UNIQUE NOT NULL,
+	email VARCHAR(100) UNIQUE NOT NULL,
+	password_hash VARCHAR(255) NOT NULL,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- exercises stores our predefined exercise library
+CREATE TABLE IF NOT EXISTS exercises (
+	id SERIAL PRIMARY KEY,
+	name VARCHAR(100) NOT NULL,
+	description TEXT,
+	category VARCHAR(50),
+	muscle_group VARCHAR(50)
+);
+
+-- workouts stores user-created workout plans
+CREATE TABLE IF NOT EXISTS workouts (
+	id SERIAL PRIMARY KEY,
+	user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	name VARCHAR(100) NOT NULL,
+	description TEXT,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- workout_exercises connects workouts with exercises
+-- and stores the specific details (sets, reps, weight)
+CREATE TABLE IF NOT EXISTS workout_exercises (
+	id SERIAL PRIMARY KEY,
+	workout_id INTEGER NOT NULL REFERENCES workouts(id) ON DELETE CASCADE,
+	exercise_id INTEGER NOT NULL REFERENCES exercises(id) ON DELETE CASCADE,
+	sets INTEGER NOT NULL,
+	reps INTEGER NOT NULL,
+	weight DECIMAL(5,2) DEFAULT 0,
+	notes TEXT
+);
+
+-- schedules stores when workouts are scheduled and whether they're completed
+CREATE TABLE IF NOT EXISTS schedules (
+	id SERIAL PRIMARY KEY,
+	user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	workout_id INTEGER NOT NULL REFERENCES workouts(id) ON DELETE CASCADE,
+	scheduled_date TIMESTAMP NOT NULL,
+	completed BOOLEAN DEFAULT FALSE,
+	completed_at TIMESTAMP,
+	notes TEXT
+);
+
+-- workout_logs tracks actual workout performance
+CREATE TABLE IF NOT EXISTS workout_logs (
+	id SERIAL PRIMARY KEY,
+	schedule_id INTEGER NOT NULL REFERENCES schedules(id) ON DELETE CASCADE,
+	exercise_id INTEGER NOT NULL REFERENCES exercises(id) ON DELETE CASCADE,
+	sets_completed INTEGER NOT NULL,
+	reps_completed INTEGER NOT NULL,
+	weight_used DECIMAL(5,2) DEFAULT 0,
+	duration INTEGER DEFAULT 0,
+	notes TEXT,
+	logged_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
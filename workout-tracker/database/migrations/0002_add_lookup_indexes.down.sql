@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_workout_logs_exercise_id;
+DROP INDEX IF EXISTS idx_workout_logs_schedule_id;
+DROP INDEX IF EXISTS idx_schedules_user_date;
+DROP INDEX IF EXISTS idx_workout_exercises_workout_id;
+DROP INDEX IF EXISTS idx_workouts_user_id;
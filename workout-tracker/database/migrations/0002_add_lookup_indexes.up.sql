@@ -0,0 +1,7 @@
+-- Every handler filters by the owner or the parent row,
+-- these indexes keep those lookups fast as the tables grow
+CREATE INDEX IF NOT EXISTS idx_workouts_user_id ON workouts(user_id);
+CREATE INDEX IF NOT EXISTS idx_workout_exercises_workout_id ON workout_exercises(workout_id);
+CREATE INDEX IF NOT EXISTS idx_schedules_user_date ON schedules(user_id, scheduled_date);
+CREATE INDEX IF NOT EXISTS idx_workout_logs_schedule_id ON workout_logs(schedule_id);
+CREATE INDEX IF NOT EXISTS idx_workout_logs_exercise_id ON workout_logs(exercise_id);
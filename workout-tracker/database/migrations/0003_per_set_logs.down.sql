@@ -0,0 +1,6 @@
+DROP TABLE IF EXISTS workout_sets;
+
+ALTER TABLE workout_exercises DROP COLUMN IF EXISTS rest_seconds;
+ALTER TABLE workout_exercises DROP COLUMN IF EXISTS group_type;
+ALTER TABLE workout_exercises DROP COLUMN IF EXISTS group_name;
+ALTER TABLE workout_exercises DROP COLUMN IF EXISTS position;
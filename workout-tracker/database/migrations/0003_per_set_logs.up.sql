@@ -0,0 +1,30 @@
+-- Supersets and circuits: exercises of a workout that share a group_name
+-- are done back-to-back, rest_seconds is the rest after the exercise (or the group)
+ALTER TABLE workout_exercises ADD COLUMN position INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE workout_exercises ADD COLUMN group_name VARCHAR(50) NOT NULL DEFAULT '';
+ALTER TABLE workout_exercises ADD COLUMN group_type VARCHAR(20) NOT NULL DEFAULT '';
+ALTER TABLE workout_exercises ADD COLUMN rest_seconds INTEGER NOT NULL DEFAULT 0;
+
+-- workout_sets stores every set of a logged exercise,
+-- so "5x5 at rising weight" keeps the weight of each set.
+-- workout_logs keeps its totals as a summary of the working sets.
+CREATE TABLE IF NOT EXISTS workout_sets (
+	id SERIAL PRIMARY KEY,
+	workout_log_id INTEGER NOT NULL REFERENCES workout_logs(id) ON DELETE CASCADE,
+	set_number INTEGER NOT NULL,
+	reps INTEGER NOT NULL,
+	weight DECIMAL(6,2) NOT NULL DEFAULT 0,
+	rpe DECIMAL(3,1),
+	rir INTEGER,
+	rest_seconds INTEGER NOT NULL DEFAULT 0,
+	is_warmup BOOLEAN NOT NULL DEFAULT FALSE
+);
+
+CREATE INDEX IF NOT EXISTS idx_workout_sets_log_id ON workout_sets(workout_log_id);
+
+-- Older logs only have totals, turn each of them into identical sets
+-- so the history looks the same for old and new logs
+INSERT INTO workout_sets (workout_log_id, set_number, reps, weight)
+SELECT wl.id, n, wl.reps_completed, COALESCE(wl.weight_used, 0)
+FROM workout_logs wl
+CROSS JOIN LATERAL generate_series(1, wl.sets_completed) AS n;
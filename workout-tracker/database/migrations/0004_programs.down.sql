@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS program_sessions;
+DROP TABLE IF EXISTS program_lifts;
+DROP TABLE IF EXISTS programs;
@@ -0,0 +1,39 @@
+-- Programs are multi-week plans made from a template (5/3/1, linear progression...)
+-- Every session of a program is a normal workout + schedule, so it shows up everywhere else too.
+CREATE TABLE IF NOT EXISTS programs (
+	id SERIAL PRIMARY KEY,
+	user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	name VARCHAR(100) NOT NULL,
+	template TEXT NOT NULL, -- the whole template as JSON, so later changes to the built-in ones don't change running programs
+	start_date TIMESTAMP NOT NULL,
+	rounding DECIMAL(6,2) NOT NULL DEFAULT 2.5,
+	status VARCHAR(20) NOT NULL DEFAULT 'active',
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- program_lifts keeps the training max of every main lift of a program
+CREATE TABLE IF NOT EXISTS program_lifts (
+	program_id INTEGER NOT NULL REFERENCES programs(id) ON DELETE CASCADE,
+	exercise_id INTEGER NOT NULL REFERENCES exercises(id) ON DELETE CASCADE,
+	position INTEGER NOT NULL DEFAULT 0,
+	training_max DECIMAL(6,2) NOT NULL,
+	failures INTEGER NOT NULL DEFAULT 0,
+	PRIMARY KEY (program_id, exercise_id)
+);
+
+-- program_sessions links each planned session to its workout and schedule
+CREATE TABLE IF NOT EXISTS program_sessions (
+	id SERIAL PRIMARY KEY,
+	program_id INTEGER NOT NULL REFERENCES programs(id) ON DELETE CASCADE,
+	workout_id INTEGER NOT NULL REFERENCES workouts(id) ON DELETE CASCADE,
+	schedule_id INTEGER NOT NULL REFERENCES schedules(id) ON DELETE CASCADE,
+	week INTEGER NOT NULL,
+	day INTEGER NOT NULL,
+	deload BOOLEAN NOT NULL DEFAULT FALSE,
+	status VARCHAR(20) NOT NULL DEFAULT 'planned',
+	note TEXT NOT NULL DEFAULT ''
+);
+
+CREATE INDEX IF NOT EXISTS idx_programs_user_id ON programs(user_id);
+CREATE INDEX IF NOT EXISTS idx_program_sessions_program_id ON program_sessions(program_id);
+CREATE INDEX IF NOT EXISTS idx_program_sessions_schedule_id ON program_sessions(schedule_id);
@@ -0,0 +1,7 @@
+DROP TABLE IF EXISTS calendar_feeds;
+DROP TABLE IF EXISTS schedule_series_exceptions;
+DROP INDEX IF EXISTS idx_schedules_series_id;
+ALTER TABLE schedules DROP COLUMN IF EXISTS detached;
+ALTER TABLE schedules DROP COLUMN IF EXISTS series_date;
+ALTER TABLE schedules DROP COLUMN IF EXISTS series_id;
+DROP TABLE IF EXISTS schedule_series;
@@ -0,0 +1,40 @@
+-- A series is a recurring schedule: one workout on the same weekdays for a number of weeks,
+-- e.g. "Mon/Wed/Fri at 07:00 for 8 weeks". Every occurrence is a normal row in schedules.
+CREATE TABLE IF NOT EXISTS schedule_series (
+	id SERIAL PRIMARY KEY,
+	user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	workout_id INTEGER NOT NULL REFERENCES workouts(id) ON DELETE CASCADE,
+	weekdays VARCHAR(30) NOT NULL, -- "mon,wed,fri"
+	time_of_day VARCHAR(5) NOT NULL, -- "07:00" (UTC)
+	start_date TIMESTAMP NOT NULL,
+	weeks INTEGER NOT NULL,
+	notes TEXT NOT NULL DEFAULT '',
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- series_date is the date the series planned the occurrence for, it doesn't change when
+-- the occurrence is moved. detached is true once an occurrence was edited on its own,
+-- then editing the whole series leaves it alone.
+-- Deleting a series keeps its completed occurrences as normal schedules (SET NULL).
+ALTER TABLE schedules ADD COLUMN series_id INTEGER REFERENCES schedule_series(id) ON DELETE SET NULL;
+ALTER TABLE schedules ADD COLUMN series_date TIMESTAMP;
+ALTER TABLE schedules ADD COLUMN detached BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- schedule_series_exceptions remembers occurrences that were deleted one by one,
+-- so editing the series doesn't bring them back
+CREATE TABLE IF NOT EXISTS schedule_series_exceptions (
+	series_id INTEGER NOT NULL REFERENCES schedule_series(id) ON DELETE CASCADE,
+	occurrence_date TIMESTAMP NOT NULL,
+	PRIMARY KEY (series_id, occurrence_date)
+);
+
+-- calendar_feeds holds the secret token of each user's .ics feed URL.
+-- Only a SHA-256 hash is stored, like a password the token can't be read back.
+CREATE TABLE IF NOT EXISTS calendar_feeds (
+	user_id INTEGER PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+	token_hash VARCHAR(64) UNIQUE NOT NULL,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_schedule_series_user_id ON schedule_series(user_id);
+CREATE INDEX IF NOT EXISTS idx_schedules_series_id ON schedules(series_id);
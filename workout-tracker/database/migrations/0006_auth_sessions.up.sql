@@ -0,0 +1,22 @@
+-- A session is one login (one browser or phone). Access tokens carry its id,
+-- so logging out (revoked_at) stops them right away, not only when they expire.
+CREATE TABLE IF NOT EXISTS auth_sessions (
+	id SERIAL PRIMARY KEY,
+	user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	revoked_at TIMESTAMP
+);
+
+-- refresh_tokens are the "token family" of a session: every refresh marks the old token
+-- used and adds a new one. Only a SHA-256 hash of the token is stored.
+-- A used token that comes back means it was stolen, then the whole session is revoked.
+CREATE TABLE IF NOT EXISTS refresh_tokens (
+	token_hash VARCHAR(64) PRIMARY KEY,
+	session_id INTEGER NOT NULL REFERENCES auth_sessions(id) ON DELETE CASCADE,
+	expires_at TIMESTAMP NOT NULL,
+	used_at TIMESTAMP,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_auth_sessions_user_id ON auth_sessions(user_id);
+CREATE INDEX IF NOT EXISTS idx_refresh_tokens_session_id ON refresh_tokens(session_id);
@@ -0,0 +1,28 @@
+-- Existing accounts start unverified, they can ask for a new verification mail
+ALTER TABLE users ADD COLUMN email_verified BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- Password resets look users up by email without case, so an email may only be used once
+-- ignoring case. Before this "Bob@x.com" and "bob@x.com" could both register:
+-- the oldest account keeps the address, the newer ones get "duplicate-<id>-" in front
+-- so they can't receive the mails of the other account (they can still log in by username).
+UPDATE users u
+SET email = 'duplicate-' || u.id || '-' || LEFT(u.email, 80)
+WHERE EXISTS (
+	SELECT 1 FROM users older
+	WHERE LOWER(older.email) = LOWER(u.email) AND older.id < u.id
+);
+UPDATE users SET email = LOWER(email) WHERE email <> LOWER(email);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_email_lower ON users (LOWER(email));
+
+-- user_tokens are the single-use tokens we send by email.
+-- purpose is 'verify_email' or 'reset_password'. Only a SHA-256 hash of the token is stored.
+CREATE TABLE IF NOT EXISTS user_tokens (
+	token_hash VARCHAR(64) PRIMARY KEY,
+	user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	purpose VARCHAR(20) NOT NULL,
+	expires_at TIMESTAMP NOT NULL,
+	used_at TIMESTAMP,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_user_tokens_user_id ON user_tokens(user_id);
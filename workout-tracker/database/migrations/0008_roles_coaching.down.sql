@@ -0,0 +1,4 @@
+ALTER TABLE schedules DROP COLUMN IF EXISTS assigned_by;
+ALTER TABLE workouts DROP COLUMN IF EXISTS assigned_by;
+DROP TABLE IF EXISTS coach_athletes;
+ALTER TABLE users DROP COLUMN IF EXISTS role;
@@ -0,0 +1,21 @@
+-- Every user has one role: 'athlete' (everyone starts here), 'coach' or 'admin'.
+-- Admins give out roles, the first admin is made with "go run . role <username> admin".
+ALTER TABLE users ADD COLUMN role VARCHAR(20) NOT NULL DEFAULT 'athlete';
+
+-- coach_athletes links a coach to an athlete. The coach invites, the link is 'pending'
+-- until the athlete accepts it ('active'). Either side can end it by deleting the row.
+CREATE TABLE IF NOT EXISTS coach_athletes (
+	coach_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	athlete_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	status VARCHAR(10) NOT NULL DEFAULT 'pending',
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	accepted_at TIMESTAMP,
+	PRIMARY KEY (coach_id, athlete_id),
+	CHECK (coach_id <> athlete_id)
+);
+
+-- assigned_by is the coach who created a workout or schedule for the athlete (NULL when the athlete did)
+ALTER TABLE workouts ADD COLUMN assigned_by INTEGER REFERENCES users(id) ON DELETE SET NULL;
+ALTER TABLE schedules ADD COLUMN assigned_by INTEGER REFERENCES users(id) ON DELETE SET NULL;
+
+CREATE INDEX IF NOT EXISTS idx_coach_athletes_athlete_id ON coach_athletes(athlete_id);
@@ -0,0 +1,9 @@
+DROP INDEX IF EXISTS idx_exercises_search;
+DROP INDEX IF EXISTS idx_exercises_user_name;
+DROP INDEX IF EXISTS idx_exercises_library_name;
+-- custom exercises would become library exercises without their owner, so they go first
+DELETE FROM exercises WHERE user_id IS NOT NULL;
+ALTER TABLE exercises DROP COLUMN IF EXISTS aliases;
+ALTER TABLE exercises DROP COLUMN IF EXISTS difficulty;
+ALTER TABLE exercises DROP COLUMN IF EXISTS equipment;
+ALTER TABLE exercises DROP COLUMN IF EXISTS user_id;
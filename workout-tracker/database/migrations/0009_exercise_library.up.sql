@@ -0,0 +1,30 @@
+-- user_id is the owner of a custom exercise, NULL for the global library everyone shares.
+-- Deleting a user deletes their custom exercises (and, through the foreign keys, where they were used).
+ALTER TABLE exercises ADD COLUMN user_id INTEGER REFERENCES users(id) ON DELETE CASCADE;
+
+-- equipment: e.g. 'Barbell', 'Dumbbell', 'Bodyweight', 'Machine'
+-- difficulty: 'beginner', 'intermediate' or 'advanced' ('' when unknown)
+-- aliases: other names people search for, e.g. {'RDL'} for the Romanian Deadlift
+ALTER TABLE exercises ADD COLUMN equipment VARCHAR(50) NOT NULL DEFAULT '';
+ALTER TABLE exercises ADD COLUMN difficulty VARCHAR(20) NOT NULL DEFAULT '';
+ALTER TABLE exercises ADD COLUMN aliases TEXT[] NOT NULL DEFAULT '{}';
+
+-- Before 0009 POST /api/admin/exercises didn't check names, so the library can hold
+-- e.g. "bench press" next to the seeded "Bench Press" and the unique index below would fail.
+-- The oldest one keeps its name, the others get their id added: "bench press (27)".
+-- Workouts and logs point to exercises by id, so nothing else changes.
+UPDATE exercises e
+SET name = LEFT(e.name, 90) || ' (' || e.id || ')'
+WHERE EXISTS (
+	SELECT 1 FROM exercises older
+	WHERE LOWER(older.name) = LOWER(e.name) AND older.id < e.id
+);
+
+-- Names are unique (ignoring case) in the library, and per user for custom exercises.
+-- The seeder upserts the library by name, so it needs the first index.
+CREATE UNIQUE INDEX IF NOT EXISTS idx_exercises_library_name ON exercises (LOWER(name)) WHERE user_id IS NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_exercises_user_name ON exercises (user_id, LOWER(name)) WHERE user_id IS NOT NULL;
+
+-- Full text search over name and description ("q" of GET /api/exercises)
+CREATE INDEX IF NOT EXISTS idx_exercises_search ON exercises
+	USING GIN (to_tsvector('english', name || ' ' || COALESCE(description, '')));
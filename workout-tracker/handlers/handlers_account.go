@@ -0,0 +1,239 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"workout-tracker/auth"
+	"workout-tracker/mailer"
+	"workout-tracker/middleware"
+	"workout-tracker/models"
+	"workout-tracker/store"
+)
+
+// How long the links in our emails work
+const (
+	verifyTokenHours = 48
+	resetTokenHours  = 1
+)
+
+// TokenRequest carries a token from an email link
+type TokenRequest struct {
+	Token string `json:"token"`
+}
+
+// ForgotPasswordRequest starts a password reset
+type ForgotPasswordRequest struct {
+	Email string `json:"email"`
+}
+
+// ResetPasswordRequest sets a new password with the token from the reset email
+type ResetPasswordRequest struct {
+	Token    string `json:"token"`
+	Password string `json:"password"`
+}
+
+// MessageResponse is a short answer for endpoints that have nothing else to return
+type MessageResponse struct {
+	Message string `json:"message"`
+}
+
+// sendToken creates a single-use token for the user and mails a link with it
+// page is the static page that handles the link, e.g. "verify-email.html"
+func (s *Server) sendToken(user models.User, purpose string, hours int, page, subject, text string) error {
+	token, err := auth.NewOpaqueToken()
+	if err != nil {
+		return err
+	}
+	record := &models.UserToken{
+		TokenHash: auth.HashToken(token),
+		UserID:    user.ID,
+		Purpose:   purpose,
+		ExpiresAt: time.Now().Add(time.Duration(hours) * time.Hour),
+	}
+	if err := s.Tokens.CreateUserToken(record); err != nil {
+		return err
+	}
+
+	link := fmt.Sprintf("%s/static/%s?token=%s", strings.TrimSuffix(s.AppURL, "/"), page, token)
+	return s.Mailer.Send(mailer.Message{
+		To:      user.Email,
+		Subject: subject,
+		Body: fmt.Sprintf("Hi %s,\n\n%s\n\n%s\n\nThe link works once and expires in %d hour(s).\n",
+			user.Username, text, link, hours),
+	})
+}
+
+// sendVerification mails the link that verifies the user's email address
+func (s *Server) sendVerification(user models.User) error {
+	return s.sendToken(user, models.TokenVerifyEmail, verifyTokenHours, "verify-email.html",
+		"Verify your email address",
+		"Welcome to Workout Tracker! Please open this link to verify your email address:")
+}
+
+// VerifyEmail marks the email address as verified with the token from the verification email
+func (s *Server) VerifyEmail(w http.ResponseWriter, r *http.Request) {
+	// Parse the request body
+	var req TokenRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.Token == "" {
+		http.Error(w, "token is required", http.StatusBadRequest)
+		return
+	}
+
+	token, err := s.Tokens.UseUserToken(auth.HashToken(req.Token), models.TokenVerifyEmail)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			http.Error(w, "Invalid or expired token", http.StatusBadRequest)
+			return
+		}
+		http.Error(w, "Error checking token", http.StatusInternalServerError)
+		return
+	}
+
+	if err := s.Users.SetEmailVerified(token.UserID); err != nil {
+		http.Error(w, "Error verifying email", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(MessageResponse{Message: "Your email address is verified"})
+}
+
+// ResendVerification sends a new verification email to the logged-in user
+func (s *Server) ResendVerification(w http.ResponseWriter, r *http.Request) {
+	// Get the current user from context
+	claims := middleware.GetUserFromContext(r)
+	if claims == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	user, err := s.Users.GetUserByID(claims.UserID)
+	if err != nil {
+		http.Error(w, "Error fetching user", http.StatusInternalServerError)
+		return
+	}
+	if user.EmailVerified {
+		http.Error(w, "Your email address is already verified", http.StatusConflict)
+		return
+	}
+
+	if !s.mailLimiter.Allow("user:" + strconv.Itoa(user.ID)) {
+		http.Error(w, "Too many emails, please try again later", http.StatusTooManyRequests)
+		return
+	}
+
+	if err := s.sendVerification(*user); err != nil {
+		http.Error(w, "Error sending email", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(MessageResponse{Message: "We sent you a new verification email"})
+}
+
+// ForgotPassword sends a password reset link to the email address
+//
+// Why is the answer the same when there is no account with that email?
+// answer: Otherwise anyone could use this endpoint to find out who has an account.
+func (s *Server) ForgotPassword(w http.ResponseWriter, r *http.Request) {
+	// Parse the request body
+	var req ForgotPasswordRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	email := strings.ToLower(strings.TrimSpace(req.Email))
+	if email == "" {
+		http.Error(w, "email is required", http.StatusBadRequest)
+		return
+	}
+
+	// Limit per client and per address, so nobody can flood a mailbox or our mail server
+	if !s.ipLimiter.Allow(middleware.ClientIP(r)) || !s.mailLimiter.Allow("email:"+email) {
+		http.Error(w, "Too many reset requests, please try again later", http.StatusTooManyRequests)
+		return
+	}
+
+	user, err := s.Users.GetUserByEmail(email)
+	if err != nil && !errors.Is(err, store.ErrNotFound) {
+		http.Error(w, "Error finding user", http.StatusInternalServerError)
+		return
+	}
+	if user != nil {
+		// The mail is sent after the response, a slower answer would tell that the account exists
+		s.background.Add(1)
+		go func(user models.User) {
+			defer s.background.Done()
+			err := s.sendToken(user, models.TokenResetPassword, resetTokenHours, "reset-password.html",
+				"Reset your password",
+				"Someone (hopefully you) asked to reset your Workout Tracker password. Open this link to choose a new one:")
+			if err != nil {
+				// An error response would tell that the account exists too, so we only log it
+				log.Printf("Error sending password reset email to user %d: %v", user.ID, err)
+			}
+		}(*user)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(MessageResponse{Message: "If an account uses this email address, we sent it a link to reset the password"})
+}
+
+// ResetPassword sets a new password with the token from the reset email
+// Every session of the user is logged out, a thief who knew the old password is locked out too
+func (s *Server) ResetPassword(w http.ResponseWriter, r *http.Request) {
+	// Parse the request body
+	var req ResetPasswordRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.Token == "" || req.Password == "" {
+		http.Error(w, "token and password are required", http.StatusBadRequest)
+		return
+	}
+
+	token, err := s.Tokens.UseUserToken(auth.HashToken(req.Token), models.TokenResetPassword)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			http.Error(w, "Invalid or expired token", http.StatusBadRequest)
+			return
+		}
+		http.Error(w, "Error checking token", http.StatusInternalServerError)
+		return
+	}
+
+	// Hash the new password before storing it
+	passwordHash, err := auth.HashPassword(req.Password)
+	if err != nil {
+		http.Error(w, "Error processing password", http.StatusInternalServerError)
+		return
+	}
+	if err := s.Users.SetPassword(token.UserID, passwordHash); err != nil {
+		http.Error(w, "Error saving password", http.StatusInternalServerError)
+		return
+	}
+	if err := s.Sessions.RevokeUserSessions(token.UserID); err != nil {
+		http.Error(w, "Error logging out sessions", http.StatusInternalServerError)
+		return
+	}
+	// The link came to the user's mailbox, so the address works
+	if err := s.Users.SetEmailVerified(token.UserID); err != nil {
+		http.Error(w, "Error verifying email", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(MessageResponse{Message: "Your password was changed, please log in again"})
+}
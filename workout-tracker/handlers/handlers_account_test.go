@@ -0,0 +1,137 @@
+package handlers
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+	"workout-tracker/mailer"
+	"workout-tracker/models"
+)
+
+// lastToken returns the token of the link in the last mail to the address
+func (m *testMailer) lastToken(t *testing.T, to string) string {
+	t.Helper()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i := len(m.sent) - 1; i >= 0; i-- {
+		if m.sent[i].To != to {
+			continue
+		}
+		_, rest, ok := strings.Cut(m.sent[i].Body, "?token=")
+		if !ok {
+			t.Fatalf("no link in the mail to %s: %q", to, m.sent[i].Body)
+		}
+		return strings.Fields(rest)[0]
+	}
+	t.Fatalf("no mail to %s", to)
+	return ""
+}
+
+func TestVerifyEmailTokenWorksOnce(t *testing.T) {
+	api := newTestAPI(t)
+	alice := api.register("alice")
+	if alice.User.EmailVerified {
+		t.Fatal("a new user is already verified")
+	}
+	token := api.mail.lastToken(t, "alice@example.com")
+
+	expect(t, api.do("POST", "/api/verify-email", "", TokenRequest{Token: token}), http.StatusOK, "verify email")
+	expect(t, api.do("POST", "/api/verify-email", "", TokenRequest{Token: token}), http.StatusBadRequest, "verify email with the used token")
+
+	rec := api.do("GET", "/api/me", alice.Token, nil)
+	expect(t, rec, http.StatusOK, "GET /api/me")
+	var me models.User
+	decode(t, rec, &me)
+	if !me.EmailVerified {
+		t.Error("the email is not verified")
+	}
+	expect(t, api.do("POST", "/api/verify-email/resend", alice.Token, nil), http.StatusConflict, "resend after verifying")
+}
+
+func TestResetPasswordTokenWorksOnce(t *testing.T) {
+	api := newTestAPI(t)
+	alice := api.register("alice")
+
+	// the address is found in any case, the answer is the same for unknown addresses
+	expect(t, api.do("POST", "/api/password/forgot", "", ForgotPasswordRequest{Email: " Alice@Example.com"}), http.StatusAccepted, "forgot password")
+	expect(t, api.do("POST", "/api/password/forgot", "", ForgotPasswordRequest{Email: "nobody@example.com"}), http.StatusAccepted, "forgot password of an unknown address")
+	token := api.mail.lastToken(t, "alice@example.com")
+
+	// a reset token can't verify the email, and it isn't used up by trying
+	expect(t, api.do("POST", "/api/verify-email", "", TokenRequest{Token: token}), http.StatusBadRequest, "verify email with a reset token")
+
+	expect(t, api.do("POST", "/api/password/reset", "", ResetPasswordRequest{Token: token, Password: "new-secret"}), http.StatusOK, "reset password")
+	expect(t, api.do("POST", "/api/password/reset", "", ResetPasswordRequest{Token: token, Password: "other-secret"}), http.StatusBadRequest, "reset password with the used token")
+
+	// the first reset counts: the new password works, the old one and the old sessions don't
+	expect(t, api.do("POST", "/api/login", "", LoginRequest{Username: "alice", Password: "secret123"}), http.StatusUnauthorized, "login with the old password")
+	expect(t, api.do("POST", "/api/login", "", LoginRequest{Username: "alice", Password: "other-secret"}), http.StatusUnauthorized, "login with the password of the second reset")
+	expect(t, api.do("POST", "/api/login", "", LoginRequest{Username: "alice", Password: "new-secret"}), http.StatusOK, "login with the new password")
+	expect(t, api.do("GET", "/api/me", alice.Token, nil), http.StatusUnauthorized, "access token from before the reset")
+	expect(t, api.do("POST", "/api/refresh", "", RefreshRequest{RefreshToken: alice.RefreshToken}), http.StatusUnauthorized, "refresh token from before the reset")
+}
+
+// blockedMailer doesn't send anything until release is closed
+type blockedMailer struct {
+	release chan struct{}
+}
+
+func (m blockedMailer) Send(mailer.Message) error {
+	<-m.release
+	return nil
+}
+
+// The reset mail is sent after the response, a slow mail server can't tell who has an account
+func TestForgotPasswordDoesNotWaitForTheMail(t *testing.T) {
+	api := newTestAPI(t)
+	api.register("alice")
+	mail := blockedMailer{release: make(chan struct{})}
+	api.server.Mailer = mail
+	defer api.server.Wait()
+	defer close(mail.release)
+
+	answered := make(chan int)
+	go func() {
+		rec := httptest.NewRecorder()
+		api.router.ServeHTTP(rec, httptest.NewRequest("POST", "/api/password/forgot", bytes.NewBufferString(`{"email": "alice@example.com"}`)))
+		answered <- rec.Code
+	}()
+	select {
+	case code := <-answered:
+		if code != http.StatusAccepted {
+			t.Errorf("forgot password: status %d, want %d", code, http.StatusAccepted)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("forgot password waits for the mail")
+	}
+}
+
+// Using one verification link uses up the older ones too
+func TestVerifyEmailUsesOlderTokens(t *testing.T) {
+	api := newTestAPI(t)
+	alice := api.register("alice")
+	older := api.mail.lastToken(t, "alice@example.com")
+
+	expect(t, api.do("POST", "/api/verify-email/resend", alice.Token, nil), http.StatusAccepted, "resend verification")
+	newer := api.mail.lastToken(t, "alice@example.com")
+	if newer == older {
+		t.Fatal("the resent mail has the same token")
+	}
+
+	expect(t, api.do("POST", "/api/verify-email", "", TokenRequest{Token: newer}), http.StatusOK, "verify with the newer token")
+	expect(t, api.do("POST", "/api/verify-email", "", TokenRequest{Token: older}), http.StatusBadRequest, "verify with the older token")
+}
+
+func TestEmailTokenErrors(t *testing.T) {
+	api := newTestAPI(t)
+	api.register("alice")
+
+	expect(t, api.do("POST", "/api/verify-email", "", TokenRequest{}), http.StatusBadRequest, "verify without a token")
+	expect(t, api.do("POST", "/api/verify-email", "", TokenRequest{Token: "abc"}), http.StatusBadRequest, "verify with an unknown token")
+	expect(t, api.do("POST", "/api/password/reset", "", ResetPasswordRequest{Token: "abc", Password: "new-secret"}), http.StatusBadRequest, "reset with an unknown token")
+	expect(t, api.do("POST", "/api/password/reset", "", ResetPasswordRequest{Password: "new-secret"}), http.StatusBadRequest, "reset without a token")
+}
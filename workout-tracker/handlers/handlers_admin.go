@@ -0,0 +1,181 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"workout-tracker/middleware"
+	"workout-tracker/models"
+	"workout-tracker/store"
+
+	"github.com/gorilla/mux"
+)
+
+// SetRoleRequest represents the new role of a user
+type SetRoleRequest struct {
+	Role string `json:"role"` // "athlete", "coach" or "admin"
+}
+
+// GetUsers returns every user with their role (admins only)
+func (s *Server) GetUsers(w http.ResponseWriter, r *http.Request) {
+	users, err := s.Users.ListUsers()
+	if err != nil {
+		http.Error(w, "Error fetching users", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(users)
+}
+
+// SetUserRole changes the role of a user (admins only)
+func (s *Server) SetUserRole(w http.ResponseWriter, r *http.Request) {
+	// Get the current user from context
+	claims := middleware.GetUserFromContext(r)
+	if claims == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	vars := mux.Vars(r)
+	userID, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	// Parse the request body
+	var req SetRoleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.Role != models.RoleAthlete && req.Role != models.RoleCoach && req.Role != models.RoleAdmin {
+		http.Error(w, "role must be athlete, coach or admin", http.StatusBadRequest)
+		return
+	}
+
+	// Otherwise the last admin could lock everyone out of the admin endpoints
+	if userID == claims.UserID && req.Role != models.RoleAdmin {
+		http.Error(w, "You can't remove your own admin role", http.StatusBadRequest)
+		return
+	}
+
+	if err := s.Users.SetRole(userID, req.Role); err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			http.Error(w, "User not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Error saving role", http.StatusInternalServerError)
+		return
+	}
+
+	// Fetch and return the updated user
+	user, err := s.Users.GetUserByID(userID)
+	if err != nil {
+		http.Error(w, "Error fetching user", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(user)
+}
+
+// CreateExercise adds an exercise to the library (admins only)
+// The exercise has no owner (UserID nil), so everyone sees it
+func (s *Server) CreateExercise(w http.ResponseWriter, r *http.Request) {
+	// Parse the request body
+	var req ExerciseRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	exercise, err := req.toExercise(0)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := s.Exercises.CreateExercise(exercise); err != nil {
+		if errors.Is(err, store.ErrDuplicate) {
+			http.Error(w, "The library already has an exercise with this name", http.StatusConflict)
+			return
+		}
+		http.Error(w, "Error creating exercise", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(exercise)
+}
+
+// UpdateExercise changes an exercise of the library (admins only)
+// Workouts and logs point to the exercise by ID, so they show the new name right away.
+// Exercises from seeder/exercises.json get the catalog's values back at the next start.
+func (s *Server) UpdateExercise(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	exerciseID, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid exercise ID", http.StatusBadRequest)
+		return
+	}
+
+	// Parse the request body
+	var req ExerciseRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	exercise, err := req.toExercise(exerciseID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := s.Exercises.UpdateExercise(exercise); err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			http.Error(w, "Exercise not found", http.StatusNotFound)
+			return
+		}
+		if errors.Is(err, store.ErrDuplicate) {
+			http.Error(w, "The library already has an exercise with this name", http.StatusConflict)
+			return
+		}
+		http.Error(w, "Error updating exercise", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(exercise)
+}
+
+// DeleteExercise removes an exercise from the library (admins only)
+// An exercise that is used in a workout, a log or a program can't be deleted
+func (s *Server) DeleteExercise(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	exerciseID, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid exercise ID", http.StatusBadRequest)
+		return
+	}
+
+	// 0 is the library, custom exercises belong to their users
+	if err := s.Exercises.DeleteExercise(exerciseID, 0); err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			http.Error(w, "Exercise not found", http.StatusNotFound)
+			return
+		}
+		if errors.Is(err, store.ErrInUse) {
+			http.Error(w, "The exercise is used in workouts or logs and can't be deleted", http.StatusConflict)
+			return
+		}
+		http.Error(w, "Error deleting exercise", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
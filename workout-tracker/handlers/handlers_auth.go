@@ -1,13 +1,17 @@
 package handlers
 
 import (
-	"database/sql"
 	"encoding/json"
+	"errors"
+	"log"
 	"net/http"
+	"net/mail"
+	"strings"
+	"time"
 	"workout-tracker/auth"
-	"workout-tracker/database"
 	"workout-tracker/middleware"
 	"workout-tracker/models"
+	"workout-tracker/store"
 )
 
 // RegisterRequest represents the data needed to register a new user
@@ -23,14 +27,64 @@ type LoginRequest struct {
 	Password string `json:"password"`
 }
 
-// LoginResponse is what we send back after successful login
+// LoginResponse is what we send back after successful login (and after a refresh)
 type LoginResponse struct {
-	Token string      `json:"token"`
-	User  models.User `json:"user"`
+	Token        string      `json:"token"`         // access token, send it as "Authorization: Bearer <token>"
+	ExpiresIn    int         `json:"expires_in"`    // seconds until the access token expires
+	RefreshToken string      `json:"refresh_token"` // send it to /api/refresh for new tokens, it works once
+	User         models.User `json:"user"`
+}
+
+// RefreshRequest represents the data needed to get new tokens
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+// newRefreshToken makes a refresh token for a session
+// It returns the token for the client and the record to store (with only the hash)
+func newRefreshToken(userID, sessionID int) (string, *models.RefreshToken, error) {
+	token, err := auth.NewOpaqueToken()
+	if err != nil {
+		return "", nil, err
+	}
+	record := &models.RefreshToken{
+		TokenHash: auth.HashToken(token),
+		SessionID: sessionID,
+		UserID:    userID,
+		ExpiresAt: time.Now().AddDate(0, 0, auth.RefreshTokenDays),
+	}
+	return token, record, nil
+}
+
+// loginResponse builds the response with a new access token for the session
+func loginResponse(user models.User, sessionID int, refreshToken string) (LoginResponse, error) {
+	token, err := auth.GenerateToken(user.ID, user.Username, sessionID)
+	if err != nil {
+		return LoginResponse{}, err
+	}
+	return LoginResponse{
+		Token:        token,
+		ExpiresIn:    auth.AccessTokenMinutes * 60,
+		RefreshToken: refreshToken,
+		User:         user,
+	}, nil
+}
+
+// startSession creates a new login session for the user and its first tokens
+func (s *Server) startSession(user models.User) (LoginResponse, error) {
+	refreshToken, record, err := newRefreshToken(user.ID, 0)
+	if err != nil {
+		return LoginResponse{}, err
+	}
+	session := models.Session{UserID: user.ID}
+	if err := s.Sessions.CreateSession(&session, record); err != nil {
+		return LoginResponse{}, err
+	}
+	return loginResponse(user, session.ID, refreshToken)
 }
 
 // Register creates a new user account
-func Register(w http.ResponseWriter, r *http.Request) {
+func (s *Server) Register(w http.ResponseWriter, r *http.Request) {
 	// Parse the request body
 	var req RegisterRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -39,10 +93,17 @@ func Register(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Validate input
+	// Emails are saved in lower case, so "Bob@x.com" and "bob@x.com" are one account
+	req.Email = strings.ToLower(strings.TrimSpace(req.Email))
 	if req.Username == "" || req.Email == "" || req.Password == "" {
 		http.Error(w, "Username, email, and password are required", http.StatusBadRequest)
 		return
 	}
+	// ParseAddress also accepts "Name <a@b.c>", we only want the address itself
+	if address, err := mail.ParseAddress(req.Email); err != nil || address.Address != req.Email {
+		http.Error(w, "Invalid email address", http.StatusBadRequest)
+		return
+	}
 
 	// Hash the password before storing it
 	passwordHash, err := auth.HashPassword(req.Password)
@@ -51,18 +112,16 @@ func Register(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Insert the new user into the database
-	var userID int
-	err = database.DB.QueryRow(
-		`INSERT INTO users (username, email, password_hash) 
-		 VALUES ($1, $2, $3) RETURNING id`,
-		req.Username, req.Email, passwordHash,
-	).Scan(&userID)
-
+	// Save the new user
+	user := models.User{
+		Username:     req.Username,
+		Email:        req.Email,
+		PasswordHash: passwordHash,
+	}
+	err = s.Users.CreateUser(&user)
 	if err != nil {
 		// Check if it's a duplicate username/email error
-		if err.Error() == "pq: duplicate key value violates unique constraint \"users_username_key\"" ||
-			err.Error() == "pq: duplicate key value violates unique constraint \"users_email_key\"" {
+		if errors.Is(err, store.ErrDuplicate) {
 			http.Error(w, "Username or email already exists", http.StatusConflict)
 			return
 		}
@@ -70,33 +129,26 @@ func Register(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Return the created user (without password hash)
-	user := models.User{
-		ID:       userID,
-		Username: req.Username,
-		Email:    req.Email,
+	// Send the verification email
+	// Registration still works when the mail can't be sent, the user can ask for a new one
+	if err := s.sendVerification(user); err != nil {
+		log.Printf("Error sending verification email to user %d: %v", user.ID, err)
 	}
 
-	// Generate JWT token for the new user
-	token, err := auth.GenerateToken(userID, req.Username)
+	// Log the new user in: a session with an access and a refresh token
+	response, err := s.startSession(user)
 	if err != nil {
 		http.Error(w, "Error generating token", http.StatusInternalServerError)
 		return
 	}
 
-	// Send response with token and user
-	response := LoginResponse{
-		Token: token,
-		User:  user,
-	}
-
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
 	json.NewEncoder(w).Encode(response)
 }
 
-// Login authenticates a user and returns a JWT token
-func Login(w http.ResponseWriter, r *http.Request) {
+// Login authenticates a user and returns an access token and a refresh token
+func (s *Server) Login(w http.ResponseWriter, r *http.Request) {
 	// Parse the request body
 	var req LoginRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -110,17 +162,10 @@ func Login(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Find the user in the database
-	var user models.User
-	var passwordHash string
-	err := database.DB.QueryRow(
-		`SELECT id, username, email, password_hash, created_at 
-		 FROM users WHERE username = $1`,
-		req.Username,
-	).Scan(&user.ID, &user.Username, &user.Email, &passwordHash, &user.CreatedAt)
-
+	// Find the user
+	user, err := s.Users.GetUserByUsername(req.Username)
 	if err != nil {
-		if err == sql.ErrNoRows {
+		if errors.Is(err, store.ErrNotFound) {
 			http.Error(w, "Invalid username or password", http.StatusUnauthorized)
 			return
 		}
@@ -129,30 +174,134 @@ func Login(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Check if the password is correct
-	if !auth.CheckPassword(req.Password, passwordHash) {
+	if !auth.CheckPassword(req.Password, user.PasswordHash) {
 		http.Error(w, "Invalid username or password", http.StatusUnauthorized)
 		return
 	}
 
-	// Generate a JWT token
-	token, err := auth.GenerateToken(user.ID, user.Username)
+	// Start a session with an access and a refresh token
+	response, err := s.startSession(*user)
+	if err != nil {
+		http.Error(w, "Error generating token", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// Refresh swaps a refresh token for a new access token and a new refresh token
+// Every refresh token works only once (rotation).
+//
+// What if a used refresh token comes back?
+// answer: Then two clients have the same token, so it was stolen (or copied).
+// We can't tell which one is the real user, so the whole session is revoked:
+// the thief and the user are both logged out, and the user logs in again.
+func (s *Server) Refresh(w http.ResponseWriter, r *http.Request) {
+	// Parse the request body
+	var req RefreshRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.RefreshToken == "" {
+		http.Error(w, "refresh_token is required", http.StatusBadRequest)
+		return
+	}
+
+	// Find the token by its hash
+	oldHash := auth.HashToken(req.RefreshToken)
+	old, err := s.Sessions.GetRefreshToken(oldHash)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			http.Error(w, "Invalid refresh token", http.StatusUnauthorized)
+			return
+		}
+		http.Error(w, "Error checking refresh token", http.StatusInternalServerError)
+		return
+	}
+
+	// Reuse: revoke the whole token family
+	if old.UsedAt != nil {
+		s.revokeReusedSession(w, old.SessionID)
+		return
+	}
+
+	active, err := s.Sessions.SessionActive(old.SessionID)
+	if err != nil {
+		http.Error(w, "Error checking session", http.StatusInternalServerError)
+		return
+	}
+	if !active {
+		http.Error(w, "Session was logged out", http.StatusUnauthorized)
+		return
+	}
+	if time.Now().After(old.ExpiresAt) {
+		http.Error(w, "Refresh token expired, please log in again", http.StatusUnauthorized)
+		return
+	}
+
+	user, err := s.Users.GetUserByID(old.UserID)
+	if err != nil {
+		http.Error(w, "Error fetching user", http.StatusInternalServerError)
+		return
+	}
+
+	// Swap the old token for a new one of the same session
+	refreshToken, next, err := newRefreshToken(user.ID, old.SessionID)
 	if err != nil {
 		http.Error(w, "Error generating token", http.StatusInternalServerError)
 		return
 	}
+	if err := s.Sessions.RotateRefreshToken(oldHash, next); err != nil {
+		// Another request used the same token a moment ago, that is reuse too
+		if errors.Is(err, store.ErrTokenUsed) {
+			s.revokeReusedSession(w, old.SessionID)
+			return
+		}
+		http.Error(w, "Error saving refresh token", http.StatusInternalServerError)
+		return
+	}
 
-	// Return the token and user info
-	response := LoginResponse{
-		Token: token,
-		User:  user,
+	response, err := loginResponse(*user, old.SessionID, refreshToken)
+	if err != nil {
+		http.Error(w, "Error generating token", http.StatusInternalServerError)
+		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(response)
 }
 
+// revokeReusedSession logs out a session whose refresh token was used twice
+func (s *Server) revokeReusedSession(w http.ResponseWriter, sessionID int) {
+	if err := s.Sessions.RevokeSession(sessionID); err != nil {
+		http.Error(w, "Error revoking session", http.StatusInternalServerError)
+		return
+	}
+	http.Error(w, "Refresh token was already used, the session was logged out for safety", http.StatusUnauthorized)
+}
+
+// Logout ends the current session
+// The access token and every refresh token of the session stop working
+func (s *Server) Logout(w http.ResponseWriter, r *http.Request) {
+	// Get the current user from context
+	claims := middleware.GetUserFromContext(r)
+	if claims == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	if err := s.Sessions.RevokeSession(claims.SessionID); err != nil {
+		http.Error(w, "Error logging out", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
 // GetCurrentUser returns information about the currently logged-in user
-func GetCurrentUser(w http.ResponseWriter, r *http.Request) {
+func (s *Server) GetCurrentUser(w http.ResponseWriter, r *http.Request) {
 	// Get user info from the context (set by AuthMiddleware)
 	claims := middleware.GetUserFromContext(r)
 	if claims == nil {
@@ -160,13 +309,8 @@ func GetCurrentUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Fetch full user details from database
-	var user models.User
-	err := database.DB.QueryRow(
-		`SELECT id, username, email, created_at FROM users WHERE id = $1`,
-		claims.UserID,
-	).Scan(&user.ID, &user.Username, &user.Email, &user.CreatedAt)
-
+	// Fetch full user details
+	user, err := s.Users.GetUserByID(claims.UserID)
 	if err != nil {
 		http.Error(w, "Error fetching user", http.StatusInternalServerError)
 		return
@@ -0,0 +1,157 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"workout-tracker/models"
+)
+
+func TestRegisterAndLogin(t *testing.T) {
+	api := newTestAPI(t)
+
+	registered := api.register("alice")
+	if registered.Token == "" || registered.RefreshToken == "" || registered.User.Username != "alice" {
+		t.Fatalf("register response = %+v", registered)
+	}
+	if registered.User.Role != models.RoleAthlete {
+		t.Errorf("new user role = %q, want %q", registered.User.Role, models.RoleAthlete)
+	}
+
+	rec := api.do("POST", "/api/login", "", LoginRequest{Username: "alice", Password: "secret123"})
+	expect(t, rec, http.StatusOK, "login")
+	var login LoginResponse
+	decode(t, rec, &login)
+
+	rec = api.do("GET", "/api/me", login.Token, nil)
+	expect(t, rec, http.StatusOK, "GET /api/me")
+	var me models.User
+	decode(t, rec, &me)
+	if me.Username != "alice" || me.Email != "alice@example.com" {
+		t.Errorf("GET /api/me = %+v", me)
+	}
+}
+
+func TestRegisterErrors(t *testing.T) {
+	api := newTestAPI(t)
+	api.register("alice")
+
+	tests := []struct {
+		name string
+		req  RegisterRequest
+		want int
+	}{
+		{"missing password", RegisterRequest{Username: "bob", Email: "bob@example.com"}, http.StatusBadRequest},
+		{"missing email", RegisterRequest{Username: "bob", Password: "secret123"}, http.StatusBadRequest},
+		{"invalid email", RegisterRequest{Username: "bob", Email: "Bob <bob@example.com>", Password: "secret123"}, http.StatusBadRequest},
+		{"username taken", RegisterRequest{Username: "alice", Email: "other@example.com", Password: "secret123"}, http.StatusConflict},
+		{"email taken", RegisterRequest{Username: "bob", Email: "alice@example.com", Password: "secret123"}, http.StatusConflict},
+		{"email taken in other case", RegisterRequest{Username: "bob", Email: " Alice@Example.COM", Password: "secret123"}, http.StatusConflict},
+	}
+	for _, tt := range tests {
+		if rec := api.do("POST", "/api/register", "", tt.req); rec.Code != tt.want {
+			t.Errorf("%s: status %d, want %d: %s", tt.name, rec.Code, tt.want, rec.Body)
+		}
+	}
+}
+
+func TestUnauthorized(t *testing.T) {
+	api := newTestAPI(t)
+	alice := api.register("alice")
+
+	for _, req := range []LoginRequest{{Username: "alice", Password: "wrong"}, {Username: "nobody", Password: "secret123"}} {
+		if rec := api.do("POST", "/api/login", "", req); rec.Code != http.StatusUnauthorized {
+			t.Errorf("login as %s with %q: status %d, want 401", req.Username, req.Password, rec.Code)
+		}
+	}
+
+	tests := []struct {
+		name          string
+		authorization string
+	}{
+		{"no token", ""},
+		{"not a bearer token", "Basic " + alice.Token},
+		{"invalid token", "Bearer abc"},
+		{"refresh token as access token", "Bearer " + alice.RefreshToken},
+	}
+	for _, tt := range tests {
+		for _, path := range []string{"/api/me", "/api/workouts", "/api/schedule"} {
+			req := httptest.NewRequest("GET", path, nil)
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+			rec := httptest.NewRecorder()
+			api.router.ServeHTTP(rec, req)
+			if rec.Code != http.StatusUnauthorized {
+				t.Errorf("%s: GET %s status %d, want 401", tt.name, path, rec.Code)
+			}
+		}
+	}
+}
+
+func TestLogout(t *testing.T) {
+	api := newTestAPI(t)
+	alice := api.register("alice")
+
+	expect(t, api.do("POST", "/api/logout", alice.Token, nil), http.StatusNoContent, "logout")
+	expect(t, api.do("GET", "/api/me", alice.Token, nil), http.StatusUnauthorized, "GET /api/me after logout")
+	expect(t, api.do("POST", "/api/refresh", "", RefreshRequest{RefreshToken: alice.RefreshToken}), http.StatusUnauthorized, "refresh after logout")
+}
+
+func TestRefreshRotation(t *testing.T) {
+	api := newTestAPI(t)
+	alice := api.register("alice")
+
+	rec := api.do("POST", "/api/refresh", "", RefreshRequest{RefreshToken: alice.RefreshToken})
+	expect(t, rec, http.StatusOK, "refresh")
+	var first LoginResponse
+	decode(t, rec, &first)
+	if first.Token == "" || first.RefreshToken == "" || first.RefreshToken == alice.RefreshToken || first.User.ID != alice.User.ID {
+		t.Fatalf("refresh response = %+v", first)
+	}
+	expect(t, api.do("GET", "/api/me", first.Token, nil), http.StatusOK, "GET /api/me with the new access token")
+
+	// the new refresh token works once too
+	rec = api.do("POST", "/api/refresh", "", RefreshRequest{RefreshToken: first.RefreshToken})
+	expect(t, rec, http.StatusOK, "second refresh")
+	var second LoginResponse
+	decode(t, rec, &second)
+	if second.RefreshToken == first.RefreshToken {
+		t.Error("the second refresh returned the same refresh token")
+	}
+	expect(t, api.do("GET", "/api/me", second.Token, nil), http.StatusOK, "GET /api/me after the second refresh")
+}
+
+// A refresh token that comes back after it was swapped was copied, the whole session is logged out
+func TestRefreshReuseRevokesSession(t *testing.T) {
+	api := newTestAPI(t)
+	alice := api.register("alice")
+
+	// a second login is another session, it must keep working
+	rec := api.do("POST", "/api/login", "", LoginRequest{Username: "alice", Password: "secret123"})
+	expect(t, rec, http.StatusOK, "login")
+	var other LoginResponse
+	decode(t, rec, &other)
+
+	rec = api.do("POST", "/api/refresh", "", RefreshRequest{RefreshToken: alice.RefreshToken})
+	expect(t, rec, http.StatusOK, "refresh")
+	var rotated LoginResponse
+	decode(t, rec, &rotated)
+
+	expect(t, api.do("POST", "/api/refresh", "", RefreshRequest{RefreshToken: alice.RefreshToken}), http.StatusUnauthorized, "reuse of the old refresh token")
+
+	expect(t, api.do("POST", "/api/refresh", "", RefreshRequest{RefreshToken: rotated.RefreshToken}), http.StatusUnauthorized, "refresh with the rotated token after the reuse")
+	expect(t, api.do("GET", "/api/me", rotated.Token, nil), http.StatusUnauthorized, "access token of the revoked session")
+	expect(t, api.do("GET", "/api/me", alice.Token, nil), http.StatusUnauthorized, "first access token of the revoked session")
+
+	expect(t, api.do("GET", "/api/me", other.Token, nil), http.StatusOK, "access token of the other session")
+	expect(t, api.do("POST", "/api/refresh", "", RefreshRequest{RefreshToken: other.RefreshToken}), http.StatusOK, "refresh of the other session")
+}
+
+func TestRefreshErrors(t *testing.T) {
+	api := newTestAPI(t)
+	api.register("alice")
+
+	expect(t, api.do("POST", "/api/refresh", "", RefreshRequest{}), http.StatusBadRequest, "refresh without a token")
+	expect(t, api.do("POST", "/api/refresh", "", RefreshRequest{RefreshToken: "abc"}), http.StatusUnauthorized, "refresh with an unknown token")
+}
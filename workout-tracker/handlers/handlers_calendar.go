@@ -0,0 +1,153 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+	"workout-tracker/auth"
+	"workout-tracker/calendar"
+	"workout-tracker/middleware"
+	"workout-tracker/store"
+
+	"github.com/gorilla/mux"
+)
+
+// feedHistoryDays is how far back the .ics feed goes, older workouts are left out
+const feedHistoryDays = 90
+
+// CalendarTokenResponse is returned when a feed token is created
+type CalendarTokenResponse struct {
+	Token   string `json:"token"`
+	FeedURL string `json:"feed_url"` // subscribe to this URL in Google Calendar, Apple Calendar or Outlook
+}
+
+// CreateCalendarToken creates (or replaces) the secret token of the user's .ics feed
+// The old feed URL stops working, so this is also how a leaked URL is revoked
+func (s *Server) CreateCalendarToken(w http.ResponseWriter, r *http.Request) {
+	// Get the current user from context
+	claims := middleware.GetUserFromContext(r)
+	if claims == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	// Only the hash is stored, see auth.HashToken
+	token, err := auth.NewOpaqueToken()
+	if err != nil {
+		http.Error(w, "Error creating token", http.StatusInternalServerError)
+		return
+	}
+
+	if err := s.Calendar.SetCalendarToken(claims.UserID, auth.HashToken(token)); err != nil {
+		http.Error(w, "Error saving token", http.StatusInternalServerError)
+		return
+	}
+
+	// Calendar apps need the full URL
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(CalendarTokenResponse{
+		Token:   token,
+		FeedURL: fmt.Sprintf("%s://%s/api/calendar/%s.ics", scheme, r.Host, token),
+	})
+}
+
+// DeleteCalendarToken turns the user's .ics feed off
+func (s *Server) DeleteCalendarToken(w http.ResponseWriter, r *http.Request) {
+	// Get the current user from context
+	claims := middleware.GetUserFromContext(r)
+	if claims == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	err := s.Calendar.DeleteCalendarToken(claims.UserID)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			http.Error(w, "Calendar feed not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Error deleting token", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetCalendarFeed serves the user's schedule as an iCalendar (.ics) feed
+// It has no JWT: calendar apps can't log in, the secret token in the URL is the login
+func (s *Server) GetCalendarFeed(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	userID, err := s.Calendar.CalendarUser(auth.HashToken(vars["token"]))
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			http.Error(w, "Calendar feed not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Error fetching calendar feed", http.StatusInternalServerError)
+		return
+	}
+
+	user, err := s.Users.GetUserByID(userID)
+	if err != nil {
+		http.Error(w, "Error fetching calendar feed", http.StatusInternalServerError)
+		return
+	}
+
+	// Move missed program sessions first, like GET /api/schedule does
+	if _, err := s.refreshPrograms(userID); err != nil {
+		http.Error(w, "Error updating programs", http.StatusInternalServerError)
+		return
+	}
+
+	schedules, err := s.Schedules.ListSchedules(userID, store.ScheduleFilter{})
+	if err != nil {
+		http.Error(w, "Error fetching schedules", http.StatusInternalServerError)
+		return
+	}
+
+	now := time.Now()
+	from := now.AddDate(0, 0, -feedHistoryDays)
+	events := []calendar.Event{}
+	for _, schedule := range schedules {
+		if schedule.ScheduledDate.Before(from) {
+			continue
+		}
+
+		summary := "Workout"
+		description := []string{}
+		if schedule.Workout != nil {
+			summary = schedule.Workout.Name
+			if schedule.Workout.Description != "" {
+				description = append(description, schedule.Workout.Description)
+			}
+		}
+		if schedule.Completed {
+			summary = "Done: " + summary
+		}
+		if schedule.Notes != "" {
+			description = append(description, schedule.Notes)
+		}
+
+		events = append(events, calendar.Event{
+			// The ID never changes, so a moved workout is updated in the calendar app instead of added twice
+			UID:         fmt.Sprintf("schedule-%d@workout-tracker", schedule.ID),
+			Start:       schedule.ScheduledDate,
+			Duration:    time.Hour,
+			Summary:     summary,
+			Description: strings.Join(description, "\n"),
+		})
+	}
+
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	w.Header().Set("Content-Disposition", `inline; filename="workouts.ics"`)
+	w.Write([]byte(calendar.Feed("Workouts of "+user.Username, events, now)))
+}
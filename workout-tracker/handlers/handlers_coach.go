@@ -0,0 +1,287 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"workout-tracker/auth"
+	"workout-tracker/middleware"
+	"workout-tracker/store"
+
+	"github.com/gorilla/mux"
+)
+
+// InviteAthleteRequest is what a coach sends to invite an athlete
+type InviteAthleteRequest struct {
+	Username string `json:"username"`
+}
+
+// requireRole only lets users with one of the roles through, everyone else gets 403
+// Use it inside protected, it needs the claims that AuthMiddleware puts in the context
+//
+// Why don't we put the role in the JWT?
+// answer: An access token lives for minutes, but an admin can change a role at any time.
+// Reading the role from the database makes the change count on the very next request.
+func (s *Server) requireRole(next http.HandlerFunc, roles ...string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		claims := middleware.GetUserFromContext(r)
+		if claims == nil {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		user, err := s.Users.GetUserByID(claims.UserID)
+		if err != nil {
+			http.Error(w, "Error fetching user", http.StatusInternalServerError)
+			return
+		}
+
+		for _, role := range roles {
+			if user.Role == role {
+				next(w, r)
+				return
+			}
+		}
+		http.Error(w, "You don't have permission to do this", http.StatusForbidden)
+	}
+}
+
+// subjectUser returns whose data the request is about
+// On /api/coach/athletes/{athleteID}/... it is the athlete, if the logged-in coach coaches them.
+// Everywhere else it is the logged-in user.
+// When it returns false the error response is already written.
+func (s *Server) subjectUser(w http.ResponseWriter, r *http.Request, claims *auth.Claims) (int, bool) {
+	value, ok := mux.Vars(r)["athleteID"]
+	if !ok {
+		return claims.UserID, true
+	}
+
+	athleteID, err := strconv.Atoi(value)
+	if err != nil {
+		http.Error(w, "Invalid athlete ID", http.StatusBadRequest)
+		return 0, false
+	}
+
+	coaching, err := s.Coaching.IsCoachOf(claims.UserID, athleteID)
+	if err != nil {
+		http.Error(w, "Error checking coaching", http.StatusInternalServerError)
+		return 0, false
+	}
+	// 404 instead of 403, so nobody can find out which user IDs exist
+	if !coaching {
+		http.Error(w, "Athlete not found", http.StatusNotFound)
+		return 0, false
+	}
+	return athleteID, true
+}
+
+// assignedBy is the AssignedBy of a new workout or schedule: the coach, or nil when users make their own
+func assignedBy(claims *auth.Claims, userID int) *int {
+	if userID == claims.UserID {
+		return nil
+	}
+	coachID := claims.UserID
+	return &coachID
+}
+
+// GetAthletes returns the coach's athletes, including the invitations that aren't accepted yet
+func (s *Server) GetAthletes(w http.ResponseWriter, r *http.Request) {
+	// Get the current user from context
+	claims := middleware.GetUserFromContext(r)
+	if claims == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	athletes, err := s.Coaching.ListAthletes(claims.UserID)
+	if err != nil {
+		http.Error(w, "Error fetching athletes", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(athletes)
+}
+
+// InviteAthlete invites a user by username
+// The coach sees nothing of the athlete until the athlete accepts
+func (s *Server) InviteAthlete(w http.ResponseWriter, r *http.Request) {
+	// Get the current user from context
+	claims := middleware.GetUserFromContext(r)
+	if claims == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	// Parse the request body
+	var req InviteAthleteRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.Username == "" {
+		http.Error(w, "username is required", http.StatusBadRequest)
+		return
+	}
+
+	athlete, err := s.Users.GetUserByUsername(req.Username)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			http.Error(w, "User not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Error finding user", http.StatusInternalServerError)
+		return
+	}
+	if athlete.ID == claims.UserID {
+		http.Error(w, "You can't coach yourself", http.StatusBadRequest)
+		return
+	}
+
+	if err := s.Coaching.InviteAthlete(claims.UserID, athlete.ID); err != nil {
+		if errors.Is(err, store.ErrDuplicate) {
+			http.Error(w, "You already invited or coach this athlete", http.StatusConflict)
+			return
+		}
+		http.Error(w, "Error inviting athlete", http.StatusInternalServerError)
+		return
+	}
+
+	// Return the new link, like GET /api/coach/athletes shows it
+	athletes, err := s.Coaching.ListAthletes(claims.UserID)
+	if err != nil {
+		http.Error(w, "Error fetching athletes", http.StatusInternalServerError)
+		return
+	}
+	for _, coaching := range athletes {
+		if coaching.AthleteID == athlete.ID {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusCreated)
+			json.NewEncoder(w).Encode(coaching)
+			return
+		}
+	}
+	http.Error(w, "Error fetching created invitation", http.StatusInternalServerError)
+}
+
+// RemoveAthlete ends the coaching (or takes back the invitation) from the coach's side
+func (s *Server) RemoveAthlete(w http.ResponseWriter, r *http.Request) {
+	// Get the current user from context
+	claims := middleware.GetUserFromContext(r)
+	if claims == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	vars := mux.Vars(r)
+	athleteID, err := strconv.Atoi(vars["athleteID"])
+	if err != nil {
+		http.Error(w, "Invalid athlete ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := s.Coaching.EndCoaching(claims.UserID, athleteID); err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			http.Error(w, "Athlete not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Error removing athlete", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetCoaches returns the user's coaches and the invitations waiting for an answer
+func (s *Server) GetCoaches(w http.ResponseWriter, r *http.Request) {
+	// Get the current user from context
+	claims := middleware.GetUserFromContext(r)
+	if claims == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	coaches, err := s.Coaching.ListCoaches(claims.UserID)
+	if err != nil {
+		http.Error(w, "Error fetching coaches", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(coaches)
+}
+
+// coachID reads the coach ID from the URL
+func coachID(r *http.Request) (int, error) {
+	vars := mux.Vars(r)
+	return strconv.Atoi(vars["coachID"])
+}
+
+// AcceptCoach accepts an invitation, from now on the coach sees the user's progress
+func (s *Server) AcceptCoach(w http.ResponseWriter, r *http.Request) {
+	// Get the current user from context
+	claims := middleware.GetUserFromContext(r)
+	if claims == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	id, err := coachID(r)
+	if err != nil {
+		http.Error(w, "Invalid coach ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := s.Coaching.AcceptCoach(id, claims.UserID); err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			http.Error(w, "Invitation not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Error accepting invitation", http.StatusInternalServerError)
+		return
+	}
+
+	// Fetch and return the accepted link
+	coaches, err := s.Coaching.ListCoaches(claims.UserID)
+	if err != nil {
+		http.Error(w, "Error fetching coaches", http.StatusInternalServerError)
+		return
+	}
+	for _, coaching := range coaches {
+		if coaching.CoachID == id {
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(coaching)
+			return
+		}
+	}
+	http.Error(w, "Error fetching accepted invitation", http.StatusInternalServerError)
+}
+
+// RemoveCoach declines an invitation or ends the coaching from the athlete's side
+// Workouts and schedules the coach assigned stay, they belong to the athlete
+func (s *Server) RemoveCoach(w http.ResponseWriter, r *http.Request) {
+	// Get the current user from context
+	claims := middleware.GetUserFromContext(r)
+	if claims == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	id, err := coachID(r)
+	if err != nil {
+		http.Error(w, "Invalid coach ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := s.Coaching.EndCoaching(id, claims.UserID); err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			http.Error(w, "Coach not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Error removing coach", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
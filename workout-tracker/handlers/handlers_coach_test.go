@@ -0,0 +1,120 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+	"workout-tracker/models"
+)
+
+// coach registers a user and makes them a coach
+func (a *testAPI) coach(username string) LoginResponse {
+	a.t.Helper()
+	response := a.register(username)
+	if err := a.store.SetRole(response.User.ID, models.RoleCoach); err != nil {
+		a.t.Fatal(err)
+	}
+	return response
+}
+
+// coachPaths are the routes a coach uses for the athlete's data
+func coachPaths(athleteID int) []string {
+	base := fmt.Sprintf("/api/coach/athletes/%d", athleteID)
+	return []string{
+		base + "/workouts",
+		base + "/schedule",
+		base + "/progress",
+		base + "/progress/records",
+		base + "/progress/volume",
+	}
+}
+
+// A coach only sees the athletes who accepted them, everyone else is "not found"
+// so the answers don't tell which user IDs exist
+func TestCoachNonAthleteNotFound(t *testing.T) {
+	api := newTestAPI(t)
+	coach := api.coach("carol")
+	alice := api.register("alice")
+	bob := api.register("bob")
+	otherCoach := api.coach("dave")
+
+	// bob is invited but hasn't accepted, alice is coached by dave
+	expect(t, api.do("POST", "/api/coach/athletes", coach.Token, InviteAthleteRequest{Username: "bob"}), http.StatusCreated, "invite bob")
+	expect(t, api.do("POST", "/api/coach/athletes", otherCoach.Token, InviteAthleteRequest{Username: "alice"}), http.StatusCreated, "dave invites alice")
+	expect(t, api.do("POST", fmt.Sprintf("/api/coaches/%d/accept", otherCoach.User.ID), alice.Token, nil), http.StatusOK, "alice accepts dave")
+
+	tests := []struct {
+		name      string
+		athleteID int
+	}{
+		{"coached by another coach", alice.User.ID},
+		{"invitation not accepted", bob.User.ID},
+		{"the coach", coach.User.ID},
+		{"another coach", otherCoach.User.ID},
+		{"no such user", 9999},
+	}
+	for _, tt := range tests {
+		for _, path := range coachPaths(tt.athleteID) {
+			rec := api.do("GET", path, coach.Token, nil)
+			if rec.Code != http.StatusNotFound || !strings.Contains(rec.Body.String(), "Athlete not found") {
+				t.Errorf("%s: GET %s status %d %q, want 404 Athlete not found", tt.name, path, rec.Code, rec.Body)
+			}
+		}
+		rec := api.do("POST", fmt.Sprintf("/api/coach/athletes/%d/workouts", tt.athleteID), coach.Token, CreateWorkoutRequest{Name: "Leg day"})
+		if rec.Code != http.StatusNotFound {
+			t.Errorf("%s: create workout status %d, want 404", tt.name, rec.Code)
+		}
+	}
+
+	expect(t, api.do("GET", "/api/coach/athletes/abc/workouts", coach.Token, nil), http.StatusBadRequest, "athlete ID that is not a number")
+}
+
+func TestCoachWorksWithAcceptedAthlete(t *testing.T) {
+	api := newTestAPI(t)
+	coach := api.coach("carol")
+	alice := api.register("alice")
+
+	expect(t, api.do("POST", "/api/coach/athletes", coach.Token, InviteAthleteRequest{Username: "alice"}), http.StatusCreated, "invite alice")
+	expect(t, api.do("POST", fmt.Sprintf("/api/coaches/%d/accept", coach.User.ID), alice.Token, nil), http.StatusOK, "alice accepts")
+
+	for _, path := range coachPaths(alice.User.ID) {
+		expect(t, api.do("GET", path, coach.Token, nil), http.StatusOK, "GET "+path)
+	}
+
+	// a workout the coach makes belongs to alice and says who assigned it
+	rec := api.do("POST", fmt.Sprintf("/api/coach/athletes/%d/workouts", alice.User.ID), coach.Token, CreateWorkoutRequest{
+		Name:      "Leg day",
+		Exercises: []CreateWorkoutExerciseRequest{{ExerciseID: api.squat.ID, Sets: 5, Reps: 5, Weight: 100}},
+	})
+	expect(t, rec, http.StatusCreated, "coach creates a workout")
+	var workout models.Workout
+	decode(t, rec, &workout)
+	if workout.UserID != alice.User.ID || workout.AssignedBy == nil || *workout.AssignedBy != coach.User.ID {
+		t.Errorf("workout made by the coach = %+v", workout)
+	}
+	expect(t, api.do("GET", fmt.Sprintf("/api/workouts/%d", workout.ID), alice.Token, nil), http.StatusOK, "alice gets the workout")
+
+	rec = api.do("POST", fmt.Sprintf("/api/coach/athletes/%d/schedule", alice.User.ID), coach.Token, CreateScheduleRequest{
+		WorkoutID:     workout.ID,
+		ScheduledDate: time.Now().UTC().Add(24 * time.Hour).Format(time.RFC3339),
+	})
+	expect(t, rec, http.StatusCreated, "coach schedules the workout")
+
+	// after alice removes the coach, her data is "not found" again
+	expect(t, api.do("DELETE", fmt.Sprintf("/api/coaches/%d", coach.User.ID), alice.Token, nil), http.StatusNoContent, "alice removes the coach")
+	for _, path := range coachPaths(alice.User.ID) {
+		expect(t, api.do("GET", path, coach.Token, nil), http.StatusNotFound, "GET "+path+" after the coach was removed")
+	}
+}
+
+func TestCoachRoutesNeedCoachRole(t *testing.T) {
+	api := newTestAPI(t)
+	alice := api.register("alice")
+	bob := api.register("bob")
+
+	expect(t, api.do("GET", "/api/coach/athletes", alice.Token, nil), http.StatusForbidden, "athlete lists athletes")
+	expect(t, api.do("POST", "/api/coach/athletes", alice.Token, InviteAthleteRequest{Username: "bob"}), http.StatusForbidden, "athlete invites")
+	expect(t, api.do("GET", coachPaths(bob.User.ID)[0], alice.Token, nil), http.StatusForbidden, "athlete reads another athlete's workouts")
+}
@@ -2,53 +2,317 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
-	"workout-tracker/database"
+	"sort"
+	"strconv"
+	"strings"
+	"workout-tracker/middleware"
 	"workout-tracker/models"
+	"workout-tracker/store"
+
+	"github.com/gorilla/mux"
 )
 
-// GetExercises returns all available exercises from the library
-// This is a public endpoint - no authentication required
-func GetExercises(w http.ResponseWriter, r *http.Request) {
-	// Query all exercises from the database
-	rows, err := database.DB.Query(`
-		SELECT id, name, description, category, muscle_group 
-		FROM exercises 
-		ORDER BY category, name
-	`)
+// ExerciseRequest represents an exercise of the library or a custom exercise
+type ExerciseRequest struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Category    string   `json:"category"`     // e.g., "Chest", "Legs"
+	MuscleGroup string   `json:"muscle_group"` // e.g., "Upper Body", "Lower Body"
+	Equipment   string   `json:"equipment"`    // e.g., "Barbell", "Bodyweight"
+	Difficulty  string   `json:"difficulty"`   // "beginner", "intermediate", "advanced" or empty
+	Aliases     []string `json:"aliases"`      // other names to search for, e.g. ["RDL"]
+}
+
+// toExercise checks the request and returns the exercise
+func (req ExerciseRequest) toExercise(id int) (*models.Exercise, error) {
+	exercise := &models.Exercise{
+		ID:          id,
+		Name:        strings.TrimSpace(req.Name),
+		Description: req.Description,
+		Category:    strings.TrimSpace(req.Category),
+		MuscleGroup: strings.TrimSpace(req.MuscleGroup),
+		Equipment:   strings.TrimSpace(req.Equipment),
+		Difficulty:  strings.ToLower(strings.TrimSpace(req.Difficulty)),
+		Aliases:     []string{},
+	}
+	if exercise.Name == "" || exercise.Category == "" {
+		return nil, errors.New("name and category are required")
+	}
+	switch exercise.Difficulty {
+	case "", models.DifficultyBeginner, models.DifficultyIntermediate, models.DifficultyAdvanced:
+	default:
+		return nil, errors.New("difficulty must be beginner, intermediate or advanced")
+	}
+
+	// Skip empty and repeated aliases
+	seen := map[string]bool{}
+	for _, alias := range req.Aliases {
+		alias = strings.TrimSpace(alias)
+		if alias == "" || seen[strings.ToLower(alias)] {
+			continue
+		}
+		seen[strings.ToLower(alias)] = true
+		exercise.Aliases = append(exercise.Aliases, alias)
+	}
+	return exercise, nil
+}
+
+// ExerciseFiltersResponse lists the values the exercise filters can have, e.g. for dropdowns
+type ExerciseFiltersResponse struct {
+	Categories   []string `json:"categories"`
+	MuscleGroups []string `json:"muscle_groups"`
+	Equipment    []string `json:"equipment"`
+	Difficulties []string `json:"difficulties"`
+}
+
+// currentUserID returns the logged-in user, or 0 on a public endpoint without a token
+func currentUserID(r *http.Request) int {
+	if claims := middleware.GetUserFromContext(r); claims != nil {
+		return claims.UserID
+	}
+	return 0
+}
+
+// GetExercises returns the exercise library, filtered by the query parameters:
+//
+//	GET /api/exercises?category=legs&muscle_group=lower%20body&equipment=barbell&difficulty=beginner&q=squat
+//
+// Logged-in users (with the Authorization header) also get their own custom exercises,
+// and ?mine=true returns only those. Without a token it is a public endpoint.
+func (s *Server) GetExercises(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	filter := store.ExerciseFilter{
+		UserID:      currentUserID(r),
+		Category:    query.Get("category"),
+		MuscleGroup: query.Get("muscle_group"),
+		Equipment:   query.Get("equipment"),
+		Difficulty:  query.Get("difficulty"),
+		Query:       strings.TrimSpace(query.Get("q")),
+	}
+	if query.Get("mine") == "true" {
+		if filter.UserID == 0 {
+			http.Error(w, "Log in to see your own exercises", http.StatusUnauthorized)
+			return
+		}
+		filter.OnlyCustom = true
+	}
+
+	// Load the matching exercises, sorted by category and name
+	exercises, err := s.Exercises.ListExercises(filter)
 	if err != nil {
 		http.Error(w, "Error fetching exercises", http.StatusInternalServerError)
 		return
 	}
-	defer rows.Close()
 
-	// Build a slice to hold all exercises
-	exercises := []models.Exercise{}
+	// Return the exercises as JSON
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(exercises)
+}
 
-	// Loop through each row and scan into an Exercise struct
-	for rows.Next() {
-		var exercise models.Exercise
-		err := rows.Scan(
-			&exercise.ID,
-			&exercise.Name,
-			&exercise.Description,
-			&exercise.Category,
-			&exercise.MuscleGroup,
-		)
-		if err != nil {
-			http.Error(w, "Error scanning exercise", http.StatusInternalServerError)
+// GetExerciseFilters returns every category, muscle group, equipment and difficulty in use
+// Like GetExercises it includes the custom exercises of a logged-in user
+func (s *Server) GetExerciseFilters(w http.ResponseWriter, r *http.Request) {
+	exercises, err := s.Exercises.ListExercises(store.ExerciseFilter{UserID: currentUserID(r)})
+	if err != nil {
+		http.Error(w, "Error fetching exercises", http.StatusInternalServerError)
+		return
+	}
+
+	// A map per field collects each value once
+	categories, muscleGroups, equipment, difficulties := map[string]bool{}, map[string]bool{}, map[string]bool{}, map[string]bool{}
+	for _, exercise := range exercises {
+		categories[exercise.Category] = true
+		muscleGroups[exercise.MuscleGroup] = true
+		equipment[exercise.Equipment] = true
+		difficulties[exercise.Difficulty] = true
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(ExerciseFiltersResponse{
+		Categories:   sortedKeys(categories),
+		MuscleGroups: sortedKeys(muscleGroups),
+		Equipment:    sortedKeys(equipment),
+		Difficulties: sortedKeys(difficulties),
+	})
+}
+
+// sortedKeys returns the keys of the map in alphabetical order, without the empty string
+func sortedKeys(values map[string]bool) []string {
+	keys := []string{}
+	for key := range values {
+		if key != "" {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// exerciseID reads the exercise ID from the URL
+func exerciseID(r *http.Request) (int, error) {
+	vars := mux.Vars(r)
+	return strconv.Atoi(vars["id"])
+}
+
+// GetExercise returns one exercise of the library, or a custom exercise of the logged-in user
+func (s *Server) GetExercise(w http.ResponseWriter, r *http.Request) {
+	id, err := exerciseID(r)
+	if err != nil {
+		http.Error(w, "Invalid exercise ID", http.StatusBadRequest)
+		return
+	}
+
+	exercise, err := s.Exercises.GetExercise(id, currentUserID(r))
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			http.Error(w, "Exercise not found", http.StatusNotFound)
 			return
 		}
-		exercises = append(exercises, exercise)
+		http.Error(w, "Error fetching exercise", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(exercise)
+}
+
+// checkExercises makes sure the user may use every exercise: it is in the library or their own
+// When one isn't, it writes the error response and returns false
+func (s *Server) checkExercises(w http.ResponseWriter, userID int, ids []int) bool {
+	exercises, err := s.Exercises.ListExercises(store.ExerciseFilter{UserID: userID})
+	if err != nil {
+		http.Error(w, "Error fetching exercises", http.StatusInternalServerError)
+		return false
+	}
+	known := map[int]bool{}
+	for _, exercise := range exercises {
+		known[exercise.ID] = true
 	}
 
-	// Check for any errors during iteration
-	if err = rows.Err(); err != nil {
-		http.Error(w, "Error iterating exercises", http.StatusInternalServerError)
+	for _, id := range ids {
+		if !known[id] {
+			http.Error(w, fmt.Sprintf("Exercise %d does not exist", id), http.StatusBadRequest)
+			return false
+		}
+	}
+	return true
+}
+
+// CreateCustomExercise adds an exercise that only the logged-in user sees and can use
+func (s *Server) CreateCustomExercise(w http.ResponseWriter, r *http.Request) {
+	// Get the current user from context
+	claims := middleware.GetUserFromContext(r)
+	if claims == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	// Parse the request body
+	var req ExerciseRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	exercise, err := req.toExercise(0)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	exercise.UserID = &claims.UserID
+
+	if err := s.Exercises.CreateExercise(exercise); err != nil {
+		if errors.Is(err, store.ErrDuplicate) {
+			http.Error(w, "You already have an exercise with this name", http.StatusConflict)
+			return
+		}
+		http.Error(w, "Error creating exercise", http.StatusInternalServerError)
 		return
 	}
 
-	// Return the exercises as JSON
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(exercises)
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(exercise)
+}
+
+// UpdateCustomExercise changes one of the user's custom exercises
+// Library exercises can only be changed by admins, see UpdateExercise
+func (s *Server) UpdateCustomExercise(w http.ResponseWriter, r *http.Request) {
+	// Get the current user from context
+	claims := middleware.GetUserFromContext(r)
+	if claims == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	id, err := exerciseID(r)
+	if err != nil {
+		http.Error(w, "Invalid exercise ID", http.StatusBadRequest)
+		return
+	}
+
+	// Parse the request body
+	var req ExerciseRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	exercise, err := req.toExercise(id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	exercise.UserID = &claims.UserID
+
+	if err := s.Exercises.UpdateExercise(exercise); err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			http.Error(w, "Exercise not found", http.StatusNotFound)
+			return
+		}
+		if errors.Is(err, store.ErrDuplicate) {
+			http.Error(w, "You already have an exercise with this name", http.StatusConflict)
+			return
+		}
+		http.Error(w, "Error updating exercise", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(exercise)
+}
+
+// DeleteCustomExercise deletes one of the user's custom exercises
+// An exercise that is used in a workout, a log or a program can't be deleted
+func (s *Server) DeleteCustomExercise(w http.ResponseWriter, r *http.Request) {
+	// Get the current user from context
+	claims := middleware.GetUserFromContext(r)
+	if claims == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	id, err := exerciseID(r)
+	if err != nil {
+		http.Error(w, "Invalid exercise ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := s.Exercises.DeleteExercise(id, claims.UserID); err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			http.Error(w, "Exercise not found", http.StatusNotFound)
+			return
+		}
+		if errors.Is(err, store.ErrInUse) {
+			http.Error(w, "The exercise is used in workouts or logs and can't be deleted", http.StatusConflict)
+			return
+		}
+		http.Error(w, "Error deleting exercise", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
 }
@@ -0,0 +1,344 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"workout-tracker/calendar"
+	"workout-tracker/middleware"
+	"workout-tracker/models"
+	"workout-tracker/programs"
+	"workout-tracker/store"
+
+	"github.com/gorilla/mux"
+)
+
+// historyDays is how far back we look for logged sets to work out a training max
+const historyDays = 180
+
+// ApplyProgramRequest represents the data needed to start a program, e.g.
+//
+//	{"template": "5-3-1", "start_date": "2024-01-15", "time": "07:00",
+//	 "days": [{"weekday": "monday", "exercise_ids": [1]}, {"weekday": "thursday", "exercise_ids": [7]}],
+//	 "one_rep_maxes": {"7": 140}}
+type ApplyProgramRequest struct {
+	Template    string                  `json:"template"`   // key of a built-in template
+	Custom      *models.ProgramTemplate `json:"custom"`     // or your own template instead
+	Name        string                  `json:"name"`       // defaults to the template name
+	StartDate   string                  `json:"start_date"` // Format: "2024-01-15", defaults to today
+	Time        string                  `json:"time"`       // Format: "07:00" (UTC), defaults to 18:00
+	Rounding    float64                 `json:"rounding"`   // defaults to 2.5
+	Days        []ProgramDayRequest     `json:"days"`
+	OneRepMaxes map[int]float64         `json:"one_rep_maxes"` // exercise ID -> one-rep max, for lifts without logged history
+}
+
+// ProgramDayRequest is a training day of the week and its main lifts
+type ProgramDayRequest struct {
+	Weekday     string `json:"weekday"` // "monday" or "mon"
+	ExerciseIDs []int  `json:"exercise_ids"`
+}
+
+// toPlan checks the request and turns it into a programs.Plan, without the training maxes yet
+func (req ApplyProgramRequest) toPlan(userID int, now time.Time) (programs.Plan, error) {
+	plan := programs.Plan{UserID: userID, Name: req.Name, Rounding: req.Rounding}
+
+	// A built-in template or a custom one, not both
+	if (req.Template == "") == (req.Custom == nil) {
+		return plan, errors.New("send either template (the key of a built-in template) or custom")
+	}
+	if req.Custom != nil {
+		plan.Template = *req.Custom
+		plan.Template.Key = "custom"
+	} else {
+		template, ok := programs.Find(req.Template)
+		if !ok {
+			return plan, fmt.Errorf("unknown template %q, see GET /api/programs/templates", req.Template)
+		}
+		plan.Template = template
+	}
+	if err := programs.Validate(plan.Template); err != nil {
+		return plan, err
+	}
+	if plan.Name == "" {
+		plan.Name = plan.Template.Name
+	}
+
+	if plan.Rounding < 0 || plan.Rounding > 10 {
+		return plan, errors.New("rounding must be between 0 and 10")
+	}
+	if plan.Rounding == 0 {
+		plan.Rounding = 2.5
+	}
+
+	// Start date and time of day
+	day := now.UTC().Format("2006-01-02")
+	if req.StartDate != "" {
+		day = req.StartDate
+	}
+	clock := "18:00"
+	if req.Time != "" {
+		clock = req.Time
+	}
+	start, err := time.Parse("2006-01-02 15:04", day+" "+clock)
+	if err != nil {
+		return plan, errors.New("invalid start_date or time, use the format 2024-01-15 and 07:00")
+	}
+	plan.Start = start
+
+	// Training days
+	if len(req.Days) < 1 || len(req.Days) > 7 {
+		return plan, errors.New("days needs 1 to 7 training days")
+	}
+	usedDays := map[time.Weekday]bool{}
+	for i, d := range req.Days {
+		weekday, ok := calendar.ParseWeekday(d.Weekday)
+		if !ok {
+			return plan, fmt.Errorf("day %d: unknown weekday %q", i+1, d.Weekday)
+		}
+		if usedDays[weekday] {
+			return plan, fmt.Errorf("day %d: %s is used twice", i+1, weekday)
+		}
+		usedDays[weekday] = true
+
+		if len(d.ExerciseIDs) < 1 || len(d.ExerciseIDs) > 10 {
+			return plan, fmt.Errorf("day %d: needs 1 to 10 exercise_ids", i+1)
+		}
+		seen := map[int]bool{}
+		for _, id := range d.ExerciseIDs {
+			if seen[id] {
+				return plan, fmt.Errorf("day %d: exercise %d is listed twice", i+1, id)
+			}
+			seen[id] = true
+		}
+		plan.Days = append(plan.Days, programs.Day{Weekday: weekday, ExerciseIDs: d.ExerciseIDs})
+	}
+	return plan, nil
+}
+
+// ApplyProgram starts a program from a template
+// It creates a workout and a schedule for every session, with target weights
+// from the training max of each lift
+func (s *Server) ApplyProgram(w http.ResponseWriter, r *http.Request) {
+	// Get the current user from context
+	claims := middleware.GetUserFromContext(r)
+	if claims == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	// Parse the request body
+	var req ApplyProgramRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	now := time.Now()
+	plan, err := req.toPlan(claims.UserID, now)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Every lift must be in the exercise library, or one of the user's own exercises
+	exercises, err := s.Exercises.ListExercises(store.ExerciseFilter{UserID: claims.UserID})
+	if err != nil {
+		http.Error(w, "Error fetching exercises", http.StatusInternalServerError)
+		return
+	}
+	known := map[int]bool{}
+	for _, exercise := range exercises {
+		known[exercise.ID] = true
+	}
+
+	// Training max of every lift: from the one-rep max in the request, or from the logged history
+	plan.TrainingMaxes = map[int]float64{}
+	checked := map[int]bool{} // a lift can be on more than one day
+	missing := []string{}
+	for _, day := range plan.Days {
+		for _, id := range day.ExerciseIDs {
+			if checked[id] {
+				continue
+			}
+			checked[id] = true
+			if !known[id] {
+				http.Error(w, fmt.Sprintf("Exercise %d does not exist", id), http.StatusBadRequest)
+				return
+			}
+			if oneRepMax, ok := req.OneRepMaxes[id]; ok {
+				if oneRepMax <= 0 {
+					http.Error(w, fmt.Sprintf("one_rep_maxes: the max of exercise %d must be more than 0", id), http.StatusBadRequest)
+					return
+				}
+				// Two decimals, 100 x 0.9 would otherwise be 90.00000000000001
+				plan.TrainingMaxes[id] = math.Round(oneRepMax*plan.Template.MaxPercent*100) / 100
+				continue
+			}
+
+			sets, err := s.Progress.ListLoggedSets(claims.UserID, store.SetFilter{ExerciseID: id, From: now.AddDate(0, 0, -historyDays)})
+			if err != nil {
+				http.Error(w, "Error fetching logged sets", http.StatusInternalServerError)
+				return
+			}
+			trainingMax, ok := programs.TrainingMax(sets, plan.Template.MaxPercent)
+			if !ok {
+				missing = append(missing, strconv.Itoa(id))
+				continue
+			}
+			plan.TrainingMaxes[id] = trainingMax
+		}
+	}
+	if len(missing) > 0 {
+		http.Error(w, fmt.Sprintf("No logged sets in the last %d days for exercise %s, send one_rep_maxes for them",
+			historyDays, strings.Join(missing, ", ")), http.StatusBadRequest)
+		return
+	}
+
+	// Save the program with a workout and a schedule for every session (in one transaction)
+	created := programs.Generate(plan)
+	if err := s.Programs.CreateProgram(created); err != nil {
+		http.Error(w, "Error creating program", http.StatusInternalServerError)
+		return
+	}
+
+	// Fetch and return the complete program (with the exercise details)
+	program, err := s.Programs.GetProgram(created.ID, claims.UserID)
+	if err != nil {
+		http.Error(w, "Error fetching created program", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(program)
+}
+
+// refreshPrograms loads the user's programs and moves the sessions that were missed
+// It runs before programs or schedules are shown, so the plan is always up to date
+func (s *Server) refreshPrograms(userID int) ([]models.Program, error) {
+	list, err := s.Programs.ListPrograms(userID)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	for i := range list {
+		if programs.Reschedule(&list[i], now) {
+			if err := s.Programs.UpdateProgram(&list[i]); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return list, nil
+}
+
+// recordProgramSession updates the program a completed schedule belongs to (if any):
+// the training maxes move, and the targets of the next sessions are worked out again
+func (s *Server) recordProgramSession(userID, scheduleID int, logs []models.WorkoutLog) error {
+	list, err := s.Programs.ListPrograms(userID)
+	if err != nil {
+		return err
+	}
+	for i := range list {
+		if list[i].Status != models.ProgramActive {
+			continue
+		}
+		if programs.Record(&list[i], scheduleID, logs) {
+			programs.Reschedule(&list[i], time.Now())
+			return s.Programs.UpdateProgram(&list[i])
+		}
+	}
+	return nil
+}
+
+// GetProgramTemplates returns the built-in templates
+func (s *Server) GetProgramTemplates(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(programs.Templates())
+}
+
+// GetPrograms returns all programs of the logged-in user, newest first
+func (s *Server) GetPrograms(w http.ResponseWriter, r *http.Request) {
+	// Get the current user from context
+	claims := middleware.GetUserFromContext(r)
+	if claims == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	list, err := s.refreshPrograms(claims.UserID)
+	if err != nil {
+		http.Error(w, "Error fetching programs", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(list)
+}
+
+// GetProgram returns a program with all its sessions
+func (s *Server) GetProgram(w http.ResponseWriter, r *http.Request) {
+	// Get the current user from context
+	claims := middleware.GetUserFromContext(r)
+	if claims == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	// Get program ID from URL
+	vars := mux.Vars(r)
+	programID, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid program ID", http.StatusBadRequest)
+		return
+	}
+
+	list, err := s.refreshPrograms(claims.UserID)
+	if err != nil {
+		http.Error(w, "Error fetching program", http.StatusInternalServerError)
+		return
+	}
+	for _, program := range list {
+		if program.ID == programID {
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(program)
+			return
+		}
+	}
+	http.Error(w, "Program not found", http.StatusNotFound)
+}
+
+// DeleteProgram deletes a program
+// Sessions that were done stay in the history, the others are removed from the calendar
+func (s *Server) DeleteProgram(w http.ResponseWriter, r *http.Request) {
+	// Get the current user from context
+	claims := middleware.GetUserFromContext(r)
+	if claims == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	// Get program ID from URL
+	vars := mux.Vars(r)
+	programID, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid program ID", http.StatusBadRequest)
+		return
+	}
+
+	err = s.Programs.DeleteProgram(programID, claims.UserID)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			http.Error(w, "Program not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Error deleting program", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -1,18 +1,23 @@
 package handlers
 
 import (
-	"database/sql"
 	"encoding/json"
+	"fmt"
 	"net/http"
+	"strconv"
 	"time"
-	"workout-tracker/database"
+	"workout-tracker/analytics"
 	"workout-tracker/middleware"
 	"workout-tracker/models"
+	"workout-tracker/store"
 )
 
+// historyLimit is how many log entries GetExerciseHistory returns at most
+const historyLimit = 50
+
 // GetProgress returns a progress report for the logged-in user
 // This includes statistics about workouts completed, frequency, and trends
-func GetProgress(w http.ResponseWriter, r *http.Request) {
+func (s *Server) GetProgress(w http.ResponseWriter, r *http.Request) {
 	// Get the current user from context
 	claims := middleware.GetUserFromContext(r)
 	if claims == nil {
@@ -20,111 +25,144 @@ func GetProgress(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Initialize the progress report
-	report := models.ProgressReport{
-		UserID: claims.UserID,
+	// On /api/coach/athletes/{athleteID}/... this is the athlete, see subjectUser
+	userID, ok := s.subjectUser(w, r, claims)
+	if !ok {
+		return
 	}
 
-	// Get total completed workouts
-	err := database.DB.QueryRow(`
-		SELECT COUNT(*) FROM schedules WHERE user_id = $1 AND completed = true
-	`, claims.UserID).Scan(&report.TotalWorkouts)
+	// The store counts the workouts and works out the averages
+	report, err := s.Progress.GetProgress(userID)
 	if err != nil {
-		http.Error(w, "Error fetching total workouts", http.StatusInternalServerError)
+		http.Error(w, "Error fetching progress", http.StatusInternalServerError)
 		return
 	}
 
-	// Get total exercises performed (from workout logs)
-	err = database.DB.QueryRow(`
-		SELECT COUNT(*) FROM workout_logs wl
-		JOIN schedules s ON wl.schedule_id = s.id
-		WHERE s.user_id = $1
-	`, claims.UserID).Scan(&report.TotalExercises)
-	if err != nil {
-		http.Error(w, "Error fetching total exercises", http.StatusInternalServerError)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
+
+// GetExerciseHistory returns the history of a specific exercise for the user
+// This shows how the user's performance has changed over time
+func (s *Server) GetExerciseHistory(w http.ResponseWriter, r *http.Request) {
+	// Get the current user from context
+	claims := middleware.GetUserFromContext(r)
+	if claims == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	// On /api/coach/athletes/{athleteID}/... this is the athlete, see subjectUser
+	userID, ok := s.subjectUser(w, r, claims)
+	if !ok {
 		return
 	}
 
-	// Get workouts completed in the last 7 days
-	sevenDaysAgo := time.Now().AddDate(0, 0, -7)
-	err = database.DB.QueryRow(`
-		SELECT COUNT(*) FROM schedules 
-		WHERE user_id = $1 AND completed = true AND completed_at >= $2
-	`, claims.UserID, sevenDaysAgo).Scan(&report.WorkoutsThisWeek)
+	// Get exercise ID from query parameter
+	exerciseIDStr := r.URL.Query().Get("exercise_id")
+	if exerciseIDStr == "" {
+		http.Error(w, "exercise_id parameter is required", http.StatusBadRequest)
+		return
+	}
+	exerciseID, err := strconv.Atoi(exerciseIDStr)
 	if err != nil {
-		http.Error(w, "Error fetching weekly workouts", http.StatusInternalServerError)
+		http.Error(w, "Invalid exercise_id", http.StatusBadRequest)
 		return
 	}
 
-	// Get workouts completed in the last 30 days
-	thirtyDaysAgo := time.Now().AddDate(0, 0, -30)
-	err = database.DB.QueryRow(`
-		SELECT COUNT(*) FROM schedules 
-		WHERE user_id = $1 AND completed = true AND completed_at >= $2
-	`, claims.UserID, thirtyDaysAgo).Scan(&report.WorkoutsThisMonth)
+	// Load the latest logs for this exercise
+	history, err := s.Progress.GetExerciseHistory(userID, exerciseID, historyLimit)
 	if err != nil {
-		http.Error(w, "Error fetching monthly workouts", http.StatusInternalServerError)
+		http.Error(w, "Error fetching exercise history", http.StatusInternalServerError)
 		return
 	}
 
-	// Get the most frequently performed exercise
-	var exerciseName sql.NullString
-	err = database.DB.QueryRow(`
-		SELECT e.name 
-		FROM workout_logs wl
-		JOIN exercises e ON wl.exercise_id = e.id
-		JOIN schedules s ON wl.schedule_id = s.id
-		WHERE s.user_id = $1
-		GROUP BY e.name
-		ORDER BY COUNT(*) DESC
-		LIMIT 1
-	`, claims.UserID).Scan(&exerciseName)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(history)
+}
 
-	// If no exercises logged yet, this is okay
-	if err != nil && err != sql.ErrNoRows {
-		http.Error(w, "Error fetching most frequent exercise", http.StatusInternalServerError)
-		return
+// StrengthResponse is the chart data of one exercise
+type StrengthResponse struct {
+	ExerciseID   int                    `json:"exercise_id"`
+	ExerciseName string                 `json:"exercise_name"`
+	Formula      string                 `json:"formula"`
+	Points       []models.StrengthPoint `json:"points"`
+}
+
+// parseDateRange reads the optional "from" and "to" query parameters (format 2024-01-15)
+// "to" includes the whole day
+func parseDateRange(r *http.Request) (from, to time.Time, err error) {
+	if value := r.URL.Query().Get("from"); value != "" {
+		if from, err = time.Parse("2006-01-02", value); err != nil {
+			return from, to, fmt.Errorf("invalid from date, use the format 2024-01-15")
+		}
 	}
+	if value := r.URL.Query().Get("to"); value != "" {
+		if to, err = time.Parse("2006-01-02", value); err != nil {
+			return from, to, fmt.Errorf("invalid to date, use the format 2024-01-15")
+		}
+		to = to.AddDate(0, 0, 1).Add(-time.Nanosecond)
+	}
+	return from, to, nil
+}
 
-	if exerciseName.Valid {
-		report.MostFrequentExercise = exerciseName.String
-	} else {
-		report.MostFrequentExercise = "None yet"
+// GetStrengthProgress returns the estimated one-rep max, top set and volume of every session of an exercise
+// GET /api/progress/strength?exercise_id=1&formula=brzycki&from=2024-01-01&to=2024-03-31
+func (s *Server) GetStrengthProgress(w http.ResponseWriter, r *http.Request) {
+	// Get the current user from context
+	claims := middleware.GetUserFromContext(r)
+	if claims == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
 	}
 
-	// Get the user's start date (when they first completed a workout)
-	var startDate sql.NullTime
-	err = database.DB.QueryRow(`
-		SELECT MIN(completed_at) FROM schedules 
-		WHERE user_id = $1 AND completed = true
-	`, claims.UserID).Scan(&startDate)
+	// On /api/coach/athletes/{athleteID}/... this is the athlete, see subjectUser
+	userID, ok := s.subjectUser(w, r, claims)
+	if !ok {
+		return
+	}
 
-	if err != nil && err != sql.ErrNoRows {
-		http.Error(w, "Error fetching start date", http.StatusInternalServerError)
+	exerciseID, err := strconv.Atoi(r.URL.Query().Get("exercise_id"))
+	if err != nil {
+		http.Error(w, "exercise_id parameter is required", http.StatusBadRequest)
+		return
+	}
+	formula := r.URL.Query().Get("formula")
+	if formula == "" {
+		formula = analytics.FormulaEpley
+	}
+	if !analytics.ValidFormula(formula) {
+		http.Error(w, "formula must be epley or brzycki", http.StatusBadRequest)
+		return
+	}
+	from, to, err := parseDateRange(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	if startDate.Valid {
-		report.StartDate = startDate.Time
+	sets, err := s.Progress.ListLoggedSets(userID, store.SetFilter{ExerciseID: exerciseID, From: from, To: to})
+	if err != nil {
+		http.Error(w, "Error fetching logged sets", http.StatusInternalServerError)
+		return
+	}
 
-		// Calculate average workouts per week
-		weeksSinceStart := time.Since(report.StartDate).Hours() / 24 / 7
-		if weeksSinceStart > 0 {
-			report.AverageWorkoutsPerWeek = float64(report.TotalWorkouts) / weeksSinceStart
-		}
-	} else {
-		// If no workouts completed yet, use current time as start date
-		report.StartDate = time.Now()
-		report.AverageWorkoutsPerWeek = 0
+	response := StrengthResponse{
+		ExerciseID: exerciseID,
+		Formula:    formula,
+		Points:     analytics.StrengthSeries(sets, formula),
+	}
+	if len(sets) > 0 {
+		response.ExerciseName = sets[0].ExerciseName
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(report)
+	json.NewEncoder(w).Encode(response)
 }
 
-// GetExerciseHistory returns the history of a specific exercise for the user
-// This shows how the user's performance has changed over time
-func GetExerciseHistory(w http.ResponseWriter, r *http.Request) {
+// GetPersonalRecords returns every personal record, newest first
+// GET /api/progress/records (all exercises) or /api/progress/records?exercise_id=1
+func (s *Server) GetPersonalRecords(w http.ResponseWriter, r *http.Request) {
 	// Get the current user from context
 	claims := middleware.GetUserFromContext(r)
 	if claims == nil {
@@ -132,67 +170,77 @@ func GetExerciseHistory(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Get exercise ID from query parameter
-	exerciseIDStr := r.URL.Query().Get("exercise_id")
-	if exerciseIDStr == "" {
-		http.Error(w, "exercise_id parameter is required", http.StatusBadRequest)
+	// On /api/coach/athletes/{athleteID}/... this is the athlete, see subjectUser
+	userID, ok := s.subjectUser(w, r, claims)
+	if !ok {
 		return
 	}
 
-	// Query the workout logs for this exercise
-	rows, err := database.DB.Query(`
-		SELECT wl.sets_completed, wl.reps_completed, wl.weight_used, wl.duration, wl.notes, wl.logged_at,
-		       e.name, e.description, e.category
-		FROM workout_logs wl
-		JOIN exercises e ON wl.exercise_id = e.id
-		JOIN schedules s ON wl.schedule_id = s.id
-		WHERE s.user_id = $1 AND wl.exercise_id = $2
-		ORDER BY wl.logged_at DESC
-		LIMIT 50
-	`, claims.UserID, exerciseIDStr)
+	filter := store.SetFilter{}
+	if value := r.URL.Query().Get("exercise_id"); value != "" {
+		exerciseID, err := strconv.Atoi(value)
+		if err != nil {
+			http.Error(w, "Invalid exercise_id", http.StatusBadRequest)
+			return
+		}
+		filter.ExerciseID = exerciseID
+	}
 
+	// Records are worked out from the whole history, so the first session of every exercise is always included
+	sets, err := s.Progress.ListLoggedSets(userID, filter)
 	if err != nil {
-		http.Error(w, "Error fetching exercise history", http.StatusInternalServerError)
+		http.Error(w, "Error fetching logged sets", http.StatusInternalServerError)
 		return
 	}
-	defer rows.Close()
-
-	// Create a custom response structure
-	type ExerciseHistoryEntry struct {
-		SetsCompleted int       `json:"sets_completed"`
-		RepsCompleted int       `json:"reps_completed"`
-		WeightUsed    float64   `json:"weight_used"`
-		Duration      int       `json:"duration"`
-		Notes         string    `json:"notes"`
-		LoggedAt      time.Time `json:"logged_at"`
-		ExerciseName  string    `json:"exercise_name"`
-		Category      string    `json:"category"`
-	}
-
-	history := []ExerciseHistoryEntry{}
-	for rows.Next() {
-		var entry ExerciseHistoryEntry
-		var description string // We query it but don't use it in the response
-
-		err := rows.Scan(
-			&entry.SetsCompleted,
-			&entry.RepsCompleted,
-			&entry.WeightUsed,
-			&entry.Duration,
-			&entry.Notes,
-			&entry.LoggedAt,
-			&entry.ExerciseName,
-			&description,
-			&entry.Category,
-		)
-		if err != nil {
-			http.Error(w, "Error scanning history entry", http.StatusInternalServerError)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(analytics.RecordHistory(sets))
+}
+
+// GetWeeklyVolume returns sets x reps x weight per week for every muscle group
+// GET /api/progress/volume?weeks=12&by=muscle_group (by can also be "category")
+func (s *Server) GetWeeklyVolume(w http.ResponseWriter, r *http.Request) {
+	// Get the current user from context
+	claims := middleware.GetUserFromContext(r)
+	if claims == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	// On /api/coach/athletes/{athleteID}/... this is the athlete, see subjectUser
+	userID, ok := s.subjectUser(w, r, claims)
+	if !ok {
+		return
+	}
+
+	weeks := 12
+	if value := r.URL.Query().Get("weeks"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 || n > 104 {
+			http.Error(w, "weeks must be a number from 1 to 104", http.StatusBadRequest)
 			return
 		}
+		weeks = n
+	}
+	by := r.URL.Query().Get("by")
+	if by == "" {
+		by = "muscle_group"
+	}
+	if by != "muscle_group" && by != "category" {
+		http.Error(w, "by must be muscle_group or category", http.StatusBadRequest)
+		return
+	}
+
+	// The current week and the weeks before it
+	to := time.Now().UTC()
+	from := analytics.WeekStart(to).AddDate(0, 0, -7*(weeks-1))
 
-		history = append(history, entry)
+	sets, err := s.Progress.ListLoggedSets(userID, store.SetFilter{From: from, To: to})
+	if err != nil {
+		http.Error(w, "Error fetching logged sets", http.StatusInternalServerError)
+		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(history)
+	json.NewEncoder(w).Encode(analytics.WeeklyVolume(sets, by, from, to))
 }
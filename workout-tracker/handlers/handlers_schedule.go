@@ -1,14 +1,16 @@
 package handlers
 
 import (
-	"database/sql"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
 	"strconv"
 	"time"
-	"workout-tracker/database"
+	"workout-tracker/analytics"
 	"workout-tracker/middleware"
 	"workout-tracker/models"
+	"workout-tracker/store"
 
 	"github.com/gorilla/mux"
 )
@@ -26,18 +28,122 @@ type CompleteScheduleRequest struct {
 	Logs  []WorkoutLogRequest `json:"logs"` // Optional: detailed exercise logs
 }
 
+// CompleteScheduleResponse is the completed schedule plus the personal records it set
+// The schedule is embedded, so its fields stay at the top level of the JSON like before
+type CompleteScheduleResponse struct {
+	*models.Schedule
+	PersonalRecords []models.PersonalRecord `json:"personal_records"`
+}
+
 // WorkoutLogRequest represents a logged exercise
+// Send every set in Sets, or only the totals (sets_completed, reps_completed, weight_used)
+// like older clients do - then we record that many identical sets.
 type WorkoutLogRequest struct {
-	ExerciseID    int     `json:"exercise_id"`
-	SetsCompleted int     `json:"sets_completed"`
-	RepsCompleted int     `json:"reps_completed"`
-	WeightUsed    float64 `json:"weight_used"`
-	Duration      int     `json:"duration"` // in minutes
-	Notes         string  `json:"notes"`
+	ExerciseID    int                 `json:"exercise_id"`
+	SetsCompleted int                 `json:"sets_completed"`
+	RepsCompleted int                 `json:"reps_completed"`
+	WeightUsed    float64             `json:"weight_used"`
+	Duration      int                 `json:"duration"` // in minutes
+	Notes         string              `json:"notes"`
+	Sets          []WorkoutSetRequest `json:"sets"`
+}
+
+// WorkoutSetRequest represents one logged set
+type WorkoutSetRequest struct {
+	Reps        int      `json:"reps"`
+	Weight      float64  `json:"weight"`
+	RPE         *float64 `json:"rpe"` // optional, 1 to 10 in steps of 0.5
+	RIR         *int     `json:"rir"` // optional, reps in reserve
+	RestSeconds int      `json:"rest_seconds"`
+	IsWarmup    bool     `json:"is_warmup"`
+}
+
+// toLog checks the logged exercise and turns it into the model the store saves
+func (req WorkoutLogRequest) toLog() (models.WorkoutLog, error) {
+	log := models.WorkoutLog{
+		ExerciseID: req.ExerciseID,
+		Duration:   req.Duration,
+		Notes:      req.Notes,
+		Sets:       []models.WorkoutSet{},
+	}
+	if req.Duration < 0 {
+		return log, errors.New("duration can't be negative")
+	}
+
+	// Only totals? Then every set was the same
+	if len(req.Sets) == 0 {
+		if req.SetsCompleted < 0 || req.RepsCompleted < 0 || req.WeightUsed < 0 {
+			return log, errors.New("sets_completed, reps_completed and weight_used can't be negative")
+		}
+		for i := 0; i < req.SetsCompleted; i++ {
+			req.Sets = append(req.Sets, WorkoutSetRequest{Reps: req.RepsCompleted, Weight: req.WeightUsed})
+		}
+	}
+
+	for i, set := range req.Sets {
+		if set.Reps < 0 || set.Weight < 0 || set.RestSeconds < 0 {
+			return log, fmt.Errorf("set %d: reps, weight and rest_seconds can't be negative", i+1)
+		}
+		// RPE is given in half steps: 7, 7.5, 8...
+		if set.RPE != nil && (*set.RPE < 1 || *set.RPE > 10 || *set.RPE*2 != float64(int(*set.RPE*2))) {
+			return log, fmt.Errorf("set %d: rpe must be between 1 and 10 in steps of 0.5", i+1)
+		}
+		if set.RIR != nil && (*set.RIR < 0 || *set.RIR > 10) {
+			return log, fmt.Errorf("set %d: rir must be between 0 and 10", i+1)
+		}
+		log.Sets = append(log.Sets, models.WorkoutSet{
+			SetNumber:   i + 1,
+			Reps:        set.Reps,
+			Weight:      set.Weight,
+			RPE:         set.RPE,
+			RIR:         set.RIR,
+			RestSeconds: set.RestSeconds,
+			IsWarmup:    set.IsWarmup,
+		})
+	}
+
+	summarizeSets(&log)
+	return log, nil
+}
+
+// summarizeSets fills in the totals of a log from its working sets (warm-ups are skipped)
+// RepsCompleted and WeightUsed come from the heaviest set, the one that matters for progress
+func summarizeSets(log *models.WorkoutLog) {
+	log.SetsCompleted, log.RepsCompleted, log.WeightUsed = 0, 0, 0
+	for _, set := range log.Sets {
+		if set.IsWarmup {
+			continue
+		}
+		log.SetsCompleted++
+		if set.Weight > log.WeightUsed || (set.Weight == log.WeightUsed && set.Reps > log.RepsCompleted) {
+			log.WeightUsed = set.Weight
+			log.RepsCompleted = set.Reps
+		}
+	}
+}
+
+// checkWorkoutOwner makes sure the workout exists and belongs to the user
+// When it doesn't, it writes the error response and returns false
+func (s *Server) checkWorkoutOwner(w http.ResponseWriter, workoutID, userID int) bool {
+	workoutUserID, err := s.Workouts.WorkoutOwner(workoutID)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			http.Error(w, "Workout not found", http.StatusNotFound)
+			return false
+		}
+		http.Error(w, "Error verifying workout", http.StatusInternalServerError)
+		return false
+	}
+
+	if workoutUserID != userID {
+		http.Error(w, "You don't have permission to schedule this workout", http.StatusForbidden)
+		return false
+	}
+	return true
 }
 
 // CreateSchedule schedules a workout for a specific date
-func CreateSchedule(w http.ResponseWriter, r *http.Request) {
+func (s *Server) CreateSchedule(w http.ResponseWriter, r *http.Request) {
 	// Get the current user from context
 	claims := middleware.GetUserFromContext(r)
 	if claims == nil {
@@ -45,6 +151,12 @@ func CreateSchedule(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// On /api/coach/athletes/{athleteID}/... this is the athlete, see subjectUser
+	userID, ok := s.subjectUser(w, r, claims)
+	if !ok {
+		return
+	}
+
 	// Parse the request body
 	var req CreateScheduleRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -60,37 +172,25 @@ func CreateSchedule(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Verify the workout belongs to this user
-	var workoutUserID int
-	err = database.DB.QueryRow(`SELECT user_id FROM workouts WHERE id = $1`, req.WorkoutID).Scan(&workoutUserID)
-	if err != nil {
-		if err == sql.ErrNoRows {
-			http.Error(w, "Workout not found", http.StatusNotFound)
-			return
-		}
-		http.Error(w, "Error verifying workout", http.StatusInternalServerError)
+	if !s.checkWorkoutOwner(w, req.WorkoutID, userID) {
 		return
 	}
 
-	if workoutUserID != claims.UserID {
-		http.Error(w, "You don't have permission to schedule this workout", http.StatusForbidden)
-		return
+	// Save the schedule
+	created := &models.Schedule{
+		UserID:        userID,
+		WorkoutID:     req.WorkoutID,
+		ScheduledDate: scheduledDate,
+		Notes:         req.Notes,
+		AssignedBy:    assignedBy(claims, userID),
 	}
-
-	// Insert the schedule
-	var scheduleID int
-	err = database.DB.QueryRow(
-		`INSERT INTO schedules (user_id, workout_id, scheduled_date, completed, notes) 
-		 VALUES ($1, $2, $3, $4, $5) RETURNING id`,
-		claims.UserID, req.WorkoutID, scheduledDate, false, req.Notes,
-	).Scan(&scheduleID)
-
-	if err != nil {
+	if err := s.Schedules.CreateSchedule(created); err != nil {
 		http.Error(w, "Error creating schedule", http.StatusInternalServerError)
 		return
 	}
 
 	// Fetch and return the created schedule
-	schedule, err := getScheduleByID(scheduleID)
+	schedule, err := s.Schedules.GetSchedule(created.ID, userID)
 	if err != nil {
 		http.Error(w, "Error fetching created schedule", http.StatusInternalServerError)
 		return
@@ -102,7 +202,7 @@ func CreateSchedule(w http.ResponseWriter, r *http.Request) {
 }
 
 // GetSchedules returns all scheduled workouts for the logged-in user
-func GetSchedules(w http.ResponseWriter, r *http.Request) {
+func (s *Server) GetSchedules(w http.ResponseWriter, r *http.Request) {
 	// Get the current user from context
 	claims := middleware.GetUserFromContext(r)
 	if claims == nil {
@@ -110,85 +210,41 @@ func GetSchedules(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// On /api/coach/athletes/{athleteID}/... this is the athlete, see subjectUser
+	userID, ok := s.subjectUser(w, r, claims)
+	if !ok {
+		return
+	}
+
 	// Optional query parameters for filtering
 	upcoming := r.URL.Query().Get("upcoming")   // "true" to show only future workouts
 	completed := r.URL.Query().Get("completed") // "true" or "false" to filter by completion
 
-	// Build the query
-	query := `
-		SELECT s.id, s.user_id, s.workout_id, s.scheduled_date, s.completed, s.completed_at, s.notes,
-		       w.id, w.user_id, w.name, w.description, w.created_at, w.updated_at
-		FROM schedules s
-		JOIN workouts w ON s.workout_id = w.id
-		WHERE s.user_id = $1
-	`
-
-	// Add filters
-	args := []interface{}{claims.UserID}
-	argCount := 1
-
-	if upcoming == "true" {
-		argCount++
-		query += ` AND s.scheduled_date >= NOW()`
+	// Move missed program sessions first, so the calendar shows where they are now
+	if _, err := s.refreshPrograms(userID); err != nil {
+		http.Error(w, "Error updating programs", http.StatusInternalServerError)
+		return
 	}
 
-	if completed == "true" {
-		query += ` AND s.completed = true`
-	} else if completed == "false" {
-		query += ` AND s.completed = false`
+	// Build the filter
+	filter := store.ScheduleFilter{Upcoming: upcoming == "true"}
+	if completed == "true" || completed == "false" {
+		done := completed == "true"
+		filter.Completed = &done
 	}
 
-	query += ` ORDER BY s.scheduled_date ASC`
-
-	// Execute the query
-	rows, err := database.DB.Query(query, args...)
+	schedules, err := s.Schedules.ListSchedules(userID, filter)
 	if err != nil {
 		http.Error(w, "Error fetching schedules", http.StatusInternalServerError)
 		return
 	}
-	defer rows.Close()
-
-	schedules := []models.Schedule{}
-	for rows.Next() {
-		var schedule models.Schedule
-		var workout models.Workout
-		var completedAt sql.NullTime
-
-		err := rows.Scan(
-			&schedule.ID,
-			&schedule.UserID,
-			&schedule.WorkoutID,
-			&schedule.ScheduledDate,
-			&schedule.Completed,
-			&completedAt,
-			&schedule.Notes,
-			&workout.ID,
-			&workout.UserID,
-			&workout.Name,
-			&workout.Description,
-			&workout.CreatedAt,
-			&workout.UpdatedAt,
-		)
-		if err != nil {
-			http.Error(w, "Error scanning schedule", http.StatusInternalServerError)
-			return
-		}
-
-		// Handle nullable completed_at
-		if completedAt.Valid {
-			schedule.CompletedAt = &completedAt.Time
-		}
-
-		schedule.Workout = &workout
-		schedules = append(schedules, schedule)
-	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(schedules)
 }
 
 // CompleteSchedule marks a scheduled workout as completed
-func CompleteSchedule(w http.ResponseWriter, r *http.Request) {
+func (s *Server) CompleteSchedule(w http.ResponseWriter, r *http.Request) {
 	// Get the current user from context
 	claims := middleware.GetUserFromContext(r)
 	if claims == nil {
@@ -211,64 +267,97 @@ func CompleteSchedule(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Start a transaction
-	tx, err := database.DB.Begin()
-	if err != nil {
-		http.Error(w, "Error starting transaction", http.StatusInternalServerError)
-		return
+	// Check the requested logs and turn them into models
+	logs := []models.WorkoutLog{}
+	for i, logReq := range req.Logs {
+		log, err := logReq.toLog()
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Log %d: %v", i+1, err), http.StatusBadRequest)
+			return
+		}
+		logs = append(logs, log)
 	}
-	defer tx.Rollback()
-
-	// Mark the schedule as completed
-	completedAt := time.Now()
-	result, err := tx.Exec(
-		`UPDATE schedules SET completed = true, completed_at = $1, notes = $2 
-		 WHERE id = $3 AND user_id = $4`,
-		completedAt, req.Notes, scheduleID, claims.UserID,
-	)
-	if err != nil {
-		http.Error(w, "Error completing schedule", http.StatusInternalServerError)
-		return
+	logged := []int{}
+	for _, log := range logs {
+		logged = append(logged, log.ExerciseID)
 	}
-
-	rowsAffected, _ := result.RowsAffected()
-	if rowsAffected == 0 {
-		http.Error(w, "Schedule not found", http.StatusNotFound)
+	if !s.checkExercises(w, claims.UserID, logged) {
 		return
 	}
 
-	// If logs are provided, insert them
-	for _, log := range req.Logs {
-		_, err = tx.Exec(
-			`INSERT INTO workout_logs (schedule_id, exercise_id, sets_completed, reps_completed, weight_used, duration, notes, logged_at) 
-			 VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`,
-			scheduleID, log.ExerciseID, log.SetsCompleted, log.RepsCompleted, log.WeightUsed, log.Duration, log.Notes, time.Now(),
-		)
+	// Compare every exercise with its earlier sets, before the new sets are saved
+	records := []models.PersonalRecord{}
+	now := time.Now()
+	for _, log := range logsPerExercise(logs) {
+		previous, err := s.Progress.ListLoggedSets(claims.UserID, store.SetFilter{ExerciseID: log.ExerciseID})
 		if err != nil {
-			http.Error(w, "Error logging workout details", http.StatusInternalServerError)
+			http.Error(w, "Error checking personal records", http.StatusInternalServerError)
 			return
 		}
+		records = append(records, analytics.NewRecords(previous, log, scheduleID, now)...)
 	}
 
-	// Commit the transaction
-	if err = tx.Commit(); err != nil {
-		http.Error(w, "Error committing transaction", http.StatusInternalServerError)
+	// Mark the schedule as completed and save the logs (in one transaction)
+	err = s.Schedules.CompleteSchedule(scheduleID, claims.UserID, req.Notes, logs)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			http.Error(w, "Schedule not found", http.StatusNotFound)
+			return
+		}
+		if errors.Is(err, store.ErrCompleted) {
+			http.Error(w, "Schedule is already completed", http.StatusConflict)
+			return
+		}
+		http.Error(w, "Error completing schedule", http.StatusInternalServerError)
+		return
+	}
+
+	// If the schedule is a program session, the program adjusts the next sessions to how it went
+	if err := s.recordProgramSession(claims.UserID, scheduleID, logs); err != nil {
+		http.Error(w, "Error updating program", http.StatusInternalServerError)
 		return
 	}
 
 	// Fetch and return the updated schedule
-	schedule, err := getScheduleByID(scheduleID)
+	schedule, err := s.Schedules.GetSchedule(scheduleID, claims.UserID)
 	if err != nil {
 		http.Error(w, "Error fetching updated schedule", http.StatusInternalServerError)
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(schedule)
+	json.NewEncoder(w).Encode(CompleteScheduleResponse{Schedule: schedule, PersonalRecords: records})
 }
 
-// DeleteSchedule deletes a scheduled workout
-func DeleteSchedule(w http.ResponseWriter, r *http.Request) {
+// logsPerExercise puts the sets of logs of the same exercise into one log, in the order they came
+// One workout is one session of an exercise, even when it was logged in two parts
+func logsPerExercise(logs []models.WorkoutLog) []models.WorkoutLog {
+	merged := []models.WorkoutLog{}
+	index := map[int]int{} // exercise ID -> position in merged
+	for _, log := range logs {
+		i, ok := index[log.ExerciseID]
+		if !ok {
+			index[log.ExerciseID] = len(merged)
+			log.Sets = append([]models.WorkoutSet{}, log.Sets...)
+			merged = append(merged, log)
+			continue
+		}
+		merged[i].Sets = append(merged[i].Sets, log.Sets...)
+	}
+	return merged
+}
+
+// UpdateScheduleRequest changes one schedule, fields that are left out stay the same
+type UpdateScheduleRequest struct {
+	WorkoutID     int     `json:"workout_id"`
+	ScheduledDate string  `json:"scheduled_date"` // Format: "2024-01-15T10:00:00Z"
+	Notes         *string `json:"notes"`          // a pointer, so "" can clear the notes
+}
+
+// UpdateSchedule moves or changes one scheduled workout
+// For an occurrence of a series only this occurrence changes (it becomes "detached"),
+// use PUT /api/schedule/series/{id} to change the whole series
+func (s *Server) UpdateSchedule(w http.ResponseWriter, r *http.Request) {
 	// Get the current user from context
 	claims := middleware.GetUserFromContext(r)
 	if claims == nil {
@@ -284,61 +373,90 @@ func DeleteSchedule(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Delete the schedule
-	result, err := database.DB.Exec(
-		`DELETE FROM schedules WHERE id = $1 AND user_id = $2`,
-		scheduleID, claims.UserID,
-	)
+	// Parse the request body
+	var req UpdateScheduleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	// Start from the current schedule
+	schedule, err := s.Schedules.GetSchedule(scheduleID, claims.UserID)
 	if err != nil {
-		http.Error(w, "Error deleting schedule", http.StatusInternalServerError)
+		if errors.Is(err, store.ErrNotFound) {
+			http.Error(w, "Schedule not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Error fetching schedule", http.StatusInternalServerError)
 		return
 	}
 
-	rowsAffected, _ := result.RowsAffected()
-	if rowsAffected == 0 {
-		http.Error(w, "Schedule not found", http.StatusNotFound)
+	if req.WorkoutID != 0 && req.WorkoutID != schedule.WorkoutID {
+		if !s.checkWorkoutOwner(w, req.WorkoutID, claims.UserID) {
+			return
+		}
+		schedule.WorkoutID = req.WorkoutID
+	}
+	if req.ScheduledDate != "" {
+		scheduledDate, err := time.Parse(time.RFC3339, req.ScheduledDate)
+		if err != nil {
+			http.Error(w, "Invalid date format. Use RFC3339 format like: 2024-01-15T10:00:00Z", http.StatusBadRequest)
+			return
+		}
+		schedule.ScheduledDate = scheduledDate
+	}
+	if req.Notes != nil {
+		schedule.Notes = *req.Notes
+	}
+
+	if err := s.Schedules.UpdateSchedule(schedule); err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			http.Error(w, "Schedule not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Error updating schedule", http.StatusInternalServerError)
 		return
 	}
 
-	w.WriteHeader(http.StatusNoContent)
+	// Fetch and return the updated schedule
+	updated, err := s.Schedules.GetSchedule(scheduleID, claims.UserID)
+	if err != nil {
+		http.Error(w, "Error fetching updated schedule", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(updated)
 }
 
-// Helper function to get a schedule by ID
-func getScheduleByID(scheduleID int) (*models.Schedule, error) {
-	var schedule models.Schedule
-	var workout models.Workout
-	var completedAt sql.NullTime
-
-	err := database.DB.QueryRow(`
-		SELECT s.id, s.user_id, s.workout_id, s.scheduled_date, s.completed, s.completed_at, s.notes,
-		       w.id, w.user_id, w.name, w.description, w.created_at, w.updated_at
-		FROM schedules s
-		JOIN workouts w ON s.workout_id = w.id
-		WHERE s.id = $1
-	`, scheduleID).Scan(
-		&schedule.ID,
-		&schedule.UserID,
-		&schedule.WorkoutID,
-		&schedule.ScheduledDate,
-		&schedule.Completed,
-		&completedAt,
-		&schedule.Notes,
-		&workout.ID,
-		&workout.UserID,
-		&workout.Name,
-		&workout.Description,
-		&workout.CreatedAt,
-		&workout.UpdatedAt,
-	)
+// DeleteSchedule deletes a scheduled workout
+// Deleting an occurrence of a series only removes that one date
+func (s *Server) DeleteSchedule(w http.ResponseWriter, r *http.Request) {
+	// Get the current user from context
+	claims := middleware.GetUserFromContext(r)
+	if claims == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
 
+	// Get schedule ID from URL
+	vars := mux.Vars(r)
+	scheduleID, err := strconv.Atoi(vars["id"])
 	if err != nil {
-		return nil, err
+		http.Error(w, "Invalid schedule ID", http.StatusBadRequest)
+		return
 	}
 
-	if completedAt.Valid {
-		schedule.CompletedAt = &completedAt.Time
+	// Delete the schedule
+	err = s.Schedules.DeleteSchedule(scheduleID, claims.UserID)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			http.Error(w, "Schedule not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Error deleting schedule", http.StatusInternalServerError)
+		return
 	}
 
-	schedule.Workout = &workout
-	return &schedule, nil
+	w.WriteHeader(http.StatusNoContent)
 }
@@ -0,0 +1,201 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+	"workout-tracker/models"
+	"workout-tracker/store"
+)
+
+func TestScheduleLifecycle(t *testing.T) {
+	api := newTestAPI(t)
+	alice := api.register("alice")
+	workout := api.createWorkout(alice.Token, "Leg day")
+
+	tomorrow := time.Now().UTC().Add(24 * time.Hour).Truncate(time.Second)
+	rec := api.do("POST", "/api/schedule", alice.Token, CreateScheduleRequest{
+		WorkoutID:     workout.ID,
+		ScheduledDate: tomorrow.Format(time.RFC3339),
+		Notes:         "before work",
+	})
+	expect(t, rec, http.StatusCreated, "create schedule")
+	var created models.Schedule
+	decode(t, rec, &created)
+	if created.WorkoutID != workout.ID || !created.ScheduledDate.Equal(tomorrow) || created.Completed || created.Workout == nil {
+		t.Fatalf("created schedule = %+v", created)
+	}
+	path := fmt.Sprintf("/api/schedule/%d", created.ID)
+
+	// moved one day later, the notes stay
+	later := tomorrow.Add(24 * time.Hour)
+	rec = api.do("PUT", path, alice.Token, UpdateScheduleRequest{ScheduledDate: later.Format(time.RFC3339)})
+	expect(t, rec, http.StatusOK, "update schedule")
+	var moved models.Schedule
+	decode(t, rec, &moved)
+	if !moved.ScheduledDate.Equal(later) || moved.Notes != "before work" {
+		t.Errorf("moved schedule = %+v", moved)
+	}
+
+	rec = api.do("POST", path+"/complete", alice.Token, CompleteScheduleRequest{
+		Notes: "felt strong",
+		Logs:  []WorkoutLogRequest{{ExerciseID: api.squat.ID, SetsCompleted: 5, RepsCompleted: 5, WeightUsed: 100}},
+	})
+	expect(t, rec, http.StatusOK, "complete schedule")
+	var completed CompleteScheduleResponse
+	decode(t, rec, &completed)
+	if completed.Schedule == nil || !completed.Completed || completed.CompletedAt == nil || completed.Notes != "felt strong" {
+		t.Errorf("completed schedule = %+v", completed.Schedule)
+	}
+	// the first squat ever has nothing to beat
+	if len(completed.PersonalRecords) != 0 {
+		t.Errorf("the first logged squat set records: %+v", completed.PersonalRecords)
+	}
+
+	for query, want := range map[string]int{"": 1, "?completed=true": 1, "?completed=false": 0, "?upcoming=true": 1} {
+		rec = api.do("GET", "/api/schedule"+query, alice.Token, nil)
+		expect(t, rec, http.StatusOK, "list schedules"+query)
+		var list []models.Schedule
+		decode(t, rec, &list)
+		if len(list) != want {
+			t.Errorf("GET /api/schedule%s returned %d schedules, want %d", query, len(list), want)
+		}
+	}
+
+	expect(t, api.do("DELETE", path, alice.Token, nil), http.StatusNoContent, "delete schedule")
+	expect(t, api.do("DELETE", path, alice.Token, nil), http.StatusNotFound, "delete schedule again")
+}
+
+func TestCreateScheduleErrors(t *testing.T) {
+	api := newTestAPI(t)
+	alice := api.register("alice")
+	bob := api.register("bob")
+	own := api.createWorkout(alice.Token, "Leg day")
+	other := api.createWorkout(bob.Token, "Bob's leg day")
+	date := time.Now().UTC().Format(time.RFC3339)
+
+	tests := []struct {
+		name string
+		req  CreateScheduleRequest
+		want int
+	}{
+		{"bad date", CreateScheduleRequest{WorkoutID: own.ID, ScheduledDate: "tomorrow"}, http.StatusBadRequest},
+		{"date without time", CreateScheduleRequest{WorkoutID: own.ID, ScheduledDate: "2025-01-15"}, http.StatusBadRequest},
+		{"unknown workout", CreateScheduleRequest{WorkoutID: 9999, ScheduledDate: date}, http.StatusNotFound},
+		{"workout of another user", CreateScheduleRequest{WorkoutID: other.ID, ScheduledDate: date}, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		if rec := api.do("POST", "/api/schedule", alice.Token, tt.req); rec.Code != tt.want {
+			t.Errorf("%s: status %d, want %d: %s", tt.name, rec.Code, tt.want, rec.Body)
+		}
+	}
+}
+
+func TestSchedulesOfOtherUsers(t *testing.T) {
+	api := newTestAPI(t)
+	alice := api.register("alice")
+	bob := api.register("bob")
+	workout := api.createWorkout(alice.Token, "Leg day")
+
+	rec := api.do("POST", "/api/schedule", alice.Token, CreateScheduleRequest{WorkoutID: workout.ID, ScheduledDate: time.Now().UTC().Format(time.RFC3339)})
+	expect(t, rec, http.StatusCreated, "create schedule")
+	var schedule models.Schedule
+	decode(t, rec, &schedule)
+	path := fmt.Sprintf("/api/schedule/%d", schedule.ID)
+
+	expect(t, api.do("PUT", path, bob.Token, UpdateScheduleRequest{ScheduledDate: time.Now().UTC().Format(time.RFC3339)}), http.StatusNotFound, "bob moves alice's schedule")
+	expect(t, api.do("POST", path+"/complete", bob.Token, CompleteScheduleRequest{}), http.StatusNotFound, "bob completes alice's schedule")
+	expect(t, api.do("DELETE", path, bob.Token, nil), http.StatusNotFound, "bob deletes alice's schedule")
+
+	rec = api.do("GET", "/api/schedule", bob.Token, nil)
+	expect(t, rec, http.StatusOK, "bob lists schedules")
+	var list []models.Schedule
+	decode(t, rec, &list)
+	if len(list) != 0 {
+		t.Errorf("bob sees %d schedules, want 0", len(list))
+	}
+}
+
+// An exercise logged in two parts is one session, its records are reported once
+func TestCompleteScheduleRecordsPerExercise(t *testing.T) {
+	api := newTestAPI(t)
+	alice := api.register("alice")
+	workout := api.createWorkout(alice.Token, "Leg day")
+
+	complete := func(logs ...WorkoutLogRequest) CompleteScheduleResponse {
+		t.Helper()
+		rec := api.do("POST", "/api/schedule", alice.Token, CreateScheduleRequest{WorkoutID: workout.ID, ScheduledDate: time.Now().UTC().Format(time.RFC3339)})
+		expect(t, rec, http.StatusCreated, "create schedule")
+		var schedule models.Schedule
+		decode(t, rec, &schedule)
+		rec = api.do("POST", fmt.Sprintf("/api/schedule/%d/complete", schedule.ID), alice.Token, CompleteScheduleRequest{Logs: logs})
+		expect(t, rec, http.StatusOK, "complete schedule")
+		var completed CompleteScheduleResponse
+		decode(t, rec, &completed)
+		return completed
+	}
+
+	complete(WorkoutLogRequest{ExerciseID: api.squat.ID, SetsCompleted: 3, RepsCompleted: 5, WeightUsed: 100})
+	completed := complete(
+		WorkoutLogRequest{ExerciseID: api.squat.ID, SetsCompleted: 2, RepsCompleted: 5, WeightUsed: 110},
+		WorkoutLogRequest{ExerciseID: api.squat.ID, SetsCompleted: 2, RepsCompleted: 5, WeightUsed: 110},
+	)
+
+	kinds := []string{}
+	for _, record := range completed.PersonalRecords {
+		kinds = append(kinds, record.Kind)
+	}
+	if fmt.Sprint(kinds) != "[max_weight estimated_1rm max_volume]" {
+		t.Errorf("records of the split squat: %+v", completed.PersonalRecords)
+	}
+
+	// the records page agrees with what the completion said
+	rec := api.do("GET", "/api/progress/records", alice.Token, nil)
+	expect(t, rec, http.StatusOK, "list records")
+	var records []models.PersonalRecord
+	decode(t, rec, &records)
+	if len(records) != len(completed.PersonalRecords) {
+		t.Errorf("GET /api/progress/records returned %d records, want %d: %+v", len(records), len(completed.PersonalRecords), records)
+	}
+}
+
+// Completing twice (a double tap, a retried request) must not log the sets twice
+func TestCompleteScheduleOnce(t *testing.T) {
+	api := newTestAPI(t)
+	alice := api.register("alice")
+	workout := api.createWorkout(alice.Token, "Leg day")
+
+	rec := api.do("POST", "/api/schedule", alice.Token, CreateScheduleRequest{WorkoutID: workout.ID, ScheduledDate: time.Now().UTC().Format(time.RFC3339)})
+	expect(t, rec, http.StatusCreated, "create schedule")
+	var schedule models.Schedule
+	decode(t, rec, &schedule)
+	path := fmt.Sprintf("/api/schedule/%d/complete", schedule.ID)
+
+	first := CompleteScheduleRequest{Notes: "first", Logs: []WorkoutLogRequest{{ExerciseID: api.squat.ID, SetsCompleted: 5, RepsCompleted: 5, WeightUsed: 100}}}
+	expect(t, api.do("POST", path, alice.Token, first), http.StatusOK, "complete schedule")
+	second := CompleteScheduleRequest{Notes: "second", Logs: []WorkoutLogRequest{{ExerciseID: api.squat.ID, SetsCompleted: 5, RepsCompleted: 5, WeightUsed: 120}}}
+	expect(t, api.do("POST", path, alice.Token, second), http.StatusConflict, "complete schedule again")
+	expect(t, api.do("POST", "/api/schedule/9999/complete", alice.Token, first), http.StatusNotFound, "complete an unknown schedule")
+
+	sets, err := api.store.ListLoggedSets(alice.User.ID, store.SetFilter{ExerciseID: api.squat.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sets) != 5 {
+		t.Errorf("%d squat sets logged, want 5", len(sets))
+	}
+	for _, set := range sets {
+		if set.Weight != 100 {
+			t.Errorf("logged set %+v, want the sets of the first request", set)
+		}
+	}
+
+	got, err := api.store.GetSchedule(schedule.ID, alice.User.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Notes != "first" {
+		t.Errorf("notes after completing twice = %q, want %q", got.Notes, "first")
+	}
+}
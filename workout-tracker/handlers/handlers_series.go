@@ -0,0 +1,302 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+	"workout-tracker/calendar"
+	"workout-tracker/middleware"
+	"workout-tracker/models"
+	"workout-tracker/store"
+
+	"github.com/gorilla/mux"
+)
+
+// SeriesRequest represents a recurring schedule, written as one rule:
+//
+//	{"workout_id": 3, "rule": "Mon/Wed/Fri at 7am for 8 weeks", "start_date": "2024-01-15"}
+//
+// or with separate fields:
+//
+//	{"workout_id": 3, "weekdays": ["mon", "wed", "fri"], "time": "07:00", "weeks": 8}
+type SeriesRequest struct {
+	WorkoutID int      `json:"workout_id"`
+	Rule      string   `json:"rule"`
+	Weekdays  []string `json:"weekdays"`
+	Time      string   `json:"time"`       // Format: "07:00" or "7am" (UTC)
+	Weeks     int      `json:"weeks"`      // 1 to 52
+	StartDate string   `json:"start_date"` // Format: "2024-01-15", defaults to today
+	Notes     string   `json:"notes"`
+}
+
+// toSeries checks the request and returns the series with the date of every occurrence
+func (req SeriesRequest) toSeries(userID int, now time.Time) (*models.ScheduleSeries, []time.Time, error) {
+	if req.WorkoutID == 0 {
+		return nil, nil, errors.New("workout_id is required")
+	}
+
+	// A rule or the separate fields, not both
+	var rule calendar.Rule
+	var err error
+	if req.Rule != "" {
+		if len(req.Weekdays) > 0 || req.Time != "" || req.Weeks != 0 {
+			return nil, nil, errors.New("send either rule or weekdays, time and weeks")
+		}
+		rule, err = calendar.ParseRule(req.Rule)
+	} else {
+		rule, err = calendar.NewRule(req.Weekdays, req.Time, req.Weeks)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	start := now.UTC().Truncate(24 * time.Hour)
+	if req.StartDate != "" {
+		start, err = time.Parse("2006-01-02", req.StartDate)
+		if err != nil {
+			return nil, nil, errors.New("invalid start_date, use the format 2024-01-15")
+		}
+	}
+
+	series := &models.ScheduleSeries{
+		UserID:    userID,
+		WorkoutID: req.WorkoutID,
+		Weekdays:  rule.WeekdayNames(),
+		Time:      rule.Clock(),
+		StartDate: start,
+		Weeks:     rule.Weeks,
+		Notes:     req.Notes,
+	}
+	return series, rule.Dates(start), nil
+}
+
+// seriesID reads the series ID from the URL
+func seriesID(r *http.Request) (int, error) {
+	vars := mux.Vars(r)
+	return strconv.Atoi(vars["id"])
+}
+
+// CreateSeries schedules a workout on a repeating pattern
+// Every occurrence becomes a normal schedule, so it can be completed, moved or deleted on its own
+func (s *Server) CreateSeries(w http.ResponseWriter, r *http.Request) {
+	// Get the current user from context
+	claims := middleware.GetUserFromContext(r)
+	if claims == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	// Parse the request body
+	var req SeriesRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	series, dates, err := req.toSeries(claims.UserID, time.Now())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Verify the workout belongs to this user
+	if !s.checkWorkoutOwner(w, req.WorkoutID, claims.UserID) {
+		return
+	}
+
+	// Save the series and all its occurrences (in one transaction)
+	if err := s.Schedules.CreateSeries(series, dates); err != nil {
+		http.Error(w, "Error creating series", http.StatusInternalServerError)
+		return
+	}
+
+	// Fetch and return the series with its occurrences
+	created, err := s.Schedules.GetSeries(series.ID, claims.UserID)
+	if err != nil {
+		http.Error(w, "Error fetching created series", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(created)
+}
+
+// GetSeriesList returns all series of the logged-in user, without their occurrences
+func (s *Server) GetSeriesList(w http.ResponseWriter, r *http.Request) {
+	// Get the current user from context
+	claims := middleware.GetUserFromContext(r)
+	if claims == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	list, err := s.Schedules.ListSeries(claims.UserID)
+	if err != nil {
+		http.Error(w, "Error fetching series", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(list)
+}
+
+// GetSeries returns a series with all its occurrences
+func (s *Server) GetSeries(w http.ResponseWriter, r *http.Request) {
+	// Get the current user from context
+	claims := middleware.GetUserFromContext(r)
+	if claims == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	id, err := seriesID(r)
+	if err != nil {
+		http.Error(w, "Invalid series ID", http.StatusBadRequest)
+		return
+	}
+
+	series, err := s.Schedules.GetSeries(id, claims.UserID)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			http.Error(w, "Series not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Error fetching series", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(series)
+}
+
+// UpdateSeries changes the whole series: the rule, the workout or the notes
+// Only the future is rewritten. Past, completed and detached occurrences stay as they are,
+// and dates that were deleted one by one don't come back.
+func (s *Server) UpdateSeries(w http.ResponseWriter, r *http.Request) {
+	// Get the current user from context
+	claims := middleware.GetUserFromContext(r)
+	if claims == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	id, err := seriesID(r)
+	if err != nil {
+		http.Error(w, "Invalid series ID", http.StatusBadRequest)
+		return
+	}
+
+	// Parse the request body
+	var req SeriesRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	existing, err := s.Schedules.GetSeries(id, claims.UserID)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			http.Error(w, "Series not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Error fetching series", http.StatusInternalServerError)
+		return
+	}
+
+	// Keep the original start date unless a new one is sent
+	if req.StartDate == "" {
+		req.StartDate = existing.StartDate.Format("2006-01-02")
+	}
+	now := time.Now()
+	series, dates, err := req.toSeries(claims.UserID, now)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	series.ID = existing.ID
+
+	if series.WorkoutID != existing.WorkoutID {
+		if !s.checkWorkoutOwner(w, series.WorkoutID, claims.UserID) {
+			return
+		}
+	}
+
+	// Days that already have an occurrence we keep, or that were deleted on purpose
+	taken := append([]time.Time{}, existing.Exceptions...)
+	for _, occurrence := range existing.Occurrences {
+		if occurrence.Completed || occurrence.Detached || occurrence.ScheduledDate.Before(now) {
+			if occurrence.SeriesDate != nil {
+				taken = append(taken, *occurrence.SeriesDate)
+			}
+		}
+	}
+
+	// New occurrences only from now on, and not on a taken day
+	future := []time.Time{}
+	for _, date := range dates {
+		if date.Before(now) {
+			continue
+		}
+		free := true
+		for _, day := range taken {
+			if calendar.SameDay(date, day) {
+				free = false
+				break
+			}
+		}
+		if free {
+			future = append(future, date)
+		}
+	}
+
+	if err := s.Schedules.UpdateSeries(series, now, future); err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			http.Error(w, "Series not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Error updating series", http.StatusInternalServerError)
+		return
+	}
+
+	// Fetch and return the updated series
+	updated, err := s.Schedules.GetSeries(id, claims.UserID)
+	if err != nil {
+		http.Error(w, "Error fetching updated series", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(updated)
+}
+
+// DeleteSeries deletes a series and its open occurrences
+// Completed occurrences stay in the history as one-off schedules
+func (s *Server) DeleteSeries(w http.ResponseWriter, r *http.Request) {
+	// Get the current user from context
+	claims := middleware.GetUserFromContext(r)
+	if claims == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	id, err := seriesID(r)
+	if err != nil {
+		http.Error(w, "Invalid series ID", http.StatusBadRequest)
+		return
+	}
+
+	err = s.Schedules.DeleteSeries(id, claims.UserID)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			http.Error(w, "Series not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Error deleting series", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
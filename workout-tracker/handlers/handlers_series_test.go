@@ -0,0 +1,167 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+	"workout-tracker/calendar"
+	"workout-tracker/models"
+)
+
+// createSeries creates a series starting tomorrow, so every occurrence is in the future
+func (a *testAPI) createSeries(token string, workoutID int, weekdays []string, clock string, weeks int) models.ScheduleSeries {
+	a.t.Helper()
+	rec := a.do("POST", "/api/schedule/series", token, SeriesRequest{
+		WorkoutID: workoutID,
+		Weekdays:  weekdays,
+		Time:      clock,
+		Weeks:     weeks,
+		StartDate: time.Now().UTC().AddDate(0, 0, 1).Format("2006-01-02"),
+		Notes:     "series notes",
+	})
+	expect(a.t, rec, http.StatusCreated, "create series")
+	var series models.ScheduleSeries
+	decode(a.t, rec, &series)
+	return series
+}
+
+func (a *testAPI) getSeries(token string, id int) models.ScheduleSeries {
+	a.t.Helper()
+	rec := a.do("GET", fmt.Sprintf("/api/schedule/series/%d", id), token, nil)
+	expect(a.t, rec, http.StatusOK, "get series")
+	var series models.ScheduleSeries
+	decode(a.t, rec, &series)
+	return series
+}
+
+// occurrenceOn returns the occurrence the series planned for the day of the date
+func occurrenceOn(series models.ScheduleSeries, date time.Time) *models.Schedule {
+	for i, occurrence := range series.Occurrences {
+		if occurrence.SeriesDate != nil && calendar.SameDay(*occurrence.SeriesDate, date) {
+			return &series.Occurrences[i]
+		}
+	}
+	return nil
+}
+
+var everyDay = []string{"mon", "tue", "wed", "thu", "fri", "sat", "sun"}
+
+func TestSeriesOccurrenceEdit(t *testing.T) {
+	api := newTestAPI(t)
+	alice := api.register("alice")
+	workout := api.createWorkout(alice.Token, "Leg day")
+
+	series := api.createSeries(alice.Token, workout.ID, everyDay, "07:00", 2)
+	if len(series.Occurrences) != 14 {
+		t.Fatalf("%d occurrences, want 14", len(series.Occurrences))
+	}
+	for _, occurrence := range series.Occurrences {
+		if occurrence.Detached || occurrence.SeriesID == nil || *occurrence.SeriesID != series.ID {
+			t.Fatalf("new occurrence %+v", occurrence)
+		}
+	}
+
+	// The second occurrence gets its own notes, the third moves to the evening
+	edited, moved := series.Occurrences[1], series.Occurrences[2]
+	notes := "only this one"
+	expect(t, api.do("PUT", fmt.Sprintf("/api/schedule/%d", edited.ID), alice.Token, UpdateScheduleRequest{Notes: &notes}), http.StatusOK, "edit one occurrence")
+	evening := moved.ScheduledDate.Add(12 * time.Hour)
+	expect(t, api.do("PUT", fmt.Sprintf("/api/schedule/%d", moved.ID), alice.Token, UpdateScheduleRequest{ScheduledDate: evening.Format(time.RFC3339)}), http.StatusOK, "move one occurrence")
+
+	series = api.getSeries(alice.Token, series.ID)
+	for _, occurrence := range series.Occurrences {
+		want := occurrence.ID == edited.ID || occurrence.ID == moved.ID
+		if occurrence.Detached != want {
+			t.Errorf("occurrence on %s detached = %v, want %v", occurrence.ScheduledDate, occurrence.Detached, want)
+		}
+	}
+
+	// Editing the whole series leaves the detached occurrences alone
+	rec := api.do("PUT", fmt.Sprintf("/api/schedule/series/%d", series.ID), alice.Token, SeriesRequest{
+		WorkoutID: workout.ID, Weekdays: everyDay, Time: "18:00", Weeks: 2, Notes: "evenings now",
+	})
+	expect(t, rec, http.StatusOK, "edit the series")
+	var updated models.ScheduleSeries
+	decode(t, rec, &updated)
+	if len(updated.Occurrences) != 14 {
+		t.Errorf("%d occurrences after editing the series, want 14", len(updated.Occurrences))
+	}
+	for _, occurrence := range updated.Occurrences {
+		switch occurrence.ID {
+		case edited.ID:
+			if occurrence.Notes != "only this one" || !occurrence.ScheduledDate.Equal(edited.ScheduledDate) {
+				t.Errorf("edited occurrence after editing the series = %+v", occurrence)
+			}
+		case moved.ID:
+			if !occurrence.ScheduledDate.Equal(evening) {
+				t.Errorf("moved occurrence after editing the series = %+v", occurrence)
+			}
+		default:
+			if occurrence.Notes != "evenings now" || occurrence.ScheduledDate.Hour() != 18 {
+				t.Errorf("occurrence after editing the series = %+v", occurrence)
+			}
+		}
+	}
+}
+
+func TestSeriesOccurrenceDelete(t *testing.T) {
+	api := newTestAPI(t)
+	alice := api.register("alice")
+	workout := api.createWorkout(alice.Token, "Leg day")
+
+	series := api.createSeries(alice.Token, workout.ID, everyDay, "07:00", 2)
+	deleted := series.Occurrences[3]
+	expect(t, api.do("DELETE", fmt.Sprintf("/api/schedule/%d", deleted.ID), alice.Token, nil), http.StatusNoContent, "delete one occurrence")
+
+	series = api.getSeries(alice.Token, series.ID)
+	if len(series.Occurrences) != 13 || occurrenceOn(series, *deleted.SeriesDate) != nil {
+		t.Errorf("%d occurrences after deleting one, want 13", len(series.Occurrences))
+	}
+	if len(series.Exceptions) != 1 || !series.Exceptions[0].Equal(*deleted.SeriesDate) {
+		t.Errorf("exceptions %v, want [%s]", series.Exceptions, deleted.SeriesDate)
+	}
+
+	// The deleted day doesn't come back when the series changes
+	rec := api.do("PUT", fmt.Sprintf("/api/schedule/series/%d", series.ID), alice.Token, SeriesRequest{
+		WorkoutID: workout.ID, Weekdays: everyDay, Time: "08:00", Weeks: 2,
+	})
+	expect(t, rec, http.StatusOK, "edit the series")
+	var updated models.ScheduleSeries
+	decode(t, rec, &updated)
+	if len(updated.Occurrences) != 13 || occurrenceOn(updated, *deleted.SeriesDate) != nil {
+		t.Errorf("the deleted day came back: %d occurrences", len(updated.Occurrences))
+	}
+
+	// Deleting the series keeps the completed occurrence as a one-off schedule
+	done := updated.Occurrences[0]
+	expect(t, api.do("POST", fmt.Sprintf("/api/schedule/%d/complete", done.ID), alice.Token, CompleteScheduleRequest{}), http.StatusOK, "complete one occurrence")
+	expect(t, api.do("DELETE", fmt.Sprintf("/api/schedule/series/%d", series.ID), alice.Token, nil), http.StatusNoContent, "delete the series")
+	expect(t, api.do("GET", fmt.Sprintf("/api/schedule/series/%d", series.ID), alice.Token, nil), http.StatusNotFound, "get the deleted series")
+
+	rec = api.do("GET", "/api/schedule", alice.Token, nil)
+	expect(t, rec, http.StatusOK, "list schedules")
+	var list []models.Schedule
+	decode(t, rec, &list)
+	if len(list) != 1 || list[0].ID != done.ID || list[0].SeriesID != nil {
+		t.Errorf("schedules after deleting the series = %+v", list)
+	}
+}
+
+func TestSeriesOfOtherUsers(t *testing.T) {
+	api := newTestAPI(t)
+	alice := api.register("alice")
+	bob := api.register("bob")
+	workout := api.createWorkout(alice.Token, "Leg day")
+	series := api.createSeries(alice.Token, workout.ID, []string{"mon"}, "07:00", 1)
+	path := fmt.Sprintf("/api/schedule/series/%d", series.ID)
+
+	expect(t, api.do("GET", path, bob.Token, nil), http.StatusNotFound, "bob gets alice's series")
+	expect(t, api.do("PUT", path, bob.Token, SeriesRequest{WorkoutID: workout.ID, Rule: "Tue at 7am for 1 week"}), http.StatusNotFound, "bob edits alice's series")
+	expect(t, api.do("DELETE", path, bob.Token, nil), http.StatusNotFound, "bob deletes alice's series")
+	expect(t, api.do("DELETE", fmt.Sprintf("/api/schedule/%d", series.Occurrences[0].ID), bob.Token, nil), http.StatusNotFound, "bob deletes an occurrence")
+
+	if got := api.getSeries(alice.Token, series.ID); len(got.Occurrences) != 1 || len(got.Exceptions) != 0 {
+		t.Errorf("alice's series after bob's requests = %+v", got)
+	}
+}
@@ -1,14 +1,14 @@
 package handlers
 
 import (
-	"database/sql"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
 	"strconv"
-	"time"
-	"workout-tracker/database"
 	"workout-tracker/middleware"
 	"workout-tracker/models"
+	"workout-tracker/store"
 
 	"github.com/gorilla/mux"
 )
@@ -21,16 +21,108 @@ type CreateWorkoutRequest struct {
 }
 
 // CreateWorkoutExerciseRequest represents an exercise in a workout
+// Exercises next to each other with the same Group form a superset or a circuit, e.g.
+//
+//	{"exercise_id": 1, "group": "A", "group_type": "superset"},
+//	{"exercise_id": 5, "group": "A", "group_type": "superset", "rest_seconds": 90}
 type CreateWorkoutExerciseRequest struct {
-	ExerciseID int     `json:"exercise_id"`
-	Sets       int     `json:"sets"`
-	Reps       int     `json:"reps"`
-	Weight     float64 `json:"weight"`
-	Notes      string  `json:"notes"`
+	ExerciseID  int     `json:"exercise_id"`
+	Sets        int     `json:"sets"`
+	Reps        int     `json:"reps"`
+	Weight      float64 `json:"weight"`
+	Notes       string  `json:"notes"`
+	Group       string  `json:"group"`
+	GroupType   string  `json:"group_type"` // "superset" (the default for a group) or "circuit"
+	RestSeconds int     `json:"rest_seconds"`
+}
+
+// toWorkout checks the request and turns it into the model the store saves
+// The exercises keep the order of the request, that's their Position
+func (req CreateWorkoutRequest) toWorkout(id, userID int) (*models.Workout, error) {
+	workout := &models.Workout{
+		ID:          id,
+		UserID:      userID,
+		Name:        req.Name,
+		Description: req.Description,
+	}
+	for i, exercise := range req.Exercises {
+		if exercise.Sets < 0 || exercise.Reps < 0 || exercise.Weight < 0 || exercise.RestSeconds < 0 {
+			return nil, fmt.Errorf("exercise %d: sets, reps, weight and rest_seconds can't be negative", i+1)
+		}
+		workout.Exercises = append(workout.Exercises, models.WorkoutExercise{
+			ExerciseID:  exercise.ExerciseID,
+			Position:    i + 1,
+			Sets:        exercise.Sets,
+			Reps:        exercise.Reps,
+			Weight:      exercise.Weight,
+			Notes:       exercise.Notes,
+			Group:       exercise.Group,
+			GroupType:   exercise.GroupType,
+			RestSeconds: exercise.RestSeconds,
+		})
+	}
+	if err := checkGroups(workout.Exercises); err != nil {
+		return nil, err
+	}
+	return workout, nil
+}
+
+// checkGroups makes sure supersets and circuits make sense:
+//   - a group needs at least two exercises, and they have to be next to each other
+//   - all exercises of a group have the same type (a group without a type is a superset)
+func checkGroups(exercises []models.WorkoutExercise) error {
+	size := map[string]int{}
+	groupType := map[string]string{}
+	previous := ""
+
+	for i := range exercises {
+		exercise := &exercises[i]
+		if exercise.Group == "" {
+			if exercise.GroupType != "" {
+				return fmt.Errorf("exercise %d: group_type needs a group", exercise.Position)
+			}
+			previous = ""
+			continue
+		}
+
+		if exercise.GroupType == "" {
+			exercise.GroupType = models.GroupSuperset
+		}
+		if exercise.GroupType != models.GroupSuperset && exercise.GroupType != models.GroupCircuit {
+			return fmt.Errorf("exercise %d: group_type must be %q or %q", exercise.Position, models.GroupSuperset, models.GroupCircuit)
+		}
+
+		// Seen before, but not right above this exercise? Then the group is split up
+		if size[exercise.Group] > 0 && previous != exercise.Group {
+			return fmt.Errorf("the exercises of group %q must be next to each other", exercise.Group)
+		}
+		if t, ok := groupType[exercise.Group]; ok && t != exercise.GroupType {
+			return fmt.Errorf("group %q can't be both a superset and a circuit", exercise.Group)
+		}
+		groupType[exercise.Group] = exercise.GroupType
+		size[exercise.Group]++
+		previous = exercise.Group
+	}
+
+	for group, n := range size {
+		if n < 2 {
+			return fmt.Errorf("group %q needs at least two exercises", group)
+		}
+	}
+	return nil
+}
+
+// exerciseIDs returns the exercise ID of every exercise in the workout
+func exerciseIDs(workout *models.Workout) []int {
+	ids := []int{}
+	for _, exercise := range workout.Exercises {
+		ids = append(ids, exercise.ExerciseID)
+	}
+	return ids
 }
 
 // CreateWorkout creates a new workout for the logged-in user
-func CreateWorkout(w http.ResponseWriter, r *http.Request) {
+func (s *Server) CreateWorkout(w http.ResponseWriter, r *http.Request) {
 	// Get the current user from context
 	claims := middleware.GetUserFromContext(r)
 	if claims == nil {
@@ -38,6 +130,12 @@ func CreateWorkout(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// On /api/coach/athletes/{athleteID}/... this is the athlete, see subjectUser
+	userID, ok := s.subjectUser(w, r, claims)
+	if !ok {
+		return
+	}
+
 	// Parse the request body
 	var req CreateWorkoutRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -51,50 +149,27 @@ func CreateWorkout(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Start a database transaction
-	// This ensures that either all changes succeed or none do
-	tx, err := database.DB.Begin()
+	created, err := req.toWorkout(0, userID)
 	if err != nil {
-		http.Error(w, "Error starting transaction", http.StatusInternalServerError)
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
-	// If anything goes wrong, rollback the transaction
-	defer tx.Rollback()
-
-	// Insert the workout
-	var workoutID int
-	err = tx.QueryRow(
-		`INSERT INTO workouts (user_id, name, description, created_at, updated_at) 
-		 VALUES ($1, $2, $3, $4, $5) RETURNING id`,
-		claims.UserID, req.Name, req.Description, time.Now(), time.Now(),
-	).Scan(&workoutID)
+	created.AssignedBy = assignedBy(claims, userID)
 
-	if err != nil {
-		http.Error(w, "Error creating workout", http.StatusInternalServerError)
+	// The exercises must be in the library or be the user's own (a coach uses the athlete's)
+	if !s.checkExercises(w, userID, exerciseIDs(created)) {
 		return
 	}
 
-	// Insert each exercise in the workout
-	for _, exercise := range req.Exercises {
-		_, err = tx.Exec(
-			`INSERT INTO workout_exercises (workout_id, exercise_id, sets, reps, weight, notes) 
-			 VALUES ($1, $2, $3, $4, $5, $6)`,
-			workoutID, exercise.ExerciseID, exercise.Sets, exercise.Reps, exercise.Weight, exercise.Notes,
-		)
-		if err != nil {
-			http.Error(w, "Error adding exercise to workout", http.StatusInternalServerError)
-			return
-		}
-	}
-
-	// Commit the transaction
-	if err = tx.Commit(); err != nil {
-		http.Error(w, "Error committing transaction", http.StatusInternalServerError)
+	// Save the workout with all its exercises
+	// The store does this in one transaction, so either all changes succeed or none do
+	if err := s.Workouts.CreateWorkout(created); err != nil {
+		http.Error(w, "Error creating workout", http.StatusInternalServerError)
 		return
 	}
 
-	// Fetch and return the complete workout
-	workout, err := getWorkoutByID(workoutID, claims.UserID)
+	// Fetch and return the complete workout (with the exercise details)
+	workout, err := s.Workouts.GetWorkout(created.ID, userID)
 	if err != nil {
 		http.Error(w, "Error fetching created workout", http.StatusInternalServerError)
 		return
@@ -106,7 +181,7 @@ func CreateWorkout(w http.ResponseWriter, r *http.Request) {
 }
 
 // GetWorkouts returns all workouts for the logged-in user
-func GetWorkouts(w http.ResponseWriter, r *http.Request) {
+func (s *Server) GetWorkouts(w http.ResponseWriter, r *http.Request) {
 	// Get the current user from context
 	claims := middleware.GetUserFromContext(r)
 	if claims == nil {
@@ -114,53 +189,25 @@ func GetWorkouts(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Query workouts for this user
-	rows, err := database.DB.Query(`
-		SELECT id, user_id, name, description, created_at, updated_at 
-		FROM workouts 
-		WHERE user_id = $1 
-		ORDER BY created_at DESC
-	`, claims.UserID)
+	// On /api/coach/athletes/{athleteID}/... this is the athlete, see subjectUser
+	userID, ok := s.subjectUser(w, r, claims)
+	if !ok {
+		return
+	}
 
+	// Load this user's workouts with their exercises
+	workouts, err := s.Workouts.ListWorkouts(userID)
 	if err != nil {
 		http.Error(w, "Error fetching workouts", http.StatusInternalServerError)
 		return
 	}
-	defer rows.Close()
-
-	workouts := []models.Workout{}
-	for rows.Next() {
-		var workout models.Workout
-		err := rows.Scan(
-			&workout.ID,
-			&workout.UserID,
-			&workout.Name,
-			&workout.Description,
-			&workout.CreatedAt,
-			&workout.UpdatedAt,
-		)
-		if err != nil {
-			http.Error(w, "Error scanning workout", http.StatusInternalServerError)
-			return
-		}
-
-		// Load exercises for this workout
-		exercises, err := getWorkoutExercises(workout.ID)
-		if err != nil {
-			http.Error(w, "Error loading workout exercises", http.StatusInternalServerError)
-			return
-		}
-		workout.Exercises = exercises
-
-		workouts = append(workouts, workout)
-	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(workouts)
 }
 
 // GetWorkout returns a specific workout by ID
-func GetWorkout(w http.ResponseWriter, r *http.Request) {
+func (s *Server) GetWorkout(w http.ResponseWriter, r *http.Request) {
 	// Get the current user from context
 	claims := middleware.GetUserFromContext(r)
 	if claims == nil {
@@ -177,9 +224,9 @@ func GetWorkout(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Fetch the workout
-	workout, err := getWorkoutByID(workoutID, claims.UserID)
+	workout, err := s.Workouts.GetWorkout(workoutID, claims.UserID)
 	if err != nil {
-		if err == sql.ErrNoRows {
+		if errors.Is(err, store.ErrNotFound) {
 			http.Error(w, "Workout not found", http.StatusNotFound)
 			return
 		}
@@ -192,7 +239,7 @@ func GetWorkout(w http.ResponseWriter, r *http.Request) {
 }
 
 // UpdateWorkout updates an existing workout
-func UpdateWorkout(w http.ResponseWriter, r *http.Request) {
+func (s *Server) UpdateWorkout(w http.ResponseWriter, r *http.Request) {
 	// Get the current user from context
 	claims := middleware.GetUserFromContext(r)
 	if claims == nil {
@@ -215,60 +262,29 @@ func UpdateWorkout(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Start a transaction
-	tx, err := database.DB.Begin()
+	updated, err := req.toWorkout(workoutID, claims.UserID)
 	if err != nil {
-		http.Error(w, "Error starting transaction", http.StatusInternalServerError)
-		return
-	}
-	defer tx.Rollback()
-
-	// Update the workout
-	result, err := tx.Exec(
-		`UPDATE workouts SET name = $1, description = $2, updated_at = $3 
-		 WHERE id = $4 AND user_id = $5`,
-		req.Name, req.Description, time.Now(), workoutID, claims.UserID,
-	)
-	if err != nil {
-		http.Error(w, "Error updating workout", http.StatusInternalServerError)
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
-
-	// Check if the workout was found
-	rowsAffected, _ := result.RowsAffected()
-	if rowsAffected == 0 {
-		http.Error(w, "Workout not found", http.StatusNotFound)
+	if !s.checkExercises(w, claims.UserID, exerciseIDs(updated)) {
 		return
 	}
 
-	// Delete existing exercises
-	_, err = tx.Exec(`DELETE FROM workout_exercises WHERE workout_id = $1`, workoutID)
+	// Update the workout and replace its exercises
+	err = s.Workouts.UpdateWorkout(updated)
 	if err != nil {
-		http.Error(w, "Error removing old exercises", http.StatusInternalServerError)
-		return
-	}
-
-	// Insert new exercises
-	for _, exercise := range req.Exercises {
-		_, err = tx.Exec(
-			`INSERT INTO workout_exercises (workout_id, exercise_id, sets, reps, weight, notes) 
-			 VALUES ($1, $2, $3, $4, $5, $6)`,
-			workoutID, exercise.ExerciseID, exercise.Sets, exercise.Reps, exercise.Weight, exercise.Notes,
-		)
-		if err != nil {
-			http.Error(w, "Error adding exercise to workout", http.StatusInternalServerError)
+		// Check if the workout was found
+		if errors.Is(err, store.ErrNotFound) {
+			http.Error(w, "Workout not found", http.StatusNotFound)
 			return
 		}
-	}
-
-	// Commit the transaction
-	if err = tx.Commit(); err != nil {
-		http.Error(w, "Error committing transaction", http.StatusInternalServerError)
+		http.Error(w, "Error updating workout", http.StatusInternalServerError)
 		return
 	}
 
 	// Fetch and return the updated workout
-	workout, err := getWorkoutByID(workoutID, claims.UserID)
+	workout, err := s.Workouts.GetWorkout(workoutID, claims.UserID)
 	if err != nil {
 		http.Error(w, "Error fetching updated workout", http.StatusInternalServerError)
 		return
@@ -279,7 +295,7 @@ func UpdateWorkout(w http.ResponseWriter, r *http.Request) {
 }
 
 // DeleteWorkout deletes a workout
-func DeleteWorkout(w http.ResponseWriter, r *http.Request) {
+func (s *Server) DeleteWorkout(w http.ResponseWriter, r *http.Request) {
 	// Get the current user from context
 	claims := middleware.GetUserFromContext(r)
 	if claims == nil {
@@ -295,97 +311,17 @@ func DeleteWorkout(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Delete the workout (cascades to workout_exercises due to foreign key)
-	result, err := database.DB.Exec(
-		`DELETE FROM workouts WHERE id = $1 AND user_id = $2`,
-		workoutID, claims.UserID,
-	)
+	// Delete the workout (its exercises and schedules go with it)
+	err = s.Workouts.DeleteWorkout(workoutID, claims.UserID)
 	if err != nil {
+		// Check if the workout was found
+		if errors.Is(err, store.ErrNotFound) {
+			http.Error(w, "Workout not found", http.StatusNotFound)
+			return
+		}
 		http.Error(w, "Error deleting workout", http.StatusInternalServerError)
 		return
 	}
 
-	// Check if the workout was found
-	rowsAffected, _ := result.RowsAffected()
-	if rowsAffected == 0 {
-		http.Error(w, "Workout not found", http.StatusNotFound)
-		return
-	}
-
 	w.WriteHeader(http.StatusNoContent)
 }
-
-// Helper function to get a workout by ID
-func getWorkoutByID(workoutID, userID int) (*models.Workout, error) {
-	var workout models.Workout
-	err := database.DB.QueryRow(`
-		SELECT id, user_id, name, description, created_at, updated_at 
-		FROM workouts 
-		WHERE id = $1 AND user_id = $2
-	`, workoutID, userID).Scan(
-		&workout.ID,
-		&workout.UserID,
-		&workout.Name,
-		&workout.Description,
-		&workout.CreatedAt,
-		&workout.UpdatedAt,
-	)
-
-	if err != nil {
-		return nil, err
-	}
-
-	// Load exercises
-	exercises, err := getWorkoutExercises(workoutID)
-	if err != nil {
-		return nil, err
-	}
-	workout.Exercises = exercises
-
-	return &workout, nil
-}
-
-// Helper function to get exercises for a workout
-func getWorkoutExercises(workoutID int) ([]models.WorkoutExercise, error) {
-	rows, err := database.DB.Query(`
-		SELECT we.id, we.workout_id, we.exercise_id, we.sets, we.reps, we.weight, we.notes,
-		       e.id, e.name, e.description, e.category, e.muscle_group
-		FROM workout_exercises we
-		JOIN exercises e ON we.exercise_id = e.id
-		WHERE we.workout_id = $1
-	`, workoutID)
-
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-
-	exercises := []models.WorkoutExercise{}
-	for rows.Next() {
-		var we models.WorkoutExercise
-		var exercise models.Exercise
-
-		err := rows.Scan(
-			&we.ID,
-			&we.WorkoutID,
-			&we.ExerciseID,
-			&we.Sets,
-			&we.Reps,
-			&we.Weight,
-			&we.Notes,
-			&exercise.ID,
-			&exercise.Name,
-			&exercise.Description,
-			&exercise.Category,
-			&exercise.MuscleGroup,
-		)
-		if err != nil {
-			return nil, err
-		}
-
-		we.Exercise = &exercise
-		exercises = append(exercises, we)
-	}
-
-	return exercises, nil
-}
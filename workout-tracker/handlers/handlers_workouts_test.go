@@ -0,0 +1,113 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"workout-tracker/models"
+)
+
+// createWorkout creates a workout with one squat exercise and returns it
+func (a *testAPI) createWorkout(token, name string) models.Workout {
+	a.t.Helper()
+	rec := a.do("POST", "/api/workouts", token, CreateWorkoutRequest{
+		Name:      name,
+		Exercises: []CreateWorkoutExerciseRequest{{ExerciseID: a.squat.ID, Sets: 5, Reps: 5, Weight: 100}},
+	})
+	expect(a.t, rec, http.StatusCreated, "create workout "+name)
+	var workout models.Workout
+	decode(a.t, rec, &workout)
+	return workout
+}
+
+func TestWorkoutCRUD(t *testing.T) {
+	api := newTestAPI(t)
+	alice := api.register("alice")
+
+	created := api.createWorkout(alice.Token, "Leg day")
+	if created.UserID != alice.User.ID || len(created.Exercises) != 1 || created.Exercises[0].Exercise == nil ||
+		created.Exercises[0].Exercise.Name != "Squat" || created.AssignedBy != nil {
+		t.Fatalf("created workout = %+v", created)
+	}
+	path := fmt.Sprintf("/api/workouts/%d", created.ID)
+
+	rec := api.do("GET", "/api/workouts", alice.Token, nil)
+	expect(t, rec, http.StatusOK, "list workouts")
+	var list []models.Workout
+	decode(t, rec, &list)
+	if len(list) != 1 || list[0].ID != created.ID {
+		t.Errorf("list workouts = %+v", list)
+	}
+
+	rec = api.do("PUT", path, alice.Token, CreateWorkoutRequest{
+		Name: "Heavy leg day",
+		Exercises: []CreateWorkoutExerciseRequest{
+			{ExerciseID: api.squat.ID, Sets: 3, Reps: 3, Weight: 120},
+			{ExerciseID: api.squat.ID, Sets: 2, Reps: 10, Weight: 80},
+		},
+	})
+	expect(t, rec, http.StatusOK, "update workout")
+
+	rec = api.do("GET", path, alice.Token, nil)
+	expect(t, rec, http.StatusOK, "get workout")
+	var got models.Workout
+	decode(t, rec, &got)
+	if got.Name != "Heavy leg day" || len(got.Exercises) != 2 || got.Exercises[0].Weight != 120 || got.Exercises[1].Position != 2 {
+		t.Errorf("updated workout = %+v", got)
+	}
+
+	expect(t, api.do("DELETE", path, alice.Token, nil), http.StatusNoContent, "delete workout")
+	expect(t, api.do("GET", path, alice.Token, nil), http.StatusNotFound, "get deleted workout")
+	expect(t, api.do("DELETE", path, alice.Token, nil), http.StatusNotFound, "delete workout again")
+}
+
+func TestCreateWorkoutErrors(t *testing.T) {
+	api := newTestAPI(t)
+	alice := api.register("alice")
+	squat := api.squat.ID
+
+	tests := []struct {
+		name string
+		req  CreateWorkoutRequest
+	}{
+		{"no name", CreateWorkoutRequest{Exercises: []CreateWorkoutExerciseRequest{{ExerciseID: squat}}}},
+		{"unknown exercise", CreateWorkoutRequest{Name: "W", Exercises: []CreateWorkoutExerciseRequest{{ExerciseID: 9999}}}},
+		{"negative sets", CreateWorkoutRequest{Name: "W", Exercises: []CreateWorkoutExerciseRequest{{ExerciseID: squat, Sets: -1}}}},
+		{"group of one", CreateWorkoutRequest{Name: "W", Exercises: []CreateWorkoutExerciseRequest{{ExerciseID: squat, Group: "A"}}}},
+		{"group split up", CreateWorkoutRequest{Name: "W", Exercises: []CreateWorkoutExerciseRequest{
+			{ExerciseID: squat, Group: "A"}, {ExerciseID: squat}, {ExerciseID: squat, Group: "A"},
+		}}},
+		{"unknown group type", CreateWorkoutRequest{Name: "W", Exercises: []CreateWorkoutExerciseRequest{
+			{ExerciseID: squat, Group: "A", GroupType: "giant"}, {ExerciseID: squat, Group: "A", GroupType: "giant"},
+		}}},
+	}
+	for _, tt := range tests {
+		if rec := api.do("POST", "/api/workouts", alice.Token, tt.req); rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: status %d, want 400: %s", tt.name, rec.Code, rec.Body)
+		}
+	}
+}
+
+// Users only see and change their own workouts, the others are "not found"
+func TestWorkoutsOfOtherUsers(t *testing.T) {
+	api := newTestAPI(t)
+	alice := api.register("alice")
+	bob := api.register("bob")
+
+	workout := api.createWorkout(alice.Token, "Leg day")
+	path := fmt.Sprintf("/api/workouts/%d", workout.ID)
+
+	expect(t, api.do("GET", path, bob.Token, nil), http.StatusNotFound, "bob gets alice's workout")
+	expect(t, api.do("PUT", path, bob.Token, CreateWorkoutRequest{Name: "Mine now"}), http.StatusNotFound, "bob updates alice's workout")
+	expect(t, api.do("DELETE", path, bob.Token, nil), http.StatusNotFound, "bob deletes alice's workout")
+
+	rec := api.do("GET", "/api/workouts", bob.Token, nil)
+	expect(t, rec, http.StatusOK, "bob lists workouts")
+	var list []models.Workout
+	decode(t, rec, &list)
+	if len(list) != 0 {
+		t.Errorf("bob sees %d workouts, want 0", len(list))
+	}
+
+	expect(t, api.do("GET", path, alice.Token, nil), http.StatusOK, "alice gets her workout")
+}
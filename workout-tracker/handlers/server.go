@@ -0,0 +1,197 @@
+package handlers
+
+import (
+	"net/http"
+	"sync"
+	"time"
+	"workout-tracker/mailer"
+	"workout-tracker/middleware"
+	"workout-tracker/models"
+	"workout-tracker/store"
+
+	"github.com/gorilla/mux"
+)
+
+// Server holds everything the handlers need to do their job
+// Instead of using the global database.DB, each handler is a method on Server
+// and reads or writes data through the store interfaces.
+//
+// Why?
+// answer: In a test we can build a Server with store.NewMemory() and call it with httptest,
+// no PostgreSQL needed. The real server passes store.NewPostgres(database.DB) instead.
+type Server struct {
+	Users     store.UserStore
+	Exercises store.ExerciseStore
+	Coaching  store.CoachStore
+	Workouts  store.WorkoutStore
+	Schedules store.ScheduleStore
+	Progress  store.ProgressStore
+	Programs  store.ProgramStore
+	Calendar  store.CalendarStore
+	Sessions  store.SessionStore
+	Tokens    store.UserTokenStore
+
+	// Mailer sends the verification and password reset emails, NewServer only logs them
+	Mailer mailer.Mailer
+	// AppURL is where the links in our emails point to, e.g. "https://workouts.example.com"
+	AppURL string
+
+	// Rate limits for the endpoints that send emails
+	ipLimiter   *middleware.RateLimiter // per client IP
+	mailLimiter *middleware.RateLimiter // per email address or user
+
+	// background counts the emails that are sent after the response, see Wait
+	background sync.WaitGroup
+}
+
+// NewServer uses one store (Postgres or Memory) for every part of the API
+// Set Mailer and AppURL afterwards to send real emails
+func NewServer(s store.Store) *Server {
+	return &Server{
+		Users:     s,
+		Exercises: s,
+		Coaching:  s,
+		Workouts:  s,
+		Schedules: s,
+		Progress:  s,
+		Programs:  s,
+		Calendar:  s,
+		Sessions:  s,
+		Tokens:    s,
+
+		Mailer: mailer.Log{},
+		AppURL: "http://localhost:8080",
+
+		ipLimiter:   middleware.NewRateLimiter(10, time.Hour),
+		mailLimiter: middleware.NewRateLimiter(3, time.Hour),
+	}
+}
+
+// Wait blocks until the emails sent in the background are out
+func (s *Server) Wait() {
+	s.background.Wait()
+}
+
+// Routes configures all the API endpoints on the router
+// Tests can call it on a fresh mux.NewRouter() to get exactly the routes of the real server
+func (s *Server) Routes(router *mux.Router) {
+	// API prefix
+	//why we use PathPrefix here?
+	//  We use PathPrefix to group all API routes under the /api path.
+
+	api := router.PathPrefix("/api").Subrouter()
+
+	// protected wraps a handler in AuthMiddleware, which also checks our sessions for logouts
+	protected := func(next http.HandlerFunc) http.HandlerFunc {
+		return middleware.AuthMiddleware(s.Sessions, next)
+	}
+
+	// optional is for public routes that show more to a logged-in user, like their own exercises
+	optional := func(next http.HandlerFunc) http.HandlerFunc {
+		return middleware.OptionalAuthMiddleware(s.Sessions, next)
+	}
+
+	// Public routes (no authentication required)
+	api.HandleFunc("/register", s.Register).Methods("POST")
+	api.HandleFunc("/login", s.Login).Methods("POST")
+	api.HandleFunc("/refresh", s.Refresh).Methods("POST")
+	api.HandleFunc("/verify-email", s.VerifyEmail).Methods("POST")
+	api.HandleFunc("/password/forgot", s.ForgotPassword).Methods("POST")
+	api.HandleFunc("/password/reset", s.ResetPassword).Methods("POST")
+	// "/exercises/filters" comes before "/exercises/{id}", otherwise {id} would match "filters"
+	api.HandleFunc("/exercises", optional(s.GetExercises)).Methods("GET")
+	api.HandleFunc("/exercises/filters", optional(s.GetExerciseFilters)).Methods("GET")
+	api.HandleFunc("/exercises/{id}", optional(s.GetExercise)).Methods("GET")
+
+	// Protected routes (authentication required)
+	// These routes use the AuthMiddleware to verify JWT tokens (through protected)
+
+	// User routes
+	api.HandleFunc("/me", protected(s.GetCurrentUser)).Methods("GET")
+	api.HandleFunc("/logout", protected(s.Logout)).Methods("POST")
+	api.HandleFunc("/verify-email/resend", protected(s.ResendVerification)).Methods("POST")
+
+	// Custom exercise routes (the user's own exercises, the library is under /admin/exercises)
+	api.HandleFunc("/exercises", protected(s.CreateCustomExercise)).Methods("POST")
+	api.HandleFunc("/exercises/{id}", protected(s.UpdateCustomExercise)).Methods("PUT")
+	api.HandleFunc("/exercises/{id}", protected(s.DeleteCustomExercise)).Methods("DELETE")
+
+	// Workout routes
+	api.HandleFunc("/workouts", protected(s.CreateWorkout)).Methods("POST")
+	api.HandleFunc("/workouts", protected(s.GetWorkouts)).Methods("GET")
+	api.HandleFunc("/workouts/{id}", protected(s.GetWorkout)).Methods("GET")
+	api.HandleFunc("/workouts/{id}", protected(s.UpdateWorkout)).Methods("PUT")
+	api.HandleFunc("/workouts/{id}", protected(s.DeleteWorkout)).Methods("DELETE")
+
+	// Schedule routes
+	// "series" has to come before "{id}", mux uses the first route that matches
+	api.HandleFunc("/schedule/series", protected(s.CreateSeries)).Methods("POST")
+	api.HandleFunc("/schedule/series", protected(s.GetSeriesList)).Methods("GET")
+	api.HandleFunc("/schedule/series/{id}", protected(s.GetSeries)).Methods("GET")
+	api.HandleFunc("/schedule/series/{id}", protected(s.UpdateSeries)).Methods("PUT")
+	api.HandleFunc("/schedule/series/{id}", protected(s.DeleteSeries)).Methods("DELETE")
+	api.HandleFunc("/schedule", protected(s.CreateSchedule)).Methods("POST")
+	api.HandleFunc("/schedule", protected(s.GetSchedules)).Methods("GET")
+	api.HandleFunc("/schedule/{id}/complete", protected(s.CompleteSchedule)).Methods("POST")
+	api.HandleFunc("/schedule/{id}", protected(s.UpdateSchedule)).Methods("PUT")
+	api.HandleFunc("/schedule/{id}", protected(s.DeleteSchedule)).Methods("DELETE")
+
+	// Calendar feed routes
+	// The feed itself has no JWT, the secret token in the URL identifies the user
+	api.HandleFunc("/calendar/token", protected(s.CreateCalendarToken)).Methods("POST")
+	api.HandleFunc("/calendar/token", protected(s.DeleteCalendarToken)).Methods("DELETE")
+	api.HandleFunc("/calendar/{token:[0-9a-f]+}.ics", s.GetCalendarFeed).Methods("GET")
+
+	// Progress routes
+	api.HandleFunc("/progress", protected(s.GetProgress)).Methods("GET")
+	api.HandleFunc("/progress/exercise", protected(s.GetExerciseHistory)).Methods("GET")
+	api.HandleFunc("/progress/strength", protected(s.GetStrengthProgress)).Methods("GET")
+	api.HandleFunc("/progress/records", protected(s.GetPersonalRecords)).Methods("GET")
+	api.HandleFunc("/progress/volume", protected(s.GetWeeklyVolume)).Methods("GET")
+
+	// Coaching routes
+	// Any user can answer an invitation, only coaches (and admins) can send one.
+	// The coach routes call the normal handlers, subjectUser makes them use the athlete's data.
+	coach := func(next http.HandlerFunc) http.HandlerFunc {
+		return protected(s.requireRole(next, models.RoleCoach, models.RoleAdmin))
+	}
+	api.HandleFunc("/coaches", protected(s.GetCoaches)).Methods("GET")
+	api.HandleFunc("/coaches/{coachID}/accept", protected(s.AcceptCoach)).Methods("POST")
+	api.HandleFunc("/coaches/{coachID}", protected(s.RemoveCoach)).Methods("DELETE")
+	api.HandleFunc("/coach/athletes", coach(s.GetAthletes)).Methods("GET")
+	api.HandleFunc("/coach/athletes", coach(s.InviteAthlete)).Methods("POST")
+	api.HandleFunc("/coach/athletes/{athleteID}", coach(s.RemoveAthlete)).Methods("DELETE")
+	api.HandleFunc("/coach/athletes/{athleteID}/progress", coach(s.GetProgress)).Methods("GET")
+	api.HandleFunc("/coach/athletes/{athleteID}/progress/exercise", coach(s.GetExerciseHistory)).Methods("GET")
+	api.HandleFunc("/coach/athletes/{athleteID}/progress/strength", coach(s.GetStrengthProgress)).Methods("GET")
+	api.HandleFunc("/coach/athletes/{athleteID}/progress/records", coach(s.GetPersonalRecords)).Methods("GET")
+	api.HandleFunc("/coach/athletes/{athleteID}/progress/volume", coach(s.GetWeeklyVolume)).Methods("GET")
+	api.HandleFunc("/coach/athletes/{athleteID}/workouts", coach(s.GetWorkouts)).Methods("GET")
+	api.HandleFunc("/coach/athletes/{athleteID}/workouts", coach(s.CreateWorkout)).Methods("POST")
+	api.HandleFunc("/coach/athletes/{athleteID}/schedule", coach(s.GetSchedules)).Methods("GET")
+	api.HandleFunc("/coach/athletes/{athleteID}/schedule", coach(s.CreateSchedule)).Methods("POST")
+
+	// Admin routes
+	admin := func(next http.HandlerFunc) http.HandlerFunc {
+		return protected(s.requireRole(next, models.RoleAdmin))
+	}
+	api.HandleFunc("/admin/users", admin(s.GetUsers)).Methods("GET")
+	api.HandleFunc("/admin/users/{id}/role", admin(s.SetUserRole)).Methods("PUT")
+	api.HandleFunc("/admin/exercises", admin(s.CreateExercise)).Methods("POST")
+	api.HandleFunc("/admin/exercises/{id}", admin(s.UpdateExercise)).Methods("PUT")
+	api.HandleFunc("/admin/exercises/{id}", admin(s.DeleteExercise)).Methods("DELETE")
+
+	// Program routes
+	// "templates" has to come before "{id}", mux uses the first route that matches
+	api.HandleFunc("/programs/templates", s.GetProgramTemplates).Methods("GET")
+	api.HandleFunc("/programs", protected(s.ApplyProgram)).Methods("POST")
+	api.HandleFunc("/programs", protected(s.GetPrograms)).Methods("GET")
+	api.HandleFunc("/programs/{id}", protected(s.GetProgram)).Methods("GET")
+	api.HandleFunc("/programs/{id}", protected(s.DeleteProgram)).Methods("DELETE")
+
+	// Health check endpoint
+	api.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("OK"))
+	}).Methods("GET")
+}
@@ -0,0 +1,100 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"workout-tracker/mailer"
+	"workout-tracker/models"
+	"workout-tracker/store"
+
+	"github.com/gorilla/mux"
+)
+
+// testMailer keeps the sent mails instead of logging them
+type testMailer struct {
+	mu   sync.Mutex
+	sent []mailer.Message
+}
+
+func (m *testMailer) Send(msg mailer.Message) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sent = append(m.sent, msg)
+	return nil
+}
+
+// testAPI is the real router on a Server with an in-memory store
+type testAPI struct {
+	t      *testing.T
+	store  *store.Memory
+	server *Server
+	router *mux.Router
+	mail   *testMailer
+	squat  models.Exercise // in the library of every testAPI
+}
+
+func newTestAPI(t *testing.T) *testAPI {
+	t.Helper()
+	memory := store.NewMemory()
+	server := NewServer(memory)
+	mail := &testMailer{}
+	server.Mailer = mail
+
+	router := mux.NewRouter()
+	server.Routes(router)
+
+	squat := memory.AddExercise(models.Exercise{Name: "Squat", Category: "Legs", MuscleGroup: "Lower Body", Equipment: "Barbell"})
+	return &testAPI{t: t, store: memory, server: server, router: router, mail: mail, squat: squat}
+}
+
+// do sends a request, body is encoded as JSON unless it is nil
+// token is the access token, "" sends the request without an Authorization header
+func (a *testAPI) do(method, path, token string, body interface{}) *httptest.ResponseRecorder {
+	a.t.Helper()
+	var buf bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&buf).Encode(body); err != nil {
+			a.t.Fatalf("encoding the body of %s %s: %v", method, path, err)
+		}
+	}
+	req := httptest.NewRequest(method, path, &buf)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	rec := httptest.NewRecorder()
+	a.router.ServeHTTP(rec, req)
+	// some emails are sent after the response, wait so the tests can read them
+	a.server.Wait()
+	return rec
+}
+
+// register creates a user with the password "secret123" and returns its tokens
+func (a *testAPI) register(username string) LoginResponse {
+	a.t.Helper()
+	rec := a.do("POST", "/api/register", "", RegisterRequest{Username: username, Email: username + "@example.com", Password: "secret123"})
+	if rec.Code != http.StatusCreated {
+		a.t.Fatalf("register %s: status %d: %s", username, rec.Code, rec.Body)
+	}
+	var response LoginResponse
+	decode(a.t, rec, &response)
+	return response
+}
+
+// expect fails the test when the response doesn't have the status
+func expect(t *testing.T, rec *httptest.ResponseRecorder, status int, what string) {
+	t.Helper()
+	if rec.Code != status {
+		t.Fatalf("%s: status %d, want %d: %s", what, rec.Code, status, rec.Body)
+	}
+}
+
+func decode(t *testing.T, rec *httptest.ResponseRecorder, v interface{}) {
+	t.Helper()
+	if err := json.NewDecoder(rec.Body).Decode(v); err != nil {
+		t.Fatalf("decoding %q: %v", rec.Body, err)
+	}
+}
@@ -0,0 +1,116 @@
+package mailer
+
+import (
+	"fmt"
+	"log"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// The mailer package sends the emails of the API (verification and password reset).
+// Handlers only know the Mailer interface, so they don't care how a mail leaves the server:
+//   - SMTP - a real mail server, for production
+//   - Log  - writes the mail to a file or the log, for local development and tests
+
+// Message is one plain text email
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer sends a message
+type Mailer interface {
+	Send(msg Message) error
+}
+
+// format writes the message the way mail servers expect it (RFC 5322): headers, a blank line, the body
+// Lines end with CRLF
+func format(from string, msg Message, date time.Time) []byte {
+	lines := []string{
+		"From: " + from,
+		"To: " + msg.To,
+		"Subject: " + msg.Subject,
+		"Date: " + date.Format(time.RFC1123Z),
+		"MIME-Version: 1.0",
+		"Content-Type: text/plain; charset=UTF-8",
+		"",
+		msg.Body,
+	}
+	text := strings.Join(lines, "\n")
+	return []byte(strings.ReplaceAll(text, "\n", "\r\n"))
+}
+
+// checkHeader makes sure a value can't add its own header lines (header injection)
+func checkHeader(value string) error {
+	if strings.ContainsAny(value, "\r\n") {
+		return fmt.Errorf("invalid line break in %q", value)
+	}
+	return nil
+}
+
+// SMTP sends mail through an SMTP server, e.g. smtp.gmail.com:587
+type SMTP struct {
+	Host     string
+	Port     int
+	Username string // leave empty for servers without login (like a local relay)
+	Password string
+	From     string // "Workout Tracker <noreply@example.com>" or just the address
+}
+
+// Send sends the message with net/smtp, which uses STARTTLS when the server offers it
+func (s SMTP) Send(msg Message) error {
+	if err := checkHeader(msg.To); err != nil {
+		return err
+	}
+	if err := checkHeader(msg.Subject); err != nil {
+		return err
+	}
+
+	var auth smtp.Auth
+	if s.Username != "" {
+		auth = smtp.PlainAuth("", s.Username, s.Password, s.Host)
+	}
+
+	// The envelope sender is only the address part of From
+	from := s.From
+	if start := strings.Index(from, "<"); start >= 0 {
+		from = strings.Trim(from[start:], "<>")
+	}
+
+	addr := fmt.Sprintf("%s:%d", s.Host, s.Port)
+	return smtp.SendMail(addr, auth, from, []string{msg.To}, format(s.From, msg, time.Now()))
+}
+
+// Log doesn't send anything: it writes every mail to a file in Dir, or to the log when Dir is empty
+// Open the file to click the link in a verification or reset mail during development
+type Log struct {
+	Dir string
+}
+
+// Send writes the message
+func (l Log) Send(msg Message) error {
+	if err := checkHeader(msg.To); err != nil {
+		return err
+	}
+	if err := checkHeader(msg.Subject); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	text := format("workout-tracker", msg, now)
+	if l.Dir == "" {
+		log.Printf("mail to %s:\n%s", msg.To, text)
+		return nil
+	}
+
+	if err := os.MkdirAll(l.Dir, 0o755); err != nil {
+		return err
+	}
+	// The time in nanoseconds keeps the names unique and sorted
+	name := fmt.Sprintf("%d-%s.eml", now.UnixNano(), strings.NewReplacer("@", "_at_", "/", "_").Replace(msg.To))
+	return os.WriteFile(filepath.Join(l.Dir, name), text, 0o644)
+}
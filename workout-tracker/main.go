@@ -1,6 +1,7 @@
 package main
 
 import (
+	"fmt"
 	"log"
 	"net/http"
 	"os"
@@ -8,8 +9,11 @@ import (
 	"workout-tracker/auth"
 	"workout-tracker/database"
 	"workout-tracker/handlers"
+	"workout-tracker/mailer"
 	"workout-tracker/middleware"
+	"workout-tracker/models"
 	"workout-tracker/seeder"
+	"workout-tracker/store"
 
 	"github.com/gorilla/mux"
 	"github.com/joho/godotenv"
@@ -43,12 +47,30 @@ func main() {
 	}
 	defer database.Close()
 
-	// Initialize database schema (create tables if they don't exist)
+	// "go run . migrate up|down|status" manages the schema and exits without starting the server
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := runMigrate(os.Args[2:]); err != nil {
+			database.Close()
+			log.Fatal("Migration failed: ", err)
+		}
+		return
+	}
+
+	// Apply any pending migrations (see database/migrations)
 	err = database.InitializeSchema()
 	if err != nil {
 		log.Fatal("Failed to initialize schema:", err)
 	}
 
+	// "go run . role <username> <role>" gives a user a role and exits, this is how the first admin is made
+	if len(os.Args) > 1 && os.Args[1] == "role" {
+		if err := runRole(os.Args[2:]); err != nil {
+			database.Close()
+			log.Fatal("Setting role failed: ", err)
+		}
+		return
+	}
+
 	// Seed exercises into the database
 	err = seeder.SeedExercises(database.DB)
 	if err != nil {
@@ -89,7 +111,11 @@ func main() {
 	})
 
 	// Set up API routes
-	setupRoutes(router)
+	// The handlers read and write data through the Postgres store
+	server := handlers.NewServer(store.NewPostgres(database.DB))
+	server.Mailer = loadMailer()
+	server.AppURL = getEnv("APP_URL", "http://localhost:"+getEnv("SERVER_PORT", "8080"))
+	server.Routes(router)
 
 	// Serve static files (HTML, CSS, JS)
 	// The PathPrefix tells the router to handle all URLs starting with /static
@@ -116,47 +142,85 @@ func main() {
 	log.Fatal(http.ListenAndServe(":"+port, router))
 }
 
-// setupRoutes configures all the API endpoints
-func setupRoutes(router *mux.Router) {
-	// API prefix
-	//why we use PathPrefix here?
-	//  We use PathPrefix to group all API routes under the /api path.
-
-	api := router.PathPrefix("/api").Subrouter()
-
-	// Public routes (no authentication required)
-	api.HandleFunc("/register", handlers.Register).Methods("POST")
-	api.HandleFunc("/login", handlers.Login).Methods("POST")
-	api.HandleFunc("/exercises", handlers.GetExercises).Methods("GET")
-
-	// Protected routes (authentication required)
-	// These routes use the AuthMiddleware to verify JWT tokens
-
-	// User routes
-	api.HandleFunc("/me", middleware.AuthMiddleware(handlers.GetCurrentUser)).Methods("GET")
-
-	// Workout routes
-	api.HandleFunc("/workouts", middleware.AuthMiddleware(handlers.CreateWorkout)).Methods("POST")
-	api.HandleFunc("/workouts", middleware.AuthMiddleware(handlers.GetWorkouts)).Methods("GET")
-	api.HandleFunc("/workouts/{id}", middleware.AuthMiddleware(handlers.GetWorkout)).Methods("GET")
-	api.HandleFunc("/workouts/{id}", middleware.AuthMiddleware(handlers.UpdateWorkout)).Methods("PUT")
-	api.HandleFunc("/workouts/{id}", middleware.AuthMiddleware(handlers.DeleteWorkout)).Methods("DELETE")
-
-	// Schedule routes
-	api.HandleFunc("/schedule", middleware.AuthMiddleware(handlers.CreateSchedule)).Methods("POST")
-	api.HandleFunc("/schedule", middleware.AuthMiddleware(handlers.GetSchedules)).Methods("GET")
-	api.HandleFunc("/schedule/{id}/complete", middleware.AuthMiddleware(handlers.CompleteSchedule)).Methods("POST")
-	api.HandleFunc("/schedule/{id}", middleware.AuthMiddleware(handlers.DeleteSchedule)).Methods("DELETE")
-
-	// Progress routes
-	api.HandleFunc("/progress", middleware.AuthMiddleware(handlers.GetProgress)).Methods("GET")
-	api.HandleFunc("/progress/exercise", middleware.AuthMiddleware(handlers.GetExerciseHistory)).Methods("GET")
-
-	// Health check endpoint
-	api.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
-		w.WriteHeader(http.StatusOK)
-		w.Write([]byte("OK"))
-	}).Methods("GET")
+// runMigrate handles the migrate subcommand
+//
+//	migrate up [n]    - apply all pending migrations, or only the next n
+//	migrate down [n]  - revert the last applied migration, or the last n
+//	migrate status    - list every migration and whether it is applied
+func runMigrate(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: migrate up [n] | down [n] | status")
+	}
+
+	// Optional number of steps, up defaults to all (0) and down to just one
+	steps := 0
+	if args[0] == "down" {
+		steps = 1
+	}
+	if len(args) > 1 {
+		n, err := strconv.Atoi(args[1])
+		if err != nil || n < 1 {
+			return fmt.Errorf("invalid number of steps: %s", args[1])
+		}
+		steps = n
+	}
+
+	switch args[0] {
+	case "up":
+		applied, err := database.MigrateUp(database.DB, steps)
+		if err != nil {
+			return err
+		}
+		log.Printf("%d migration(s) applied", applied)
+	case "down":
+		reverted, err := database.MigrateDown(database.DB, steps)
+		if err != nil {
+			return err
+		}
+		log.Printf("%d migration(s) reverted", reverted)
+	case "status":
+		status, err := database.Status(database.DB)
+		if err != nil {
+			return err
+		}
+		for _, m := range status {
+			state := "pending"
+			if m.AppliedAt != nil {
+				state = "applied " + m.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Printf("%04d  %-30s %s\n", m.Version, m.Name, state)
+		}
+	default:
+		return fmt.Errorf("unknown migrate command %q, use up, down or status", args[0])
+	}
+	return nil
+}
+
+// runRole handles the role subcommand
+//
+//	role <username> athlete|coach|admin
+//
+// Why not an API endpoint?
+// answer: Only admins may change roles, so the very first admin has to be made from the server itself.
+func runRole(args []string) error {
+	if len(args) != 2 {
+		return fmt.Errorf("usage: role <username> athlete|coach|admin")
+	}
+	username, role := args[0], args[1]
+	if role != models.RoleAthlete && role != models.RoleCoach && role != models.RoleAdmin {
+		return fmt.Errorf("unknown role %q, use athlete, coach or admin", role)
+	}
+
+	users := store.NewPostgres(database.DB)
+	user, err := users.GetUserByUsername(username)
+	if err != nil {
+		return fmt.Errorf("user %q: %w", username, err)
+	}
+	if err := users.SetRole(user.ID, role); err != nil {
+		return err
+	}
+	log.Printf("%s is now %s", username, role)
+	return nil
 }
 
 // loadConfig loads configuration from environment variables
@@ -184,13 +248,19 @@ func loadConfig() database.Config {
 	jwtSecret := getEnv("JWT_SECRET", "your-secret-key-change-this-in-production")
 	auth.JWTSecret = []byte(jwtSecret)
 
-	jwtExpirationStr := getEnv("JWT_EXPIRATION_HOURS", "24")
-	jwtExpiration, err := strconv.Atoi(jwtExpirationStr)
-	if err != nil {
-		log.Printf("Invalid JWT_EXPIRATION_HOURS, using default: 24")
-		jwtExpiration = 24
+	accessMinutes, err := strconv.Atoi(getEnv("ACCESS_TOKEN_MINUTES", "15"))
+	if err != nil || accessMinutes < 1 {
+		log.Printf("Invalid ACCESS_TOKEN_MINUTES, using default: 15")
+		accessMinutes = 15
 	}
-	auth.TokenExpirationHours = jwtExpiration
+	auth.AccessTokenMinutes = accessMinutes
+
+	refreshDays, err := strconv.Atoi(getEnv("REFRESH_TOKEN_DAYS", "30"))
+	if err != nil || refreshDays < 1 {
+		log.Printf("Invalid REFRESH_TOKEN_DAYS, using default: 30")
+		refreshDays = 30
+	}
+	auth.RefreshTokenDays = refreshDays
 
 	return database.Config{
 		Host:     host,
@@ -202,6 +272,35 @@ func loadConfig() database.Config {
 	}
 }
 
+// loadMailer picks how emails are sent
+// With SMTP_HOST set they go to that mail server, otherwise they are written to
+// files in MAIL_DIR (or to the log when MAIL_DIR is empty too), handy for local development
+func loadMailer() mailer.Mailer {
+	host := getEnv("SMTP_HOST", "")
+	if host == "" {
+		dir := getEnv("MAIL_DIR", "")
+		if dir == "" {
+			log.Println("SMTP_HOST not set, emails are written to the log")
+		} else {
+			log.Printf("SMTP_HOST not set, emails are written to %s", dir)
+		}
+		return mailer.Log{Dir: dir}
+	}
+
+	port, err := strconv.Atoi(getEnv("SMTP_PORT", "587"))
+	if err != nil {
+		log.Printf("Invalid SMTP_PORT, using default: 587")
+		port = 587
+	}
+	return mailer.SMTP{
+		Host:     host,
+		Port:     port,
+		Username: getEnv("SMTP_USERNAME", ""),
+		Password: getEnv("SMTP_PASSWORD", ""),
+		From:     getEnv("MAIL_FROM", "Workout Tracker <noreply@localhost>"),
+	}
+}
+
 // getEnv gets an environment variable with a fallback default value
 func getEnv(key, defaultValue string) string {
 	value := os.Getenv(key)
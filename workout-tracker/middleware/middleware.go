@@ -2,8 +2,11 @@ package middleware
 
 import (
 	"context"
+	"net"
 	"net/http"
 	"strings"
+	"sync"
+	"time"
 	"workout-tracker/auth"
 )
 
@@ -17,10 +20,20 @@ type contextKey string
 
 const UserContextKey contextKey = "user"
 
+// SessionChecker tells whether a login session is still active (not logged out)
+// store.SessionStore has this method, the middleware only needs this one
+type SessionChecker interface {
+	SessionActive(sessionID int) (bool, error)
+}
+
 // AuthMiddleware checks if the request has a valid JWT token
 // If valid, it adds the user info to the request context
 // If invalid, it returns a 401 Unauthorized response
-func AuthMiddleware(next http.HandlerFunc) http.HandlerFunc {
+//
+// Why do we check the session when the JWT is already valid?
+// answer: A JWT stays valid until it expires, the server can't take it back.
+// The session check makes logout (and a stolen refresh token) stop the access token right away.
+func AuthMiddleware(sessions SessionChecker, next http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		// Get the Authorization header
 		// Expected format: "Bearer <token>"
@@ -46,6 +59,17 @@ func AuthMiddleware(next http.HandlerFunc) http.HandlerFunc {
 			return
 		}
 
+		// Make sure the session wasn't logged out or revoked
+		active, err := sessions.SessionActive(claims.SessionID)
+		if err != nil {
+			http.Error(w, "Error checking session", http.StatusInternalServerError)
+			return
+		}
+		if !active {
+			http.Error(w, "Session was logged out", http.StatusUnauthorized)
+			return
+		}
+
 		// Add the user info to the request context
 		// This allows handlers to access the current user's information
 		ctx := context.WithValue(r.Context(), UserContextKey, claims)
@@ -55,6 +79,20 @@ func AuthMiddleware(next http.HandlerFunc) http.HandlerFunc {
 	}
 }
 
+// OptionalAuthMiddleware is AuthMiddleware for endpoints that also work without logging in
+// Without an Authorization header the request goes on with no user in the context.
+// With one, the token has to be valid: a client that sends an expired token gets 401 and knows to refresh it.
+func OptionalAuthMiddleware(sessions SessionChecker, next http.HandlerFunc) http.HandlerFunc {
+	withAuth := AuthMiddleware(sessions, next)
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") == "" {
+			next(w, r)
+			return
+		}
+		withAuth(w, r)
+	}
+}
+
 // GetUserFromContext extracts the user claims from the request context
 // This should be called in handlers that are protected by AuthMiddleware
 func GetUserFromContext(r *http.Request) *auth.Claims {
@@ -83,3 +121,60 @@ func CORSMiddleware(next http.HandlerFunc) http.HandlerFunc {
 		next(w, r)
 	}
 }
+
+// RateLimiter allows a number of events per key (an IP address, an email...) in a time window
+// It lives in memory, so the counts start over when the server restarts
+type RateLimiter struct {
+	mu     sync.Mutex
+	limit  int
+	window time.Duration
+	events map[string][]time.Time
+	swept  time.Time // when the keys without recent events were last removed
+}
+
+// NewRateLimiter allows limit events per key in every window, e.g. 3 per hour
+func NewRateLimiter(limit int, window time.Duration) *RateLimiter {
+	return &RateLimiter{limit: limit, window: window, events: map[string][]time.Time{}}
+}
+
+// Allow records an event for the key and tells whether it is within the limit
+// Events that were refused don't count
+func (l *RateLimiter) Allow(key string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	// Once per window forget the keys without recent events, or the map grows with every new key
+	now := time.Now()
+	if now.Sub(l.swept) >= l.window {
+		for k, events := range l.events {
+			if len(events) == 0 || now.Sub(events[len(events)-1]) >= l.window {
+				delete(l.events, k)
+			}
+		}
+		l.swept = now
+	}
+
+	// Forget the events that are older than the window
+	recent := []time.Time{}
+	for _, t := range l.events[key] {
+		if now.Sub(t) < l.window {
+			recent = append(recent, t)
+		}
+	}
+	if len(recent) >= l.limit {
+		l.events[key] = recent
+		return false
+	}
+	l.events[key] = append(recent, now)
+	return true
+}
+
+// ClientIP returns the IP address of the client, without the port
+// Behind a proxy every request comes from the proxy, so this is only as good as the setup
+func ClientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
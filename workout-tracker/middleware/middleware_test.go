@@ -0,0 +1,32 @@
+package middleware
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRateLimiter(t *testing.T) {
+	l := NewRateLimiter(2, time.Hour)
+	for i, want := range []bool{true, true, false, false} {
+		if got := l.Allow("a"); got != want {
+			t.Errorf("event %d: Allow = %v, want %v", i+1, got, want)
+		}
+	}
+	if !l.Allow("b") {
+		t.Error("the limit of one key counts for another")
+	}
+}
+
+func TestRateLimiterForgetsOldKeys(t *testing.T) {
+	l := NewRateLimiter(1, 20*time.Millisecond)
+	l.Allow("a")
+	l.Allow("b")
+	time.Sleep(30 * time.Millisecond)
+
+	if !l.Allow("a") {
+		t.Error("an event older than the window still counts")
+	}
+	if _, ok := l.events["b"]; ok || len(l.events) != 1 {
+		t.Errorf("keys after the window: %v, want only a", l.events)
+	}
+}
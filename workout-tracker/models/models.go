@@ -5,23 +5,99 @@ import "time"
 // User represents a registered user in the system
 // Each user has their own workouts and progress tracking
 type User struct {
-	ID           int       `json:"id"`
-	Username     string    `json:"username"`
-	Email        string    `json:"email"`
-	PasswordHash string    `json:"-"` // "-" means this field won't be included in JSON responses
-	CreatedAt    time.Time `json:"created_at"`
+	ID            int       `json:"id"`
+	Username      string    `json:"username"`
+	Email         string    `json:"email"`
+	PasswordHash  string    `json:"-"` // "-" means this field won't be included in JSON responses
+	EmailVerified bool      `json:"email_verified"`
+	Role          string    `json:"role"` // RoleAthlete, RoleCoach or RoleAdmin
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// User roles
+// Athletes only see their own data, coaches also see the athletes who accepted them,
+// admins manage the users' roles and the exercise library
+const (
+	RoleAthlete = "athlete"
+	RoleCoach   = "coach"
+	RoleAdmin   = "admin"
+)
+
+// Status of a Coaching link
+const (
+	CoachingPending = "pending" // invited by the coach, not accepted yet
+	CoachingActive  = "active"
+)
+
+// Coaching links a coach to an athlete
+type Coaching struct {
+	CoachID    int        `json:"coach_id"`
+	AthleteID  int        `json:"athlete_id"`
+	Status     string     `json:"status"`
+	CreatedAt  time.Time  `json:"created_at"`
+	AcceptedAt *time.Time `json:"accepted_at"`
+	Coach      *User      `json:"coach,omitempty"`   // filled in when an athlete lists their coaches
+	Athlete    *User      `json:"athlete,omitempty"` // filled in when a coach lists their athletes
+}
+
+// What a UserToken is for
+const (
+	TokenVerifyEmail   = "verify_email"
+	TokenResetPassword = "reset_password"
+)
+
+// UserToken is a single-use token we send by email, to verify the address or reset the password
+// Only its hash is stored, the token itself is only in the email
+type UserToken struct {
+	TokenHash string     `json:"-"`
+	UserID    int        `json:"user_id"`
+	Purpose   string     `json:"purpose"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// Session is one login of a user (one browser or phone)
+// Logging out revokes the session, and with it every token that belongs to it
+type Session struct {
+	ID        int        `json:"id"`
+	UserID    int        `json:"user_id"`
+	CreatedAt time.Time  `json:"created_at"`
+	RevokedAt *time.Time `json:"revoked_at"` // nil while the session is active
+}
+
+// RefreshToken is one token of a session's token family
+// Only its hash is stored, the token itself is only ever sent to the client
+type RefreshToken struct {
+	TokenHash string     `json:"-"`
+	SessionID int        `json:"session_id"`
+	UserID    int        `json:"user_id"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at"` // set when it was swapped for a new token
+	CreatedAt time.Time  `json:"created_at"`
 }
 
 // Exercise represents a single exercise from our predefined library
 // Examples: Push-ups, Squats, Bench Press, etc.
 type Exercise struct {
-	ID          int    `json:"id"`
-	Name        string `json:"name"`
-	Description string `json:"description"`
-	Category    string `json:"category"`     // e.g., "Chest", "Legs", "Back", "Arms"
-	MuscleGroup string `json:"muscle_group"` // e.g., "Upper Body", "Lower Body", "Core"
+	ID          int      `json:"id"`
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Category    string   `json:"category"`     // e.g., "Chest", "Legs", "Back", "Arms"
+	MuscleGroup string   `json:"muscle_group"` // e.g., "Upper Body", "Lower Body", "Core"
+	Equipment   string   `json:"equipment"`    // e.g., "Barbell", "Dumbbell", "Bodyweight", "Machine"
+	Difficulty  string   `json:"difficulty"`   // DifficultyBeginner, DifficultyIntermediate, DifficultyAdvanced or ""
+	Aliases     []string `json:"aliases"`      // other names to search for, e.g. "RDL"
+	UserID      *int     `json:"user_id"`      // the owner of a custom exercise (null for the library everyone shares)
 }
 
+// Difficulty of an Exercise
+const (
+	DifficultyBeginner     = "beginner"
+	DifficultyIntermediate = "intermediate"
+	DifficultyAdvanced     = "advanced"
+)
+
 // Workout represents a collection of exercises that a user plans to do
 // Think of it as a "workout plan" or "routine"
 type Workout struct {
@@ -30,21 +106,35 @@ type Workout struct {
 	Name        string            `json:"name"`        // e.g., "Morning Chest Day", "Leg Workout"
 	Description string            `json:"description"` // Optional notes about the workout
 	Exercises   []WorkoutExercise `json:"exercises"`   // The exercises included in this workout
+	AssignedBy  *int              `json:"assigned_by"` // The coach who made it for the user (null if the user did)
 	CreatedAt   time.Time         `json:"created_at"`
 	UpdatedAt   time.Time         `json:"updated_at"`
 }
 
+// Group types for WorkoutExercise.GroupType
+const (
+	GroupSuperset = "superset" // two or more exercises back-to-back, then rest
+	GroupCircuit  = "circuit"  // a round of several exercises, repeated "sets" times
+)
+
 // WorkoutExercise links an exercise to a workout with specific details
 // This is the "join" between workouts and exercises, with extra info
+//
+// Supersets and circuits: exercises next to each other with the same Group
+// (e.g. "A") are done back-to-back without resting in between.
 type WorkoutExercise struct {
-	ID         int       `json:"id"`
-	WorkoutID  int       `json:"workout_id"`
-	ExerciseID int       `json:"exercise_id"`
-	Sets       int       `json:"sets"`               // Number of sets to perform
-	Reps       int       `json:"reps"`               // Number of repetitions per set
-	Weight     float64   `json:"weight"`             // Weight in kg or lbs
-	Notes      string    `json:"notes"`              // Any additional notes
-	Exercise   *Exercise `json:"exercise,omitempty"` // The actual exercise details (populated when needed)
+	ID          int       `json:"id"`
+	WorkoutID   int       `json:"workout_id"`
+	ExerciseID  int       `json:"exercise_id"`
+	Position    int       `json:"position"`             // Order in the workout, starting at 1
+	Sets        int       `json:"sets"`                 // Number of sets to perform
+	Reps        int       `json:"reps"`                 // Number of repetitions per set
+	Weight      float64   `json:"weight"`               // Weight in kg or lbs
+	Notes       string    `json:"notes"`                // Any additional notes
+	Group       string    `json:"group,omitempty"`      // Superset/circuit name, empty for a normal exercise
+	GroupType   string    `json:"group_type,omitempty"` // GroupSuperset or GroupCircuit
+	RestSeconds int       `json:"rest_seconds"`         // Rest after the exercise (after the whole group when grouped)
+	Exercise    *Exercise `json:"exercise,omitempty"`   // The actual exercise details (populated when needed)
 }
 
 // Schedule represents when a user plans to do a specific workout
@@ -57,21 +147,205 @@ type Schedule struct {
 	Completed     bool       `json:"completed"`         // Whether the workout was done
 	CompletedAt   *time.Time `json:"completed_at"`      // When it was actually completed (null if not done)
 	Notes         string     `json:"notes"`             // Post-workout notes
+	SeriesID      *int       `json:"series_id"`         // The recurring series this is part of (null for a one-off)
+	SeriesDate    *time.Time `json:"series_date"`       // The date the series planned it for, even if it was moved
+	Detached      bool       `json:"detached"`          // Edited on its own, editing the series leaves it alone
+	AssignedBy    *int       `json:"assigned_by"`       // The coach who scheduled it (null if the user did)
 	Workout       *Workout   `json:"workout,omitempty"` // The workout details (populated when needed)
 }
 
+// ScheduleSeries is a recurring schedule: one workout on the same weekdays for a number of weeks,
+// e.g. "Mon/Wed/Fri at 07:00 for 8 weeks". Every occurrence is a normal Schedule.
+type ScheduleSeries struct {
+	ID          int         `json:"id"`
+	UserID      int         `json:"user_id"`
+	WorkoutID   int         `json:"workout_id"`
+	Weekdays    []string    `json:"weekdays"` // "mon", "wed", "fri"
+	Time        string      `json:"time"`     // "07:00" (UTC)
+	StartDate   time.Time   `json:"start_date"`
+	Weeks       int         `json:"weeks"`
+	Notes       string      `json:"notes"`
+	Exceptions  []time.Time `json:"exceptions"` // series dates of occurrences that were deleted one by one
+	CreatedAt   time.Time   `json:"created_at"`
+	Occurrences []Schedule  `json:"occurrences,omitempty"`
+}
+
 // WorkoutLog tracks the actual performance of a workout
 // This records what the user actually did (may differ from the plan)
+//
+// Sets holds every set that was done. The three totals are a summary of the
+// working sets (warm-ups don't count), kept for older clients:
+// SetsCompleted is how many there were, RepsCompleted and WeightUsed come from the heaviest one.
 type WorkoutLog struct {
+	ID            int          `json:"id"`
+	ScheduleID    int          `json:"schedule_id"`
+	ExerciseID    int          `json:"exercise_id"`
+	SetsCompleted int          `json:"sets_completed"`
+	RepsCompleted int          `json:"reps_completed"`
+	WeightUsed    float64      `json:"weight_used"`
+	Duration      int          `json:"duration"` // Duration in minutes
+	Notes         string       `json:"notes"`
+	LoggedAt      time.Time    `json:"logged_at"`
+	Sets          []WorkoutSet `json:"sets"`
+}
+
+// WorkoutSet is one set of a logged exercise
+//
+// RPE vs RIR?
+// answer: Both say how hard the set was. RPE (rate of perceived exertion) goes from 1 to 10,
+// where 10 means nothing was left. RIR (reps in reserve) counts how many more reps you could have done,
+// so RPE 8 is about the same as RIR 2. Both are optional, that's why they are pointers (nil = not given).
+type WorkoutSet struct {
+	ID           int      `json:"id"`
+	WorkoutLogID int      `json:"workout_log_id"`
+	SetNumber    int      `json:"set_number"` // 1 for the first set
+	Reps         int      `json:"reps"`
+	Weight       float64  `json:"weight"`
+	RPE          *float64 `json:"rpe,omitempty"`
+	RIR          *int     `json:"rir,omitempty"`
+	RestSeconds  int      `json:"rest_seconds"` // Rest taken after this set
+	IsWarmup     bool     `json:"is_warmup"`
+}
+
+// ExerciseHistoryEntry is one logged exercise in the history of that exercise
+type ExerciseHistoryEntry struct {
+	ScheduleID    int          `json:"schedule_id"`
+	SetsCompleted int          `json:"sets_completed"`
+	RepsCompleted int          `json:"reps_completed"`
+	WeightUsed    float64      `json:"weight_used"`
+	Duration      int          `json:"duration"`
+	Notes         string       `json:"notes"`
+	LoggedAt      time.Time    `json:"logged_at"`
+	ExerciseName  string       `json:"exercise_name"`
+	Category      string       `json:"category"`
+	Sets          []WorkoutSet `json:"sets"`
+}
+
+// Program statuses
+const (
+	ProgramActive   = "active"
+	ProgramFinished = "finished" // no planned sessions left
+)
+
+// Program session statuses
+const (
+	SessionPlanned = "planned"
+	SessionDone    = "done"
+	SessionFailed  = "failed"  // completed, but a lift fell short of its target reps or weight
+	SessionSkipped = "skipped" // never done, and a later session was done already
+)
+
+// ProgramTemplate describes a multi-week program like 5/3/1 or linear progression
+// The built-in templates live in the programs package, users can also send their own
+type ProgramTemplate struct {
+	Key         string         `json:"key"`
+	Name        string         `json:"name"`
+	Description string         `json:"description"`
+	MaxPercent  float64        `json:"max_percent"`           // training max = estimated one-rep max x MaxPercent
+	Increment   float64        `json:"increment"`             // added to the training max after every successful session (0 = none)
+	CycleWeeks  int            `json:"cycle_weeks,omitempty"` // when set, Increment is added once per cycle of this many weeks instead
+	OnFail      string         `json:"on_fail"`               // "repeat" or "reset", see the programs package
+	Weeks       []TemplateWeek `json:"weeks"`
+}
+
+// TemplateWeek is what every main lift gets on each training day of that week
+type TemplateWeek struct {
+	Deload bool          `json:"deload"` // a lighter week, it doesn't move the training max
+	Sets   []TemplateSet `json:"sets"`
+}
+
+// TemplateSet is one target set, the weight is a percentage of the training max
+type TemplateSet struct {
+	Reps    int     `json:"reps"`
+	Percent float64 `json:"percent"` // 0.85 means 85% of the training max
+	AMRAP   bool    `json:"amrap"`   // as many reps as possible, Reps is the minimum
+}
+
+// Program is a template applied for one user
+// Every session is a normal Workout with its own Schedule, so it shows up in the calendar
+type Program struct {
+	ID        int              `json:"id"`
+	UserID    int              `json:"user_id"`
+	Name      string           `json:"name"`
+	Template  ProgramTemplate  `json:"template"`
+	StartDate time.Time        `json:"start_date"`
+	Rounding  float64          `json:"rounding"` // target weights are rounded to this, e.g. 2.5
+	Status    string           `json:"status"`
+	Lifts     []ProgramLift    `json:"lifts"`
+	Sessions  []ProgramSession `json:"sessions"`
+	CreatedAt time.Time        `json:"created_at"`
+}
+
+// ProgramLift is a main lift of a program
+type ProgramLift struct {
+	ExerciseID  int     `json:"exercise_id"`
+	TrainingMax float64 `json:"training_max"` // the weight the percentages are taken from, it moves as sessions are done
+	Failures    int     `json:"failures"`     // failed sessions in a row
+}
+
+// ProgramSession is one planned training day of a program
+type ProgramSession struct {
 	ID            int       `json:"id"`
+	ProgramID     int       `json:"program_id"`
+	WorkoutID     int       `json:"workout_id"`
 	ScheduleID    int       `json:"schedule_id"`
-	ExerciseID    int       `json:"exercise_id"`
-	SetsCompleted int       `json:"sets_completed"`
-	RepsCompleted int       `json:"reps_completed"`
-	WeightUsed    float64   `json:"weight_used"`
-	Duration      int       `json:"duration"` // Duration in minutes
-	Notes         string    `json:"notes"`
-	LoggedAt      time.Time `json:"logged_at"`
+	Week          int       `json:"week"` // 1 for the first week
+	Day           int       `json:"day"`  // 1 for the first training day of the week
+	Deload        bool      `json:"deload"`
+	Status        string    `json:"status"`
+	Note          string    `json:"note"` // why the session was moved or changed
+	ScheduledDate time.Time `json:"scheduled_date"`
+	Workout       *Workout  `json:"workout,omitempty"`
+}
+
+// LoggedSet is one set together with the log, exercise and date it belongs to
+// The analytics functions work on lists of these
+type LoggedSet struct {
+	WorkoutSet
+	ScheduleID   int       `json:"schedule_id"`
+	ExerciseID   int       `json:"exercise_id"`
+	ExerciseName string    `json:"exercise_name"`
+	Category     string    `json:"category"`
+	MuscleGroup  string    `json:"muscle_group"`
+	LoggedAt     time.Time `json:"logged_at"`
+}
+
+// PersonalRecord is a moment where the user beat their own best for an exercise
+type PersonalRecord struct {
+	ExerciseID   int       `json:"exercise_id"`
+	ExerciseName string    `json:"exercise_name"`
+	Kind         string    `json:"kind"`     // see the Record* constants in the analytics package
+	Value        float64   `json:"value"`    // the new best
+	Previous     float64   `json:"previous"` // the best before this one
+	Weight       float64   `json:"weight"`   // the set that did it (for volume: the heaviest set of the session)
+	Reps         int       `json:"reps"`
+	ScheduleID   int       `json:"schedule_id"`
+	AchievedAt   time.Time `json:"achieved_at"`
+}
+
+// StrengthPoint is one session of an exercise, a point on a strength chart
+type StrengthPoint struct {
+	Date           time.Time `json:"date"`
+	ScheduleID     int       `json:"schedule_id"`
+	EstimatedOneRM float64   `json:"estimated_1rm"` // best estimate of the session, 0 if no set had 1-12 reps
+	TopWeight      float64   `json:"top_weight"`
+	TopReps        int       `json:"top_reps"` // reps of the top weight set
+	Volume         float64   `json:"volume"`   // sets x reps x weight of the working sets
+	WorkingSets    int       `json:"working_sets"`
+}
+
+// VolumeSeries is the weekly volume of one muscle group (or category)
+// Volume[i] belongs to the week that starts on VolumeReport.Weeks[i]
+type VolumeSeries struct {
+	Group  string    `json:"group"`
+	Volume []float64 `json:"volume"`
+}
+
+// VolumeReport has the shape most chart libraries want: labels plus one list per line
+type VolumeReport struct {
+	By     string         `json:"by"`    // "muscle_group" or "category"
+	Weeks  []string       `json:"weeks"` // Monday of every week, like "2024-01-15"
+	Series []VolumeSeries `json:"series"`
 }
 
 // ProgressReport represents aggregated statistics for a user
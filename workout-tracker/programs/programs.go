@@ -0,0 +1,568 @@
+package programs
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+	"workout-tracker/analytics"
+	"workout-tracker/models"
+)
+
+// The programs package turns a template (5/3/1, linear progression...) into planned
+// sessions, and keeps the plan up to date when sessions are done, failed or missed.
+// Like analytics it doesn't touch the database: the handlers load the program
+// through the store, call these functions and save the result.
+
+// What happens to a lift after a failed session
+const (
+	FailRepeat = "repeat" // keep the same weight next time, reset after FailuresBeforeReset failures in a row
+	FailReset  = "reset"  // lower the training max right away
+)
+
+// FailuresBeforeReset is how many failed sessions in a row a "repeat" template allows
+const FailuresBeforeReset = 3
+
+// ResetFactor is what is left of the training max after a reset (90%)
+const ResetFactor = 0.9
+
+// MaxWeeks is the longest program we generate
+const MaxWeeks = 26
+
+// linearWeeks returns count weeks of 3x5 at the training max
+// When deloadEvery is more than 0, every deloadEvery-th week is 3x5 at 60% instead
+func linearWeeks(count, deloadEvery int) []models.TemplateWeek {
+	weeks := []models.TemplateWeek{}
+	for i := 1; i <= count; i++ {
+		percent := 1.0
+		deload := deloadEvery > 0 && i%deloadEvery == 0
+		if deload {
+			percent = 0.6
+		}
+		weeks = append(weeks, models.TemplateWeek{
+			Deload: deload,
+			Sets: []models.TemplateSet{
+				{Reps: 5, Percent: percent},
+				{Reps: 5, Percent: percent},
+				{Reps: 5, Percent: percent},
+			},
+		})
+	}
+	return weeks
+}
+
+// fiveThreeOneCycle is one cycle of 5/3/1: the 5s, 3s and 5/3/1 weeks, then a deload
+var fiveThreeOneCycle = []models.TemplateWeek{
+	{Sets: []models.TemplateSet{{Reps: 5, Percent: 0.65}, {Reps: 5, Percent: 0.75}, {Reps: 5, Percent: 0.85, AMRAP: true}}},
+	{Sets: []models.TemplateSet{{Reps: 3, Percent: 0.70}, {Reps: 3, Percent: 0.80}, {Reps: 3, Percent: 0.90, AMRAP: true}}},
+	{Sets: []models.TemplateSet{{Reps: 5, Percent: 0.75}, {Reps: 3, Percent: 0.85}, {Reps: 1, Percent: 0.95, AMRAP: true}}},
+	{Deload: true, Sets: []models.TemplateSet{{Reps: 5, Percent: 0.40}, {Reps: 5, Percent: 0.50}, {Reps: 5, Percent: 0.60}}},
+}
+
+// repeatWeeks returns the weeks count times in a row
+func repeatWeeks(weeks []models.TemplateWeek, count int) []models.TemplateWeek {
+	all := []models.TemplateWeek{}
+	for i := 0; i < count; i++ {
+		all = append(all, weeks...)
+	}
+	return all
+}
+
+// templates are the built-in programs, GET /api/programs/templates lists them
+var templates = []models.ProgramTemplate{
+	{
+		Key:         "5-3-1",
+		Name:        "5/3/1",
+		Description: "Wendler's 5/3/1: three heavy weeks with a last set for as many reps as possible, then a deload week. Three cycles, the training max goes up 2.5 kg after each",
+		MaxPercent:  0.9,
+		Increment:   2.5,
+		CycleWeeks:  len(fiveThreeOneCycle),
+		OnFail:      FailReset,
+		Weeks:       repeatWeeks(fiveThreeOneCycle, 3),
+	},
+	{
+		Key:         "linear",
+		Name:        "Linear progression",
+		Description: "3x5 at the same weight, a little heavier after every successful session",
+		MaxPercent:  0.8,
+		Increment:   2.5,
+		OnFail:      FailRepeat,
+		Weeks:       linearWeeks(6, 0),
+	},
+	{
+		Key:         "linear-deload",
+		Name:        "Linear progression with deload weeks",
+		Description: "Like linear progression, but every 4th week is a lighter deload week at 60%",
+		MaxPercent:  0.8,
+		Increment:   2.5,
+		OnFail:      FailRepeat,
+		Weeks:       linearWeeks(8, 4),
+	},
+}
+
+// Templates returns the built-in templates
+func Templates() []models.ProgramTemplate {
+	return append([]models.ProgramTemplate{}, templates...)
+}
+
+// Find returns the built-in template with this key
+func Find(key string) (models.ProgramTemplate, bool) {
+	for _, t := range templates {
+		if t.Key == key {
+			return t, true
+		}
+	}
+	return models.ProgramTemplate{}, false
+}
+
+// Validate checks a template, users can send their own so we can't trust the numbers
+func Validate(t models.ProgramTemplate) error {
+	if strings.TrimSpace(t.Name) == "" {
+		return errors.New("template name is required")
+	}
+	if len(t.Weeks) < 1 || len(t.Weeks) > MaxWeeks {
+		return fmt.Errorf("a template needs 1 to %d weeks", MaxWeeks)
+	}
+	if t.MaxPercent < 0.5 || t.MaxPercent > 1 {
+		return errors.New("max_percent must be between 0.5 and 1")
+	}
+	if t.Increment < 0 || t.Increment > 20 {
+		return errors.New("increment must be between 0 and 20")
+	}
+	if t.CycleWeeks < 0 || t.CycleWeeks > len(t.Weeks) {
+		return fmt.Errorf("cycle_weeks must be between 0 and the number of weeks (%d)", len(t.Weeks))
+	}
+	if t.OnFail != FailRepeat && t.OnFail != FailReset {
+		return fmt.Errorf("on_fail must be %q or %q", FailRepeat, FailReset)
+	}
+	for i, week := range t.Weeks {
+		if len(week.Sets) < 1 || len(week.Sets) > 20 {
+			return fmt.Errorf("week %d: needs 1 to 20 sets", i+1)
+		}
+		for j, set := range week.Sets {
+			if set.Reps < 1 || set.Reps > 50 {
+				return fmt.Errorf("week %d set %d: reps must be between 1 and 50", i+1, j+1)
+			}
+			if set.Percent < 0.3 || set.Percent > 1.2 {
+				return fmt.Errorf("week %d set %d: percent must be between 0.3 and 1.2", i+1, j+1)
+			}
+		}
+	}
+	return nil
+}
+
+// TrainingMax works out a training max from the logged sets of one exercise:
+// the best estimated one-rep max in those sets x maxPercent
+// ok is false when no set could be used for an estimate
+func TrainingMax(sets []models.LoggedSet, maxPercent float64) (trainingMax float64, ok bool) {
+	best := 0.0
+	for _, point := range analytics.StrengthSeries(sets, analytics.FormulaEpley) {
+		if point.EstimatedOneRM > best {
+			best = point.EstimatedOneRM
+		}
+	}
+	if best == 0 {
+		return 0, false
+	}
+	return round(best * maxPercent), true
+}
+
+// round keeps two decimals, like the analytics package
+func round(value float64) float64 {
+	return math.Round(value*100) / 100
+}
+
+// roundTo rounds a weight to the nearest step, e.g. 2.5 for the smallest plates in a gym
+func roundTo(value, step float64) float64 {
+	if step <= 0 {
+		return round(value)
+	}
+	return round(math.Round(value/step) * step)
+}
+
+// Day is a training day of the week and the main lifts done on it
+type Day struct {
+	Weekday     time.Weekday
+	ExerciseIDs []int
+}
+
+// Plan is everything Generate needs
+type Plan struct {
+	UserID        int
+	Name          string
+	Template      models.ProgramTemplate
+	Start         time.Time // the first day the program may use, its clock time is used for every session
+	Days          []Day
+	TrainingMaxes map[int]float64 // exercise ID -> training max
+	Rounding      float64
+}
+
+// daysUntil counts the days from t to the next weekday (0 if t already is that day)
+func daysUntil(t time.Time, weekday time.Weekday) int {
+	return (int(weekday) - int(t.Weekday()) + 7) % 7
+}
+
+// Generate builds the program with one session per training day per week
+// The sessions get a Workout with their target sets, the caller saves them
+func Generate(plan Plan) *models.Program {
+	program := &models.Program{
+		UserID:    plan.UserID,
+		Name:      plan.Name,
+		Template:  plan.Template,
+		StartDate: plan.Start,
+		Rounding:  plan.Rounding,
+		Status:    models.ProgramActive,
+		Lifts:     []models.ProgramLift{},
+		Sessions:  []models.ProgramSession{},
+	}
+
+	// Training days in the order they come after the start date
+	days := append([]Day{}, plan.Days...)
+	sort.SliceStable(days, func(i, j int) bool {
+		return daysUntil(plan.Start, days[i].Weekday) < daysUntil(plan.Start, days[j].Weekday)
+	})
+
+	// Every main lift once, in the order it first shows up
+	seen := map[int]bool{}
+	for _, day := range days {
+		for _, id := range day.ExerciseIDs {
+			if !seen[id] {
+				seen[id] = true
+				program.Lifts = append(program.Lifts, models.ProgramLift{ExerciseID: id, TrainingMax: plan.TrainingMaxes[id]})
+			}
+		}
+	}
+
+	for w, week := range plan.Template.Weeks {
+		description := fmt.Sprintf("Week %d of %s", w+1, plan.Template.Name)
+		if week.Deload {
+			description = fmt.Sprintf("Deload week of %s", plan.Template.Name)
+		}
+		for d, day := range days {
+			workout := &models.Workout{
+				UserID:      plan.UserID,
+				Name:        fmt.Sprintf("%s - week %d day %d", plan.Name, w+1, d+1),
+				Description: description,
+			}
+			// Only the exercise IDs for now, Project fills in the targets
+			for _, id := range day.ExerciseIDs {
+				workout.Exercises = append(workout.Exercises, models.WorkoutExercise{ExerciseID: id})
+			}
+			program.Sessions = append(program.Sessions, models.ProgramSession{
+				Week:          w + 1,
+				Day:           d + 1,
+				Deload:        week.Deload,
+				Status:        models.SessionPlanned,
+				ScheduledDate: plan.Start.AddDate(0, 0, daysUntil(plan.Start, day.Weekday)+7*w),
+				Workout:       workout,
+			})
+		}
+	}
+
+	Project(program)
+	return program
+}
+
+// sessionOrder returns the indexes of the sessions, earliest date first
+func sessionOrder(program *models.Program) []int {
+	order := []int{}
+	for i := range program.Sessions {
+		order = append(order, i)
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		sa, sb := program.Sessions[order[a]], program.Sessions[order[b]]
+		if !sa.ScheduledDate.Equal(sb.ScheduledDate) {
+			return sa.ScheduledDate.Before(sb.ScheduledDate)
+		}
+		if sa.Week != sb.Week {
+			return sa.Week < sb.Week
+		}
+		return sa.Day < sb.Day
+	})
+	return order
+}
+
+// targetSets turns one week of the template into workout exercises for a lift
+// Sets that are exactly the same become one row, so 3x5 at 100 kg is one row with Sets: 3
+func targetSets(exerciseID int, trainingMax float64, week models.TemplateWeek, rounding float64) []models.WorkoutExercise {
+	rows := []models.WorkoutExercise{}
+	for _, set := range week.Sets {
+		weight := roundTo(trainingMax*set.Percent, rounding)
+		notes := fmt.Sprintf("%.0f%% of training max", set.Percent*100)
+		if set.AMRAP {
+			notes += fmt.Sprintf(", as many reps as possible (at least %d)", set.Reps)
+		}
+
+		last := len(rows) - 1
+		if last >= 0 && rows[last].Reps == set.Reps && rows[last].Weight == weight && rows[last].Notes == notes {
+			rows[last].Sets++
+			continue
+		}
+		rows = append(rows, models.WorkoutExercise{
+			ExerciseID: exerciseID,
+			Sets:       1,
+			Reps:       set.Reps,
+			Weight:     weight,
+			Notes:      notes,
+		})
+	}
+	return rows
+}
+
+// raisesMax reports whether a successful session in the week adds the template's Increment
+// Without CycleWeeks every week but a deload does. With CycleWeeks only the last week
+// of each cycle that isn't a deload does, so 5/3/1 goes up once per cycle, after its 5/3/1 week.
+func raisesMax(template models.ProgramTemplate, week int) bool {
+	if week < 1 || week > len(template.Weeks) || template.Weeks[week-1].Deload {
+		return false
+	}
+	if template.CycleWeeks <= 0 {
+		return true
+	}
+	end := ((week-1)/template.CycleWeeks + 1) * template.CycleWeeks
+	if end > len(template.Weeks) {
+		end = len(template.Weeks)
+	}
+	for w := week + 1; w <= end; w++ {
+		if !template.Weeks[w-1].Deload {
+			return false
+		}
+	}
+	return true
+}
+
+// Project writes the target sets of every planned session from the current training maxes.
+// It assumes every planned session will be done: a lift goes up by the template's Increment
+// after each of its sessions, except in deload weeks (once per cycle with CycleWeeks, see raisesMax).
+// Exercises that are not main lifts (added by the user) are kept after the main lifts.
+func Project(program *models.Program) {
+	next := map[int]float64{} // exercise ID -> training max for its next session
+	for _, lift := range program.Lifts {
+		next[lift.ExerciseID] = lift.TrainingMax
+	}
+
+	for _, i := range sessionOrder(program) {
+		session := &program.Sessions[i]
+		if session.Status != models.SessionPlanned || session.Workout == nil {
+			continue
+		}
+		if session.Week < 1 || session.Week > len(program.Template.Weeks) {
+			continue
+		}
+		week := program.Template.Weeks[session.Week-1]
+
+		lifts := []models.WorkoutExercise{}
+		others := []models.WorkoutExercise{}
+		rebuilt := map[int]bool{}
+		for _, exercise := range session.Workout.Exercises {
+			trainingMax, isLift := next[exercise.ExerciseID]
+			if !isLift {
+				others = append(others, exercise)
+				continue
+			}
+			// All rows of a lift are rebuilt at once, when we see its first row
+			if rebuilt[exercise.ExerciseID] {
+				continue
+			}
+			rebuilt[exercise.ExerciseID] = true
+			lifts = append(lifts, targetSets(exercise.ExerciseID, trainingMax, week, program.Rounding)...)
+			if raisesMax(program.Template, session.Week) {
+				next[exercise.ExerciseID] = round(trainingMax + program.Template.Increment)
+			}
+		}
+
+		exercises := append(lifts, others...)
+		for p := range exercises {
+			exercises[p].Position = p + 1
+		}
+		session.Workout.Exercises = exercises
+	}
+}
+
+// startOfDay returns midnight (UTC) of the day t is in
+func startOfDay(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// Reschedule handles sessions that weren't done in time, it returns true when something changed:
+//   - a planned session from before a session that is already done is skipped
+//   - a planned session from before today was missed: it moves whole weeks, to the first
+//     same weekday from today on, and every later planned session moves just as many weeks.
+//     So the order of the program stays the same, and every lift stays on the day the user picked.
+//
+// When nothing is planned any more the program is finished.
+func Reschedule(program *models.Program, now time.Time) bool {
+	if program.Status != models.ProgramActive {
+		return false
+	}
+	changed := false
+	order := sessionOrder(program)
+
+	// The last session that was done (or failed)
+	last := -1
+	for pos, i := range order {
+		status := program.Sessions[i].Status
+		if status == models.SessionDone || status == models.SessionFailed {
+			last = pos
+		}
+	}
+	pending := []*models.ProgramSession{}
+	for pos, i := range order {
+		session := &program.Sessions[i]
+		if session.Status != models.SessionPlanned {
+			continue
+		}
+		if pos < last {
+			session.Status = models.SessionSkipped
+			session.Note = "skipped, a later session was done first"
+			changed = true
+			continue
+		}
+		pending = append(pending, session)
+	}
+
+	today := startOfDay(now)
+	if len(pending) > 0 && pending[0].ScheduledDate.Before(today) {
+		// Days since the first missed session, rounded up to whole weeks
+		days := int(today.Sub(startOfDay(pending[0].ScheduledDate)).Hours() / 24)
+		weeks := (days + 6) / 7
+
+		for _, session := range pending {
+			previous := session.ScheduledDate
+			session.ScheduledDate = previous.AddDate(0, 0, 7*weeks)
+			if previous.Before(today) {
+				session.Note = fmt.Sprintf("missed on %s, moved to %s", previous.Format("2006-01-02"), session.ScheduledDate.Format("2006-01-02"))
+			} else {
+				session.Note = fmt.Sprintf("moved from %s because a session was missed", previous.Format("2006-01-02"))
+			}
+		}
+		changed = true
+	}
+
+	if len(pending) == 0 {
+		program.Status = models.ProgramFinished
+		changed = true
+	}
+	return changed
+}
+
+// FailedLifts compares the logs with the targets of a session and returns the main lifts
+// that fell short: fewer working sets, fewer reps or less weight than planned.
+// Without any logs there is nothing to compare, so nothing failed.
+func FailedLifts(program *models.Program, session models.ProgramSession, logs []models.WorkoutLog) []int {
+	failed := []int{}
+	if len(logs) == 0 || session.Workout == nil {
+		return failed
+	}
+
+	isLift := map[int]bool{}
+	for _, lift := range program.Lifts {
+		isLift[lift.ExerciseID] = true
+	}
+
+	// Every target set and every logged working set, per exercise
+	targets := map[int][]models.WorkoutSet{}
+	order := []int{}
+	for _, exercise := range session.Workout.Exercises {
+		if !isLift[exercise.ExerciseID] {
+			continue
+		}
+		if targets[exercise.ExerciseID] == nil {
+			order = append(order, exercise.ExerciseID)
+		}
+		for i := 0; i < exercise.Sets; i++ {
+			targets[exercise.ExerciseID] = append(targets[exercise.ExerciseID], models.WorkoutSet{Reps: exercise.Reps, Weight: exercise.Weight})
+		}
+	}
+	logged := map[int][]models.WorkoutSet{}
+	for _, log := range logs {
+		for _, set := range log.Sets {
+			if !set.IsWarmup {
+				logged[log.ExerciseID] = append(logged[log.ExerciseID], set)
+			}
+		}
+	}
+
+	for _, id := range order {
+		sets := logged[id]
+		short := len(sets) < len(targets[id])
+		for i := 0; !short && i < len(targets[id]); i++ {
+			// A tiny margin, so 102.49 kg logged for a 102.5 kg target doesn't count as a fail
+			short = sets[i].Reps < targets[id][i].Reps || sets[i].Weight < targets[id][i].Weight-0.01
+		}
+		if short {
+			failed = append(failed, id)
+		}
+	}
+	return failed
+}
+
+// Record updates the program after the schedule of one of its sessions was completed:
+//   - a successful session adds the template's Increment to the training max of its lifts
+//     (with CycleWeeks only the session that ends a cycle, see raisesMax)
+//   - a failed lift keeps its training max ("repeat"), or goes back to ResetFactor of it
+//     ("reset", or the FailuresBeforeReset-th failure in a row of a "repeat" template)
+//   - deload sessions don't change anything
+//
+// Then the targets of the remaining sessions are worked out again.
+// It returns false when the schedule isn't a planned session of this program.
+func Record(program *models.Program, scheduleID int, logs []models.WorkoutLog) bool {
+	var session *models.ProgramSession
+	for i := range program.Sessions {
+		if program.Sessions[i].ScheduleID == scheduleID && program.Sessions[i].Status == models.SessionPlanned {
+			session = &program.Sessions[i]
+		}
+	}
+	if session == nil {
+		return false
+	}
+
+	inSession := map[int]bool{}
+	names := map[int]string{}
+	if session.Workout != nil {
+		for _, exercise := range session.Workout.Exercises {
+			inSession[exercise.ExerciseID] = true
+			names[exercise.ExerciseID] = fmt.Sprintf("exercise %d", exercise.ExerciseID)
+			if exercise.Exercise != nil {
+				names[exercise.ExerciseID] = exercise.Exercise.Name
+			}
+		}
+	}
+	failed := map[int]bool{}
+	failedNames := []string{}
+	for _, id := range FailedLifts(program, *session, logs) {
+		failed[id] = true
+		failedNames = append(failedNames, names[id])
+	}
+
+	session.Status = models.SessionDone
+	if len(failed) > 0 && !session.Deload {
+		session.Status = models.SessionFailed
+		session.Note = "fell short of the target: " + strings.Join(failedNames, ", ")
+	}
+
+	for i := range program.Lifts {
+		lift := &program.Lifts[i]
+		if !inSession[lift.ExerciseID] || session.Deload {
+			continue
+		}
+		if !failed[lift.ExerciseID] {
+			if raisesMax(program.Template, session.Week) {
+				lift.TrainingMax = round(lift.TrainingMax + program.Template.Increment)
+			}
+			lift.Failures = 0
+			continue
+		}
+		lift.Failures++
+		if program.Template.OnFail == FailReset || lift.Failures >= FailuresBeforeReset {
+			lift.TrainingMax = round(lift.TrainingMax * ResetFactor)
+			lift.Failures = 0
+		}
+	}
+
+	Project(program)
+	return true
+}
@@ -0,0 +1,368 @@
+package programs
+
+import (
+	"reflect"
+	"testing"
+	"time"
+	"workout-tracker/models"
+)
+
+func date(s string) time.Time {
+	t, err := time.Parse("2006-01-02 15:04", s)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+// topWeight is the weight of the last target row of the exercise in a session
+func topWeight(session models.ProgramSession, exerciseID int) float64 {
+	weight := 0.0
+	for _, exercise := range session.Workout.Exercises {
+		if exercise.ExerciseID == exerciseID {
+			weight = exercise.Weight
+		}
+	}
+	return weight
+}
+
+// oneDay generates a program with one lift (exercise 1) on Mondays from 2025-01-06
+func oneDay(template models.ProgramTemplate, trainingMax float64) *models.Program {
+	program := Generate(Plan{
+		UserID:        1,
+		Name:          template.Name,
+		Template:      template,
+		Start:         date("2025-01-06 07:00"),
+		Days:          []Day{{Weekday: time.Monday, ExerciseIDs: []int{1}}},
+		TrainingMaxes: map[int]float64{1: trainingMax},
+		Rounding:      2.5,
+	})
+	for i := range program.Sessions {
+		program.Sessions[i].ScheduleID = i + 1
+	}
+	return program
+}
+
+func builtIn(key string) models.ProgramTemplate {
+	template, ok := Find(key)
+	if !ok {
+		panic("no template " + key)
+	}
+	return template
+}
+
+func TestBuiltInTemplatesAreValid(t *testing.T) {
+	for _, template := range Templates() {
+		if err := Validate(template); err != nil {
+			t.Errorf("template %s: %v", template.Key, err)
+		}
+	}
+}
+
+func TestRaisesMax(t *testing.T) {
+	fiveThreeOne := builtIn("5-3-1")
+	linear := builtIn("linear")
+	deload := builtIn("linear-deload")
+
+	tests := []struct {
+		name     string
+		template models.ProgramTemplate
+		week     int
+		want     bool
+	}{
+		{"5/3/1 5s week", fiveThreeOne, 1, false},
+		{"5/3/1 3s week", fiveThreeOne, 2, false},
+		{"5/3/1 5/3/1 week ends the cycle", fiveThreeOne, 3, true},
+		{"5/3/1 deload", fiveThreeOne, 4, false},
+		{"5/3/1 second cycle", fiveThreeOne, 7, true},
+		{"5/3/1 third cycle", fiveThreeOne, 11, true},
+		{"5/3/1 last deload", fiveThreeOne, 12, false},
+		{"linear every week", linear, 1, true},
+		{"linear last week", linear, 6, true},
+		{"linear deload week", deload, 4, false},
+		{"week 0", linear, 0, false},
+		{"after the last week", linear, 7, false},
+	}
+	for _, tt := range tests {
+		if got := raisesMax(tt.template, tt.week); got != tt.want {
+			t.Errorf("%s: raisesMax(week %d) = %v, want %v", tt.name, tt.week, got, tt.want)
+		}
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	template := models.ProgramTemplate{
+		Name:      "Test",
+		Increment: 2.5,
+		Weeks: []models.TemplateWeek{
+			{Sets: []models.TemplateSet{{Reps: 5, Percent: 1}, {Reps: 5, Percent: 1}}},
+			{Deload: true, Sets: []models.TemplateSet{{Reps: 5, Percent: 0.5}}},
+			{Sets: []models.TemplateSet{{Reps: 5, Percent: 1}}},
+		},
+	}
+	// starts on a Wednesday, so Thursday comes first
+	program := Generate(Plan{
+		Name:          "Test",
+		Template:      template,
+		Start:         date("2025-01-15 07:00"),
+		Days:          []Day{{Weekday: time.Monday, ExerciseIDs: []int{1}}, {Weekday: time.Thursday, ExerciseIDs: []int{2}}},
+		TrainingMaxes: map[int]float64{1: 100, 2: 60},
+		Rounding:      2.5,
+	})
+
+	if len(program.Lifts) != 2 || program.Lifts[0].ExerciseID != 2 || program.Lifts[1].ExerciseID != 1 {
+		t.Errorf("lifts = %+v, want exercise 2 (Thursday) first", program.Lifts)
+	}
+	want := []struct {
+		date     string
+		week     int
+		deload   bool
+		exercise int
+		sets     int
+		weight   float64
+	}{
+		{"2025-01-16 07:00", 1, false, 2, 2, 60},
+		{"2025-01-20 07:00", 1, false, 1, 2, 100},
+		// 50% of the next training max, rounded to 2.5
+		{"2025-01-23 07:00", 2, true, 2, 1, 32.5},
+		{"2025-01-27 07:00", 2, true, 1, 1, 52.5},
+		// a deload week doesn't raise the training max
+		{"2025-01-30 07:00", 3, false, 2, 1, 62.5},
+		{"2025-02-03 07:00", 3, false, 1, 1, 102.5},
+	}
+	if len(program.Sessions) != len(want) {
+		t.Fatalf("%d sessions, want %d", len(program.Sessions), len(want))
+	}
+	for i, w := range want {
+		s := program.Sessions[i]
+		if !s.ScheduledDate.Equal(date(w.date)) || s.Week != w.week || s.Deload != w.deload || s.Status != models.SessionPlanned {
+			t.Errorf("session %d = week %d deload %v %s on %s, want week %d deload %v planned on %s",
+				i, s.Week, s.Deload, s.Status, s.ScheduledDate, w.week, w.deload, w.date)
+			continue
+		}
+		rows := s.Workout.Exercises
+		if len(rows) != 1 || rows[0].ExerciseID != w.exercise || rows[0].Sets != w.sets || rows[0].Weight != w.weight {
+			t.Errorf("session %d targets = %+v, want exercise %d %dx5 at %v", i, rows, w.exercise, w.sets, w.weight)
+		}
+	}
+}
+
+// 5/3/1 raises the training max once per cycle, not after every session
+func TestGenerateFiveThreeOneProgression(t *testing.T) {
+	program := oneDay(builtIn("5-3-1"), 100)
+	if len(program.Sessions) != 12 {
+		t.Fatalf("%d sessions, want 12", len(program.Sessions))
+	}
+
+	tests := []struct {
+		week int
+		want float64 // the weight of the last set
+	}{
+		{1, 85},   // 85% of 100
+		{2, 90},   // 90% of 100
+		{3, 95},   // 95% of 100
+		{4, 62.5}, // deload, 60% of 102.5 = 61.5, the cycle is over
+		{5, 87.5}, // 85% of 102.5 = 87.125
+		{7, 97.5}, // 95% of 102.5 = 97.375
+		{9, 90},   // 85% of 105 = 89.25
+		{11, 100}, // 95% of 105 = 99.75
+		{12, 65},  // deload, 60% of 107.5 = 64.5
+	}
+	for _, tt := range tests {
+		if got := topWeight(program.Sessions[tt.week-1], 1); got != tt.want {
+			t.Errorf("week %d: last set at %v, want %v", tt.week, got, tt.want)
+		}
+	}
+}
+
+func TestFailedLifts(t *testing.T) {
+	program := &models.Program{Lifts: []models.ProgramLift{{ExerciseID: 1, TrainingMax: 100}}}
+	session := models.ProgramSession{Workout: &models.Workout{Exercises: []models.WorkoutExercise{
+		{ExerciseID: 1, Sets: 2, Reps: 5, Weight: 102.5},
+		{ExerciseID: 9, Sets: 3, Reps: 10, Weight: 20}, // added by the user, not a main lift
+	}}}
+	set := func(reps int, weight float64) models.WorkoutSet {
+		return models.WorkoutSet{Reps: reps, Weight: weight}
+	}
+	warmup := models.WorkoutSet{Reps: 5, Weight: 102.5, IsWarmup: true}
+
+	tests := []struct {
+		name string
+		logs []models.WorkoutLog
+		want []int
+	}{
+		{"no logs", nil, []int{}},
+		{"as planned", []models.WorkoutLog{{ExerciseID: 1, Sets: []models.WorkoutSet{set(5, 102.5), set(5, 102.5)}}}, []int{}},
+		{"more reps and sets", []models.WorkoutLog{{ExerciseID: 1, Sets: []models.WorkoutSet{set(8, 102.5), set(5, 105), set(3, 102.5)}}}, []int{}},
+		{"within the margin", []models.WorkoutLog{{ExerciseID: 1, Sets: []models.WorkoutSet{set(5, 102.49), set(5, 102.5)}}}, []int{}},
+		{"lighter", []models.WorkoutLog{{ExerciseID: 1, Sets: []models.WorkoutSet{set(5, 100), set(5, 102.5)}}}, []int{1}},
+		{"fewer reps", []models.WorkoutLog{{ExerciseID: 1, Sets: []models.WorkoutSet{set(5, 102.5), set(4, 102.5)}}}, []int{1}},
+		{"fewer sets", []models.WorkoutLog{{ExerciseID: 1, Sets: []models.WorkoutSet{set(5, 102.5)}}}, []int{1}},
+		{"warm-ups don't count", []models.WorkoutLog{{ExerciseID: 1, Sets: []models.WorkoutSet{warmup, set(5, 102.5)}}}, []int{1}},
+		{"two logs of the lift", []models.WorkoutLog{
+			{ExerciseID: 1, Sets: []models.WorkoutSet{set(5, 102.5)}},
+			{ExerciseID: 1, Sets: []models.WorkoutSet{set(5, 102.5)}},
+		}, []int{}},
+		{"only other exercises logged", []models.WorkoutLog{{ExerciseID: 9, Sets: []models.WorkoutSet{set(1, 20)}}}, []int{1}},
+	}
+	for _, tt := range tests {
+		if got := FailedLifts(program, session, tt.logs); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: FailedLifts() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+// logsFor logs the targets of a session, with short reps less in every set
+func logsFor(session models.ProgramSession, short int) []models.WorkoutLog {
+	logs := []models.WorkoutLog{}
+	for _, exercise := range session.Workout.Exercises {
+		log := models.WorkoutLog{ExerciseID: exercise.ExerciseID}
+		for i := 0; i < exercise.Sets; i++ {
+			log.Sets = append(log.Sets, models.WorkoutSet{Reps: exercise.Reps - short, Weight: exercise.Weight})
+		}
+		logs = append(logs, log)
+	}
+	return logs
+}
+
+func TestRecord(t *testing.T) {
+	tests := []struct {
+		name         string
+		template     string
+		week         int
+		failures     int  // failed sessions in a row before this one
+		short        int  // reps missed in every set
+		noLogs       bool // completed without logging anything
+		wantMax      float64
+		wantFailures int
+		wantStatus   string
+	}{
+		{"linear done", "linear", 1, 0, 0, false, 102.5, 0, models.SessionDone},
+		{"linear without logs", "linear", 1, 0, 0, true, 102.5, 0, models.SessionDone},
+		{"linear done after a fail", "linear", 2, 1, 0, false, 102.5, 0, models.SessionDone},
+		{"linear fail repeats", "linear", 1, 0, 1, false, 100, 1, models.SessionFailed},
+		{"linear second fail repeats", "linear", 1, 1, 1, false, 100, 2, models.SessionFailed},
+		{"linear third fail resets", "linear", 1, 2, 1, false, 90, 0, models.SessionFailed},
+		{"linear deload week", "linear-deload", 4, 0, 0, false, 100, 0, models.SessionDone},
+		{"linear deload never fails", "linear-deload", 4, 1, 3, false, 100, 1, models.SessionDone},
+		{"5/3/1 first week doesn't raise", "5-3-1", 1, 0, 0, false, 100, 0, models.SessionDone},
+		{"5/3/1 end of the cycle raises", "5-3-1", 3, 0, 0, false, 102.5, 0, models.SessionDone},
+		{"5/3/1 fail resets right away", "5-3-1", 1, 0, 1, false, 90, 0, models.SessionFailed},
+	}
+	for _, tt := range tests {
+		program := oneDay(builtIn(tt.template), 100)
+		program.Lifts[0].Failures = tt.failures
+		for i := 0; i < tt.week-1; i++ {
+			program.Sessions[i].Status = models.SessionDone
+		}
+		session := program.Sessions[tt.week-1]
+		logs := logsFor(session, tt.short)
+		if tt.noLogs {
+			logs = nil
+		}
+
+		if !Record(program, session.ScheduleID, logs) {
+			t.Errorf("%s: Record() = false", tt.name)
+			continue
+		}
+		lift := program.Lifts[0]
+		if lift.TrainingMax != tt.wantMax || lift.Failures != tt.wantFailures {
+			t.Errorf("%s: training max %v with %d failures, want %v with %d", tt.name, lift.TrainingMax, lift.Failures, tt.wantMax, tt.wantFailures)
+		}
+		if status := program.Sessions[tt.week-1].Status; status != tt.wantStatus {
+			t.Errorf("%s: session status %s, want %s", tt.name, status, tt.wantStatus)
+		}
+		// the next session is planned from the new training max
+		if tt.template == "linear" {
+			if got := topWeight(program.Sessions[tt.week], 1); got != tt.wantMax {
+				t.Errorf("%s: next session at %v, want %v", tt.name, got, tt.wantMax)
+			}
+		}
+		// a session is only recorded once
+		if Record(program, session.ScheduleID, logs) {
+			t.Errorf("%s: a second Record() of the same schedule = true", tt.name)
+		}
+	}
+
+	if Record(oneDay(builtIn("linear"), 100), 999, nil) {
+		t.Error("Record() of a schedule that isn't in the program = true")
+	}
+}
+
+func TestReschedule(t *testing.T) {
+	// three Mondays: 2025-01-06, 01-13 and 01-20
+	template := models.ProgramTemplate{Name: "Test", Weeks: linearWeeks(3, 0)}
+
+	tests := []struct {
+		name        string
+		done        []int // indexes of the sessions already done
+		now         string
+		want        bool
+		wantDates   []string
+		wantStatus  []string
+		wantProgram string
+	}{
+		{
+			name: "nothing missed", now: "2025-01-06 20:00", want: false,
+			wantDates:   []string{"2025-01-06", "2025-01-13", "2025-01-20"},
+			wantStatus:  []string{"planned", "planned", "planned"},
+			wantProgram: models.ProgramActive,
+		},
+		{
+			name: "missed by two days moves one week", now: "2025-01-08 09:00", want: true,
+			wantDates:   []string{"2025-01-13", "2025-01-20", "2025-01-27"},
+			wantStatus:  []string{"planned", "planned", "planned"},
+			wantProgram: models.ProgramActive,
+		},
+		{
+			name: "missed by eight days moves two weeks", now: "2025-01-14 09:00", want: true,
+			wantDates:   []string{"2025-01-20", "2025-01-27", "2025-02-03"},
+			wantStatus:  []string{"planned", "planned", "planned"},
+			wantProgram: models.ProgramActive,
+		},
+		{
+			name: "done later session skips the earlier one", done: []int{1}, now: "2025-01-14 09:00", want: true,
+			wantDates:   []string{"2025-01-06", "2025-01-13", "2025-01-20"},
+			wantStatus:  []string{"skipped", "done", "planned"},
+			wantProgram: models.ProgramActive,
+		},
+		{
+			name: "everything done", done: []int{0, 1, 2}, now: "2025-01-21 09:00", want: true,
+			wantDates:   []string{"2025-01-06", "2025-01-13", "2025-01-20"},
+			wantStatus:  []string{"done", "done", "done"},
+			wantProgram: models.ProgramFinished,
+		},
+	}
+	for _, tt := range tests {
+		program := oneDay(template, 100)
+		for _, i := range tt.done {
+			program.Sessions[i].Status = models.SessionDone
+		}
+		planned := []time.Time{}
+		for _, s := range program.Sessions {
+			planned = append(planned, s.ScheduledDate)
+		}
+
+		if got := Reschedule(program, date(tt.now)); got != tt.want {
+			t.Errorf("%s: Reschedule() = %v, want %v", tt.name, got, tt.want)
+		}
+		for i, s := range program.Sessions {
+			if got := s.ScheduledDate.Format("2006-01-02"); got != tt.wantDates[i] || s.Status != tt.wantStatus[i] {
+				t.Errorf("%s: session %d %s on %s, want %s on %s", tt.name, i, s.Status, got, tt.wantStatus[i], tt.wantDates[i])
+			}
+			if !s.ScheduledDate.Equal(planned[i]) && s.Note == "" {
+				t.Errorf("%s: moved session %d has no note", tt.name, i)
+			}
+		}
+		if program.Status != tt.wantProgram {
+			t.Errorf("%s: program %s, want %s", tt.name, program.Status, tt.wantProgram)
+		}
+	}
+
+	// a finished program is left alone
+	program := oneDay(template, 100)
+	program.Status = models.ProgramFinished
+	if Reschedule(program, date("2025-03-01 09:00")) {
+		t.Error("Reschedule() of a finished program = true")
+	}
+}
@@ -2,163 +2,129 @@ package seeder
 
 import (
 	"database/sql"
+	_ "embed"
+	"encoding/json"
+	"fmt"
 	"log"
+	"strings"
+	"workout-tracker/models"
+
+	"github.com/lib/pq"
 )
 
 // Exercise represents the exercise data we want to seed
 type Exercise struct {
-	Name        string
-	Description string
-	Category    string
-	MuscleGroup string
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Category    string   `json:"category"`
+	MuscleGroup string   `json:"muscle_group"`
+	Equipment   string   `json:"equipment"`
+	Difficulty  string   `json:"difficulty"`
+	Aliases     []string `json:"aliases"`
 }
 
-// predefinedExercises contains our starter set of exercises
-// This gives users a library to choose from when creating workouts
-var predefinedExercises = []Exercise{
-	// Chest exercises
-	{
-		Name:        "Bench Press",
-		Description: "Lie on a bench and press a barbell or dumbbells upward",
-		Category:    "Chest",
-		MuscleGroup: "Upper Body",
-	},
-	{
-		Name:        "Push-ups",
-		Description: "Classic bodyweight exercise for chest, shoulders, and triceps",
-		Category:    "Chest",
-		MuscleGroup: "Upper Body",
-	},
-	{
-		Name:        "Dumbbell Flyes",
-		Description: "Lying on bench, move dumbbells in an arc motion",
-		Category:    "Chest",
-		MuscleGroup: "Upper Body",
-	},
-
-	// Back exercises
-	{
-		Name:        "Deadlift",
-		Description: "Lift a barbell from the ground to hip level",
-		Category:    "Back",
-		MuscleGroup: "Full Body",
-	},
-	{
-		Name:        "Pull-ups",
-		Description: "Hang from a bar and pull yourself up",
-		Category:    "Back",
-		MuscleGroup: "Upper Body",
-	},
-	{
-		Name:        "Barbell Rows",
-		Description: "Bent over position, pull barbell to your chest",
-		Category:    "Back",
-		MuscleGroup: "Upper Body",
-	},
-
-	// Leg exercises
-	{
-		Name:        "Squats",
-		Description: "Lower your body by bending knees and hips",
-		Category:    "Legs",
-		MuscleGroup: "Lower Body",
-	},
-	{
-		Name:        "Lunges",
-		Description: "Step forward and lower your body until both knees are bent",
-		Category:    "Legs",
-		MuscleGroup: "Lower Body",
-	},
-	{
-		Name:        "Leg Press",
-		Description: "Push weight away using leg press machine",
-		Category:    "Legs",
-		MuscleGroup: "Lower Body",
-	},
+// catalogJSON is our starter library, edit exercises.json to change it
+// It is embedded in the binary like the migrations, so the server doesn't need the file at runtime.
+//
+//go:embed exercises.json
+var catalogJSON []byte
 
-	// Shoulder exercises
-	{
-		Name:        "Shoulder Press",
-		Description: "Press dumbbells or barbell overhead",
-		Category:    "Shoulders",
-		MuscleGroup: "Upper Body",
-	},
-	{
-		Name:        "Lateral Raises",
-		Description: "Raise dumbbells to the sides",
-		Category:    "Shoulders",
-		MuscleGroup: "Upper Body",
-	},
+// Catalog reads and checks the embedded exercise catalog
+func Catalog() ([]Exercise, error) {
+	var exercises []Exercise
+	if err := json.Unmarshal(catalogJSON, &exercises); err != nil {
+		return nil, fmt.Errorf("exercises.json: %w", err)
+	}
 
-	// Arm exercises
-	{
-		Name:        "Bicep Curls",
-		Description: "Curl dumbbells or barbell toward shoulders",
-		Category:    "Arms",
-		MuscleGroup: "Upper Body",
-	},
-	{
-		Name:        "Tricep Dips",
-		Description: "Lower and raise your body using parallel bars",
-		Category:    "Arms",
-		MuscleGroup: "Upper Body",
-	},
+	seen := map[string]bool{}
+	for i, exercise := range exercises {
+		if exercise.Name == "" || exercise.Category == "" {
+			return nil, fmt.Errorf("exercises.json: exercise %d needs a name and a category", i+1)
+		}
+		// The database compares names without upper/lower case, see migration 0009
+		key := strings.ToLower(exercise.Name)
+		if seen[key] {
+			return nil, fmt.Errorf("exercises.json: %q is in the catalog twice", exercise.Name)
+		}
+		seen[key] = true
 
-	// Core exercises
-	{
-		Name:        "Plank",
-		Description: "Hold a push-up position on your forearms",
-		Category:    "Core",
-		MuscleGroup: "Core",
-	},
-	{
-		Name:        "Crunches",
-		Description: "Lie on back and lift shoulders toward knees",
-		Category:    "Core",
-		MuscleGroup: "Core",
-	},
-	{
-		Name:        "Russian Twists",
-		Description: "Seated position, rotate torso side to side",
-		Category:    "Core",
-		MuscleGroup: "Core",
-	},
+		switch exercise.Difficulty {
+		case "", models.DifficultyBeginner, models.DifficultyIntermediate, models.DifficultyAdvanced:
+		default:
+			return nil, fmt.Errorf("exercises.json: %q has an unknown difficulty %q", exercise.Name, exercise.Difficulty)
+		}
+		if exercise.Aliases == nil {
+			exercises[i].Aliases = []string{}
+		}
+	}
+	return exercises, nil
 }
 
-// SeedExercises populates the exercises table with predefined exercises
-// This only adds exercises if the table is empty
+// SeedExercises adds the catalog to the exercise library, or updates it
+// It runs at every start and is safe to run again (idempotent):
+//   - an exercise that isn't in the library yet is added
+//   - one with the same name gets the catalog's description, category, equipment and so on
+//   - one that is already the same is left alone
+//
+// Why not only seed an empty table like before?
+// answer: Then a catalog change (a new exercise, an alias) would never reach an existing database.
+// Exercises that admins added through the API aren't in the catalog, so they are never touched,
+// but an admin's change to a catalog exercise is undone at the next start: change exercises.json for those.
 func SeedExercises(db *sql.DB) error {
-	log.Println("Checking if exercises need to be seeded...")
-
-	// First, check if we already have exercises
-	var count int
-	err := db.QueryRow("SELECT COUNT(*) FROM exercises").Scan(&count)
+	exercises, err := Catalog()
 	if err != nil {
 		return err
 	}
 
-	// If exercises already exist, skip seeding
-	if count > 0 {
-		log.Printf("Exercises already seeded (%d exercises found). Skipping...", count)
-		return nil
+	tx, err := db.Begin()
+	if err != nil {
+		return err
 	}
+	defer tx.Rollback()
 
-	// Insert each exercise into the database
-	log.Println("Seeding exercises...")
-	for _, exercise := range predefinedExercises {
-		_, err := db.Exec(
-			`INSERT INTO exercises (name, description, category, muscle_group) 
-			 VALUES ($1, $2, $3, $4)`,
+	// ON CONFLICT uses the unique index on LOWER(name) of the library (user_id IS NULL)
+	// The WHERE of DO UPDATE skips rows that didn't change, so they don't count below
+	changed := int64(0)
+	for _, exercise := range exercises {
+		result, err := tx.Exec(`
+			INSERT INTO exercises (name, description, category, muscle_group, equipment, difficulty, aliases)
+			VALUES ($1, $2, $3, $4, $5, $6, $7)
+			ON CONFLICT ((LOWER(name))) WHERE user_id IS NULL DO UPDATE
+			SET name = EXCLUDED.name, description = EXCLUDED.description, category = EXCLUDED.category,
+			    muscle_group = EXCLUDED.muscle_group, equipment = EXCLUDED.equipment,
+			    difficulty = EXCLUDED.difficulty, aliases = EXCLUDED.aliases
+			WHERE (exercises.name, exercises.description, exercises.category, exercises.muscle_group,
+			       exercises.equipment, exercises.difficulty, exercises.aliases)
+			      IS DISTINCT FROM
+			      (EXCLUDED.name, EXCLUDED.description, EXCLUDED.category, EXCLUDED.muscle_group,
+			       EXCLUDED.equipment, EXCLUDED.difficulty, EXCLUDED.aliases)
+		`,
 			exercise.Name,
 			exercise.Description,
 			exercise.Category,
 			exercise.MuscleGroup,
+			exercise.Equipment,
+			exercise.Difficulty,
+			pq.Array(exercise.Aliases),
 		)
+		if err != nil {
+			return fmt.Errorf("seeding %q: %w", exercise.Name, err)
+		}
+		n, err := result.RowsAffected()
 		if err != nil {
 			return err
 		}
+		changed += n
 	}
 
-	log.Printf("Successfully seeded %d exercises", len(predefinedExercises))
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	if changed == 0 {
+		log.Printf("Exercise library is up to date (%d exercises in the catalog)", len(exercises))
+	} else {
+		log.Printf("Added or updated %d of the %d exercises in the catalog", changed, len(exercises))
+	}
 	return nil
 }
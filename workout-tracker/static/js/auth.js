@@ -35,8 +35,10 @@ if (loginForm) {
             const data = await response.json();
             
             if (response.ok) {
-                // Save token to localStorage
+                // Save both tokens to localStorage
+                // the access token expires after a few minutes, the refresh token gets a new one
                 localStorage.setItem('token', data.token);
+                localStorage.setItem('refreshToken', data.refresh_token);
                 localStorage.setItem('username', data.user.username);
                 
                 showMessage('Login successful! Redirecting...', 'success');
@@ -112,8 +114,10 @@ if (registerForm) {
             const data = await response.json();
             
             if (response.ok) {
-                // Save token to localStorage
+                // Save both tokens to localStorage
+                // the access token expires after a few minutes, the refresh token gets a new one
                 localStorage.setItem('token', data.token);
+                localStorage.setItem('refreshToken', data.refresh_token);
                 localStorage.setItem('username', data.user.username);
                 
                 showMessage('Registration successful! Redirecting...', 'success');
@@ -141,6 +145,132 @@ if (registerForm) {
     });
 }
 
+// The token from an email link: reset-password.html?token=...
+const urlToken = new URLSearchParams(window.location.search).get('token');
+
+// Handle Forgot Password Form (reset-password.html without a token)
+const forgotForm = document.getElementById('forgotForm');
+const resetForm = document.getElementById('resetForm');
+if (forgotForm && urlToken) {
+    // We came from the email, so show the new password form instead
+    forgotForm.style.display = 'none';
+    resetForm.style.display = 'block';
+}
+if (forgotForm) {
+    forgotForm.addEventListener('submit', async (e) => {
+        e.preventDefault();
+        
+        const email = document.getElementById('email').value.trim();
+        const submitBtn = e.target.querySelector('button[type="submit"]');
+        
+        submitBtn.disabled = true;
+        submitBtn.textContent = 'Sending...';
+        
+        try {
+            const response = await fetch(`${API_URL}/password/forgot`, {
+                method: 'POST',
+                headers: {
+                    'Content-Type': 'application/json',
+                },
+                body: JSON.stringify({ email })
+            });
+            
+            if (response.ok) {
+                const data = await response.json();
+                showMessage(data.message, 'success');
+            } else if (response.status === 429) {
+                showMessage('Too many requests. Please try again in an hour.', 'error');
+            } else {
+                // http.Error answers with plain text, not JSON
+                showMessage(await response.text(), 'error');
+            }
+        } catch (error) {
+            console.error('Forgot password error:', error);
+            showMessage('Connection error. Make sure the server is running.', 'error');
+        } finally {
+            submitBtn.disabled = false;
+            submitBtn.textContent = 'Send Reset Link';
+        }
+    });
+}
+
+// Handle Reset Password Form (reset-password.html?token=...)
+if (resetForm) {
+    resetForm.addEventListener('submit', async (e) => {
+        e.preventDefault();
+        
+        const password = document.getElementById('password').value;
+        const submitBtn = e.target.querySelector('button[type="submit"]');
+        
+        if (password.length < 6) {
+            showMessage('Password must be at least 6 characters', 'error');
+            return;
+        }
+        
+        submitBtn.disabled = true;
+        submitBtn.textContent = 'Saving...';
+        
+        try {
+            const response = await fetch(`${API_URL}/password/reset`, {
+                method: 'POST',
+                headers: {
+                    'Content-Type': 'application/json',
+                },
+                body: JSON.stringify({ token: urlToken, password })
+            });
+            
+            if (response.ok) {
+                // Every session was logged out, so forget the old tokens too
+                localStorage.removeItem('token');
+                localStorage.removeItem('refreshToken');
+                showMessage('Password changed! Redirecting to login...', 'success');
+                setTimeout(() => {
+                    window.location.href = '/static/login.html';
+                }, 1500);
+            } else {
+                showMessage(await response.text(), 'error');
+            }
+        } catch (error) {
+            console.error('Reset password error:', error);
+            showMessage('Connection error. Make sure the server is running.', 'error');
+        } finally {
+            submitBtn.disabled = false;
+            submitBtn.textContent = 'Change Password';
+        }
+    });
+}
+
+// Verify the email address as soon as verify-email.html opens
+if (document.querySelector('[data-verify-email]')) {
+    verifyEmail();
+}
+
+async function verifyEmail() {
+    if (!urlToken) {
+        showMessage('The link is missing its token. Please open the link from the email again.', 'error');
+        return;
+    }
+    try {
+        const response = await fetch(`${API_URL}/verify-email`, {
+            method: 'POST',
+            headers: {
+                'Content-Type': 'application/json',
+            },
+            body: JSON.stringify({ token: urlToken })
+        });
+        
+        if (response.ok) {
+            const data = await response.json();
+            showMessage(data.message, 'success');
+        } else {
+            showMessage(await response.text(), 'error');
+        }
+    } catch (error) {
+        console.error('Verify email error:', error);
+        showMessage('Connection error. Make sure the server is running.', 'error');
+    }
+}
+
 // Show message helper
 function showMessage(text, type) {
     const messageEl = document.getElementById('message');
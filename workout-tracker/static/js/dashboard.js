@@ -10,15 +10,63 @@ if (!token) {
 // Display username
 document.getElementById('username').textContent = localStorage.getItem('username');
 
-// Logout function
-function logout() {
+// Forget the tokens and go back to the login page
+function clearSession() {
     localStorage.removeItem('token');
+    localStorage.removeItem('refreshToken');
     localStorage.removeItem('username');
     window.location.href = '/static/login.html';
 }
 
+// Logout function
+// Tells the server first, so the tokens stop working everywhere
+async function logout() {
+    try {
+        await fetch(`${API_URL}/logout`, {
+            method: 'POST',
+            headers: { 'Authorization': `Bearer ${localStorage.getItem('token')}` }
+        });
+    } catch (error) {
+        console.error('Error logging out:', error);
+    }
+    clearSession();
+}
+
+// Get a new access token with the refresh token
+// Returns false when the session is over (expired or logged out)
+//
+// why do we keep the promise in refreshing?
+// answer: a refresh token works only once. When two requests get a 401 at the same time,
+// they must wait for the same refresh, a second refresh with the old token would log us out
+let refreshing = null;
+function refreshTokens() {
+    if (!refreshing) {
+        refreshing = doRefresh().finally(() => { refreshing = null; });
+    }
+    return refreshing;
+}
+
+async function doRefresh() {
+    const refreshToken = localStorage.getItem('refreshToken');
+    if (!refreshToken) {
+        return false;
+    }
+    const response = await fetch(`${API_URL}/refresh`, {
+        method: 'POST',
+        headers: { 'Content-Type': 'application/json' },
+        body: JSON.stringify({ refresh_token: refreshToken })
+    });
+    if (!response.ok) {
+        return false;
+    }
+    const data = await response.json();
+    localStorage.setItem('token', data.token);
+    localStorage.setItem('refreshToken', data.refresh_token);
+    return true;
+}
+
 // Helper function to make authenticated requests
-async function fetchWithAuth(url, options = {}) {
+async function fetchWithAuth(url, options = {}, retried = false) {
     const token = localStorage.getItem('token');
     
     options.headers = {
@@ -29,9 +77,13 @@ async function fetchWithAuth(url, options = {}) {
     
     const response = await fetch(url, options);
     
-    // If unauthorized, redirect to login
+    // If unauthorized, the access token probably expired: refresh it and try once more
+    // If that doesn't work either, redirect to login
     if (response.status === 401) {
-        logout();
+        if (!retried && await refreshTokens()) {
+            return fetchWithAuth(url, options, true);
+        }
+        clearSession();
     }
     
     return response;
@@ -44,7 +96,8 @@ async function loadExercises() {
         //what if we don't use await here?
         //answer: we would get a Promise object instead of the actual response data
         //promise: an object representing the eventual completion or failure of an asynchronous operation
-        const response = await fetch(`${API_URL}/exercises`);
+        // With the token the list also has the user's own custom exercises
+        const response = await fetchWithAuth(`${API_URL}/exercises`);
         const data = await response.json();
         
         if (response.ok) {
@@ -72,6 +125,9 @@ function displayExercises(exercises) {
         <div class="exercise-item">
             <h3>${ex.name}</h3>
             <p><strong>Muscle:</strong> ${ex.muscle_group}</p>
+            ${ex.equipment ? `<p><strong>Equipment:</strong> ${ex.equipment}</p>` : ''}
+            ${ex.difficulty ? `<p><strong>Difficulty:</strong> ${ex.difficulty}</p>` : ''}
+            ${ex.user_id ? '<p><em>Custom exercise</em></p>' : ''}
             <p>${ex.description}</p>
         </div>
     `).join('');
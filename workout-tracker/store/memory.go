@@ -0,0 +1,1417 @@
+package store
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"workout-tracker/models"
+)
+
+// Memory implements Store with plain Go slices and maps
+// Nothing is saved to disk, so it is meant for tests (httptest) and demos
+//
+// Why the mutex?
+// answer: An HTTP server handles every request in its own goroutine.
+// Without the lock two requests could change the same map at the same time and crash the program.
+type Memory struct {
+	mu sync.Mutex
+
+	users         []models.User
+	exercises     []models.Exercise
+	workouts      []models.Workout
+	schedules     []models.Schedule
+	logs          []models.WorkoutLog
+	programs      []models.Program // sessions are stored without their Workout, it is looked up when reading
+	series        []models.ScheduleSeries
+	feeds         map[int]string // user ID -> hash of the calendar feed token
+	sessions      []models.Session
+	refreshTokens []models.RefreshToken
+	userTokens    []models.UserToken // sent by email: verification and password reset
+	coachings     []models.Coaching  // stored without Coach and Athlete, they are looked up when reading
+
+	nextID int // one counter for all tables keeps the code short, IDs only have to be unique
+}
+
+// errUnknownExercise is what the foreign key on exercise_id would report in Postgres
+// It is not ErrNotFound, because then the handlers would say the workout or schedule wasn't found
+var errUnknownExercise = errors.New("exercise does not exist")
+
+// NewMemory returns an empty in-memory store
+func NewMemory() *Memory {
+	return &Memory{nextID: 1, feeds: map[int]string{}}
+}
+
+func (m *Memory) newID() int {
+	id := m.nextID
+	m.nextID++
+	return id
+}
+
+// AddExercise puts an exercise in the library, tests use it instead of the seeder
+func (m *Memory) AddExercise(exercise models.Exercise) models.Exercise {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	exercise.ID = m.newID()
+	m.exercises = append(m.exercises, exercise)
+	return exercise
+}
+
+// ---- Users ----
+
+func (m *Memory) CreateUser(user *models.User) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, u := range m.users {
+		// like the unique index on LOWER(email) in Postgres
+		if u.Username == user.Username || strings.EqualFold(u.Email, user.Email) {
+			return ErrDuplicate
+		}
+	}
+	user.ID = m.newID()
+	user.Role = models.RoleAthlete // the column default in Postgres
+	user.CreatedAt = time.Now()
+	m.users = append(m.users, *user)
+	return nil
+}
+
+func (m *Memory) GetUserByUsername(username string) (*models.User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, u := range m.users {
+		if u.Username == username {
+			return &u, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (m *Memory) GetUserByID(id int) (*models.User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, u := range m.users {
+		if u.ID == id {
+			u.PasswordHash = "" // like the Postgres query, which doesn't select it
+			return &u, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (m *Memory) GetUserByEmail(email string) (*models.User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, u := range m.users {
+		if strings.EqualFold(u.Email, email) {
+			u.PasswordHash = ""
+			return &u, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+// findUser returns a pointer into m.users, the caller holds the lock
+func (m *Memory) findUser(id int) *models.User {
+	for i := range m.users {
+		if m.users[i].ID == id {
+			return &m.users[i]
+		}
+	}
+	return nil
+}
+
+func (m *Memory) SetEmailVerified(userID int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	user := m.findUser(userID)
+	if user == nil {
+		return ErrNotFound
+	}
+	user.EmailVerified = true
+	return nil
+}
+
+func (m *Memory) SetPassword(userID int, passwordHash string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	user := m.findUser(userID)
+	if user == nil {
+		return ErrNotFound
+	}
+	user.PasswordHash = passwordHash
+	return nil
+}
+
+func (m *Memory) ListUsers() ([]models.User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	users := []models.User{}
+	for _, u := range m.users {
+		u.PasswordHash = ""
+		users = append(users, u)
+	}
+	return users, nil
+}
+
+func (m *Memory) SetRole(userID int, role string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	user := m.findUser(userID)
+	if user == nil {
+		return ErrNotFound
+	}
+	user.Role = role
+	return nil
+}
+
+// ---- Email tokens ----
+
+func (m *Memory) CreateUserToken(token *models.UserToken) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	token.UsedAt = nil
+	token.CreatedAt = time.Now()
+	m.userTokens = append(m.userTokens, *token)
+	return nil
+}
+
+func (m *Memory) UseUserToken(tokenHash, purpose string) (*models.UserToken, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	for _, t := range m.userTokens {
+		if t.TokenHash != tokenHash || t.Purpose != purpose || t.UsedAt != nil || !t.ExpiresAt.After(now) {
+			continue
+		}
+		// Use it, and the other open tokens of the user for the same thing
+		for i := range m.userTokens {
+			other := &m.userTokens[i]
+			if other.UserID == t.UserID && other.Purpose == purpose && other.UsedAt == nil {
+				other.UsedAt = &now
+			}
+		}
+		t.UsedAt = &now
+		return &t, nil
+	}
+	return nil, ErrNotFound
+}
+
+// ---- Exercises ----
+
+func (m *Memory) ListExercises(filter ExerciseFilter) ([]models.Exercise, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	exercises := []models.Exercise{}
+	for _, e := range m.exercises {
+		if filter.OnlyCustom {
+			if e.UserID == nil || *e.UserID != filter.UserID {
+				continue
+			}
+		} else if !visible(e, filter.UserID) {
+			continue
+		}
+		if !sameText(filter.Category, e.Category) || !sameText(filter.MuscleGroup, e.MuscleGroup) ||
+			!sameText(filter.Equipment, e.Equipment) || !sameText(filter.Difficulty, e.Difficulty) {
+			continue
+		}
+		if filter.Query != "" && !matchesQuery(e, filter.Query) {
+			continue
+		}
+		exercises = append(exercises, copyExercise(e))
+	}
+	sort.Slice(exercises, func(i, j int) bool {
+		if exercises[i].Category != exercises[j].Category {
+			return exercises[i].Category < exercises[j].Category
+		}
+		return exercises[i].Name < exercises[j].Name
+	})
+	return exercises, nil
+}
+
+// visible tells whether the user may use the exercise: it is in the library or their own
+func visible(e models.Exercise, userID int) bool {
+	return e.UserID == nil || (userID != 0 && *e.UserID == userID)
+}
+
+// sameOwner tells whether the exercise belongs to userID (0 is the library)
+func sameOwner(e models.Exercise, userID int) bool {
+	if e.UserID == nil {
+		return userID == 0
+	}
+	return *e.UserID == userID
+}
+
+// sameText is true for an empty filter, or when the value matches ignoring upper/lower case
+func sameText(filter, value string) bool {
+	return filter == "" || strings.EqualFold(filter, value)
+}
+
+// matchesQuery is a simple stand-in for the Postgres full text search:
+// a part of the name or an alias, or every word of the query starts a word of the name or description
+func matchesQuery(e models.Exercise, query string) bool {
+	query = strings.ToLower(strings.TrimSpace(query))
+	if strings.Contains(strings.ToLower(e.Name), query) {
+		return true
+	}
+	for _, alias := range e.Aliases {
+		if strings.Contains(strings.ToLower(alias), query) {
+			return true
+		}
+	}
+
+	words := strings.Fields(strings.ToLower(e.Name + " " + e.Description))
+	for _, q := range strings.Fields(query) {
+		found := false
+		for _, word := range words {
+			if strings.HasPrefix(word, q) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// copyExercise returns a copy, so callers can't change the stored aliases by accident
+func copyExercise(e models.Exercise) models.Exercise {
+	e.Aliases = append([]string{}, e.Aliases...)
+	return e
+}
+
+func (m *Memory) exercise(id int) *models.Exercise {
+	for i := range m.exercises {
+		if m.exercises[i].ID == id {
+			e := copyExercise(m.exercises[i])
+			return &e
+		}
+	}
+	return nil
+}
+
+func (m *Memory) GetExercise(id, userID int) (*models.Exercise, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	exercise := m.exercise(id)
+	if exercise == nil || !visible(*exercise, userID) {
+		return nil, ErrNotFound
+	}
+	return exercise, nil
+}
+
+// nameTaken is the unique index on the name: per user, or in the library; the caller holds the lock
+func (m *Memory) nameTaken(exercise *models.Exercise) bool {
+	userID := 0
+	if exercise.UserID != nil {
+		userID = *exercise.UserID
+	}
+	for _, e := range m.exercises {
+		if e.ID != exercise.ID && sameOwner(e, userID) && strings.EqualFold(e.Name, exercise.Name) {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *Memory) CreateExercise(exercise *models.Exercise) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.nameTaken(exercise) {
+		return ErrDuplicate
+	}
+	exercise.ID = m.newID()
+	m.exercises = append(m.exercises, copyExercise(*exercise))
+	return nil
+}
+
+func (m *Memory) UpdateExercise(exercise *models.Exercise) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	userID := 0
+	if exercise.UserID != nil {
+		userID = *exercise.UserID
+	}
+	for i := range m.exercises {
+		if m.exercises[i].ID == exercise.ID && sameOwner(m.exercises[i], userID) {
+			if m.nameTaken(exercise) {
+				return ErrDuplicate
+			}
+			m.exercises[i] = copyExercise(*exercise)
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+func (m *Memory) DeleteExercise(id, userID int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	index := -1
+	for i, e := range m.exercises {
+		if e.ID == id && sameOwner(e, userID) {
+			index = i
+		}
+	}
+	if index == -1 {
+		return ErrNotFound
+	}
+
+	// same checks as Postgres: workouts, logs and program lifts
+	for _, w := range m.workouts {
+		for _, we := range w.Exercises {
+			if we.ExerciseID == id {
+				return ErrInUse
+			}
+		}
+	}
+	for _, l := range m.logs {
+		if l.ExerciseID == id {
+			return ErrInUse
+		}
+	}
+	for _, p := range m.programs {
+		for _, lift := range p.Lifts {
+			if lift.ExerciseID == id {
+				return ErrInUse
+			}
+		}
+	}
+
+	m.exercises = append(m.exercises[:index], m.exercises[index+1:]...)
+	return nil
+}
+
+// ---- Coaching ----
+
+// findCoaching returns a pointer into m.coachings, the caller holds the lock
+func (m *Memory) findCoaching(coachID, athleteID int) *models.Coaching {
+	for i := range m.coachings {
+		if m.coachings[i].CoachID == coachID && m.coachings[i].AthleteID == athleteID {
+			return &m.coachings[i]
+		}
+	}
+	return nil
+}
+
+func (m *Memory) InviteAthlete(coachID, athleteID int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.findCoaching(coachID, athleteID) != nil {
+		return ErrDuplicate
+	}
+	m.coachings = append(m.coachings, models.Coaching{
+		CoachID:   coachID,
+		AthleteID: athleteID,
+		Status:    models.CoachingPending,
+		CreatedAt: time.Now(),
+	})
+	return nil
+}
+
+func (m *Memory) AcceptCoach(coachID, athleteID int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	coaching := m.findCoaching(coachID, athleteID)
+	if coaching == nil || coaching.Status != models.CoachingPending {
+		return ErrNotFound
+	}
+	now := time.Now()
+	coaching.Status = models.CoachingActive
+	coaching.AcceptedAt = &now
+	return nil
+}
+
+func (m *Memory) EndCoaching(coachID, athleteID int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i, c := range m.coachings {
+		if c.CoachID == coachID && c.AthleteID == athleteID {
+			m.coachings = append(m.coachings[:i], m.coachings[i+1:]...)
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+// publicUser returns a copy of the user without the password hash, the caller holds the lock
+func (m *Memory) publicUser(id int) *models.User {
+	user := m.findUser(id)
+	if user == nil {
+		return nil
+	}
+	u := *user
+	u.PasswordHash = ""
+	return &u
+}
+
+func (m *Memory) ListAthletes(coachID int) ([]models.Coaching, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	list := []models.Coaching{}
+	for _, c := range m.coachings {
+		if c.CoachID == coachID {
+			c.Athlete = m.publicUser(c.AthleteID)
+			list = append(list, c)
+		}
+	}
+	return list, nil
+}
+
+func (m *Memory) ListCoaches(athleteID int) ([]models.Coaching, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	list := []models.Coaching{}
+	for _, c := range m.coachings {
+		if c.AthleteID == athleteID {
+			c.Coach = m.publicUser(c.CoachID)
+			list = append(list, c)
+		}
+	}
+	return list, nil
+}
+
+func (m *Memory) IsCoachOf(coachID, athleteID int) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	coaching := m.findCoaching(coachID, athleteID)
+	return coaching != nil && coaching.Status == models.CoachingActive, nil
+}
+
+// ---- Workouts ----
+
+// saveExercises gives the workout's exercises their IDs and details
+// An unknown exercise ID fails, like the foreign key does in Postgres
+func (m *Memory) saveExercises(workout *models.Workout) error {
+	exercises := []models.WorkoutExercise{}
+	for _, we := range workout.Exercises {
+		exercise := m.exercise(we.ExerciseID)
+		if exercise == nil {
+			return errUnknownExercise
+		}
+		we.ID = m.newID()
+		we.WorkoutID = workout.ID
+		we.Exercise = exercise
+		exercises = append(exercises, we)
+	}
+	workout.Exercises = exercises
+	return nil
+}
+
+func (m *Memory) CreateWorkout(workout *models.Workout) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.createWorkout(workout)
+}
+
+// createWorkout saves a workout, the caller holds the lock
+func (m *Memory) createWorkout(workout *models.Workout) error {
+	saved := *workout
+	saved.ID = m.newID()
+	if err := m.saveExercises(&saved); err != nil {
+		return err
+	}
+	saved.CreatedAt = time.Now()
+	saved.UpdatedAt = saved.CreatedAt
+	m.workouts = append(m.workouts, saved)
+
+	*workout = saved
+	return nil
+}
+
+// copyWorkout returns a copy, so callers can't change the stored workout by accident
+func copyWorkout(w models.Workout) models.Workout {
+	w.Exercises = append([]models.WorkoutExercise{}, w.Exercises...)
+	return w
+}
+
+func (m *Memory) ListWorkouts(userID int) ([]models.Workout, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	workouts := []models.Workout{}
+	for _, w := range m.workouts {
+		if w.UserID == userID {
+			workouts = append(workouts, copyWorkout(w))
+		}
+	}
+	// Newest first
+	sort.SliceStable(workouts, func(i, j int) bool { return workouts[i].CreatedAt.After(workouts[j].CreatedAt) })
+	return workouts, nil
+}
+
+func (m *Memory) findWorkout(id, userID int) *models.Workout {
+	for i := range m.workouts {
+		if m.workouts[i].ID == id && m.workouts[i].UserID == userID {
+			return &m.workouts[i]
+		}
+	}
+	return nil
+}
+
+func (m *Memory) GetWorkout(id, userID int) (*models.Workout, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	w := m.findWorkout(id, userID)
+	if w == nil {
+		return nil, ErrNotFound
+	}
+	workout := copyWorkout(*w)
+	return &workout, nil
+}
+
+func (m *Memory) UpdateWorkout(workout *models.Workout) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	w := m.findWorkout(workout.ID, workout.UserID)
+	if w == nil {
+		return ErrNotFound
+	}
+	updated := *w
+	updated.Name = workout.Name
+	updated.Description = workout.Description
+	updated.Exercises = workout.Exercises
+	if err := m.saveExercises(&updated); err != nil {
+		return err
+	}
+	updated.UpdatedAt = time.Now()
+	*w = updated
+	return nil
+}
+
+func (m *Memory) DeleteWorkout(id, userID int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.deleteWorkout(id, userID)
+}
+
+// deleteWorkout removes a workout with its schedules, the caller holds the lock
+func (m *Memory) deleteWorkout(id, userID int) error {
+	for i, w := range m.workouts {
+		if w.ID == id && w.UserID == userID {
+			m.workouts = append(m.workouts[:i], m.workouts[i+1:]...)
+			// Postgres deletes the schedules (and their logs) of the workout too
+			// Collect the IDs first because deleteSchedule changes m.schedules
+			scheduleIDs := []int{}
+			for _, s := range m.schedules {
+				if s.WorkoutID == id {
+					scheduleIDs = append(scheduleIDs, s.ID)
+				}
+			}
+			for _, scheduleID := range scheduleIDs {
+				m.deleteSchedule(scheduleID)
+			}
+			// And the series that repeat it
+			series := m.series[:0]
+			for _, sr := range m.series {
+				if sr.WorkoutID != id {
+					series = append(series, sr)
+				}
+			}
+			m.series = series
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+func (m *Memory) WorkoutOwner(id int) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, w := range m.workouts {
+		if w.ID == id {
+			return w.UserID, nil
+		}
+	}
+	return 0, ErrNotFound
+}
+
+// ---- Schedules ----
+
+func (m *Memory) CreateSchedule(schedule *models.Schedule) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.createSchedule(schedule)
+	return nil
+}
+
+// createSchedule saves a schedule, the caller holds the lock
+func (m *Memory) createSchedule(schedule *models.Schedule) {
+	saved := *schedule
+	saved.ID = m.newID()
+	saved.Completed = false
+	saved.CompletedAt = nil
+	saved.Workout = nil // filled in when reading, so it always shows the current workout
+	m.schedules = append(m.schedules, saved)
+	schedule.ID = saved.ID
+}
+
+// withWorkout returns a copy of the schedule with its workout, like the JOIN in Postgres
+// The JOIN only returns the workout columns, so the copy has no exercises either
+func (m *Memory) withWorkout(s models.Schedule) models.Schedule {
+	for _, w := range m.workouts {
+		if w.ID == s.WorkoutID {
+			workout := w
+			workout.Exercises = nil
+			s.Workout = &workout
+		}
+	}
+	return s
+}
+
+func (m *Memory) ListSchedules(userID int, filter ScheduleFilter) ([]models.Schedule, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	schedules := []models.Schedule{}
+	for _, s := range m.schedules {
+		if s.UserID != userID {
+			continue
+		}
+		if filter.Upcoming && s.ScheduledDate.Before(now) {
+			continue
+		}
+		if filter.Completed != nil && s.Completed != *filter.Completed {
+			continue
+		}
+		schedules = append(schedules, m.withWorkout(s))
+	}
+	sort.SliceStable(schedules, func(i, j int) bool { return schedules[i].ScheduledDate.Before(schedules[j].ScheduledDate) })
+	return schedules, nil
+}
+
+func (m *Memory) GetSchedule(id, userID int) (*models.Schedule, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, s := range m.schedules {
+		if s.ID == id && s.UserID == userID {
+			schedule := m.withWorkout(s)
+			return &schedule, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (m *Memory) CompleteSchedule(id, userID int, notes string, logs []models.WorkoutLog) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i := range m.schedules {
+		s := &m.schedules[i]
+		if s.ID != id || s.UserID != userID {
+			continue
+		}
+		if s.Completed {
+			return ErrCompleted
+		}
+		// Check every log before changing anything, so it is all or nothing like the transaction
+		for _, log := range logs {
+			if m.exercise(log.ExerciseID) == nil {
+				return errUnknownExercise
+			}
+		}
+
+		now := time.Now()
+		s.Completed = true
+		s.CompletedAt = &now
+		s.Notes = notes
+		for _, log := range logs {
+			log.ID = m.newID()
+			log.ScheduleID = id
+			log.LoggedAt = now
+			sets := []models.WorkoutSet{}
+			for _, set := range log.Sets {
+				set.ID = m.newID()
+				set.WorkoutLogID = log.ID
+				sets = append(sets, set)
+			}
+			log.Sets = sets
+			m.logs = append(m.logs, log)
+		}
+		return nil
+	}
+	return ErrNotFound
+}
+
+// deleteSchedule removes a schedule and its logs, the caller holds the lock
+func (m *Memory) deleteSchedule(id int) {
+	schedules := m.schedules[:0]
+	for _, s := range m.schedules {
+		if s.ID != id {
+			schedules = append(schedules, s)
+		}
+	}
+	m.schedules = schedules
+
+	logs := m.logs[:0]
+	for _, l := range m.logs {
+		if l.ScheduleID != id {
+			logs = append(logs, l)
+		}
+	}
+	m.logs = logs
+}
+
+func (m *Memory) UpdateSchedule(schedule *models.Schedule) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i := range m.schedules {
+		s := &m.schedules[i]
+		if s.ID == schedule.ID && s.UserID == schedule.UserID {
+			s.WorkoutID = schedule.WorkoutID
+			s.ScheduledDate = schedule.ScheduledDate
+			s.Notes = schedule.Notes
+			s.Detached = s.SeriesID != nil
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+func (m *Memory) DeleteSchedule(id, userID int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, s := range m.schedules {
+		if s.ID == id && s.UserID == userID {
+			// Remember the date if it is an occurrence of a series
+			if s.SeriesID != nil && s.SeriesDate != nil {
+				if series := m.findSeries(*s.SeriesID, userID); series != nil {
+					series.Exceptions = append(series.Exceptions, *s.SeriesDate)
+				}
+			}
+			m.deleteSchedule(id)
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+// ---- Schedule series ----
+
+func (m *Memory) findSeries(id, userID int) *models.ScheduleSeries {
+	for i := range m.series {
+		if m.series[i].ID == id && m.series[i].UserID == userID {
+			return &m.series[i]
+		}
+	}
+	return nil
+}
+
+// addOccurrences adds one schedule per date for the series, the caller holds the lock
+func (m *Memory) addOccurrences(series *models.ScheduleSeries, dates []time.Time) {
+	for _, date := range dates {
+		seriesID := series.ID
+		seriesDate := date
+		m.createSchedule(&models.Schedule{
+			UserID:        series.UserID,
+			WorkoutID:     series.WorkoutID,
+			ScheduledDate: date,
+			Notes:         series.Notes,
+			SeriesID:      &seriesID,
+			SeriesDate:    &seriesDate,
+		})
+	}
+}
+
+func (m *Memory) CreateSeries(series *models.ScheduleSeries, dates []time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	series.ID = m.newID()
+	series.CreatedAt = time.Now()
+	series.Exceptions = []time.Time{}
+	saved := *series
+	saved.Weekdays = append([]string{}, series.Weekdays...)
+	saved.Occurrences = nil
+	m.series = append(m.series, saved)
+
+	m.addOccurrences(series, dates)
+	return nil
+}
+
+// copySeries returns a copy without occurrences, so callers can't change the stored series
+func copySeries(s models.ScheduleSeries) models.ScheduleSeries {
+	s.Weekdays = append([]string{}, s.Weekdays...)
+	s.Exceptions = append([]time.Time{}, s.Exceptions...)
+	s.Occurrences = nil
+	return s
+}
+
+func (m *Memory) ListSeries(userID int) ([]models.ScheduleSeries, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	list := []models.ScheduleSeries{}
+	// Newest first: series are appended, so walk the slice backwards
+	for i := len(m.series) - 1; i >= 0; i-- {
+		if m.series[i].UserID == userID {
+			list = append(list, copySeries(m.series[i]))
+		}
+	}
+	return list, nil
+}
+
+func (m *Memory) GetSeries(id, userID int) (*models.ScheduleSeries, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	s := m.findSeries(id, userID)
+	if s == nil {
+		return nil, ErrNotFound
+	}
+	series := copySeries(*s)
+	series.Occurrences = []models.Schedule{}
+	for _, schedule := range m.schedules {
+		if schedule.SeriesID != nil && *schedule.SeriesID == id {
+			series.Occurrences = append(series.Occurrences, m.withWorkout(schedule))
+		}
+	}
+	sort.SliceStable(series.Occurrences, func(i, j int) bool {
+		return series.Occurrences[i].ScheduledDate.Before(series.Occurrences[j].ScheduledDate)
+	})
+	return &series, nil
+}
+
+func (m *Memory) UpdateSeries(series *models.ScheduleSeries, from time.Time, dates []time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	s := m.findSeries(series.ID, series.UserID)
+	if s == nil {
+		return ErrNotFound
+	}
+	s.WorkoutID = series.WorkoutID
+	s.Weekdays = append([]string{}, series.Weekdays...)
+	s.Time = series.Time
+	s.StartDate = series.StartDate
+	s.Weeks = series.Weeks
+	s.Notes = series.Notes
+
+	// Completed, detached and past occurrences stay, the rest is replaced
+	// Collect the IDs first because deleteSchedule changes m.schedules
+	replaced := []int{}
+	for _, schedule := range m.schedules {
+		if schedule.SeriesID != nil && *schedule.SeriesID == series.ID &&
+			!schedule.Completed && !schedule.Detached && !schedule.ScheduledDate.Before(from) {
+			replaced = append(replaced, schedule.ID)
+		}
+	}
+	for _, id := range replaced {
+		m.deleteSchedule(id)
+	}
+	m.addOccurrences(series, dates)
+	return nil
+}
+
+func (m *Memory) DeleteSeries(id, userID int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i, s := range m.series {
+		if s.ID != id || s.UserID != userID {
+			continue
+		}
+		open := []int{}
+		for j := range m.schedules {
+			schedule := &m.schedules[j]
+			if schedule.SeriesID == nil || *schedule.SeriesID != id {
+				continue
+			}
+			if schedule.Completed {
+				schedule.SeriesID = nil // like ON DELETE SET NULL
+			} else {
+				open = append(open, schedule.ID)
+			}
+		}
+		for _, scheduleID := range open {
+			m.deleteSchedule(scheduleID)
+		}
+		m.series = append(m.series[:i], m.series[i+1:]...)
+		return nil
+	}
+	return ErrNotFound
+}
+
+// ---- Calendar feed ----
+
+func (m *Memory) SetCalendarToken(userID int, tokenHash string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.feeds[userID] = tokenHash
+	return nil
+}
+
+func (m *Memory) DeleteCalendarToken(userID int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.feeds[userID]; !ok {
+		return ErrNotFound
+	}
+	delete(m.feeds, userID)
+	return nil
+}
+
+func (m *Memory) CalendarUser(tokenHash string) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for userID, hash := range m.feeds {
+		if hash == tokenHash {
+			return userID, nil
+		}
+	}
+	return 0, ErrNotFound
+}
+
+// ---- Sessions and refresh tokens ----
+
+func (m *Memory) CreateSession(session *models.Session, token *models.RefreshToken) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	session.ID = m.newID()
+	session.CreatedAt = time.Now()
+	session.RevokedAt = nil
+	m.sessions = append(m.sessions, *session)
+
+	token.SessionID = session.ID
+	token.UserID = session.UserID
+	token.UsedAt = nil
+	token.CreatedAt = time.Now()
+	m.refreshTokens = append(m.refreshTokens, *token)
+	return nil
+}
+
+func (m *Memory) GetRefreshToken(tokenHash string) (*models.RefreshToken, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, t := range m.refreshTokens {
+		if t.TokenHash == tokenHash {
+			return &t, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (m *Memory) RotateRefreshToken(oldHash string, next *models.RefreshToken) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var old *models.RefreshToken
+	for i := range m.refreshTokens {
+		if m.refreshTokens[i].TokenHash == oldHash {
+			old = &m.refreshTokens[i]
+		}
+	}
+	if old == nil {
+		return ErrNotFound
+	}
+	if old.UsedAt != nil {
+		return ErrTokenUsed
+	}
+	now := time.Now()
+	old.UsedAt = &now
+
+	next.UsedAt = nil
+	next.CreatedAt = now
+	m.refreshTokens = append(m.refreshTokens, *next)
+
+	// Expired tokens of the session are not needed anymore
+	tokens := m.refreshTokens[:0]
+	for _, t := range m.refreshTokens {
+		if t.SessionID != next.SessionID || !t.ExpiresAt.Before(now) {
+			tokens = append(tokens, t)
+		}
+	}
+	m.refreshTokens = tokens
+	return nil
+}
+
+func (m *Memory) SessionActive(sessionID int) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, s := range m.sessions {
+		if s.ID == sessionID {
+			return s.RevokedAt == nil, nil
+		}
+	}
+	return false, nil
+}
+
+func (m *Memory) RevokeUserSessions(userID int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	for i := range m.sessions {
+		if m.sessions[i].UserID == userID && m.sessions[i].RevokedAt == nil {
+			m.sessions[i].RevokedAt = &now
+		}
+	}
+	return nil
+}
+
+func (m *Memory) RevokeSession(sessionID int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i := range m.sessions {
+		if m.sessions[i].ID == sessionID && m.sessions[i].RevokedAt == nil {
+			now := time.Now()
+			m.sessions[i].RevokedAt = &now
+		}
+	}
+	return nil
+}
+
+// ---- Progress ----
+
+// userLogs returns the logs of a user's schedules, the caller holds the lock
+func (m *Memory) userLogs(userID int) []models.WorkoutLog {
+	owned := map[int]bool{}
+	for _, s := range m.schedules {
+		if s.UserID == userID {
+			owned[s.ID] = true
+		}
+	}
+	logs := []models.WorkoutLog{}
+	for _, l := range m.logs {
+		if owned[l.ScheduleID] {
+			logs = append(logs, l)
+		}
+	}
+	return logs
+}
+
+func (m *Memory) GetProgress(userID int) (*models.ProgressReport, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	report := models.ProgressReport{UserID: userID}
+	weekAgo := time.Now().AddDate(0, 0, -7)
+	monthAgo := time.Now().AddDate(0, 0, -30)
+
+	var start *time.Time
+	for _, s := range m.schedules {
+		if s.UserID != userID || !s.Completed || s.CompletedAt == nil {
+			continue
+		}
+		report.TotalWorkouts++
+		if !s.CompletedAt.Before(weekAgo) {
+			report.WorkoutsThisWeek++
+		}
+		if !s.CompletedAt.Before(monthAgo) {
+			report.WorkoutsThisMonth++
+		}
+		if start == nil || s.CompletedAt.Before(*start) {
+			start = s.CompletedAt
+		}
+	}
+
+	// Count the logs per exercise name to find the most frequent one
+	logs := m.userLogs(userID)
+	report.TotalExercises = len(logs)
+	counts := map[string]int{}
+	for _, l := range logs {
+		if e := m.exercise(l.ExerciseID); e != nil {
+			counts[e.Name]++
+		}
+	}
+	mostFrequent := ""
+	for name, count := range counts {
+		// Ties go to the first name alphabetically, like the ORDER BY in Postgres
+		if count > counts[mostFrequent] || (count == counts[mostFrequent] && name < mostFrequent) {
+			mostFrequent = name
+		}
+	}
+
+	finishReport(&report, start, mostFrequent)
+	return &report, nil
+}
+
+func (m *Memory) GetExerciseHistory(userID, exerciseID, limit int) ([]models.ExerciseHistoryEntry, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	history := []models.ExerciseHistoryEntry{}
+	exercise := m.exercise(exerciseID)
+	if exercise == nil {
+		return history, nil
+	}
+	for _, l := range m.userLogs(userID) {
+		if l.ExerciseID != exerciseID {
+			continue
+		}
+		history = append(history, models.ExerciseHistoryEntry{
+			ScheduleID:    l.ScheduleID,
+			SetsCompleted: l.SetsCompleted,
+			RepsCompleted: l.RepsCompleted,
+			WeightUsed:    l.WeightUsed,
+			Duration:      l.Duration,
+			Notes:         l.Notes,
+			LoggedAt:      l.LoggedAt,
+			ExerciseName:  exercise.Name,
+			Category:      exercise.Category,
+			Sets:          append([]models.WorkoutSet{}, l.Sets...),
+		})
+	}
+
+	// Newest first, then keep only the first "limit" entries
+	sort.SliceStable(history, func(i, j int) bool { return history[i].LoggedAt.After(history[j].LoggedAt) })
+	if len(history) > limit {
+		history = history[:limit]
+	}
+	return history, nil
+}
+
+func (m *Memory) ListLoggedSets(userID int, filter SetFilter) ([]models.LoggedSet, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	sets := []models.LoggedSet{}
+	for _, l := range m.userLogs(userID) {
+		if filter.ExerciseID != 0 && l.ExerciseID != filter.ExerciseID {
+			continue
+		}
+		if (!filter.From.IsZero() && l.LoggedAt.Before(filter.From)) || (!filter.To.IsZero() && l.LoggedAt.After(filter.To)) {
+			continue
+		}
+		exercise := m.exercise(l.ExerciseID)
+		if exercise == nil {
+			continue
+		}
+		for _, set := range l.Sets {
+			sets = append(sets, models.LoggedSet{
+				WorkoutSet:   set,
+				ScheduleID:   l.ScheduleID,
+				ExerciseID:   l.ExerciseID,
+				ExerciseName: exercise.Name,
+				Category:     exercise.Category,
+				MuscleGroup:  exercise.MuscleGroup,
+				LoggedAt:     l.LoggedAt,
+			})
+		}
+	}
+	// Oldest first, the logs are already in the order they were saved
+	sort.SliceStable(sets, func(i, j int) bool { return sets[i].LoggedAt.Before(sets[j].LoggedAt) })
+	return sets, nil
+}
+
+// ---- Programs ----
+
+// checkProgramExercises makes sure every exercise of the program exists,
+// so CreateProgram and UpdateProgram can fail before they change anything
+func (m *Memory) checkProgramExercises(program *models.Program) error {
+	for _, lift := range program.Lifts {
+		if m.exercise(lift.ExerciseID) == nil {
+			return errUnknownExercise
+		}
+	}
+	for _, session := range program.Sessions {
+		if session.Workout == nil {
+			continue
+		}
+		for _, we := range session.Workout.Exercises {
+			if m.exercise(we.ExerciseID) == nil {
+				return errUnknownExercise
+			}
+		}
+	}
+	return nil
+}
+
+func (m *Memory) CreateProgram(program *models.Program) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.checkProgramExercises(program); err != nil {
+		return err
+	}
+
+	program.ID = m.newID()
+	program.CreatedAt = time.Now()
+	for i := range program.Sessions {
+		session := &program.Sessions[i]
+		if err := m.createWorkout(session.Workout); err != nil {
+			return err
+		}
+		schedule := models.Schedule{UserID: program.UserID, WorkoutID: session.Workout.ID, ScheduledDate: session.ScheduledDate}
+		m.createSchedule(&schedule)
+
+		session.ID = m.newID()
+		session.ProgramID = program.ID
+		session.WorkoutID = session.Workout.ID
+		session.ScheduleID = schedule.ID
+	}
+
+	saved := *program
+	saved.Lifts = append([]models.ProgramLift{}, program.Lifts...)
+	saved.Sessions = []models.ProgramSession{}
+	for _, session := range program.Sessions {
+		session.Workout = nil
+		saved.Sessions = append(saved.Sessions, session)
+	}
+	m.programs = append(m.programs, saved)
+	return nil
+}
+
+// withSessions returns a copy of the program with the date and workout of every session,
+// like the JOINs in Postgres. Sessions whose schedule or workout was deleted are left out.
+// The caller holds the lock.
+func (m *Memory) withSessions(p models.Program) models.Program {
+	p.Lifts = append([]models.ProgramLift{}, p.Lifts...)
+	sessions := []models.ProgramSession{}
+	for _, session := range p.Sessions {
+		schedule := -1
+		for i, s := range m.schedules {
+			if s.ID == session.ScheduleID {
+				schedule = i
+			}
+		}
+		w := m.findWorkout(session.WorkoutID, p.UserID)
+		if schedule < 0 || w == nil {
+			continue
+		}
+		workout := copyWorkout(*w)
+		session.ScheduledDate = m.schedules[schedule].ScheduledDate
+		session.Workout = &workout
+		sessions = append(sessions, session)
+	}
+	sort.SliceStable(sessions, func(i, j int) bool { return sessions[i].ScheduledDate.Before(sessions[j].ScheduledDate) })
+	p.Sessions = sessions
+	return p
+}
+
+func (m *Memory) ListPrograms(userID int) ([]models.Program, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	programs := []models.Program{}
+	// Newest first: programs are appended, so walk the slice backwards
+	for i := len(m.programs) - 1; i >= 0; i-- {
+		if m.programs[i].UserID == userID {
+			programs = append(programs, m.withSessions(m.programs[i]))
+		}
+	}
+	return programs, nil
+}
+
+func (m *Memory) findProgram(id, userID int) *models.Program {
+	for i := range m.programs {
+		if m.programs[i].ID == id && m.programs[i].UserID == userID {
+			return &m.programs[i]
+		}
+	}
+	return nil
+}
+
+func (m *Memory) GetProgram(id, userID int) (*models.Program, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	p := m.findProgram(id, userID)
+	if p == nil {
+		return nil, ErrNotFound
+	}
+	program := m.withSessions(*p)
+	return &program, nil
+}
+
+func (m *Memory) UpdateProgram(program *models.Program) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	p := m.findProgram(program.ID, program.UserID)
+	if p == nil {
+		return ErrNotFound
+	}
+	if err := m.checkProgramExercises(program); err != nil {
+		return err
+	}
+
+	p.Status = program.Status
+	p.Lifts = append([]models.ProgramLift{}, program.Lifts...)
+	for _, session := range program.Sessions {
+		for i := range p.Sessions {
+			stored := &p.Sessions[i]
+			if stored.ID != session.ID {
+				continue
+			}
+			stored.Status = session.Status
+			stored.Note = session.Note
+			for j := range m.schedules {
+				if m.schedules[j].ID == stored.ScheduleID {
+					m.schedules[j].ScheduledDate = session.ScheduledDate
+				}
+			}
+			// Only planned sessions get new targets, like in Postgres
+			if session.Status != models.SessionPlanned || session.Workout == nil {
+				continue
+			}
+			if w := m.findWorkout(stored.WorkoutID, p.UserID); w != nil {
+				w.Exercises = session.Workout.Exercises
+				m.saveExercises(w) // can't fail, checkProgramExercises checked the IDs
+				w.UpdatedAt = time.Now()
+			}
+		}
+	}
+	return nil
+}
+
+func (m *Memory) DeleteProgram(id, userID int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i, p := range m.programs {
+		if p.ID != id || p.UserID != userID {
+			continue
+		}
+		// Sessions that were never done lose their workout (and schedule), done ones stay in the history
+		for _, session := range p.Sessions {
+			if session.Status == models.SessionPlanned || session.Status == models.SessionSkipped {
+				m.deleteWorkout(session.WorkoutID, userID)
+			}
+		}
+		m.programs = append(m.programs[:i], m.programs[i+1:]...)
+		return nil
+	}
+	return ErrNotFound
+}
@@ -0,0 +1,1718 @@
+package store
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"workout-tracker/models"
+
+	"github.com/lib/pq"
+)
+
+// Postgres implements Store with the SQL that used to live in the handlers
+type Postgres struct {
+	DB *sql.DB
+}
+
+// NewPostgres wraps an open database connection (see database.Connect)
+func NewPostgres(db *sql.DB) *Postgres {
+	return &Postgres{DB: db}
+}
+
+// notFound turns sql.ErrNoRows into our own ErrNotFound
+// so the handlers don't have to know about database/sql
+func notFound(err error) error {
+	if err == sql.ErrNoRows {
+		return ErrNotFound
+	}
+	return err
+}
+
+// checkAffected returns ErrNotFound when an UPDATE or DELETE matched no rows
+func checkAffected(result sql.Result) error {
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// ---- Users ----
+
+func (p *Postgres) CreateUser(user *models.User) error {
+	err := p.DB.QueryRow(
+		`INSERT INTO users (username, email, password_hash)
+		 VALUES ($1, $2, $3) RETURNING id, role, created_at`,
+		user.Username, user.Email, user.PasswordHash,
+	).Scan(&user.ID, &user.Role, &user.CreatedAt)
+
+	// 23505 is the Postgres error code for "unique_violation"
+	// It is safer than comparing the error message text
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+		return ErrDuplicate
+	}
+	return err
+}
+
+func (p *Postgres) GetUserByUsername(username string) (*models.User, error) {
+	var user models.User
+	err := p.DB.QueryRow(
+		`SELECT id, username, email, password_hash, email_verified, role, created_at
+		 FROM users WHERE username = $1`,
+		username,
+	).Scan(&user.ID, &user.Username, &user.Email, &user.PasswordHash, &user.EmailVerified, &user.Role, &user.CreatedAt)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	return &user, nil
+}
+
+func (p *Postgres) GetUserByID(id int) (*models.User, error) {
+	var user models.User
+	err := p.DB.QueryRow(
+		`SELECT id, username, email, email_verified, role, created_at FROM users WHERE id = $1`,
+		id,
+	).Scan(&user.ID, &user.Username, &user.Email, &user.EmailVerified, &user.Role, &user.CreatedAt)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	return &user, nil
+}
+
+func (p *Postgres) GetUserByEmail(email string) (*models.User, error) {
+	var user models.User
+	err := p.DB.QueryRow(
+		`SELECT id, username, email, email_verified, role, created_at FROM users WHERE LOWER(email) = LOWER($1)`,
+		email,
+	).Scan(&user.ID, &user.Username, &user.Email, &user.EmailVerified, &user.Role, &user.CreatedAt)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	return &user, nil
+}
+
+func (p *Postgres) SetEmailVerified(userID int) error {
+	result, err := p.DB.Exec(`UPDATE users SET email_verified = TRUE WHERE id = $1`, userID)
+	if err != nil {
+		return err
+	}
+	return checkAffected(result)
+}
+
+func (p *Postgres) SetPassword(userID int, passwordHash string) error {
+	result, err := p.DB.Exec(`UPDATE users SET password_hash = $1 WHERE id = $2`, passwordHash, userID)
+	if err != nil {
+		return err
+	}
+	return checkAffected(result)
+}
+
+func (p *Postgres) ListUsers() ([]models.User, error) {
+	rows, err := p.DB.Query(`SELECT id, username, email, email_verified, role, created_at FROM users ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := []models.User{}
+	for rows.Next() {
+		var user models.User
+		if err := rows.Scan(&user.ID, &user.Username, &user.Email, &user.EmailVerified, &user.Role, &user.CreatedAt); err != nil {
+			return nil, err
+		}
+		users = append(users, user)
+	}
+	return users, rows.Err()
+}
+
+func (p *Postgres) SetRole(userID int, role string) error {
+	result, err := p.DB.Exec(`UPDATE users SET role = $1 WHERE id = $2`, role, userID)
+	if err != nil {
+		return err
+	}
+	return checkAffected(result)
+}
+
+// ---- Email tokens ----
+
+func (p *Postgres) CreateUserToken(token *models.UserToken) error {
+	return p.DB.QueryRow(`
+		INSERT INTO user_tokens (token_hash, user_id, purpose, expires_at, created_at)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING created_at
+	`, token.TokenHash, token.UserID, token.Purpose, token.ExpiresAt, time.Now()).Scan(&token.CreatedAt)
+}
+
+func (p *Postgres) UseUserToken(tokenHash, purpose string) (*models.UserToken, error) {
+	tx, err := p.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// One UPDATE checks and uses the token, so two requests can't both use it
+	now := time.Now()
+	var token models.UserToken
+	err = tx.QueryRow(`
+		UPDATE user_tokens SET used_at = $1
+		WHERE token_hash = $2 AND purpose = $3 AND used_at IS NULL AND expires_at > $1
+		RETURNING token_hash, user_id, purpose, expires_at, used_at, created_at
+	`, now, tokenHash, purpose).Scan(&token.TokenHash, &token.UserID, &token.Purpose, &token.ExpiresAt, &token.UsedAt, &token.CreatedAt)
+	if err != nil {
+		return nil, notFound(err)
+	}
+
+	// The other open tokens for the same thing stop working too
+	_, err = tx.Exec(`
+		UPDATE user_tokens SET used_at = $1 WHERE user_id = $2 AND purpose = $3 AND used_at IS NULL
+	`, now, token.UserID, purpose)
+	if err != nil {
+		return nil, err
+	}
+	return &token, tx.Commit()
+}
+
+// ---- Exercises ----
+
+// exerciseColumns is shared by ListExercises and GetExercise, scanned by scanExercise
+const exerciseColumns = `
+	SELECT id, name, description, category, muscle_group, equipment, difficulty, aliases, user_id
+	FROM exercises
+`
+
+func scanExercise(row scanner) (*models.Exercise, error) {
+	var exercise models.Exercise
+	err := row.Scan(
+		&exercise.ID,
+		&exercise.Name,
+		&exercise.Description,
+		&exercise.Category,
+		&exercise.MuscleGroup,
+		&exercise.Equipment,
+		&exercise.Difficulty,
+		pq.Array(&exercise.Aliases), // TEXT[] needs pq.Array to become a []string
+		&exercise.UserID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if exercise.Aliases == nil {
+		exercise.Aliases = []string{}
+	}
+	return &exercise, nil
+}
+
+// owner turns a user ID into the user_id column value, 0 is the library (NULL)
+func owner(userID int) interface{} {
+	if userID == 0 {
+		return nil
+	}
+	return userID
+}
+
+func (p *Postgres) ListExercises(filter ExerciseFilter) ([]models.Exercise, error) {
+	query := exerciseColumns
+	args := []interface{}{}
+
+	// The library and the user's own exercises, or only the user's own
+	if filter.OnlyCustom {
+		args = append(args, filter.UserID)
+		query += fmt.Sprintf(` WHERE user_id = $%d`, len(args))
+	} else {
+		args = append(args, owner(filter.UserID))
+		query += fmt.Sprintf(` WHERE (user_id IS NULL OR user_id = $%d)`, len(args))
+	}
+
+	// Add the filters that are set, each one gets the next $ number
+	columns := []struct{ column, value string }{
+		{"category", filter.Category},
+		{"muscle_group", filter.MuscleGroup},
+		{"equipment", filter.Equipment},
+		{"difficulty", filter.Difficulty},
+	}
+	for _, c := range columns {
+		if c.value != "" {
+			args = append(args, c.value)
+			query += fmt.Sprintf(` AND LOWER(%s) = LOWER($%d)`, c.column, len(args))
+		}
+	}
+	if filter.Query != "" {
+		args = append(args, filter.Query)
+		n := len(args)
+		// the full text search finds words in any form ("pressing" finds "Press"),
+		// ILIKE finds a part of the name or an alias ("bench" while the user is still typing)
+		query += fmt.Sprintf(` AND (
+			to_tsvector('english', name || ' ' || COALESCE(description, '')) @@ plainto_tsquery('english', $%d)
+			OR name ILIKE '%%' || $%d || '%%'
+			OR EXISTS (SELECT 1 FROM unnest(aliases) AS alias WHERE alias ILIKE '%%' || $%d || '%%')
+		)`, n, n, n)
+	}
+	query += ` ORDER BY category, name`
+
+	rows, err := p.DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	exercises := []models.Exercise{}
+	for rows.Next() {
+		exercise, err := scanExercise(rows)
+		if err != nil {
+			return nil, err
+		}
+		exercises = append(exercises, *exercise)
+	}
+	return exercises, rows.Err()
+}
+
+func (p *Postgres) GetExercise(id, userID int) (*models.Exercise, error) {
+	row := p.DB.QueryRow(exerciseColumns+` WHERE id = $1 AND (user_id IS NULL OR user_id = $2)`, id, owner(userID))
+	exercise, err := scanExercise(row)
+	return exercise, notFound(err)
+}
+
+func (p *Postgres) CreateExercise(exercise *models.Exercise) error {
+	err := p.DB.QueryRow(`
+		INSERT INTO exercises (name, description, category, muscle_group, equipment, difficulty, aliases, user_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8) RETURNING id
+	`, exercise.Name, exercise.Description, exercise.Category, exercise.MuscleGroup,
+		exercise.Equipment, exercise.Difficulty, pq.Array(exercise.Aliases), exercise.UserID,
+	).Scan(&exercise.ID)
+
+	// the unique indexes on the name, see migration 0009
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+		return ErrDuplicate
+	}
+	return err
+}
+
+func (p *Postgres) UpdateExercise(exercise *models.Exercise) error {
+	// IS NOT DISTINCT FROM is "=" that also matches NULL with NULL (library exercises)
+	result, err := p.DB.Exec(`
+		UPDATE exercises
+		SET name = $1, description = $2, category = $3, muscle_group = $4,
+		    equipment = $5, difficulty = $6, aliases = $7
+		WHERE id = $8 AND user_id IS NOT DISTINCT FROM $9
+	`, exercise.Name, exercise.Description, exercise.Category, exercise.MuscleGroup,
+		exercise.Equipment, exercise.Difficulty, pq.Array(exercise.Aliases), exercise.ID, exercise.UserID)
+
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+		return ErrDuplicate
+	}
+	if err != nil {
+		return err
+	}
+	return checkAffected(result)
+}
+
+func (p *Postgres) DeleteExercise(id, userID int) error {
+	tx, err := p.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var owned bool
+	err = tx.QueryRow(`
+		SELECT EXISTS(SELECT 1 FROM exercises WHERE id = $1 AND user_id IS NOT DISTINCT FROM $2)
+	`, id, owner(userID)).Scan(&owned)
+	if err != nil {
+		return err
+	}
+	if !owned {
+		return ErrNotFound
+	}
+
+	// the foreign keys are ON DELETE CASCADE, so check first instead of wiping workouts and history
+	var used bool
+	err = tx.QueryRow(`
+		SELECT EXISTS(SELECT 1 FROM workout_exercises WHERE exercise_id = $1)
+		    OR EXISTS(SELECT 1 FROM workout_logs WHERE exercise_id = $1)
+		    OR EXISTS(SELECT 1 FROM program_lifts WHERE exercise_id = $1)
+	`, id).Scan(&used)
+	if err != nil {
+		return err
+	}
+	if used {
+		return ErrInUse
+	}
+
+	if _, err := tx.Exec(`DELETE FROM exercises WHERE id = $1`, id); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// ---- Coaching ----
+
+func (p *Postgres) InviteAthlete(coachID, athleteID int) error {
+	_, err := p.DB.Exec(`
+		INSERT INTO coach_athletes (coach_id, athlete_id, status, created_at) VALUES ($1, $2, $3, $4)
+	`, coachID, athleteID, models.CoachingPending, time.Now())
+
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+		return ErrDuplicate
+	}
+	return err
+}
+
+func (p *Postgres) AcceptCoach(coachID, athleteID int) error {
+	result, err := p.DB.Exec(`
+		UPDATE coach_athletes SET status = $1, accepted_at = $2
+		WHERE coach_id = $3 AND athlete_id = $4 AND status = $5
+	`, models.CoachingActive, time.Now(), coachID, athleteID, models.CoachingPending)
+	if err != nil {
+		return err
+	}
+	return checkAffected(result)
+}
+
+func (p *Postgres) EndCoaching(coachID, athleteID int) error {
+	result, err := p.DB.Exec(`DELETE FROM coach_athletes WHERE coach_id = $1 AND athlete_id = $2`, coachID, athleteID)
+	if err != nil {
+		return err
+	}
+	return checkAffected(result)
+}
+
+// listCoaching returns the links where column (coach_id or athlete_id) is userID,
+// joined with the user on the other side
+func (p *Postgres) listCoaching(column string, userID int) ([]models.Coaching, error) {
+	other := "athlete_id"
+	if column == "athlete_id" {
+		other = "coach_id"
+	}
+	// column and other are our own constants, never user input
+	rows, err := p.DB.Query(`
+		SELECT ca.coach_id, ca.athlete_id, ca.status, ca.created_at, ca.accepted_at,
+		       u.id, u.username, u.email, u.email_verified, u.role, u.created_at
+		FROM coach_athletes ca
+		JOIN users u ON u.id = ca.`+other+`
+		WHERE ca.`+column+` = $1
+		ORDER BY ca.created_at
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := []models.Coaching{}
+	for rows.Next() {
+		var coaching models.Coaching
+		var user models.User
+		err := rows.Scan(
+			&coaching.CoachID, &coaching.AthleteID, &coaching.Status, &coaching.CreatedAt, &coaching.AcceptedAt,
+			&user.ID, &user.Username, &user.Email, &user.EmailVerified, &user.Role, &user.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		if column == "coach_id" {
+			coaching.Athlete = &user
+		} else {
+			coaching.Coach = &user
+		}
+		list = append(list, coaching)
+	}
+	return list, rows.Err()
+}
+
+func (p *Postgres) ListAthletes(coachID int) ([]models.Coaching, error) {
+	return p.listCoaching("coach_id", coachID)
+}
+
+func (p *Postgres) ListCoaches(athleteID int) ([]models.Coaching, error) {
+	return p.listCoaching("athlete_id", athleteID)
+}
+
+func (p *Postgres) IsCoachOf(coachID, athleteID int) (bool, error) {
+	var active bool
+	err := p.DB.QueryRow(`
+		SELECT EXISTS(SELECT 1 FROM coach_athletes WHERE coach_id = $1 AND athlete_id = $2 AND status = $3)
+	`, coachID, athleteID, models.CoachingActive).Scan(&active)
+	return active, err
+}
+
+// ---- Workouts ----
+
+func (p *Postgres) CreateWorkout(workout *models.Workout) error {
+	// Start a database transaction
+	// This ensures that either all changes succeed or none do
+	tx, err := p.DB.Begin()
+	if err != nil {
+		return err
+	}
+	// If anything goes wrong, rollback the transaction
+	defer tx.Rollback()
+
+	if err := insertWorkout(tx, workout); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// insertWorkout adds the workout with its exercises inside the caller's transaction
+// CreateProgram uses it too, for the workout of every session
+func insertWorkout(tx *sql.Tx, workout *models.Workout) error {
+	now := time.Now()
+	err := tx.QueryRow(
+		`INSERT INTO workouts (user_id, name, description, assigned_by, created_at, updated_at)
+		 VALUES ($1, $2, $3, $4, $5, $6) RETURNING id`,
+		workout.UserID, workout.Name, workout.Description, workout.AssignedBy, now, now,
+	).Scan(&workout.ID)
+	if err != nil {
+		return err
+	}
+	workout.CreatedAt, workout.UpdatedAt = now, now
+
+	return insertWorkoutExercises(tx, workout.ID, workout.Exercises)
+}
+
+// insertWorkoutExercises adds each exercise of a workout inside the caller's transaction
+func insertWorkoutExercises(tx *sql.Tx, workoutID int, exercises []models.WorkoutExercise) error {
+	for _, exercise := range exercises {
+		_, err := tx.Exec(
+			`INSERT INTO workout_exercises (workout_id, exercise_id, position, sets, reps, weight, notes,
+			                                group_name, group_type, rest_seconds)
+			 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`,
+			workoutID, exercise.ExerciseID, exercise.Position, exercise.Sets, exercise.Reps, exercise.Weight, exercise.Notes,
+			exercise.Group, exercise.GroupType, exercise.RestSeconds,
+		)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *Postgres) ListWorkouts(userID int) ([]models.Workout, error) {
+	rows, err := p.DB.Query(`
+		SELECT id, user_id, name, description, assigned_by, created_at, updated_at
+		FROM workouts
+		WHERE user_id = $1
+		ORDER BY created_at DESC
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	workouts := []models.Workout{}
+	for rows.Next() {
+		var workout models.Workout
+		err := rows.Scan(
+			&workout.ID,
+			&workout.UserID,
+			&workout.Name,
+			&workout.Description,
+			&workout.AssignedBy, // a nil pointer when NULL
+			&workout.CreatedAt,
+			&workout.UpdatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		workouts = append(workouts, workout)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Load exercises for each workout (after closing the rows above,
+	// so we don't hold two connections per request)
+	for i := range workouts {
+		exercises, err := p.workoutExercises(workouts[i].ID)
+		if err != nil {
+			return nil, err
+		}
+		workouts[i].Exercises = exercises
+	}
+	return workouts, nil
+}
+
+func (p *Postgres) GetWorkout(id, userID int) (*models.Workout, error) {
+	var workout models.Workout
+	err := p.DB.QueryRow(`
+		SELECT id, user_id, name, description, assigned_by, created_at, updated_at
+		FROM workouts
+		WHERE id = $1 AND user_id = $2
+	`, id, userID).Scan(
+		&workout.ID,
+		&workout.UserID,
+		&workout.Name,
+		&workout.Description,
+		&workout.AssignedBy,
+		&workout.CreatedAt,
+		&workout.UpdatedAt,
+	)
+	if err != nil {
+		return nil, notFound(err)
+	}
+
+	exercises, err := p.workoutExercises(id)
+	if err != nil {
+		return nil, err
+	}
+	workout.Exercises = exercises
+	return &workout, nil
+}
+
+// workoutExercises loads the exercises of a workout together with the exercise details
+func (p *Postgres) workoutExercises(workoutID int) ([]models.WorkoutExercise, error) {
+	rows, err := p.DB.Query(`
+		SELECT we.id, we.workout_id, we.exercise_id, we.position, we.sets, we.reps, we.weight, we.notes,
+		       we.group_name, we.group_type, we.rest_seconds,
+		       e.id, e.name, e.description, e.category, e.muscle_group, e.equipment, e.difficulty, e.aliases, e.user_id
+		FROM workout_exercises we
+		JOIN exercises e ON we.exercise_id = e.id
+		WHERE we.workout_id = $1
+		ORDER BY we.position, we.id
+	`, workoutID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	exercises := []models.WorkoutExercise{}
+	for rows.Next() {
+		var we models.WorkoutExercise
+		var exercise models.Exercise
+
+		err := rows.Scan(
+			&we.ID,
+			&we.WorkoutID,
+			&we.ExerciseID,
+			&we.Position,
+			&we.Sets,
+			&we.Reps,
+			&we.Weight,
+			&we.Notes,
+			&we.Group,
+			&we.GroupType,
+			&we.RestSeconds,
+			&exercise.ID,
+			&exercise.Name,
+			&exercise.Description,
+			&exercise.Category,
+			&exercise.MuscleGroup,
+			&exercise.Equipment,
+			&exercise.Difficulty,
+			pq.Array(&exercise.Aliases),
+			&exercise.UserID,
+		)
+		if err != nil {
+			return nil, err
+		}
+		if exercise.Aliases == nil {
+			exercise.Aliases = []string{}
+		}
+
+		we.Exercise = &exercise
+		exercises = append(exercises, we)
+	}
+	return exercises, rows.Err()
+}
+
+func (p *Postgres) UpdateWorkout(workout *models.Workout) error {
+	tx, err := p.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(
+		`UPDATE workouts SET name = $1, description = $2, updated_at = $3
+		 WHERE id = $4 AND user_id = $5`,
+		workout.Name, workout.Description, time.Now(), workout.ID, workout.UserID,
+	)
+	if err != nil {
+		return err
+	}
+	if err := checkAffected(result); err != nil {
+		return err
+	}
+
+	// Replace the exercises: delete the old ones and insert the new list
+	if _, err := tx.Exec(`DELETE FROM workout_exercises WHERE workout_id = $1`, workout.ID); err != nil {
+		return err
+	}
+	if err := insertWorkoutExercises(tx, workout.ID, workout.Exercises); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (p *Postgres) DeleteWorkout(id, userID int) error {
+	// Cascades to workout_exercises due to foreign key
+	result, err := p.DB.Exec(`DELETE FROM workouts WHERE id = $1 AND user_id = $2`, id, userID)
+	if err != nil {
+		return err
+	}
+	return checkAffected(result)
+}
+
+func (p *Postgres) WorkoutOwner(id int) (int, error) {
+	var userID int
+	err := p.DB.QueryRow(`SELECT user_id FROM workouts WHERE id = $1`, id).Scan(&userID)
+	return userID, notFound(err)
+}
+
+// ---- Schedules ----
+
+func (p *Postgres) CreateSchedule(schedule *models.Schedule) error {
+	return p.DB.QueryRow(
+		`INSERT INTO schedules (user_id, workout_id, scheduled_date, completed, notes, assigned_by)
+		 VALUES ($1, $2, $3, $4, $5, $6) RETURNING id`,
+		schedule.UserID, schedule.WorkoutID, schedule.ScheduledDate, false, schedule.Notes, schedule.AssignedBy,
+	).Scan(&schedule.ID)
+}
+
+// scheduleColumns is shared by ListSchedules and GetSchedule, scanned by scanSchedule
+const scheduleColumns = `
+	SELECT s.id, s.user_id, s.workout_id, s.scheduled_date, s.completed, s.completed_at, s.notes,
+	       s.series_id, s.series_date, s.detached, s.assigned_by,
+	       w.id, w.user_id, w.name, w.description, w.created_at, w.updated_at
+	FROM schedules s
+	JOIN workouts w ON s.workout_id = w.id
+`
+
+// scanner is the Scan method that both *sql.Row and *sql.Rows have
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func scanSchedule(row scanner) (*models.Schedule, error) {
+	var schedule models.Schedule
+	var workout models.Workout
+	var completedAt sql.NullTime
+	var seriesID sql.NullInt64
+	var seriesDate sql.NullTime
+
+	err := row.Scan(
+		&schedule.ID,
+		&schedule.UserID,
+		&schedule.WorkoutID,
+		&schedule.ScheduledDate,
+		&schedule.Completed,
+		&completedAt,
+		&schedule.Notes,
+		&seriesID,
+		&seriesDate,
+		&schedule.Detached,
+		&schedule.AssignedBy,
+		&workout.ID,
+		&workout.UserID,
+		&workout.Name,
+		&workout.Description,
+		&workout.CreatedAt,
+		&workout.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	// Handle nullable completed_at
+	if completedAt.Valid {
+		schedule.CompletedAt = &completedAt.Time
+	}
+	// One-off schedules have no series
+	if seriesID.Valid {
+		id := int(seriesID.Int64)
+		schedule.SeriesID = &id
+	}
+	if seriesDate.Valid {
+		schedule.SeriesDate = &seriesDate.Time
+	}
+	schedule.Workout = &workout
+	return &schedule, nil
+}
+
+func (p *Postgres) ListSchedules(userID int, filter ScheduleFilter) ([]models.Schedule, error) {
+	query := scheduleColumns + ` WHERE s.user_id = $1`
+	if filter.Upcoming {
+		query += ` AND s.scheduled_date >= NOW()`
+	}
+	if filter.Completed != nil {
+		if *filter.Completed {
+			query += ` AND s.completed = true`
+		} else {
+			query += ` AND s.completed = false`
+		}
+	}
+	query += ` ORDER BY s.scheduled_date ASC`
+
+	rows, err := p.DB.Query(query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	schedules := []models.Schedule{}
+	for rows.Next() {
+		schedule, err := scanSchedule(rows)
+		if err != nil {
+			return nil, err
+		}
+		schedules = append(schedules, *schedule)
+	}
+	return schedules, rows.Err()
+}
+
+func (p *Postgres) GetSchedule(id, userID int) (*models.Schedule, error) {
+	row := p.DB.QueryRow(scheduleColumns+` WHERE s.id = $1 AND s.user_id = $2`, id, userID)
+	schedule, err := scanSchedule(row)
+	return schedule, notFound(err)
+}
+
+func (p *Postgres) CompleteSchedule(id, userID int, notes string, logs []models.WorkoutLog) error {
+	tx, err := p.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Mark the schedule as completed, only once: a second request must not log the sets again
+	result, err := tx.Exec(
+		`UPDATE schedules SET completed = true, completed_at = $1, notes = $2
+		 WHERE id = $3 AND user_id = $4 AND completed = false`,
+		time.Now(), notes, id, userID,
+	)
+	if err != nil {
+		return err
+	}
+	if err := checkAffected(result); err != nil {
+		// Nothing changed: either there is no such schedule or it is done already
+		var exists bool
+		err := tx.QueryRow(`SELECT EXISTS (SELECT 1 FROM schedules WHERE id = $1 AND user_id = $2)`, id, userID).Scan(&exists)
+		if err != nil {
+			return err
+		}
+		if exists {
+			return ErrCompleted
+		}
+		return ErrNotFound
+	}
+
+	// If logs are provided, insert them together with their sets
+	for _, log := range logs {
+		var logID int
+		err = tx.QueryRow(
+			`INSERT INTO workout_logs (schedule_id, exercise_id, sets_completed, reps_completed, weight_used, duration, notes, logged_at)
+			 VALUES ($1, $2, $3, $4, $5, $6, $7, $8) RETURNING id`,
+			id, log.ExerciseID, log.SetsCompleted, log.RepsCompleted, log.WeightUsed, log.Duration, log.Notes, time.Now(),
+		).Scan(&logID)
+		if err != nil {
+			return err
+		}
+
+		for _, set := range log.Sets {
+			_, err = tx.Exec(
+				`INSERT INTO workout_sets (workout_log_id, set_number, reps, weight, rpe, rir, rest_seconds, is_warmup)
+				 VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`,
+				logID, set.SetNumber, set.Reps, set.Weight, set.RPE, set.RIR, set.RestSeconds, set.IsWarmup,
+			)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return tx.Commit()
+}
+
+func (p *Postgres) UpdateSchedule(schedule *models.Schedule) error {
+	// detached only becomes true for occurrences of a series
+	result, err := p.DB.Exec(
+		`UPDATE schedules SET workout_id = $1, scheduled_date = $2, notes = $3, detached = (series_id IS NOT NULL)
+		 WHERE id = $4 AND user_id = $5`,
+		schedule.WorkoutID, schedule.ScheduledDate, schedule.Notes, schedule.ID, schedule.UserID,
+	)
+	if err != nil {
+		return err
+	}
+	return checkAffected(result)
+}
+
+func (p *Postgres) DeleteSchedule(id, userID int) error {
+	tx, err := p.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Remember the date if it is an occurrence of a series (does nothing for one-off schedules)
+	_, err = tx.Exec(`
+		INSERT INTO schedule_series_exceptions (series_id, occurrence_date)
+		SELECT series_id, series_date FROM schedules
+		WHERE id = $1 AND user_id = $2 AND series_id IS NOT NULL AND series_date IS NOT NULL
+		ON CONFLICT DO NOTHING
+	`, id, userID)
+	if err != nil {
+		return err
+	}
+
+	result, err := tx.Exec(`DELETE FROM schedules WHERE id = $1 AND user_id = $2`, id, userID)
+	if err != nil {
+		return err
+	}
+	if err := checkAffected(result); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// ---- Schedule series ----
+
+// insertOccurrences adds one schedule per date for the series, inside the caller's transaction
+func insertOccurrences(tx *sql.Tx, series *models.ScheduleSeries, dates []time.Time) error {
+	for _, date := range dates {
+		_, err := tx.Exec(
+			`INSERT INTO schedules (user_id, workout_id, scheduled_date, completed, notes, series_id, series_date)
+			 VALUES ($1, $2, $3, false, $4, $5, $3)`,
+			series.UserID, series.WorkoutID, date, series.Notes, series.ID,
+		)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *Postgres) CreateSeries(series *models.ScheduleSeries, dates []time.Time) error {
+	tx, err := p.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRow(
+		`INSERT INTO schedule_series (user_id, workout_id, weekdays, time_of_day, start_date, weeks, notes)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id, created_at`,
+		series.UserID, series.WorkoutID, strings.Join(series.Weekdays, ","), series.Time, series.StartDate, series.Weeks, series.Notes,
+	).Scan(&series.ID, &series.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	if err := insertOccurrences(tx, series, dates); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// seriesColumns is shared by ListSeries and GetSeries, scanned by scanSeries
+const seriesColumns = `
+	SELECT id, user_id, workout_id, weekdays, time_of_day, start_date, weeks, notes, created_at
+	FROM schedule_series
+`
+
+func scanSeries(row scanner) (*models.ScheduleSeries, error) {
+	var series models.ScheduleSeries
+	var weekdays string
+	err := row.Scan(
+		&series.ID,
+		&series.UserID,
+		&series.WorkoutID,
+		&weekdays,
+		&series.Time,
+		&series.StartDate,
+		&series.Weeks,
+		&series.Notes,
+		&series.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	// "mon,wed,fri" -> ["mon", "wed", "fri"]
+	series.Weekdays = strings.Split(weekdays, ",")
+	series.Exceptions = []time.Time{}
+	return &series, nil
+}
+
+func (p *Postgres) ListSeries(userID int) ([]models.ScheduleSeries, error) {
+	rows, err := p.DB.Query(seriesColumns+` WHERE user_id = $1 ORDER BY created_at DESC, id DESC`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := []models.ScheduleSeries{}
+	for rows.Next() {
+		series, err := scanSeries(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, *series)
+	}
+	return list, rows.Err()
+}
+
+func (p *Postgres) GetSeries(id, userID int) (*models.ScheduleSeries, error) {
+	series, err := scanSeries(p.DB.QueryRow(seriesColumns+` WHERE id = $1 AND user_id = $2`, id, userID))
+	if err != nil {
+		return nil, notFound(err)
+	}
+
+	// Dates that were deleted one by one
+	rows, err := p.DB.Query(`
+		SELECT occurrence_date FROM schedule_series_exceptions
+		WHERE series_id = $1
+		ORDER BY occurrence_date
+	`, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var date time.Time
+		if err := rows.Scan(&date); err != nil {
+			return nil, err
+		}
+		series.Exceptions = append(series.Exceptions, date)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// The occurrences are normal schedules
+	occurrences, err := p.DB.Query(scheduleColumns+` WHERE s.series_id = $1 AND s.user_id = $2 ORDER BY s.scheduled_date ASC`, id, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer occurrences.Close()
+	series.Occurrences = []models.Schedule{}
+	for occurrences.Next() {
+		schedule, err := scanSchedule(occurrences)
+		if err != nil {
+			return nil, err
+		}
+		series.Occurrences = append(series.Occurrences, *schedule)
+	}
+	return series, occurrences.Err()
+}
+
+func (p *Postgres) UpdateSeries(series *models.ScheduleSeries, from time.Time, dates []time.Time) error {
+	tx, err := p.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(
+		`UPDATE schedule_series SET workout_id = $1, weekdays = $2, time_of_day = $3, start_date = $4, weeks = $5, notes = $6
+		 WHERE id = $7 AND user_id = $8`,
+		series.WorkoutID, strings.Join(series.Weekdays, ","), series.Time, series.StartDate, series.Weeks, series.Notes,
+		series.ID, series.UserID,
+	)
+	if err != nil {
+		return err
+	}
+	if err := checkAffected(result); err != nil {
+		return err
+	}
+
+	// Completed, detached and past occurrences stay, the rest is replaced
+	_, err = tx.Exec(`
+		DELETE FROM schedules
+		WHERE series_id = $1 AND completed = false AND detached = false AND scheduled_date >= $2
+	`, series.ID, from)
+	if err != nil {
+		return err
+	}
+	if err := insertOccurrences(tx, series, dates); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (p *Postgres) DeleteSeries(id, userID int) error {
+	tx, err := p.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`DELETE FROM schedules WHERE series_id = $1 AND user_id = $2 AND completed = false`, id, userID)
+	if err != nil {
+		return err
+	}
+	// The foreign key sets series_id of the completed occurrences to NULL
+	result, err := tx.Exec(`DELETE FROM schedule_series WHERE id = $1 AND user_id = $2`, id, userID)
+	if err != nil {
+		return err
+	}
+	if err := checkAffected(result); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// ---- Calendar feed ----
+
+func (p *Postgres) SetCalendarToken(userID int, tokenHash string) error {
+	// ON CONFLICT turns the INSERT into an UPDATE when the user already has a feed
+	_, err := p.DB.Exec(`
+		INSERT INTO calendar_feeds (user_id, token_hash, created_at)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (user_id) DO UPDATE SET token_hash = EXCLUDED.token_hash, created_at = EXCLUDED.created_at
+	`, userID, tokenHash, time.Now())
+	return err
+}
+
+func (p *Postgres) DeleteCalendarToken(userID int) error {
+	result, err := p.DB.Exec(`DELETE FROM calendar_feeds WHERE user_id = $1`, userID)
+	if err != nil {
+		return err
+	}
+	return checkAffected(result)
+}
+
+func (p *Postgres) CalendarUser(tokenHash string) (int, error) {
+	var userID int
+	err := p.DB.QueryRow(`SELECT user_id FROM calendar_feeds WHERE token_hash = $1`, tokenHash).Scan(&userID)
+	return userID, notFound(err)
+}
+
+// ---- Sessions and refresh tokens ----
+
+func (p *Postgres) CreateSession(session *models.Session, token *models.RefreshToken) error {
+	tx, err := p.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRow(`
+		INSERT INTO auth_sessions (user_id, created_at)
+		VALUES ($1, $2)
+		RETURNING id, created_at
+	`, session.UserID, time.Now()).Scan(&session.ID, &session.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	token.SessionID = session.ID
+	if err := insertRefreshToken(tx, token); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// insertRefreshToken saves a refresh token as part of a bigger transaction
+func insertRefreshToken(tx *sql.Tx, token *models.RefreshToken) error {
+	return tx.QueryRow(`
+		INSERT INTO refresh_tokens (token_hash, session_id, expires_at, created_at)
+		VALUES ($1, $2, $3, $4)
+		RETURNING created_at
+	`, token.TokenHash, token.SessionID, token.ExpiresAt, time.Now()).Scan(&token.CreatedAt)
+}
+
+func (p *Postgres) GetRefreshToken(tokenHash string) (*models.RefreshToken, error) {
+	var token models.RefreshToken
+	err := p.DB.QueryRow(`
+		SELECT rt.token_hash, rt.session_id, s.user_id, rt.expires_at, rt.used_at, rt.created_at
+		FROM refresh_tokens rt
+		JOIN auth_sessions s ON rt.session_id = s.id
+		WHERE rt.token_hash = $1
+	`, tokenHash).Scan(&token.TokenHash, &token.SessionID, &token.UserID, &token.ExpiresAt, &token.UsedAt, &token.CreatedAt)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	return &token, nil
+}
+
+func (p *Postgres) RotateRefreshToken(oldHash string, next *models.RefreshToken) error {
+	tx, err := p.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// "AND used_at IS NULL" makes this safe when two requests refresh with the same token
+	// at the same time: only one of them changes the row
+	result, err := tx.Exec(`
+		UPDATE refresh_tokens SET used_at = $1 WHERE token_hash = $2 AND used_at IS NULL
+	`, time.Now(), oldHash)
+	if err != nil {
+		return err
+	}
+	if err := checkAffected(result); err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return ErrTokenUsed
+		}
+		return err
+	}
+
+	if err := insertRefreshToken(tx, next); err != nil {
+		return err
+	}
+
+	// Expired tokens can't be reused anymore, we don't need them for reuse detection
+	_, err = tx.Exec(`DELETE FROM refresh_tokens WHERE session_id = $1 AND expires_at < $2`, next.SessionID, time.Now())
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (p *Postgres) SessionActive(sessionID int) (bool, error) {
+	var active bool
+	err := p.DB.QueryRow(`
+		SELECT EXISTS(SELECT 1 FROM auth_sessions WHERE id = $1 AND revoked_at IS NULL)
+	`, sessionID).Scan(&active)
+	return active, err
+}
+
+func (p *Postgres) RevokeSession(sessionID int) error {
+	// COALESCE keeps the first revoke time
+	_, err := p.DB.Exec(`
+		UPDATE auth_sessions SET revoked_at = COALESCE(revoked_at, $1) WHERE id = $2
+	`, time.Now(), sessionID)
+	return err
+}
+
+func (p *Postgres) RevokeUserSessions(userID int) error {
+	_, err := p.DB.Exec(`
+		UPDATE auth_sessions SET revoked_at = $1 WHERE user_id = $2 AND revoked_at IS NULL
+	`, time.Now(), userID)
+	return err
+}
+
+// ---- Progress ----
+
+func (p *Postgres) GetProgress(userID int) (*models.ProgressReport, error) {
+	report := models.ProgressReport{UserID: userID}
+
+	// Get total completed workouts
+	err := p.DB.QueryRow(`
+		SELECT COUNT(*) FROM schedules WHERE user_id = $1 AND completed = true
+	`, userID).Scan(&report.TotalWorkouts)
+	if err != nil {
+		return nil, err
+	}
+
+	// Get total exercises performed (from workout logs)
+	err = p.DB.QueryRow(`
+		SELECT COUNT(*) FROM workout_logs wl
+		JOIN schedules s ON wl.schedule_id = s.id
+		WHERE s.user_id = $1
+	`, userID).Scan(&report.TotalExercises)
+	if err != nil {
+		return nil, err
+	}
+
+	// Get workouts completed in the last 7 and the last 30 days
+	countSince := `
+		SELECT COUNT(*) FROM schedules
+		WHERE user_id = $1 AND completed = true AND completed_at >= $2
+	`
+	if err := p.DB.QueryRow(countSince, userID, time.Now().AddDate(0, 0, -7)).Scan(&report.WorkoutsThisWeek); err != nil {
+		return nil, err
+	}
+	if err := p.DB.QueryRow(countSince, userID, time.Now().AddDate(0, 0, -30)).Scan(&report.WorkoutsThisMonth); err != nil {
+		return nil, err
+	}
+
+	// Get the most frequently performed exercise
+	var exerciseName sql.NullString
+	err = p.DB.QueryRow(`
+		SELECT e.name
+		FROM workout_logs wl
+		JOIN exercises e ON wl.exercise_id = e.id
+		JOIN schedules s ON wl.schedule_id = s.id
+		WHERE s.user_id = $1
+		GROUP BY e.name
+		ORDER BY COUNT(*) DESC, e.name
+		LIMIT 1
+	`, userID).Scan(&exerciseName)
+	// If no exercises logged yet, this is okay
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+
+	// Get the user's start date (when they first completed a workout)
+	var startDate sql.NullTime
+	err = p.DB.QueryRow(`
+		SELECT MIN(completed_at) FROM schedules
+		WHERE user_id = $1 AND completed = true
+	`, userID).Scan(&startDate)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+
+	var start *time.Time
+	if startDate.Valid {
+		start = &startDate.Time
+	}
+	finishReport(&report, start, exerciseName.String)
+	return &report, nil
+}
+
+func (p *Postgres) GetExerciseHistory(userID, exerciseID, limit int) ([]models.ExerciseHistoryEntry, error) {
+	rows, err := p.DB.Query(`
+		SELECT wl.id, wl.schedule_id, wl.sets_completed, wl.reps_completed, wl.weight_used, wl.duration, wl.notes, wl.logged_at,
+		       e.name, e.category
+		FROM workout_logs wl
+		JOIN exercises e ON wl.exercise_id = e.id
+		JOIN schedules s ON wl.schedule_id = s.id
+		WHERE s.user_id = $1 AND wl.exercise_id = $2
+		ORDER BY wl.logged_at DESC
+		LIMIT $3
+	`, userID, exerciseID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	history := []models.ExerciseHistoryEntry{}
+	logIDs := []int{}
+	for rows.Next() {
+		var entry models.ExerciseHistoryEntry
+		var logID int
+		err := rows.Scan(
+			&logID,
+			&entry.ScheduleID,
+			&entry.SetsCompleted,
+			&entry.RepsCompleted,
+			&entry.WeightUsed,
+			&entry.Duration,
+			&entry.Notes,
+			&entry.LoggedAt,
+			&entry.ExerciseName,
+			&entry.Category,
+		)
+		if err != nil {
+			return nil, err
+		}
+		history = append(history, entry)
+		logIDs = append(logIDs, logID)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Attach the sets of every log
+	sets, err := p.setsForLogs(logIDs)
+	if err != nil {
+		return nil, err
+	}
+	for i := range history {
+		history[i].Sets = sets[logIDs[i]]
+		if history[i].Sets == nil {
+			history[i].Sets = []models.WorkoutSet{}
+		}
+	}
+	return history, nil
+}
+
+// setsForLogs loads the sets of many logs with one query, grouped by log ID
+func (p *Postgres) setsForLogs(logIDs []int) (map[int][]models.WorkoutSet, error) {
+	sets := map[int][]models.WorkoutSet{}
+	if len(logIDs) == 0 {
+		return sets, nil
+	}
+
+	// pq.Array sends the Go slice as a Postgres array, so "= ANY($1)" matches any ID in it
+	rows, err := p.DB.Query(`
+		SELECT id, workout_log_id, set_number, reps, weight, rpe, rir, rest_seconds, is_warmup
+		FROM workout_sets
+		WHERE workout_log_id = ANY($1)
+		ORDER BY workout_log_id, set_number
+	`, pq.Array(logIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var set models.WorkoutSet
+		var rpe sql.NullFloat64
+		var rir sql.NullInt64
+		err := rows.Scan(&set.ID, &set.WorkoutLogID, &set.SetNumber, &set.Reps, &set.Weight,
+			&rpe, &rir, &set.RestSeconds, &set.IsWarmup)
+		if err != nil {
+			return nil, err
+		}
+		setOptional(&set, rpe, rir)
+		sets[set.WorkoutLogID] = append(sets[set.WorkoutLogID], set)
+	}
+	return sets, rows.Err()
+}
+
+// setOptional copies RPE and RIR into the set
+// NULL means the value wasn't logged, so the pointer stays nil
+func setOptional(set *models.WorkoutSet, rpe sql.NullFloat64, rir sql.NullInt64) {
+	if rpe.Valid {
+		value := rpe.Float64
+		set.RPE = &value
+	}
+	if rir.Valid {
+		value := int(rir.Int64)
+		set.RIR = &value
+	}
+}
+
+func (p *Postgres) ListLoggedSets(userID int, filter SetFilter) ([]models.LoggedSet, error) {
+	query := `
+		SELECT ws.id, ws.workout_log_id, ws.set_number, ws.reps, ws.weight, ws.rpe, ws.rir, ws.rest_seconds, ws.is_warmup,
+		       wl.schedule_id, wl.exercise_id, wl.logged_at, e.name, e.category, e.muscle_group
+		FROM workout_sets ws
+		JOIN workout_logs wl ON ws.workout_log_id = wl.id
+		JOIN schedules s ON wl.schedule_id = s.id
+		JOIN exercises e ON wl.exercise_id = e.id
+		WHERE s.user_id = $1
+	`
+	// Add the filters that are set, each one gets the next $ number
+	args := []interface{}{userID}
+	if filter.ExerciseID != 0 {
+		args = append(args, filter.ExerciseID)
+		query += fmt.Sprintf(` AND wl.exercise_id = $%d`, len(args))
+	}
+	if !filter.From.IsZero() {
+		args = append(args, filter.From)
+		query += fmt.Sprintf(` AND wl.logged_at >= $%d`, len(args))
+	}
+	if !filter.To.IsZero() {
+		args = append(args, filter.To)
+		query += fmt.Sprintf(` AND wl.logged_at <= $%d`, len(args))
+	}
+	query += ` ORDER BY wl.logged_at, wl.id, ws.set_number`
+
+	rows, err := p.DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sets := []models.LoggedSet{}
+	for rows.Next() {
+		var set models.LoggedSet
+		var rpe sql.NullFloat64
+		var rir sql.NullInt64
+		err := rows.Scan(&set.ID, &set.WorkoutLogID, &set.SetNumber, &set.Reps, &set.Weight,
+			&rpe, &rir, &set.RestSeconds, &set.IsWarmup,
+			&set.ScheduleID, &set.ExerciseID, &set.LoggedAt, &set.ExerciseName, &set.Category, &set.MuscleGroup)
+		if err != nil {
+			return nil, err
+		}
+		setOptional(&set.WorkoutSet, rpe, rir)
+		sets = append(sets, set)
+	}
+	return sets, rows.Err()
+}
+
+// ---- Programs ----
+
+func (p *Postgres) CreateProgram(program *models.Program) error {
+	// The template is saved as JSON text, it is only ever read back as a whole
+	template, err := json.Marshal(program.Template)
+	if err != nil {
+		return err
+	}
+
+	tx, err := p.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRow(
+		`INSERT INTO programs (user_id, name, template, start_date, rounding, status)
+		 VALUES ($1, $2, $3, $4, $5, $6) RETURNING id, created_at`,
+		program.UserID, program.Name, string(template), program.StartDate, program.Rounding, program.Status,
+	).Scan(&program.ID, &program.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	for i, lift := range program.Lifts {
+		_, err = tx.Exec(
+			`INSERT INTO program_lifts (program_id, exercise_id, position, training_max, failures)
+			 VALUES ($1, $2, $3, $4, $5)`,
+			program.ID, lift.ExerciseID, i+1, lift.TrainingMax, lift.Failures,
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Every session is a normal workout with a schedule, linked by a program_sessions row
+	for i := range program.Sessions {
+		session := &program.Sessions[i]
+		if err := insertWorkout(tx, session.Workout); err != nil {
+			return err
+		}
+		err = tx.QueryRow(
+			`INSERT INTO schedules (user_id, workout_id, scheduled_date, completed, notes)
+			 VALUES ($1, $2, $3, false, '') RETURNING id`,
+			program.UserID, session.Workout.ID, session.ScheduledDate,
+		).Scan(&session.ScheduleID)
+		if err != nil {
+			return err
+		}
+
+		session.ProgramID = program.ID
+		session.WorkoutID = session.Workout.ID
+		err = tx.QueryRow(
+			`INSERT INTO program_sessions (program_id, workout_id, schedule_id, week, day, deload, status, note)
+			 VALUES ($1, $2, $3, $4, $5, $6, $7, $8) RETURNING id`,
+			program.ID, session.WorkoutID, session.ScheduleID, session.Week, session.Day, session.Deload, session.Status, session.Note,
+		).Scan(&session.ID)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (p *Postgres) ListPrograms(userID int) ([]models.Program, error) {
+	rows, err := p.DB.Query(`SELECT id FROM programs WHERE user_id = $1 ORDER BY created_at DESC, id DESC`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := []int{}
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Load each program after closing the rows above
+	programs := []models.Program{}
+	for _, id := range ids {
+		program, err := p.GetProgram(id, userID)
+		if err != nil {
+			return nil, err
+		}
+		programs = append(programs, *program)
+	}
+	return programs, nil
+}
+
+func (p *Postgres) GetProgram(id, userID int) (*models.Program, error) {
+	var program models.Program
+	var template string
+	err := p.DB.QueryRow(`
+		SELECT id, user_id, name, template, start_date, rounding, status, created_at
+		FROM programs
+		WHERE id = $1 AND user_id = $2
+	`, id, userID).Scan(
+		&program.ID,
+		&program.UserID,
+		&program.Name,
+		&template,
+		&program.StartDate,
+		&program.Rounding,
+		&program.Status,
+		&program.CreatedAt,
+	)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	if err := json.Unmarshal([]byte(template), &program.Template); err != nil {
+		return nil, err
+	}
+
+	lifts, err := p.programLifts(id)
+	if err != nil {
+		return nil, err
+	}
+	program.Lifts = lifts
+
+	sessions, err := p.programSessions(id)
+	if err != nil {
+		return nil, err
+	}
+	program.Sessions = sessions
+	return &program, nil
+}
+
+func (p *Postgres) programLifts(programID int) ([]models.ProgramLift, error) {
+	rows, err := p.DB.Query(`
+		SELECT exercise_id, training_max, failures
+		FROM program_lifts
+		WHERE program_id = $1
+		ORDER BY position
+	`, programID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	lifts := []models.ProgramLift{}
+	for rows.Next() {
+		var lift models.ProgramLift
+		if err := rows.Scan(&lift.ExerciseID, &lift.TrainingMax, &lift.Failures); err != nil {
+			return nil, err
+		}
+		lifts = append(lifts, lift)
+	}
+	return lifts, rows.Err()
+}
+
+// programSessions loads the sessions with their schedule date and workout, earliest first
+func (p *Postgres) programSessions(programID int) ([]models.ProgramSession, error) {
+	rows, err := p.DB.Query(`
+		SELECT ps.id, ps.program_id, ps.workout_id, ps.schedule_id, ps.week, ps.day, ps.deload, ps.status, ps.note,
+		       s.scheduled_date, w.user_id, w.name, w.description, w.created_at, w.updated_at
+		FROM program_sessions ps
+		JOIN schedules s ON ps.schedule_id = s.id
+		JOIN workouts w ON ps.workout_id = w.id
+		WHERE ps.program_id = $1
+		ORDER BY s.scheduled_date, ps.week, ps.day
+	`, programID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sessions := []models.ProgramSession{}
+	for rows.Next() {
+		var session models.ProgramSession
+		var workout models.Workout
+		err := rows.Scan(
+			&session.ID,
+			&session.ProgramID,
+			&session.WorkoutID,
+			&session.ScheduleID,
+			&session.Week,
+			&session.Day,
+			&session.Deload,
+			&session.Status,
+			&session.Note,
+			&session.ScheduledDate,
+			&workout.UserID,
+			&workout.Name,
+			&workout.Description,
+			&workout.CreatedAt,
+			&workout.UpdatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		workout.ID = session.WorkoutID
+		session.Workout = &workout
+		sessions = append(sessions, session)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for i := range sessions {
+		exercises, err := p.workoutExercises(sessions[i].WorkoutID)
+		if err != nil {
+			return nil, err
+		}
+		sessions[i].Workout.Exercises = exercises
+	}
+	return sessions, nil
+}
+
+func (p *Postgres) UpdateProgram(program *models.Program) error {
+	tx, err := p.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(
+		`UPDATE programs SET status = $1 WHERE id = $2 AND user_id = $3`,
+		program.Status, program.ID, program.UserID,
+	)
+	if err != nil {
+		return err
+	}
+	if err := checkAffected(result); err != nil {
+		return err
+	}
+
+	for _, lift := range program.Lifts {
+		_, err = tx.Exec(
+			`UPDATE program_lifts SET training_max = $1, failures = $2 WHERE program_id = $3 AND exercise_id = $4`,
+			lift.TrainingMax, lift.Failures, program.ID, lift.ExerciseID,
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, session := range program.Sessions {
+		_, err = tx.Exec(
+			`UPDATE program_sessions SET status = $1, note = $2 WHERE id = $3 AND program_id = $4`,
+			session.Status, session.Note, session.ID, program.ID,
+		)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec(
+			`UPDATE schedules SET scheduled_date = $1 WHERE id = $2 AND user_id = $3`,
+			session.ScheduledDate, session.ScheduleID, program.UserID,
+		)
+		if err != nil {
+			return err
+		}
+
+		// Only planned sessions get new targets, done ones keep what was planned for them
+		if session.Status != models.SessionPlanned || session.Workout == nil {
+			continue
+		}
+		if _, err := tx.Exec(`DELETE FROM workout_exercises WHERE workout_id = $1`, session.WorkoutID); err != nil {
+			return err
+		}
+		if err := insertWorkoutExercises(tx, session.WorkoutID, session.Workout.Exercises); err != nil {
+			return err
+		}
+		_, err = tx.Exec(`UPDATE workouts SET updated_at = $1 WHERE id = $2`, time.Now(), session.WorkoutID)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (p *Postgres) DeleteProgram(id, userID int) error {
+	tx, err := p.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// The workouts of sessions that were never done go away (with their schedules),
+	// this has to happen first because deleting the program deletes program_sessions
+	_, err = tx.Exec(`
+		DELETE FROM workouts
+		WHERE user_id = $2 AND id IN (
+			SELECT workout_id FROM program_sessions
+			WHERE program_id = $1 AND status IN ('planned', 'skipped')
+		)
+	`, id, userID)
+	if err != nil {
+		return err
+	}
+
+	result, err := tx.Exec(`DELETE FROM programs WHERE id = $1 AND user_id = $2`, id, userID)
+	if err != nil {
+		return err
+	}
+	if err := checkAffected(result); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
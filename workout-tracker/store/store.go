@@ -0,0 +1,249 @@
+package store
+
+import (
+	"errors"
+	"time"
+	"workout-tracker/models"
+)
+
+// The store package hides where our data lives.
+// Handlers only talk to these interfaces, so the same handler code can run against:
+//   - Postgres (postgres.go) - what the real server uses
+//   - Memory (memory.go)     - plain Go maps, handy for tests with httptest and no database
+//
+// Why interfaces?
+// answer: An interface only lists the methods something must have.
+// Any type with those methods can be used, so we can swap Postgres for a fake without touching the handlers.
+
+// ErrNotFound is returned when a row doesn't exist (or belongs to another user)
+var ErrNotFound = errors.New("not found")
+
+// ErrDuplicate is returned when a unique value (like a username) is already taken
+var ErrDuplicate = errors.New("already exists")
+
+// UserStore manages user accounts
+type UserStore interface {
+	// CreateUser saves a new user and fills in its ID and CreatedAt
+	// Returns ErrDuplicate if the username or email is taken
+	CreateUser(user *models.User) error
+	// GetUserByUsername also loads the PasswordHash, for logging in
+	GetUserByUsername(username string) (*models.User, error)
+	GetUserByID(id int) (*models.User, error)
+	// GetUserByEmail finds a user by email, upper or lower case doesn't matter
+	GetUserByEmail(email string) (*models.User, error)
+	SetEmailVerified(userID int) error
+	SetPassword(userID int, passwordHash string) error
+	// ListUsers returns every user, oldest first (for admins)
+	ListUsers() ([]models.User, error)
+	SetRole(userID int, role string) error
+}
+
+// UserTokenStore keeps the single-use tokens we send by email
+type UserTokenStore interface {
+	CreateUserToken(token *models.UserToken) error
+	// UseUserToken marks the token used and returns it, together with the other open tokens
+	// of the same user and purpose (an older reset mail stops working too)
+	// Returns ErrNotFound if the token is unknown, has another purpose, was used or expired
+	UseUserToken(tokenHash, purpose string) (*models.UserToken, error)
+}
+
+// ErrCompleted is returned when a schedule that is already done is completed again
+var ErrCompleted = errors.New("already completed")
+
+// ErrInUse is returned when something can't be deleted because other rows still use it
+var ErrInUse = errors.New("still in use")
+
+// ExerciseFilter narrows down ListExercises, empty fields don't filter
+type ExerciseFilter struct {
+	UserID      int    // also return this user's custom exercises (0: only the library)
+	OnlyCustom  bool   // only the user's custom exercises, not the library
+	Category    string // the filters below ignore upper/lower case
+	MuscleGroup string
+	Equipment   string
+	Difficulty  string
+	Query       string // full text search in name and description, and part of the name or an alias
+}
+
+// ExerciseStore gives access to the exercise library and the users' custom exercises
+// userID 0 means the library, the exercises without an owner
+type ExerciseStore interface {
+	// ListExercises returns the exercises sorted by category and name
+	ListExercises(filter ExerciseFilter) ([]models.Exercise, error)
+	// GetExercise returns a library exercise or one of the user's own, ErrNotFound for anything else
+	GetExercise(id, userID int) (*models.Exercise, error)
+	// CreateExercise adds to the library when exercise.UserID is nil, otherwise a custom exercise.
+	// ErrDuplicate when the library or the user already has an exercise with that name.
+	CreateExercise(exercise *models.Exercise) error
+	// UpdateExercise only changes the exercise if it has the same owner (exercise.UserID), otherwise ErrNotFound
+	UpdateExercise(exercise *models.Exercise) error
+	// DeleteExercise deletes an exercise of the owner, ErrInUse if a workout, a log or a program uses it
+	// (the foreign keys would otherwise delete them too)
+	DeleteExercise(id, userID int) error
+}
+
+// CoachStore manages the links between coaches and athletes
+type CoachStore interface {
+	// InviteAthlete adds a pending link, ErrDuplicate if there already is one
+	InviteAthlete(coachID, athleteID int) error
+	// AcceptCoach makes a pending link active, ErrNotFound if there is no pending link
+	AcceptCoach(coachID, athleteID int) error
+	// EndCoaching deletes the link (pending or active), ErrNotFound if there is none
+	EndCoaching(coachID, athleteID int) error
+	// ListAthletes returns the coach's links with the athlete filled in
+	ListAthletes(coachID int) ([]models.Coaching, error)
+	// ListCoaches returns the athlete's links with the coach filled in
+	ListCoaches(athleteID int) ([]models.Coaching, error)
+	// IsCoachOf tells whether there is an active link
+	IsCoachOf(coachID, athleteID int) (bool, error)
+}
+
+// WorkoutStore manages workout plans and their exercises
+// Every method takes the user ID, so a user can never see or change someone else's workout
+type WorkoutStore interface {
+	// CreateWorkout saves the workout with its exercises and fills in the ID and timestamps
+	CreateWorkout(workout *models.Workout) error
+	// ListWorkouts returns the user's workouts, newest first
+	ListWorkouts(userID int) ([]models.Workout, error)
+	GetWorkout(id, userID int) (*models.Workout, error)
+	// UpdateWorkout replaces the name, description and exercises of workout.ID
+	UpdateWorkout(workout *models.Workout) error
+	DeleteWorkout(id, userID int) error
+	// WorkoutOwner returns the ID of the user who owns the workout
+	WorkoutOwner(id int) (int, error)
+}
+
+// ScheduleFilter narrows down ListSchedules
+type ScheduleFilter struct {
+	Upcoming  bool  // only workouts scheduled from now on
+	Completed *bool // nil means both completed and open schedules
+}
+
+// ScheduleStore manages the calendar of planned workouts
+type ScheduleStore interface {
+	// CreateSchedule saves the schedule and fills in its ID
+	CreateSchedule(schedule *models.Schedule) error
+	// ListSchedules returns the user's schedules with their workout, oldest date first
+	ListSchedules(userID int, filter ScheduleFilter) ([]models.Schedule, error)
+	GetSchedule(id, userID int) (*models.Schedule, error)
+	// CompleteSchedule marks the schedule as done and saves the logs, all or nothing
+	// Returns ErrCompleted if the schedule was already done, its logs stay as they were
+	CompleteSchedule(id, userID int, notes string, logs []models.WorkoutLog) error
+	// UpdateSchedule changes the workout, date and notes of one schedule
+	// An occurrence of a series becomes detached, so editing the whole series leaves it alone
+	UpdateSchedule(schedule *models.Schedule) error
+	// DeleteSchedule deletes one schedule
+	// For an occurrence of a series its date is saved as an exception, so editing the series doesn't bring it back
+	DeleteSchedule(id, userID int) error
+
+	// CreateSeries saves the series and an occurrence on every date, all or nothing
+	CreateSeries(series *models.ScheduleSeries, dates []time.Time) error
+	// ListSeries returns the user's series without their occurrences, newest first
+	ListSeries(userID int) ([]models.ScheduleSeries, error)
+	// GetSeries returns the series with its exceptions and occurrences (oldest first)
+	GetSeries(id, userID int) (*models.ScheduleSeries, error)
+	// UpdateSeries saves the new rule, workout and notes of series.ID, and replaces the occurrences
+	// from "from" on that are neither completed nor detached with new ones on the dates
+	UpdateSeries(series *models.ScheduleSeries, from time.Time, dates []time.Time) error
+	// DeleteSeries deletes the series and its open occurrences, completed ones stay as one-off schedules
+	DeleteSeries(id, userID int) error
+}
+
+// SetFilter narrows down ListLoggedSets, the zero value means everything
+type SetFilter struct {
+	ExerciseID int       // 0 means all exercises
+	From       time.Time // zero means from the beginning
+	To         time.Time // zero means up to now
+}
+
+// ProgressStore answers the statistics questions
+type ProgressStore interface {
+	GetProgress(userID int) (*models.ProgressReport, error)
+	// GetExerciseHistory returns the latest logs of one exercise, newest first
+	GetExerciseHistory(userID, exerciseID, limit int) ([]models.ExerciseHistoryEntry, error)
+	// ListLoggedSets returns every logged set of the user, oldest first
+	// The analytics package computes 1RM, records and volume from them
+	ListLoggedSets(userID int, filter SetFilter) ([]models.LoggedSet, error)
+}
+
+// ProgramStore manages programs made from templates (see the programs package)
+type ProgramStore interface {
+	// CreateProgram saves the program and, for every session, its workout and schedule - all or nothing
+	CreateProgram(program *models.Program) error
+	// ListPrograms returns the user's programs with their lifts and sessions, newest first
+	ListPrograms(userID int) ([]models.Program, error)
+	GetProgram(id, userID int) (*models.Program, error)
+	// UpdateProgram saves the status and lifts of the program, and for every session
+	// its status, note, schedule date and (while it is planned) the exercises of its workout
+	UpdateProgram(program *models.Program) error
+	// DeleteProgram removes the program and the workouts of the sessions that weren't done,
+	// done sessions stay in the history
+	DeleteProgram(id, userID int) error
+}
+
+// ErrTokenUsed is returned when a refresh token is swapped for a new one a second time
+var ErrTokenUsed = errors.New("token already used")
+
+// SessionStore keeps login sessions and their refresh tokens
+type SessionStore interface {
+	// CreateSession saves a new session with its first refresh token, and fills in their IDs
+	CreateSession(session *models.Session, token *models.RefreshToken) error
+	// GetRefreshToken finds a token by its hash, also when it was already used
+	GetRefreshToken(tokenHash string) (*models.RefreshToken, error)
+	// RotateRefreshToken marks the old token used and saves next, in one transaction
+	// Returns ErrTokenUsed if the old token was used in the meantime
+	RotateRefreshToken(oldHash string, next *models.RefreshToken) error
+	// SessionActive tells whether the session exists and wasn't revoked
+	SessionActive(sessionID int) (bool, error)
+	// RevokeSession logs the session out, revoking a revoked session is fine
+	RevokeSession(sessionID int) error
+	// RevokeUserSessions logs the user out everywhere, e.g. after a password reset
+	RevokeUserSessions(userID int) error
+}
+
+// CalendarStore keeps the secret tokens of the .ics feeds
+// Only a hash of the token is stored, see handlers_calendar.go
+type CalendarStore interface {
+	// SetCalendarToken saves the token hash of the user's feed, replacing the old one
+	SetCalendarToken(userID int, tokenHash string) error
+	// DeleteCalendarToken turns the user's feed off, ErrNotFound if there was none
+	DeleteCalendarToken(userID int) error
+	// CalendarUser returns the ID of the user whose feed token has this hash
+	CalendarUser(tokenHash string) (int, error)
+}
+
+// Store is everything the API needs, both Postgres and Memory implement it
+type Store interface {
+	UserStore
+	UserTokenStore
+	ExerciseStore
+	CoachStore
+	WorkoutStore
+	ScheduleStore
+	ProgressStore
+	ProgramStore
+	CalendarStore
+	SessionStore
+}
+
+// finishReport fills in the fields that are calculated from the counts
+// It is shared by both stores so they give exactly the same answers
+func finishReport(report *models.ProgressReport, startDate *time.Time, mostFrequent string) {
+	if mostFrequent == "" {
+		mostFrequent = "None yet"
+	}
+	report.MostFrequentExercise = mostFrequent
+
+	if startDate == nil {
+		// If no workouts completed yet, use current time as start date
+		report.StartDate = time.Now()
+		report.AverageWorkoutsPerWeek = 0
+		return
+	}
+
+	report.StartDate = *startDate
+	// Calculate average workouts per week
+	weeksSinceStart := time.Since(report.StartDate).Hours() / 24 / 7
+	if weeksSinceStart > 0 {
+		report.AverageWorkoutsPerWeek = float64(report.TotalWorkouts) / weeksSinceStart
+	}
+}